@@ -0,0 +1,261 @@
+package fileprocessor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ArchiveSeparator joins an archive's path and the name of one of its
+// members in Result.Path, e.g. "backup.tar.gz::etc/passwd".
+const ArchiveSeparator = "::"
+
+// errMemberRead is returned when a handler opens an archive member a
+// second time; members are streamed and can only be read once.
+var errMemberRead = errors.New("archive member can only be read once")
+
+// archiveKinds maps file name suffixes to the formats WithArchives opens.
+var archiveKinds = []struct {
+	suffix string
+	kind   string
+}{
+	{".tar.gz", "tgz"},
+	{".tgz", "tgz"},
+	{".tar", "tar"},
+	{".zip", "zip"},
+}
+
+// archiveKind returns the archive format of name, or "" for other files.
+func archiveKind(name string) string {
+	lower := strings.ToLower(name)
+	for _, k := range archiveKinds {
+		if strings.HasSuffix(lower, k.suffix) {
+			return k.kind
+		}
+	}
+	return ""
+}
+
+type archivesKey struct{}
+
+// archives streams the members of zip and tar files to the handler as
+// virtual files. The walker side expands an archive into one job per
+// regular member and feeds each member through a pipe; Open and Stat
+// resolve the virtual path to the pipe while the job runs.
+type archives struct {
+	mu      sync.Mutex
+	members map[string]*member
+}
+
+// member is an archive entry waiting to be, or being, read by a handler.
+// A member with a non-nil err stands for an archive that could not be
+// expanded; opening it reports the error so the archive counts as failed.
+type member struct {
+	info   fs.FileInfo
+	r      *io.PipeReader
+	err    error
+	opened bool
+	done   chan struct{}
+}
+
+func newArchives() *archives {
+	return &archives{members: make(map[string]*member)}
+}
+
+// expand submits every regular member of the archive at name. Members
+// are read in order and each blocks until its job has consumed or
+// released it, so memory use does not grow with the archive.
+func (a *archives) expand(ctx context.Context, name, kind string, submit func(string) error) error {
+	err := a.walk(ctx, name, kind, func(hdr fs.FileInfo, entry string, r io.Reader) error {
+		pr, pw := io.Pipe()
+		vpath := name + ArchiveSeparator + entry
+		m := &member{info: hdr, r: pr, done: make(chan struct{})}
+		if err := a.put(ctx, vpath, m); err != nil {
+			return err
+		}
+		if err := submit(vpath); err != nil {
+			a.release(vpath)
+			return err
+		}
+		stop := context.AfterFunc(ctx, func() { pr.CloseWithError(ctx.Err()) })
+		defer stop()
+		_, err := io.Copy(pw, r)
+		if errors.Is(err, io.ErrClosedPipe) {
+			// The handler did not need the rest of the member.
+			err = nil
+		}
+		pw.CloseWithError(err)
+		return err
+	})
+	if err == nil || ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Report the broken archive as a failed file of its own.
+	m := &member{err: fmt.Errorf("archive %s: %w", name, err), done: make(chan struct{})}
+	if err := a.put(ctx, name, m); err != nil {
+		return err
+	}
+	if err := submit(name); err != nil {
+		a.release(name)
+		return err
+	}
+	return nil
+}
+
+// walk calls fn for each regular member of the archive at name with its
+// cleaned slash-separated name and a reader positioned at its content.
+func (a *archives) walk(ctx context.Context, name, kind string, fn func(fs.FileInfo, string, io.Reader) error) error {
+	f, err := Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if kind == "zip" {
+		ra, ok := f.(io.ReaderAt)
+		if !ok {
+			return errors.New("zip archives need random access")
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(ra, info.Size())
+		if err != nil {
+			return err
+		}
+		for _, zf := range zr.File {
+			if !zf.Mode().IsRegular() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(zf.FileInfo(), memberName(zf.Name), rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = f
+	if kind == "tgz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr.FileInfo(), memberName(hdr.Name), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// memberName cleans an archive entry name for use in a virtual path.
+func memberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// put registers m under vpath. Archives may hold the same name twice; the
+// later entry waits for the earlier one's job to finish.
+func (a *archives) put(ctx context.Context, vpath string, m *member) error {
+	for {
+		a.mu.Lock()
+		prev, busy := a.members[vpath]
+		if !busy {
+			a.members[vpath] = m
+			a.mu.Unlock()
+			return nil
+		}
+		a.mu.Unlock()
+		select {
+		case <-prev.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release forgets vpath once its job has finished, unblocking the walker
+// if the handler left part of the member unread.
+func (a *archives) release(vpath string) {
+	a.mu.Lock()
+	m, ok := a.members[vpath]
+	delete(a.members, vpath)
+	a.mu.Unlock()
+	if ok {
+		if m.r != nil {
+			m.r.CloseWithError(io.ErrClosedPipe)
+		}
+		close(m.done)
+	}
+}
+
+// stat is the Stat counterpart of open.
+func (a *archives) stat(vpath string) (info fs.FileInfo, ok bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.members[vpath]
+	switch {
+	case !ok:
+		return nil, false, nil
+	case m.err != nil:
+		return nil, true, m.err
+	}
+	return m.info, true, nil
+}
+
+// open returns the member at vpath as an fs.File. ok is false for paths
+// that are not archive members.
+func (a *archives) open(vpath string) (f fs.File, ok bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.members[vpath]
+	switch {
+	case !ok:
+		return nil, false, nil
+	case m.err != nil:
+		return nil, true, m.err
+	case m.opened:
+		return nil, true, &fs.PathError{Op: "open", Path: vpath, Err: errMemberRead}
+	}
+	m.opened = true
+	return memberFile{m}, true, nil
+}
+
+// memberFile is the fs.File handed to handlers for an archive member.
+type memberFile struct{ m *member }
+
+func (f memberFile) Stat() (fs.FileInfo, error) { return f.m.info, nil }
+func (f memberFile) Read(b []byte) (int, error) { return f.m.r.Read(b) }
+func (f memberFile) Close() error               { return f.m.r.Close() }
+
+func archivesFromContext(ctx context.Context) *archives {
+	a, _ := ctx.Value(archivesKey{}).(*archives)
+	return a
+}
@@ -0,0 +1,80 @@
+package fileprocessor
+
+import (
+	"encoding/hex"
+	"hash"
+
+	"fileprocessor/internal/cdc"
+)
+
+// Chunk is one content-defined chunk of a file.
+type Chunk struct {
+	Offset int64
+	Length int64
+	// Hash is the hex digest of the chunk's bytes with the file's hash
+	// algorithm.
+	Hash string
+}
+
+// ChangedChunks returns the chunks of cur whose contents appear nowhere in
+// prev, in file order: the regions that changed between two runs. Because
+// boundaries follow the content, an insertion shifts later chunks without
+// changing their hashes, so only the edited region is reported.
+func ChangedChunks(prev, cur []Chunk) []Chunk {
+	seen := make(map[string]bool, len(prev))
+	for _, c := range prev {
+		seen[c.Hash] = true
+	}
+	var changed []Chunk
+	for _, c := range cur {
+		if !seen[c.Hash] {
+			changed = append(changed, c)
+		}
+	}
+	return changed
+}
+
+// chunkWriter hashes every content-defined chunk of a stream.
+type chunkWriter struct {
+	chunker *cdc.Chunker
+	newHash func() hash.Hash
+	cur     hash.Hash
+	offset  int64
+	n       int64
+	chunks  []Chunk
+}
+
+func newChunkWriter(avg int, newHash func() hash.Hash) *chunkWriter {
+	return &chunkWriter{chunker: cdc.New(avg), newHash: newHash}
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if w.cur == nil {
+			w.cur = w.newHash()
+		}
+		n, cut := w.chunker.Next(p)
+		w.cur.Write(p[:n])
+		w.n += int64(n)
+		p = p[n:]
+		if cut {
+			w.flush()
+		}
+	}
+	return written, nil
+}
+
+func (w *chunkWriter) flush() {
+	w.chunks = append(w.chunks, Chunk{Offset: w.offset, Length: w.n, Hash: hex.EncodeToString(w.cur.Sum(nil))})
+	w.offset += w.n
+	w.cur, w.n = nil, 0
+}
+
+// list ends the last chunk and returns them all.
+func (w *chunkWriter) list() []Chunk {
+	if w.cur != nil {
+		w.flush()
+	}
+	return w.chunks
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"fileprocessor"
+)
+
+// annotateTimeout bounds the request posting the annotation.
+const annotateTimeout = 10 * time.Second
+
+// annotation is the body posted to -annotate. It is what Grafana's
+// annotations API takes, a region spanning the run; the figures are in
+// data besides the text for webhooks that go by them.
+type annotation struct {
+	Time    int64          `json:"time"`    // Unix milliseconds
+	TimeEnd int64          `json:"timeEnd"` // Unix milliseconds
+	Tags    []string       `json:"tags"`
+	Text    string         `json:"text"`
+	Data    annotationData `json:"data"`
+}
+
+type annotationData struct {
+	Run             string  `json:"run,omitempty"`
+	Outcome         string  `json:"outcome"`
+	Processed       int64   `json:"processed"`
+	Failed          int64   `json:"failed"`
+	Skipped         int64   `json:"skipped"`
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Truncated       bool    `json:"truncated,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// annotator posts an annotation of the run to url when it ends, with the
+// bearer token in $GRAFANA_TOKEN if set.
+type annotator struct {
+	url  string
+	run  string
+	tags []string
+	err  error
+}
+
+// attach posts the annotation once p's run is over, with the outcome
+// "interrupted" if ctx is done by then.
+func (a *annotator) attach(ctx context.Context, p *fileprocessor.Processor) {
+	p.OnComplete(func(e fileprocessor.CompleteEvent) {
+		s := e.Summary
+		d := annotationData{Run: a.run, Outcome: "ok", Processed: s.Processed, Failed: s.Failed,
+			Skipped: s.Skipped, Bytes: s.Bytes, DurationSeconds: s.Duration.Seconds(), Truncated: s.Truncated}
+		switch {
+		case ctx.Err() != nil:
+			d.Outcome = "interrupted"
+		case e.Err != nil:
+			d.Outcome, d.Error = "error", e.Err.Error()
+		case s.Failed > 0:
+			d.Outcome = "failed"
+		}
+		text := fmt.Sprintf("fileprocessor run %s: %d processed, %d failed, %d bytes in %s",
+			d.Outcome, s.Processed, s.Failed, s.Bytes, s.Duration.Round(time.Millisecond))
+		a.err = a.post(annotation{
+			Time:    e.Time.Add(-s.Duration).UnixMilli(),
+			TimeEnd: e.Time.UnixMilli(),
+			Tags:    append([]string{"fileprocessor", d.Outcome}, a.tags...),
+			Text:    text,
+			Data:    d,
+		})
+	})
+}
+
+func (a *annotator) post(body annotation) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), annotateTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("GRAFANA_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", a.url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// splitTags splits the comma-separated -annotate-tags, dropping empty ones.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"fileprocessor"
+	"fileprocessor/manifest"
+)
+
+// loadBaseline reads the path -> hash map of a previous run for -baseline.
+// The format is detected from the content: the JSON lines written by
+// -report=json or -format=jsonl, a CSV file with "path" and "hash" header columns, or a
+// sha256sum-style manifest.
+func loadBaseline(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes map[string]string
+	trimmed := bytes.TrimSpace(data)
+	firstLine, _, _ := strings.Cut(string(trimmed), "\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		hashes, err = parseJSONBaseline(data)
+	case isCSVHeader(firstLine):
+		hashes, err = parseCSVBaseline(data)
+	default:
+		var entries []manifest.Entry
+		entries, err = manifest.Parse(bytes.NewReader(data))
+		hashes = make(map[string]string, len(entries))
+		for _, e := range entries {
+			hashes[e.Path] = e.Hash
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return hashes, nil
+}
+
+func parseJSONBaseline(data []byte) (map[string]string, error) {
+	hashes := make(map[string]string)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj struct {
+			Type  string `json:"type"`
+			Path  string `json:"path"`
+			Hash  string `json:"hash"`
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&obj); errors.Is(err, io.EOF) {
+			return hashes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if (obj.Type == "file" || obj.Type == "") && obj.Error == "" && obj.Hash != "" {
+			hashes[obj.Path] = obj.Hash
+		}
+	}
+}
+
+func isCSVHeader(line string) bool {
+	cols, err := csv.NewReader(strings.NewReader(line)).Read()
+	return err == nil && slices.Contains(cols, "path") && slices.Contains(cols, "hash")
+}
+
+func parseCSVBaseline(data []byte) (map[string]string, error) {
+	r := csv.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	pathCol, hashCol := slices.Index(header, "path"), slices.Index(header, "hash")
+
+	hashes := make(map[string]string)
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return hashes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if max(pathCol, hashCol) >= len(rec) || rec[hashCol] == "" {
+			continue
+		}
+		hashes[rec[pathCol]] = rec[hashCol]
+	}
+}
+
+// loadPrior reads the file results of a previous -report=json or
+// -format=jsonl run for -skip-unchanged. Hard links folded into a result
+// get a record of their own.
+func loadPrior(path string) ([]fileprocessor.Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, fmt.Errorf("%s: not the output of -report json or -format jsonl", path)
+	}
+	var prior []fileprocessor.Result
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj struct {
+			Type      string    `json:"type"`
+			Path      string    `json:"path"`
+			Size      int64     `json:"size"`
+			ModTime   time.Time `json:"mtime"`
+			Hash      string    `json:"hash"`
+			Algorithm string    `json:"algorithm"`
+			Links     []string  `json:"links"`
+			Error     string    `json:"error"`
+		}
+		if err := dec.Decode(&obj); errors.Is(err, io.EOF) {
+			return prior, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if obj.Type != "file" && obj.Type != "" || obj.Error != "" || obj.ModTime.IsZero() {
+			continue
+		}
+		for _, p := range append([]string{obj.Path}, obj.Links...) {
+			prior = append(prior, fileprocessor.Result{Path: p, Size: obj.Size, ModTime: obj.ModTime, Hash: obj.Hash, Algorithm: obj.Algorithm})
+		}
+	}
+}
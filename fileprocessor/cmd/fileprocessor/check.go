@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"fileprocessor"
+	"fileprocessor/manifest"
+)
+
+// runCheck re-hashes every file listed in the manifest at path and prints
+// a coreutils-style status line per entry, in manifest order. Plain lines
+// are checked with handler, BSD-style lines with the algorithm of their
+// tag and the other options of opts, so one manifest may mix both and
+// several algorithms. It returns
+// the process exit status: exitOK if every file matched, exitMismatch if
+// any did not, and exitFailed if the others matched but some could not be
+// read. On a terminal, and unless noColor, OK is green, mismatches are
+// yellow and unreadable files red.
+func runCheck(ctx context.Context, path string, workers int, handler fileprocessor.FileHandler, opts hashOptions, middleware []fileprocessor.Middleware, noColor bool) int {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitFatal
+	}
+	entries, err := manifest.Parse(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+		return exitFatal
+	}
+
+	// The entries are checked in a run per algorithm, the tag's or, for
+	// plain lines, handler's.
+	type key struct{ algorithm, path string }
+	handlers := map[string]fileprocessor.FileHandler{"": handler}
+	var algorithms []string
+	paths := make(map[string][]string)
+	seen := make(map[key]bool, len(entries))
+	for _, e := range entries {
+		alg := strings.ToLower(e.Algorithm)
+		if _, ok := handlers[alg]; !ok {
+			h, err := tagHandler(e.Algorithm, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s:%d: %v\n", path, e.Line, err)
+				return exitFatal
+			}
+			handlers[alg] = h
+		}
+		if _, ok := paths[alg]; !ok {
+			algorithms = append(algorithms, alg)
+		}
+		if k := (key{alg, e.Path}); !seen[k] {
+			seen[k] = true
+			paths[alg] = append(paths[alg], e.Path)
+		}
+	}
+
+	var mu sync.Mutex
+	results := make(map[key]fileprocessor.Result, len(entries))
+	for _, alg := range algorithms {
+		record := func(res fileprocessor.Result) {
+			mu.Lock()
+			results[key{alg, res.Path}] = res
+			mu.Unlock()
+		}
+		walker := fileprocessor.WalkerFunc(func(ctx context.Context, visit func(string, fs.FileInfo) error) error {
+			for _, p := range paths[alg] {
+				if err := visit(p, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		p := fileprocessor.New(
+			fileprocessor.WithWalker(walker),
+			fileprocessor.WithWorkers(workers),
+			fileprocessor.WithHandler(handlers[alg]),
+			fileprocessor.WithMiddleware(middleware...),
+		)
+		p.OnFile(func(e fileprocessor.FileEvent) { record(e.Result) })
+		p.OnError(func(e fileprocessor.ErrorEvent) { record(fileprocessor.Result{Path: e.Path, Err: e.Err}) })
+
+		if _, err := p.Run(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitFatal
+		}
+		if ctx.Err() != nil {
+			return exitInterrupted
+		}
+	}
+
+	out, errs := colorOn(os.Stdout, noColor), colorOn(os.Stderr, noColor)
+	var mismatched, unreadable int
+	for _, e := range entries {
+		res, ok := results[key{strings.ToLower(e.Algorithm), e.Path}]
+		switch {
+		case !ok:
+			// Interrupted before this file was reached.
+			return exitInterrupted
+		case res.Err != nil:
+			fmt.Fprintln(os.Stderr, "Error:", res.Err)
+			fmt.Printf("%s: %s\n", e.Path, paint(out, ansiRed, "FAILED open or read"))
+			unreadable++
+		case res.Hash != e.Hash:
+			fmt.Printf("%s: %s\n", e.Path, paint(out, ansiYellow, "FAILED"))
+			mismatched++
+		default:
+			fmt.Printf("%s: %s\n", e.Path, paint(out, ansiGreen, "OK"))
+		}
+	}
+
+	if unreadable > 0 {
+		fmt.Fprintln(os.Stderr, paint(errs, ansiRed, fmt.Sprintf("WARNING: %d listed %s could not be read", unreadable, plural(unreadable, "file", "files"))))
+	}
+	if mismatched > 0 {
+		fmt.Fprintln(os.Stderr, paint(errs, ansiYellow, fmt.Sprintf("WARNING: %d computed %s did NOT match", mismatched, plural(mismatched, "checksum", "checksums"))))
+	}
+	switch {
+	case mismatched > 0:
+		return exitMismatch
+	case unreadable > 0:
+		return exitFailed
+	}
+	return exitOK
+}
+
+// tagHandler returns the handler for the lines tagged tag, as -tag writes
+// them: the upper-case name of one of the algorithms, prefixed with HMAC-
+// for keyed digests, which need the -hmac-key-file or -hmac-key-env key.
+func tagHandler(tag string, opts hashOptions) (fileprocessor.FileHandler, error) {
+	alg := strings.ToLower(tag)
+	keyed := strings.HasPrefix(alg, "hmac-")
+	alg = strings.TrimPrefix(alg, "hmac-")
+	if !slices.Contains(fileprocessor.HashAlgorithms(), alg) {
+		return nil, fmt.Errorf("unsupported algorithm %s; -check knows %s", tag, strings.Join(fileprocessor.HashAlgorithms(), ", "))
+	}
+	switch {
+	case keyed && opts.hmacKey == nil:
+		return nil, fmt.Errorf("%s lines need the key, from -hmac-key-file or -hmac-key-env", tag)
+	case !keyed:
+		opts.hmacKey = nil
+	}
+	return newHashHandler(alg, opts)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// colorOn reports whether output to w should be colored: only on a
+// terminal, and neither with -no-color nor with NO_COLOR set, as
+// https://no-color.org asks, nor on a terminal that says it is dumb.
+func colorOn(w io.Writer, disabled bool) bool {
+	return !disabled && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(w)
+}
+
+// colorer is what the terminal reporters have in common.
+type colorer interface {
+	SetColor(on bool)
+}
+
+const (
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiReset  = "\x1b[0m"
+)
+
+// paint wraps s in an ANSI color if on.
+func paint(on bool, color, s string) string {
+	if !on {
+		return s
+	}
+	return color + s + ansiReset
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+)
+
+const diffUsage = `Usage: fileprocessor diff [-json] [-no-color] OLD NEW
+
+Compare the results of two runs, each the output of -report json, -format
+jsonl or csv, or a sha256sum-style manifest, and list the files added,
+removed, modified and renamed (same hash, new path) between them. Exits 0
+if nothing changed, 2 if something did.
+`
+
+// runDiff implements the diff subcommand and returns the exit status.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Write the changes as one JSON object")
+	noColor := fs.Bool("no-color", false, "Don't color the output, even on a terminal (so does setting NO_COLOR)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), diffUsage, "\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitFatal
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitFatal
+	}
+
+	old, err := loadBaseline(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitFatal
+	}
+	cur, err := loadBaseline(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitFatal
+	}
+
+	c := diffRuns(old, cur)
+	if *asJSON {
+		err = json.NewEncoder(os.Stdout).Encode(c)
+	} else {
+		err = c.print(os.Stdout, colorOn(os.Stdout, *noColor))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitFatal
+	}
+	if c.changed() {
+		return exitMismatch
+	}
+	return exitOK
+}
+
+// changes lists how the files of one run differ from an earlier one.
+type changes struct {
+	Unchanged int        `json:"unchanged"`
+	Added     []string   `json:"added"`
+	Removed   []string   `json:"removed"`
+	Modified  []string   `json:"modified"`
+	Renamed   []renaming `json:"renamed"`
+}
+
+type renaming struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (c changes) changed() bool {
+	return len(c.Added)+len(c.Removed)+len(c.Modified)+len(c.Renamed) > 0
+}
+
+// diffRuns compares two path -> hash maps. A path only in old whose hash
+// turns up under a path only in cur is a rename; several such paths with
+// one hash are paired in sorted order, and the rest are added or removed.
+func diffRuns(old, cur map[string]string) changes {
+	c := changes{Added: []string{}, Removed: []string{}, Modified: []string{}, Renamed: []renaming{}}
+	removed := make(map[string][]string) // hash -> paths only in old
+	for _, path := range slices.Sorted(maps.Keys(old)) {
+		hash, ok := cur[path]
+		switch {
+		case !ok:
+			removed[old[path]] = append(removed[old[path]], path)
+		case hash != old[path]:
+			c.Modified = append(c.Modified, path)
+		default:
+			c.Unchanged++
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(cur)) {
+		if _, ok := old[path]; ok {
+			continue
+		}
+		hash := cur[path]
+		if from := removed[hash]; len(from) > 0 {
+			c.Renamed = append(c.Renamed, renaming{From: from[0], To: path})
+			removed[hash] = from[1:]
+			continue
+		}
+		c.Added = append(c.Added, path)
+	}
+	for _, paths := range removed {
+		c.Removed = append(c.Removed, paths...)
+	}
+	slices.Sort(c.Removed)
+	return c
+}
+
+// print writes a section per kind of change and a totals line.
+func (c changes) print(w io.Writer, color bool) error {
+	section := func(title, ansi string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintln(w, paint(color, ansi, fmt.Sprintf("%s (%d):", title, len(lines))))
+		for _, line := range lines {
+			fmt.Fprintln(w, " ", line)
+		}
+	}
+	section("Added", ansiGreen, c.Added)
+	section("Removed", ansiRed, c.Removed)
+	section("Modified", ansiYellow, c.Modified)
+	var renamed []string
+	for _, r := range c.Renamed {
+		renamed = append(renamed, r.From+" -> "+r.To)
+	}
+	section("Renamed", ansiYellow, renamed)
+	_, err := fmt.Fprintf(w, "%d unchanged, %d added, %d removed, %d modified, %d renamed\n",
+		c.Unchanged, len(c.Added), len(c.Removed), len(c.Modified), len(c.Renamed))
+	return err
+}
@@ -0,0 +1,13 @@
+package main
+
+import "strings"
+
+// dirList is a flag.Value for a repeatable -dir.
+type dirList []string
+
+func (d *dirList) String() string { return strings.Join(*d, ",") }
+
+func (d *dirList) Set(v string) error {
+	*d = append(*d, v)
+	return nil
+}
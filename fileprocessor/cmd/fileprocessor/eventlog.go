@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"fileprocessor"
+)
+
+// event is one line of the -event-log. Every line has the time, the run
+// and the kind of event; the other fields depend on the kind.
+type event struct {
+	Time  time.Time `json:"time"`
+	Run   string    `json:"run"`
+	Event string    `json:"event"`
+
+	// run_start
+	Version string            `json:"version,omitempty"`
+	Dirs    []string          `json:"dirs,omitempty"`
+	Config  map[string]string `json:"config,omitempty"`
+
+	// file
+	Path       string   `json:"path,omitempty"`
+	Outcome    string   `json:"outcome,omitempty"` // also run_stop
+	Worker     *int     `json:"worker,omitempty"`
+	Size       *int64   `json:"size,omitempty"`
+	Hash       string   `json:"hash,omitempty"`
+	DurationMS *float64 `json:"duration_ms,omitempty"` // also run_stop
+	Class      string   `json:"class,omitempty"`
+	Error      string   `json:"error,omitempty"` // also run_stop
+
+	// scale
+	Action    string `json:"action,omitempty"`
+	From      *int   `json:"from,omitempty"`
+	To        *int   `json:"to,omitempty"`
+	Queue     *int   `json:"queue,omitempty"`
+	QueueSize *int   `json:"queue_size,omitempty"`
+
+	// run_stop
+	Processed *int64 `json:"processed,omitempty"`
+	Failed    *int64 `json:"failed,omitempty"`
+	Skipped   *int64 `json:"skipped,omitempty"`
+	Bytes     *int64 `json:"bytes,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// eventLog appends the events of a run to the -event-log file, a line of
+// JSON each, written as they happen so that the trail is complete up to
+// a crash. The processor's hooks are called one at a time, so the lines
+// never interleave.
+type eventLog struct {
+	f   *os.File
+	run string
+	err error
+}
+
+// openEventLog opens name for appending; earlier runs are kept.
+func openEventLog(name, run string) (*eventLog, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLog{f: f, run: run}, nil
+}
+
+// attach records p's run: its start with every flag's value, the outcome
+// of every file, the autoscaler's decisions and the end, whose outcome is
+// "interrupted" once ctx is done.
+func (l *eventLog) attach(ctx context.Context, p *fileprocessor.Processor) {
+	p.OnStart(func(e fileprocessor.StartEvent) {
+		config := map[string]string{}
+		flag.VisitAll(func(f *flag.Flag) { config[f.Name] = f.Value.String() })
+		l.write(event{Time: e.Time, Event: "run_start", Version: fileprocessor.Version,
+			Dirs: e.Dirs, Config: config})
+	})
+	p.OnFile(func(e fileprocessor.FileEvent) {
+		l.write(event{Time: e.Time, Event: "file", Path: e.Result.Path, Outcome: "processed",
+			Worker: &e.Worker, Size: &e.Result.Size, Hash: e.Result.Hash,
+			DurationMS: milliseconds(e.Result.Duration)})
+	})
+	p.OnError(func(e fileprocessor.ErrorEvent) {
+		l.write(event{Time: e.Time, Event: "file", Path: e.Path, Outcome: "failed",
+			Worker: &e.Worker, Class: fileprocessor.ErrorClass(e.Err), Error: e.Err.Error()})
+	})
+	p.OnScale(func(e fileprocessor.ScaleEvent) {
+		l.write(event{Time: e.Time, Event: "scale", Action: e.Action, From: &e.From, To: &e.To,
+			Queue: &e.Queue, QueueSize: &e.QueueSize})
+	})
+	p.OnComplete(func(e fileprocessor.CompleteEvent) {
+		s := e.Summary
+		stop := event{Time: e.Time, Event: "run_stop", Outcome: "ok",
+			DurationMS: milliseconds(s.Duration), Processed: &s.Processed, Failed: &s.Failed,
+			Skipped: &s.Skipped, Bytes: &s.Bytes, Truncated: s.Truncated}
+		switch {
+		case ctx.Err() != nil:
+			stop.Outcome = "interrupted"
+		case e.Err != nil:
+			stop.Outcome, stop.Error = "error", e.Err.Error()
+		case s.Failed > 0:
+			stop.Outcome = "failed"
+		}
+		l.write(stop)
+	})
+}
+
+func (l *eventLog) write(e event) {
+	if l.err != nil {
+		return
+	}
+	e.Run = l.run
+	b, err := json.Marshal(e)
+	if err != nil {
+		l.err = err
+		return
+	}
+	if _, err := l.f.Write(append(b, '\n')); err != nil {
+		l.err = fmt.Errorf("%s: %w", l.f.Name(), err)
+	}
+}
+
+// Close closes the file and returns the first error writing to it.
+func (l *eventLog) Close() error {
+	err := l.f.Close()
+	if l.err != nil {
+		return l.err
+	}
+	return err
+}
+
+func milliseconds(d time.Duration) *float64 {
+	ms := float64(d) / float64(time.Millisecond)
+	return &ms
+}
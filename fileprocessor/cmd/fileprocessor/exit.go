@@ -0,0 +1,17 @@
+package main
+
+// Exit statuses, so that cron jobs and pipelines can tell a clean run from
+// one that needs looking at. When several apply, the highest wins, except
+// that an interrupted run always exits with exitInterrupted.
+const (
+	exitOK       = 0 // every file was processed
+	exitFailed   = 1 // some files failed, or -fingerprint had nothing to print
+	exitMismatch = 2 // -check or -baseline found files that differ
+	// exitFatal is for bad flags and for errors that stop the run as a
+	// whole: a root that can't be walked, a manifest that can't be read,
+	// an -output that can't be written.
+	exitFatal = 3
+	// exitInterrupted is what shells report for a command killed by
+	// SIGINT, 128 plus its number; SIGTERM exits the same way.
+	exitInterrupted = 130
+)
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hashRule maps a file name pattern to a hash algorithm.
+type hashRule struct {
+	pattern, algorithm string
+}
+
+// hashMap is a flag.Value collecting PATTERN=ALGORITHM rules. The flag may
+// be repeated and each value may hold several comma-separated rules; the
+// first matching rule wins.
+type hashMap []hashRule
+
+func (m *hashMap) String() string {
+	rules := make([]string, len(*m))
+	for i, r := range *m {
+		rules[i] = r.pattern + "=" + r.algorithm
+	}
+	return strings.Join(rules, ",")
+}
+
+func (m *hashMap) Set(v string) error {
+	for _, rule := range strings.Split(v, ",") {
+		pattern, algorithm, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok || pattern == "" || algorithm == "" {
+			return fmt.Errorf("invalid rule %q, want PATTERN=ALGORITHM", rule)
+		}
+		*m = append(*m, hashRule{pattern, algorithm})
+	}
+	return nil
+}
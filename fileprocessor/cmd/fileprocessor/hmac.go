@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// loadHMACKey reads the HMAC key from the file at path or, if path is
+// empty, from the environment variable env. It returns nil if neither is
+// configured.
+//
+// A key file must be a regular file that only its owner can access, the
+// same rule ssh applies to private keys; otherwise anyone who can read it
+// can forge the manifest it protects.
+func loadHMACKey(path, env string) ([]byte, error) {
+	var key []byte
+	switch {
+	case path != "":
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("HMAC key file %s is not a regular file", path)
+		}
+		// Windows doesn't map ACLs onto permission bits.
+		if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm&0o077 != 0 {
+			return nil, fmt.Errorf("HMAC key file %s is accessible by group or others (mode %04o); run chmod 600 on it", path, perm)
+		}
+		key, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	case env != "":
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return nil, fmt.Errorf("HMAC key variable %s is not set", env)
+		}
+		key = []byte(v)
+	default:
+		return nil, nil
+	}
+
+	// Keys written with echo or an editor end in a newline that isn't
+	// meant to be part of them.
+	key = bytes.TrimRight(key, "\r\n")
+	if len(key) == 0 {
+		return nil, errors.New("HMAC key is empty")
+	}
+	return key, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logOptions carries the flags that shape the structured log.
+type logOptions struct {
+	format                string // -log-format
+	file                  string // -log-file
+	quiet, verbose, debug bool
+	// screen is set when stderr can't take log records, under -tui.
+	screen bool
+}
+
+// newSlog returns the logger of -log-format and -log-file, or nil when
+// neither is given and log messages stay plain lines. A -log-file gets
+// every file's record whatever the verbosity, while on stderr the level
+// follows it: failures only by default, every file with -verbose, and the
+// autoscaler's decisions with -debug.
+func newSlog(opts logOptions, diag io.Writer) (*slog.Logger, func() error, error) {
+	noClose := func() error { return nil }
+	if opts.format == "" && opts.file == "" {
+		return nil, noClose, nil
+	}
+	var w io.Writer = diag
+	closeLog := noClose
+	level := slog.LevelWarn
+	switch {
+	case opts.file != "":
+		f, err := os.OpenFile(opts.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, noClose, fmt.Errorf("-log-file: %w", err)
+		}
+		w, closeLog, level = f, f.Close, slog.LevelInfo
+	case opts.screen:
+		return nil, noClose, nil
+	case opts.quiet:
+		level = slog.LevelError
+	case opts.verbose:
+		level = slog.LevelInfo
+	}
+	if opts.debug {
+		level = slog.LevelDebug
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch opts.format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), closeLog, nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), closeLog, nil
+	}
+	closeLog()
+	return nil, noClose, fmt.Errorf("-log-format: unknown format %q", opts.format)
+}
@@ -0,0 +1,925 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"fileprocessor"
+	"fileprocessor/remote"
+	"fileprocessor/report"
+	"fileprocessor/statsd"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiff(os.Args[2:]))
+	}
+
+	var dirs dirList
+	flag.Var(&dirs, "dir", "Directory to scan, or a gs://BUCKET/PREFIX or az://ACCOUNT/CONTAINER/PREFIX URL; repeat, or list directories after the flags, to scan several (default \".\")")
+	workers := flag.Int("workers", 4, "Initial number of worker goroutines")
+	queueSize := flag.Int("queue-size", 100, "Files queued between the walker and the workers")
+	backpressure := flag.String("backpressure", "block", "When the queue is full: block the walker, or spill the backlog to a temporary file ($TMPDIR)")
+	filesFrom := flag.String("files-from", "", "Process the files listed in this file (- for stdin) instead of walking -dir")
+	nulSep := flag.Bool("0", false, "With -files-from or -urls, entries are NUL-terminated (find -print0)")
+	urlList := flag.String("urls", "", "Download and process the http(s) URLs listed in this file (- for stdin) instead of walking -dir")
+	hostRate := flag.Float64("host-rate", 0, "With -urls, max requests per second to any one host (0 = unlimited)")
+	hostConcurrency := flag.Int("host-concurrency", 0, "With -urls, max downloads in flight from any one host (0 = unlimited)")
+	walkWorkers := flag.Int("walk-workers", 1, "Directories listed concurrently; above 1, files are found in no particular order")
+	followSymlinks := flag.Bool("follow-symlinks", false, "Descend into symbolic links to directories (cycles are detected)")
+	maxDepth := flag.Int("max-depth", 0, "Descend at most this many levels below each directory; 1 = only its own files (0 = unlimited)")
+	respectGitignore := flag.Bool("respect-gitignore", false, "Skip files and directories excluded by .gitignore, .ignore and .git/info/exclude files, like ripgrep")
+	skipHidden := flag.Bool("skip-hidden", false, "Skip dotfiles and dot-directories, and on Windows files with the hidden attribute")
+	var pruneDirs patternList
+	flag.Var(&pruneDirs, "prune-dir", "Never descend into directories whose name matches this glob; repeatable (e.g. -prune-dir node_modules -prune-dir .git)")
+	var pruneMarkers nameList
+	flag.Var(&pruneMarkers, "prune-marker", "Never descend into directories holding a file of this name (e.g. .nobackup, or CACHEDIR.TAG with its signature); repeatable")
+	var excludeFrom excludeFile
+	flag.Var(&excludeFrom, "exclude-from", "Skip files and directories matching the .gitignore-style patterns in this file, one per line, # for comments; repeatable")
+	var match, notMatch regexpList
+	flag.Var(&match, "match", "Only process files whose full path matches this RE2 regexp; repeatable, any may match")
+	flag.Var(&notMatch, "not-match", "Don't process files whose full path matches this RE2 regexp; repeatable")
+	var minSize, maxSize byteSize
+	flag.Var(&minSize, "min-size", "Skip files smaller than this (e.g. 1K)")
+	flag.Var(&maxSize, "max-size", "Skip files larger than this (e.g. 10G; 0 = no limit)")
+	var newerThan, olderThan moment
+	flag.Var(&newerThan, "newer-than", "Only process files modified after this age or date (e.g. 24h, 7d, 2023-01-01)")
+	newerThanFile := flag.String("newer-than-file", "", "Only process files modified after this reference file, like find -newer (e.g. a touch file of the last run)")
+	flag.Var(&olderThan, "older-than", "Only process files modified before this age or date (e.g. 30d, 2023-01-01)")
+	types := flag.String("type", "", "Only process files whose sniffed MIME type matches one of these comma-separated patterns (e.g. image/*,application/pdf)")
+	owner := flag.String("owner", "", "Only process files owned by this user (name or uid)")
+	group := flag.String("group", "", "Only process files belonging to this group (name or gid)")
+	filterExpr := flag.String("filter", "", `Only process files matching this expression, e.g. 'size > 1MB && ext in ["log","gz"] && mtime > now()-72h'`)
+	filterCmd := flag.String("filter-cmd", "", "Only process files whose paths this shell command echoes back; it reads NUL-terminated paths on stdin and writes those to keep the same way (e.g. 'grep -z /src/')")
+	filterBatch := flag.Int("filter-batch", 1000, "With -filter-cmd, how many paths to hand the command per run")
+	perm := flag.String("perm", "", "Only process files with these permissions, as for find -perm: MODE exactly, -MODE all bits, /MODE any bit (e.g. /o+w, -4000)")
+	prescan := flag.Bool("prescan", false, "Count files and bytes before processing so progress shows percent done and ETA")
+	startAfter := flag.String("start-after", "", "Resume an interrupted run: skip every file up to and including this path, the last one reported")
+	sorted := flag.Bool("sorted", false, "Process and print files in lexicographic path order, for reproducible, diffable output")
+	hardlinks := flag.Bool("hardlinks", false, "Process a file with several hard links once, listing its other paths as links")
+	oneFileSystem := flag.Bool("one-file-system", false, "Don't descend into directories on other filesystems (mount points), like find -xdev")
+	archives := flag.Bool("archives", false, "Process the members of zip, tar and tar.gz files as files named ARCHIVE::MEMBER")
+	special := flag.String("special", "report", "Devices, FIFOs and sockets: report (as failed files), skip, or fail the run")
+	stableFor := flag.Duration("stable-for", 0, "Hold back files modified within this window, or changed while being read, as still being written (e.g. 30s)")
+	unstable := flag.String("unstable", "skip", "With -stable-for, files modified within the window: skip, or wait until they have been left alone for it")
+	dangling := flag.String("dangling", "report", "Symbolic links to missing targets: report (as failed files) or skip")
+	handlerName := flag.String("handler", "hash", "File handler to run: hash, stat or copy")
+	dest := flag.String("dest", "", "Destination directory for the copy handler")
+	hashAlgo := flag.String("hash", "sha256", "Hash algorithm: "+strings.Join(fileprocessor.HashAlgorithms(), ", ")+", or auto for the fastest on this CPU")
+	var hashRules hashMap
+	flag.Var(&hashRules, "hash-map", "Use another algorithm for matching file names, e.g. '*.iso=sha256,*.jpg=xxh3' (repeatable)")
+	hashWorkers := flag.Int("hash-workers", 1, "Goroutines used to hash a single large file")
+	var chunkThreshold byteSize
+	flag.Var(&chunkThreshold, "chunk-threshold", "Split files at least this large into chunks hashed in parallel (e.g. 1G)")
+	merkle := flag.Bool("merkle", false, "Also print per-file and per-directory Merkle roots of 1 MiB chunks")
+	fuzzyAlgo := flag.String("fuzzy", "", "Also compute a similarity digest: "+strings.Join(fileprocessor.FuzzyAlgorithms(), " or "))
+	similar := flag.Int("similar", 0, "With -fuzzy, report clusters of files at least this similar (1-100)")
+	var cdcAvg byteSize
+	flag.Var(&cdcAvg, "cdc", "Also list content-defined chunks of about this average size (e.g. 64K)")
+	hmacKeyFile := flag.String("hmac-key-file", "", "Compute HMACs keyed with the contents of this file (mode 0600) instead of plain hashes")
+	hmacKeyEnv := flag.String("hmac-key-env", "", "Like -hmac-key-file, but read the key from this environment variable")
+	meta := flag.String("meta", "", "Mix metadata into each digest: comma-separated size, mode, mtime, owner")
+	digestEncoding := flag.String("digest-encoding", "hex", "Digest encoding: "+strings.Join(fileprocessor.DigestEncodings(), ", "))
+	maxFiles := flag.Int64("max-files", 0, "Stop queueing after this many files and report the run as truncated (0 = no limit)")
+	var maxBytes byteSize
+	flag.Var(&maxBytes, "max-bytes", "Stop queueing before the total size of the files would pass this (e.g. 10G) and report the run as truncated")
+	var sampleRate fraction
+	flag.Var(&sampleRate, "sample-rate", "Process a uniformly random share of the files found, e.g. 5% or 0.05, for spot checks; -sample 5% is the same")
+	sampleCount := flag.Int("sample-count", 0, "Process this many files picked uniformly at random from those found (0 = all)")
+	sampleSeed := flag.Uint64("sample-seed", 0, "Seed for -sample percentages, -sample-rate and -sample-count, to pick the same sample again (0 = random)")
+	var sample byteSize
+	flag.Var(sampleValue{rate: &sampleRate, size: &sample}, "sample", "A percentage such as 5%: process that share of the files found, picked at random, like -sample-rate. A size such as 16K: find duplicate candidates by hashing only this many bytes from the start, middle and end of each file, then fully hash just those")
+	delay := flag.Duration("delay", 50*time.Millisecond, "Simulated extra work per file")
+	retries := flag.Int("retries", 0, "Retry failed files this many times")
+	timeout := flag.Duration("timeout", 0, "Per-file processing timeout (0 = none)")
+	rate := flag.Float64("rate", 0, "Maximum files started per second (0 = unlimited)")
+	reportFormat := flag.String("report", "auto", "Progress output: auto (progress on a terminal, console otherwise), progress, console, json, manifest or silent; or a file name ending in .html for a standalone HTML report besides that")
+	format := flag.String("format", "", "Write the result of every file in this format to stdout, or to -output, with -report going to stderr: jsonl, csv, parquet, sqlite (needs -output) or markdown (the summary as tables)")
+	output := flag.String("output", "", "Write the -format results to this file instead of stdout; a .jsonl, .csv, .parquet, .db or .md name picks the format, and a further .gz or .zst compresses it")
+	tmpl := flag.String("template", "", "Write a line per file laid out by this Go template, e.g. '{{.Hash}} {{.Size}} {{.Path}}', to stdout or -output")
+	runID := flag.String("run-id", "", "With -format sqlite, -event-log or -annotate, tag this run's rows, events or annotation with this id (default: the start time)")
+	tag := flag.Bool("tag", false, "Print BSD-style 'SHA256 (path) = digest' lines; implies -report=manifest")
+	fingerprint := flag.Bool("fingerprint", false, "Print only a single digest of the whole tree; exit 1 if any file failed")
+	check := flag.String("check", "", "Verify the files listed in a sha256sum-style manifest instead of scanning -dir")
+	skipUnchanged := flag.String("skip-unchanged", "", "Reuse the results of a previous -report json run for files whose size and mtime haven't changed, processing only new and changed ones")
+	baselinePath := flag.String("baseline", "", "Report drift against a previous run's JSON, CSV or sha256sum output; exit 2 if anything changed")
+	dirStats := flag.Bool("dir-stats", false, "Also report per-directory totals: files, bytes, failures and a combined hash of each subtree")
+	duplicates := flag.Bool("duplicates", false, "Also report groups of files with the same hash and the bytes their extra copies waste")
+	top := flag.Int("top", 20, "List this many of the slowest and largest files in the summary (0 = none)")
+	tui := flag.Bool("tui", false, "Show a full-screen dashboard of workers, queue, throughput and recent errors; p pauses and resumes, q quits")
+	metricsInterval := flag.Duration("metrics-interval", time.Second, "How often to print the [METRICS] line or redraw the progress bar; 0 prints none, leaving the summary")
+	quiet := flag.Bool("quiet", false, "Print only the summary: no progress, per-file lines or log messages")
+	verbose := flag.Bool("verbose", false, "Also print a line per file, worker lifecycle messages and a startup banner with the selected hash implementation")
+	debug := flag.Bool("debug", false, "Like -verbose, and also print every autoscaler decision")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "Send an OpenTelemetry trace of the run, with spans for the walk, queue waits and files, to this OTLP/HTTP receiver, e.g. http://localhost:4318")
+	traceSample := flag.Float64("trace-sample", 0.01, "Share of files that get queue and file spans in the -otlp-endpoint trace, from 0 to 1; failed files always do")
+	statsdAddr := flag.String("statsd-addr", "", "Send the counters and file timings to this StatsD server or Datadog agent, e.g. localhost:8125 or unix:///var/run/datadog/dsd.socket")
+	statsdTags := flag.String("statsd-tags", "", "Comma-separated DogStatsD tags sent with every -statsd-addr metric, e.g. env:prod,team:storage")
+	logFormat := flag.String("log-format", "", "Write log messages as structured records, text or json, with one for every failed file (every file with -verbose)")
+	logFile := flag.String("log-file", "", "Append structured log records to this file instead of stderr, one for every file whatever the verbosity")
+	eventLogPath := flag.String("event-log", "", "Append an audit trail of the run to this JSON lines file: its start and configuration, every file's outcome, every autoscaler decision and its end")
+	metricsAddr := flag.String("metrics-addr", "", "Serve Prometheus metrics on /metrics at this address, e.g. :9100, including each worker's busy and idle time, and liveness and readiness probes on /healthz and /readyz")
+	stallTimeout := flag.Duration("stall-timeout", 5*time.Minute, "With -metrics-addr, fail /healthz once no file has been walked or finished for this long; make it longer than the slowest file takes")
+	pushgateway := flag.String("pushgateway", "", "Push the final Prometheus metrics to this Pushgateway when the run ends, e.g. http://pushgateway:9091")
+	pushJob := flag.String("push-job", "fileprocessor", "The job label of the -pushgateway metrics")
+	pushInstance := flag.String("push-instance", "", "The instance label of the -pushgateway metrics (default: the host name)")
+	annotateURL := flag.String("annotate", "", "When the run ends, POST an annotation of it, with its outcome and counts, to this Grafana annotations API (e.g. http://grafana:3000/api/annotations, with $GRAFANA_TOKEN) or webhook")
+	annotateTags := flag.String("annotate-tags", "", "Comma-separated tags for the -annotate annotation, besides fileprocessor and the outcome")
+	statusFile := flag.String("status-file", "", "On SIGUSR1, write the status of the run, what every worker is on and the latest errors, to this file instead of stderr")
+	pprofAddr := flag.String("pprof", "", "Serve the pprof endpoints on this address, e.g. :6060, for profiling a run while it lasts, and the run's counters, queue and workers as expvar JSON on /debug/vars")
+	cpuProfile := flag.String("cpuprofile", "", "Write a CPU profile of the run to this file")
+	memProfile := flag.String("memprofile", "", "Write a heap profile to this file when the run ends")
+	noColor := flag.Bool("no-color", false, "Don't color the output, even on a terminal (so does setting NO_COLOR)")
+	// Usage errors exit like other setup errors rather than with the
+	// flag package's 2, which means a verification mismatch here.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitFatal)
+	}
+	dirs = append(dirs, flag.Args()...)
+	if len(dirs) == 0 {
+		dirs = dirList{"."}
+	}
+	var remoteURL string
+	if slices.ContainsFunc(dirs, remote.IsURL) {
+		if len(dirs) > 1 {
+			fmt.Fprintln(os.Stderr, "Error: a gs:// or az:// URL must be the only directory")
+			os.Exit(exitFatal)
+		}
+		remoteURL, dirs = dirs[0], dirList{"."}
+	}
+
+	if *tmpl != "" {
+		if *format != "" && *format != "template" {
+			fmt.Fprintln(os.Stderr, "Error: -template cannot be combined with -format", *format)
+			os.Exit(exitFatal)
+		}
+		*format = "template"
+	}
+	if *format == "" {
+		*format = formatOf(*output)
+	}
+	// Results go to stdout, and everything else to stderr, so that
+	// the output can be piped on. With -format results on stdout, the
+	// -report results move out of their way.
+	out, diag := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if *format != "" && *output == "" {
+		out = os.Stderr
+	}
+	var htmlPath string
+	if strings.EqualFold(filepath.Ext(*reportFormat), ".html") {
+		if *check != "" || *fingerprint {
+			fmt.Fprintln(os.Stderr, "Error: an HTML -report cannot be combined with -check or -fingerprint")
+			os.Exit(exitFatal)
+		}
+		htmlPath, *reportFormat = *reportFormat, "auto"
+	}
+	if *debug {
+		*verbose = true
+	}
+	if *quiet && (*verbose || *tui) {
+		fmt.Fprintln(os.Stderr, "Error: -quiet cannot be combined with -verbose, -debug or -tui")
+		os.Exit(exitFatal)
+	}
+	if *tui {
+		switch {
+		case *reportFormat != "auto" || *tag:
+			fmt.Fprintln(os.Stderr, "Error: -tui replaces -report and -tag")
+			os.Exit(exitFatal)
+		case *check != "" || *fingerprint:
+			fmt.Fprintln(os.Stderr, "Error: -tui cannot be combined with -check or -fingerprint")
+			os.Exit(exitFatal)
+		case !isTerminal(diag):
+			fmt.Fprintln(os.Stderr, "Error: -tui needs a terminal")
+			os.Exit(exitFatal)
+		}
+		*reportFormat = "tui"
+	}
+	// Per-file lines don't fit under a progress bar.
+	if *reportFormat == "auto" {
+		*reportFormat = "console"
+		if isTerminal(diag) && !*verbose {
+			*reportFormat = "progress"
+		}
+	}
+	// The progress bar and dashboard are at their best with totals to
+	// measure against.
+	if (*reportFormat == "progress" || *reportFormat == "tui") && !flagSet("prescan") {
+		*prescan = true
+	}
+	reporter, err := newReporter(*reportFormat, out, diag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitFatal)
+	}
+	if r, ok := reporter.(colorer); ok {
+		r.SetColor(colorOn(diag, *noColor))
+	}
+	switch r := reporter.(type) {
+	case console:
+		if *quiet {
+			reporter = summaryOnly{r}
+		} else if !*verbose {
+			reporter = withoutFiles{r}
+		}
+	case *report.Progress:
+		if *quiet {
+			reporter = summaryOnly{r}
+		}
+	}
+	if *tag {
+		reporter = report.NewTaggedManifest(out)
+	}
+	if *metricsInterval < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -metrics-interval must not be negative")
+		os.Exit(exitFatal)
+	}
+	if *metricsInterval == 0 {
+		if *tui {
+			fmt.Fprintln(os.Stderr, "Error: -metrics-interval 0 cannot be combined with -tui")
+			os.Exit(exitFatal)
+		}
+		reporter = withoutSnapshots{reporter}
+	}
+	if *output != "" && *format == "" {
+		fmt.Fprintln(os.Stderr, "Error: -output requires -format, or a name ending in .jsonl, .csv, .parquet, .db or .md")
+		os.Exit(exitFatal)
+	}
+	closeResults := func(bool) error { return nil }
+	if *format != "" {
+		if *check != "" || *fingerprint {
+			fmt.Fprintln(os.Stderr, "Error: -format cannot be combined with -check or -fingerprint")
+			os.Exit(exitFatal)
+		}
+		if *runID == "" {
+			*runID = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+		}
+		var results fileprocessor.Reporter
+		results, closeResults, err = openResults(*format, *output, *runID, *tmpl)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitFatal)
+		}
+		reporter = report.Multi{reporter, results}
+	}
+
+	if *backpressure != "block" && *backpressure != "spill" {
+		fmt.Fprintf(os.Stderr, "Error: unknown -backpressure strategy %q\n", *backpressure)
+		os.Exit(exitFatal)
+	}
+	specialPolicy := fileprocessor.SpecialFiles(*special)
+	if specialPolicy != fileprocessor.SpecialReport && specialPolicy != fileprocessor.SpecialSkip && specialPolicy != fileprocessor.SpecialFail {
+		fmt.Fprintf(os.Stderr, "Error: unknown -special policy %q\n", *special)
+		os.Exit(exitFatal)
+	}
+	if *unstable != "skip" && *unstable != "wait" {
+		fmt.Fprintf(os.Stderr, "Error: unknown -unstable policy %q\n", *unstable)
+		os.Exit(exitFatal)
+	}
+	if *dangling != "report" && *dangling != "skip" {
+		fmt.Fprintf(os.Stderr, "Error: unknown -dangling policy %q\n", *dangling)
+		os.Exit(exitFatal)
+	}
+
+	if *check != "" && *handlerName != "hash" {
+		fmt.Fprintln(os.Stderr, "Error: -check requires -handler=hash")
+		os.Exit(exitFatal)
+	}
+	if sample > 0 && (*check != "" || *fingerprint || *filesFrom != "") {
+		fmt.Fprintln(os.Stderr, "Error: a -sample size cannot be combined with -check, -fingerprint or -files-from")
+		os.Exit(exitFatal)
+	}
+	if (sampleRate > 0 || *sampleCount > 0) && (*check != "" || sample > 0) {
+		fmt.Fprintln(os.Stderr, "Error: random sampling (a -sample percentage, -sample-rate or -sample-count) cannot be combined with -check or a -sample size")
+		os.Exit(exitFatal)
+	}
+	if remoteURL != "" && (*check != "" || sample > 0) {
+		fmt.Fprintln(os.Stderr, "Error: remote directories cannot be combined with -check or a -sample size")
+		os.Exit(exitFatal)
+	}
+	if *filesFrom != "" && *check != "" {
+		fmt.Fprintln(os.Stderr, "Error: -files-from cannot be combined with -check")
+		os.Exit(exitFatal)
+	}
+	if *eventLogPath != "" && *check != "" {
+		fmt.Fprintln(os.Stderr, "Error: -event-log cannot be combined with -check")
+		os.Exit(exitFatal)
+	}
+	if *urlList != "" {
+		if *filesFrom != "" || *check != "" || sample > 0 || remoteURL != "" {
+			fmt.Fprintln(os.Stderr, "Error: -urls cannot be combined with -files-from, -check, a -sample size or a remote directory")
+			os.Exit(exitFatal)
+		}
+		*filesFrom = *urlList
+	}
+	if *startAfter != "" && (*check != "" || sample > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -start-after cannot be combined with -check or a -sample size")
+		os.Exit(exitFatal)
+	}
+	var typePatterns []string
+	if *types != "" {
+		if *check != "" || *archives {
+			fmt.Fprintln(os.Stderr, "Error: -type cannot be combined with -check or -archives")
+			os.Exit(exitFatal)
+		}
+		for _, t := range strings.Split(*types, ",") {
+			t = strings.TrimSpace(t)
+			if _, err := path.Match(t, ""); err != nil || !strings.Contains(t, "/") {
+				fmt.Fprintf(os.Stderr, "Error: invalid -type pattern %q, want type/subtype such as image/*\n", t)
+				os.Exit(exitFatal)
+			}
+			typePatterns = append(typePatterns, t)
+		}
+	}
+	if *archives && (*check != "" || sample > 0 || *retries > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -archives cannot be combined with -check, a -sample size or -retries")
+		os.Exit(exitFatal)
+	}
+	if *fingerprint {
+		if *handlerName != "hash" {
+			fmt.Fprintln(os.Stderr, "Error: -fingerprint requires -handler=hash")
+			os.Exit(exitFatal)
+		}
+		reporter = report.Silent{}
+	}
+
+	hmacKey, err := loadHMACKey(*hmacKeyFile, *hmacKeyEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitFatal)
+	}
+
+	metaFields, err := fileprocessor.ParseMetadataFields(*meta)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitFatal)
+	}
+
+	encoding, err := fileprocessor.ParseDigestEncoding(*digestEncoding)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitFatal)
+	}
+	if *check != "" && encoding != fileprocessor.EncodingHex {
+		fmt.Fprintln(os.Stderr, "Error: -check requires hex digests")
+		os.Exit(exitFatal)
+	}
+
+	if *handlerName == "copy" && len(dirs) > 1 {
+		fmt.Fprintln(os.Stderr, "Error: -handler=copy takes a single -dir")
+		os.Exit(exitFatal)
+	}
+	hashOpts := hashOptions{*hashWorkers, int64(chunkThreshold), *merkle, *fuzzyAlgo, int(cdcAvg), hmacKey, metaFields, encoding, hashRules}
+	handler, err := newHandler(*handlerName, *hashAlgo, hashOpts, dirs[0], *dest)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitFatal)
+	}
+
+	var prior []fileprocessor.Result
+	if *skipUnchanged != "" {
+		if *check != "" || sample > 0 {
+			fmt.Fprintln(os.Stderr, "Error: -skip-unchanged cannot be combined with -check or a -sample size")
+			os.Exit(exitFatal)
+		}
+		if prior, err = loadPrior(*skipUnchanged); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	var baseline map[string]string
+	if *baselinePath != "" {
+		if baseline, err = loadBaseline(*baselinePath); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if *verbose {
+		printBanner(*handlerName, *hashAlgo, *workers)
+	}
+
+	var middleware []fileprocessor.Middleware
+	if *stableFor > 0 {
+		middleware = append(middleware, fileprocessor.StableFor(*stableFor, *unstable == "wait"))
+	}
+	if len(typePatterns) > 0 {
+		// Ahead of the rate limit, so skipped files don't take a slot.
+		middleware = append(middleware, fileprocessor.ContentTypes(typePatterns...))
+	}
+	if *rate > 0 {
+		middleware = append(middleware, fileprocessor.RateLimit(*rate))
+	}
+	if *retries > 0 {
+		middleware = append(middleware, fileprocessor.Retry(*retries+1, 100*time.Millisecond))
+	}
+	if *timeout > 0 {
+		middleware = append(middleware, fileprocessor.Timeout(*timeout))
+	}
+	if *delay > 0 {
+		middleware = append(middleware, fileprocessor.Delay(*delay))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		fmt.Fprintln(os.Stderr, "\nReceived shutdown signal...")
+		cancel()
+	}()
+
+	stopProfiling, err := startProfiling(*pprofAddr, *cpuProfile, *memProfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitFatal)
+	}
+
+	if *check != "" {
+		status := runCheck(ctx, *check, *workers, handler, hashOpts, middleware, *noColor)
+		stopProfiling()
+		os.Exit(status)
+	}
+
+	// The walk settings are shared with the -sample pre-pass.
+	walkOpts := []fileprocessor.Option{
+		fileprocessor.WithDirs(dirs...),
+		fileprocessor.WithWalkWorkers(*walkWorkers),
+		fileprocessor.WithFollowSymlinks(*followSymlinks),
+		fileprocessor.WithSkipDangling(*dangling == "skip"),
+		fileprocessor.WithSpecialFiles(specialPolicy),
+		fileprocessor.WithMaxDepth(*maxDepth),
+		fileprocessor.WithPruneDirs(pruneDirs...),
+		fileprocessor.WithPruneMarkers(pruneMarkers...),
+		fileprocessor.WithOneFileSystem(*oneFileSystem),
+		fileprocessor.WithGitignore(*respectGitignore),
+		fileprocessor.WithSkipHidden(*skipHidden),
+		fileprocessor.WithExclude(excludeFrom.patterns...),
+		fileprocessor.WithHardlinks(*hardlinks),
+	}
+	if len(match) > 0 {
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(fileprocessor.MatchRegexp(match...)))
+	}
+	if len(notMatch) > 0 {
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(fileprocessor.SkipRegexp(notMatch...)))
+	}
+	if *newerThanFile != "" {
+		ref, err := os.Stat(*newerThanFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -newer-than-file:", err)
+			os.Exit(exitFatal)
+		}
+		if ref.ModTime().After(newerThan.t) {
+			newerThan.t = ref.ModTime()
+		}
+	}
+	if !newerThan.t.IsZero() || !olderThan.t.IsZero() {
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(fileprocessor.ModifiedBetween(newerThan.t, olderThan.t)))
+	}
+	if *owner != "" {
+		uid, err := lookupUID(*owner)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -owner:", err)
+			os.Exit(exitFatal)
+		}
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(fileprocessor.OwnedBy(uid)))
+	}
+	if *group != "" {
+		gid, err := lookupGID(*group)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -group:", err)
+			os.Exit(exitFatal)
+		}
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(fileprocessor.InGroup(gid)))
+	}
+	if *perm != "" {
+		f, err := fileprocessor.Permissions(*perm)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -perm:", err)
+			os.Exit(exitFatal)
+		}
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(f))
+	}
+	if *filterExpr != "" {
+		f, err := fileprocessor.Expression(*filterExpr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -filter:", err)
+			os.Exit(exitFatal)
+		}
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(f))
+	}
+	if minSize > 0 || maxSize > 0 {
+		walkOpts = append(walkOpts, fileprocessor.WithFilters(fileprocessor.SizeRange(int64(minSize), int64(maxSize))))
+	}
+	if *filterCmd != "" {
+		if *filterBatch < 1 {
+			fmt.Fprintln(os.Stderr, "Error: -filter-batch: must be at least 1")
+			os.Exit(exitFatal)
+		}
+		shell, flagC := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flagC = "cmd", "/C"
+		}
+		walkOpts = append(walkOpts,
+			fileprocessor.WithFilterCommand(shell, flagC, *filterCmd),
+			fileprocessor.WithFilterBatch(*filterBatch))
+	}
+	opts := append(walkOpts[:len(walkOpts):len(walkOpts)],
+		fileprocessor.WithWorkers(*workers),
+		fileprocessor.WithQueueSize(*queueSize),
+		fileprocessor.WithArchives(*archives),
+		fileprocessor.WithSorted(*sorted),
+		fileprocessor.WithStartAfter(*startAfter),
+		fileprocessor.WithPrescan(*prescan),
+		fileprocessor.WithHandler(handler),
+		fileprocessor.WithMiddleware(middleware...),
+		fileprocessor.WithReporter(reporter),
+		fileprocessor.WithFingerprint(*fingerprint),
+		fileprocessor.WithSimilarity(*similar),
+	)
+	// With -metrics-interval 0 snapshots still go to -metrics-addr and the
+	// HTML page's charts, every second.
+	if *metricsInterval > 0 {
+		opts = append(opts, fileprocessor.WithMetricsInterval(*metricsInterval))
+	}
+	if remoteURL != "" {
+		fsys, err := remote.Open(ctx, remoteURL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitFatal)
+		}
+		opts = append(opts, fileprocessor.WithFS(fsys))
+	}
+	if *backpressure == "spill" {
+		opts = append(opts, fileprocessor.WithSpillQueue(""))
+	}
+	if baseline != nil {
+		opts = append(opts, fileprocessor.WithBaseline(baseline))
+	}
+	if prior != nil {
+		opts = append(opts, fileprocessor.WithSkipUnchanged(prior))
+	}
+	if *filesFrom != "" {
+		list := os.Stdin
+		if *filesFrom != "-" {
+			if list, err = os.Open(*filesFrom); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(exitFatal)
+			}
+			defer list.Close()
+		}
+		delim := byte('\n')
+		if *nulSep {
+			delim = 0
+		}
+		opts = append(opts, fileprocessor.WithWalker(fileprocessor.ListWalker(list, delim)))
+	}
+	if *urlList != "" {
+		opts = append(opts,
+			fileprocessor.WithURLs(nil),
+			fileprocessor.WithHostRateLimit(*hostRate),
+			fileprocessor.WithHostConcurrency(*hostConcurrency),
+		)
+	}
+	if *top < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -top must not be negative")
+		os.Exit(exitFatal)
+	}
+	opts = append(opts, fileprocessor.WithTopFiles(*top), fileprocessor.WithDirStats(*dirStats), fileprocessor.WithDuplicates(*duplicates))
+	if *maxFiles > 0 || maxBytes > 0 {
+		opts = append(opts, fileprocessor.WithMaxFiles(*maxFiles), fileprocessor.WithMaxBytes(int64(maxBytes)))
+	}
+	if sampleRate > 0 || *sampleCount > 0 {
+		opts = append(opts,
+			fileprocessor.WithSampleRate(float64(sampleRate)),
+			fileprocessor.WithSampleCount(*sampleCount),
+			fileprocessor.WithSampleSeed(*sampleSeed),
+		)
+	}
+	if sample > 0 {
+		walker, err := samplePass(ctx, walkOpts, *workers, handler, int64(sample))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitFatal)
+		}
+		opts = append(opts, fileprocessor.WithWalker(walker))
+	}
+	// Log lines are for -verbose; -fingerprint output must be the digest
+	// alone, and the -tui screen would be garbled by them.
+	logger, closeLog, err := newSlog(logOptions{format: *logFormat, file: *logFile,
+		quiet: *quiet, verbose: *verbose, debug: *debug, screen: *tui}, diag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitFatal)
+	}
+	if logger != nil {
+		opts = append(opts, fileprocessor.WithSlog(logger))
+	} else if *verbose && !*fingerprint && !*tui {
+		debugLog := io.Discard
+		if *debug {
+			debugLog = diag
+		}
+		opts = append(opts,
+			fileprocessor.WithLogger(log.New(diag, "", 0)),
+			fileprocessor.WithDebugLogger(log.New(debugLog, "", 0)),
+		)
+	}
+	closeTrace := func() error { return nil }
+	if *otlpEndpoint != "" {
+		exporter, err := newExporter(*otlpEndpoint, *traceSample)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -otlp-endpoint:", err)
+			os.Exit(exitFatal)
+		}
+		opts = append(opts, fileprocessor.WithTracer(exporter))
+		closeTrace = exporter.Close
+	}
+	closeStatsd := func() error { return nil }
+	if *statsdAddr != "" {
+		var tags []string
+		if *statsdTags != "" {
+			tags = strings.Split(*statsdTags, ",")
+		}
+		client, err := statsd.New(statsd.Config{Addr: *statsdAddr, Tags: tags})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -statsd-addr:", err)
+			os.Exit(exitFatal)
+		}
+		opts = append(opts, fileprocessor.WithMetrics(client))
+		closeStatsd = client.Close
+	}
+	reporters := report.Multi{reporter}
+	closeHTML := func() error { return nil }
+	if htmlPath != "" {
+		var page fileprocessor.Reporter
+		page, closeHTML, err = openHTML(htmlPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -report:", err)
+			os.Exit(exitFatal)
+		}
+		reporters = append(reporters, page)
+	}
+	if *metricsAddr != "" {
+		prom := report.NewPrometheus()
+		health := report.NewHealth(report.HealthConfig{StallTimeout: *stallTimeout})
+		if err := serveMetrics(*metricsAddr, prom, health); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -metrics-addr:", err)
+			os.Exit(exitFatal)
+		}
+		reporters = append(reporters, prom, health)
+	}
+	closePush := func() error { return nil }
+	if *pushgateway != "" {
+		if u, err := url.Parse(*pushgateway); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "Error: -pushgateway: want an http or https URL, got %q\n", *pushgateway)
+			os.Exit(exitFatal)
+		}
+		if *pushInstance == "" && !flagSet("push-instance") {
+			*pushInstance, _ = os.Hostname()
+		}
+		push := report.NewPushgateway(*pushgateway, *pushJob, *pushInstance, nil)
+		reporters = append(reporters, push)
+		closePush = push.Close
+	}
+	var annotate *annotator
+	if *annotateURL != "" {
+		if u, err := url.Parse(*annotateURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "Error: -annotate: want an http or https URL, got %q\n", *annotateURL)
+			os.Exit(exitFatal)
+		}
+		if *runID == "" {
+			*runID = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+		}
+		annotate = &annotator{url: *annotateURL, run: *runID, tags: splitTags(*annotateTags)}
+	}
+	if len(reporters) > 1 {
+		opts = append(opts, fileprocessor.WithReporter(reporters))
+	}
+	p := fileprocessor.New(opts...)
+	dumpStatusOn(p, *statusFile, diag)
+	if annotate != nil {
+		annotate.attach(ctx, p)
+	}
+	if *pprofAddr != "" {
+		publishVars(p)
+	}
+	closeEvents := func() error { return nil }
+	if *eventLogPath != "" {
+		if *runID == "" {
+			*runID = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+		}
+		events, err := openEventLog(*eventLogPath, *runID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -event-log:", err)
+			os.Exit(exitFatal)
+		}
+		events.attach(ctx, p)
+		closeEvents = events.Close
+	}
+	restoreTerm := func() {}
+	if *tui {
+		restoreTerm = readKeys(p, cancel)
+	}
+
+	summary, err := p.Run(ctx)
+	restoreTerm()
+	stopProfiling()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	complete := err == nil && ctx.Err() == nil
+	if err := closeResults(complete); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -output:", err)
+		os.Exit(exitFatal)
+	}
+	if !complete && *output != "" {
+		fmt.Fprintf(os.Stderr, "Run incomplete: %s left as it was\n", *output)
+	}
+	// Unlike a trace or metrics, a gap in the audit trail fails the run.
+	if err := closeEvents(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -event-log:", err)
+		os.Exit(exitFatal)
+	}
+	if err := closeHTML(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -report:", err)
+		os.Exit(exitFatal)
+	}
+	if htmlPath != "" {
+		fmt.Fprintln(os.Stderr, "HTML report written to", htmlPath)
+	}
+	// A trace, metrics or an annotation that didn't get through are
+	// reported but aren't the run's failure.
+	if err := closeTrace(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -otlp-endpoint:", err)
+	}
+	if err := closeStatsd(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -statsd-addr:", err)
+	}
+	if err := closePush(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -pushgateway:", err)
+	}
+	if annotate != nil && annotate.err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -annotate:", annotate.err)
+	}
+	if err := closeLog(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -log-file:", err)
+	}
+	if *fingerprint {
+		if err != nil || summary.Fingerprint == "" {
+			for _, err := range summary.Errors {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+			if summary.Truncated {
+				fmt.Fprintln(os.Stderr, "Error: no fingerprint for a run truncated by -max-files or -max-bytes")
+			}
+			os.Exit(exitStatus(ctx, err, summary, exitFailed))
+		}
+		fp, _ := fileprocessor.EncodeDigest(summary.Fingerprint, encoding)
+		fmt.Println(fp)
+	}
+	os.Exit(exitStatus(ctx, err, summary, exitOK))
+}
+
+// exitStatus picks the exit status of a run that returned summary and
+// err, given the status its other checks settled on so far.
+func exitStatus(ctx context.Context, err error, summary fileprocessor.Summary, status int) int {
+	switch {
+	case ctx.Err() != nil:
+		return exitInterrupted
+	case err != nil:
+		return exitFatal
+	case summary.Drift != nil && summary.Drift.Changed():
+		return exitMismatch
+	case summary.Failed > 0:
+		return max(status, exitFailed)
+	}
+	return status
+}
+
+// printBanner describes the run about to start on stderr, including the
+// hash implementation picked for this CPU, which explains most throughput
+// differences between hosts.
+func printBanner(handler, algorithm string, workers int) {
+	fmt.Fprintf(os.Stderr, "fileprocessor %s (%s %s/%s)\n", fileprocessor.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if handler == "hash" {
+		algorithm = fileprocessor.ResolveAlgorithm(algorithm)
+		fmt.Fprintf(os.Stderr, "Hash: %s (%s)\n", algorithm, fileprocessor.HashBackend(algorithm))
+	}
+	fmt.Fprintf(os.Stderr, "Workers: %d\n", workers)
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// newReporter maps the -report flag to a Reporter writing results to out
+// and progress and summaries to diag. The JSON lines stay together on
+// out, being data throughout.
+func newReporter(format string, out, diag io.Writer) (fileprocessor.Reporter, error) {
+	switch format {
+	case "console":
+		return console{files: report.NewConsole(out), diag: report.NewConsole(diag)}, nil
+	case "progress":
+		return report.NewProgress(diag), nil
+	case "tui":
+		width := 0
+		if f, ok := diag.(*os.File); ok {
+			width = termWidth(f)
+		}
+		return report.NewDashboard(diag, width, tuiKeys), nil
+	case "json":
+		return report.NewJSON(out), nil
+	case "manifest":
+		return report.NewManifest(out), nil
+	case "silent":
+		return report.Silent{}, nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// hashOptions carries the flags that tune -handler=hash.
+type hashOptions struct {
+	workers        int
+	chunkThreshold int64
+	merkle         bool
+	fuzzy          string
+	cdcAvg         int
+	hmacKey        []byte
+	metadata       fileprocessor.MetadataField
+	encoding       fileprocessor.DigestEncoding
+	rules          hashMap
+}
+
+// newHashHandler returns the HashHandler for one algorithm with every hash
+// flag applied.
+func newHashHandler(algorithm string, opts hashOptions) (fileprocessor.HashHandler, error) {
+	h, err := fileprocessor.NewHashHandler(algorithm)
+	if opts.hmacKey != nil {
+		h, err = fileprocessor.NewHMACHandler(algorithm, opts.hmacKey)
+	}
+	if err != nil {
+		return fileprocessor.HashHandler{}, err
+	}
+	if opts.fuzzy != "" && !slices.Contains(fileprocessor.FuzzyAlgorithms(), opts.fuzzy) {
+		return fileprocessor.HashHandler{}, fmt.Errorf("unknown fuzzy hash %q", opts.fuzzy)
+	}
+	h.Workers, h.ChunkThreshold = opts.workers, opts.chunkThreshold
+	h.Merkle, h.Fuzzy, h.ChunkAvg = opts.merkle, opts.fuzzy, opts.cdcAvg
+	h.Metadata, h.Encoding = opts.metadata, opts.encoding
+	return h, nil
+}
+
+// newHandler maps the -handler flag to a FileHandler.
+func newHandler(name, algorithm string, opts hashOptions, dir, dest string) (fileprocessor.FileHandler, error) {
+	switch name {
+	case "hash":
+		h, err := newHashHandler(algorithm, opts)
+		if err != nil || len(opts.rules) == 0 {
+			return h, err
+		}
+		routes := make([]fileprocessor.Route, len(opts.rules))
+		for i, rule := range opts.rules {
+			rh, err := newHashHandler(rule.algorithm, opts)
+			if err != nil {
+				return nil, fmt.Errorf("-hash-map %s: %w", rule.pattern, err)
+			}
+			routes[i] = fileprocessor.Route{Pattern: rule.pattern, Handler: rh}
+		}
+		return fileprocessor.NewRouter(h, routes...)
+	case "stat":
+		return fileprocessor.StatHandler{}, nil
+	case "copy":
+		if dest == "" {
+			return nil, fmt.Errorf("-handler=copy requires -dest")
+		}
+		return fileprocessor.CopyHandler{Root: dir, Dest: dest}, nil
+	default:
+		return nil, fmt.Errorf("unknown handler %q", name)
+	}
+}
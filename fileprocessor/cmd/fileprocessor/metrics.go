@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"fileprocessor/report"
+)
+
+// serveMetrics serves h on /metrics at addr, and the probes of health on
+// /healthz and /readyz, until the process exits.
+func serveMetrics(addr string, h http.Handler, health *report.Health) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", h)
+	mux.HandleFunc("/healthz", health.Healthz)
+	mux.HandleFunc("/readyz", health.Readyz)
+	go http.Serve(ln, mux)
+	fmt.Fprintf(os.Stderr, "metrics: http://%s/metrics\n", ln.Addr())
+	return nil
+}
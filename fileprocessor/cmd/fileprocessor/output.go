@@ -0,0 +1,201 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fileprocessor"
+	"fileprocessor/internal/zstd"
+	"fileprocessor/report"
+)
+
+// openResults maps the -format flag to a Reporter writing the results to
+// output, or to stdout if it is empty. The returned function completes
+// the output once the run is over: an -output file only replaces what
+// was at its path if the run completed, and vanishes otherwise. An output
+// name ending in .gz or .zst is compressed as it is written.
+func openResults(format, output, runID, tmpl string) (fileprocessor.Reporter, func(complete bool) error, error) {
+	var newResults func(io.Writer) fileprocessor.Reporter
+	switch format {
+	case "template":
+		// Parse before creating the output, so that a bad template
+		// leaves no trace.
+		if _, err := report.NewTemplate(io.Discard, tmpl); err != nil {
+			return nil, nil, fmt.Errorf("-template: %w", err)
+		}
+		newResults = func(w io.Writer) fileprocessor.Reporter {
+			t, _ := report.NewTemplate(w, tmpl)
+			return t
+		}
+	case "jsonl":
+		newResults = func(w io.Writer) fileprocessor.Reporter { return report.NewJSONLines(w) }
+	case "csv":
+		newResults = func(w io.Writer) fileprocessor.Reporter { return report.NewCSV(w) }
+	case "parquet":
+		newResults = func(w io.Writer) fileprocessor.Reporter { return report.NewParquet(w) }
+	case "markdown":
+		newResults = func(w io.Writer) fileprocessor.Reporter { return report.NewMarkdown(w) }
+	case "sqlite":
+		if output == "" {
+			return nil, nil, errors.New("-format sqlite requires -output")
+		}
+		if compression(output) != "" {
+			return nil, nil, errors.New("-format sqlite cannot be compressed")
+		}
+		db, err := report.NewSQLite(output, runID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("-output: %w", err)
+		}
+		// The database is rewritten, atomically, by Close alone.
+		return db, func(complete bool) error {
+			if !complete {
+				return nil
+			}
+			return db.Close()
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown -format %q", format)
+	}
+
+	if output == "" {
+		results := newResults(os.Stdout)
+		return results, func(bool) error { return closeReporter(results) }, nil
+	}
+	f, err := createAtomic(output)
+	if err != nil {
+		return nil, nil, fmt.Errorf("-output: %w", err)
+	}
+	var zw io.WriteCloser
+	switch compression(output) {
+	case ".gz":
+		zw = gzip.NewWriter(f)
+	case ".zst":
+		zw = zstd.NewWriter(f)
+	}
+	results := newResults(f)
+	if zw != nil {
+		results = newResults(zw)
+	}
+	return results, func(complete bool) error {
+		err := closeReporter(results)
+		if zw != nil && err == nil {
+			err = zw.Close()
+		}
+		if err != nil || !complete {
+			f.abort()
+			return err
+		}
+		return f.commit()
+	}, nil
+}
+
+// openHTML returns a Reporter writing an HTML report to path, and the
+// function that puts it in place after the run. Unlike -output, the page
+// is kept for interrupted runs too: it shows what they got done.
+func openHTML(path string) (fileprocessor.Reporter, func() error, error) {
+	f, err := createAtomic(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	page := report.NewHTML(f)
+	return page, func() error {
+		if err := page.Close(); err != nil {
+			f.abort()
+			return err
+		}
+		return f.commit()
+	}, nil
+}
+
+// closeReporter closes r if it needs it, as Parquet does to write its
+// footer and Template to report a failure.
+func closeReporter(r fileprocessor.Reporter) error {
+	if c, ok := r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// compression returns ".gz" or ".zst" if output names a compressed
+// file, or "".
+func compression(output string) string {
+	switch ext := strings.ToLower(filepath.Ext(output)); ext {
+	case ".gz", ".zst":
+		return ext
+	}
+	return ""
+}
+
+// formatOf picks the -format for an -output file from its extension,
+// looking past a compression one, or returns "" if the extension is not a
+// known one.
+func formatOf(output string) string {
+	output = output[:len(output)-len(compression(output))]
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".csv":
+		return "csv"
+	case ".parquet":
+		return "parquet"
+	case ".db", ".sqlite", ".sqlite3":
+		return "sqlite"
+	case ".md", ".markdown":
+		return "markdown"
+	}
+	return ""
+}
+
+// atomicFile is an -output file written under a temporary name in the
+// same directory and renamed into place by commit, so that its path only
+// ever holds a complete file: the previous one until the rename, the new
+// one after it.
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp makes the file private; give it the mode of the file it
+	// replaces, or that of a new file.
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path}, nil
+}
+
+// commit flushes the file to disk and moves it to its path.
+func (f *atomicFile) commit() error {
+	err := f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// abort discards the file.
+func (f *atomicFile) abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
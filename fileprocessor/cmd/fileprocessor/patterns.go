@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// patternList is a flag.Value for a repeatable filepath.Match pattern,
+// checked for syntax errors as it is parsed.
+type patternList []string
+
+func (l *patternList) String() string { return strings.Join(*l, ",") }
+
+func (l *patternList) Set(v string) error {
+	if _, err := filepath.Match(v, ""); err != nil {
+		return err
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+// nameList is a flag.Value for a repeatable plain string.
+type nameList []string
+
+func (l *nameList) String() string { return strings.Join(*l, ",") }
+
+func (l *nameList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// regexpList is a flag.Value for a repeatable RE2 regular expression.
+type regexpList []*regexp.Regexp
+
+func (l *regexpList) String() string {
+	var s []string
+	for _, re := range *l {
+		s = append(s, re.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *regexpList) Set(v string) error {
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, re)
+	return nil
+}
+
+// excludeFile is a flag.Value for a repeatable file of exclusion patterns
+// in .gitignore syntax, one per line. Each file is read as the flag is
+// parsed; its lines collect in patterns.
+type excludeFile struct {
+	files    []string
+	patterns []string
+}
+
+func (e *excludeFile) String() string { return strings.Join(e.files, ",") }
+
+func (e *excludeFile) Set(v string) error {
+	data, err := os.ReadFile(v)
+	if err != nil {
+		return err
+	}
+	e.files = append(e.files, v)
+	e.patterns = append(e.patterns, strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")...)
+	return nil
+}
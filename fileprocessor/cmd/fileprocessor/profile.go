@@ -0,0 +1,96 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+
+	"fileprocessor"
+)
+
+// startProfiling serves the pprof endpoints and the expvar variables, on
+// /debug/vars, on addr and starts writing a CPU profile to cpuFile, each
+// if set. stop ends the CPU profile and
+// writes a heap profile to memFile, if set; the endpoints stay up until
+// the process exits.
+func startProfiling(addr, cpuFile, memFile string) (stop func(), err error) {
+	if addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("-pprof: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.Handle("/debug/vars", expvar.Handler())
+		go http.Serve(ln, mux)
+		fmt.Fprintf(os.Stderr, "pprof: http://%s/debug/pprof/\n", ln.Addr())
+	}
+	var cpu *os.File
+	if cpuFile != "" {
+		if cpu, err = os.Create(cpuFile); err != nil {
+			return nil, fmt.Errorf("-cpuprofile: %w", err)
+		}
+		if err := rpprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, fmt.Errorf("-cpuprofile: %w", err)
+		}
+	}
+	return func() {
+		if cpu != nil {
+			rpprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error: -cpuprofile:", err)
+			}
+		}
+		if memFile != "" {
+			if err := writeHeapProfile(memFile); err != nil {
+				fmt.Fprintln(os.Stderr, "Error: -memprofile:", err)
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes the heap profile as of the last garbage
+// collection, which it runs first so that the profile is up to date.
+func writeHeapProfile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := rpprof.Lookup("heap").WriteTo(f, 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// publishVars publishes the state of p's run as the expvar variable
+// fileprocessor: its counters, the queue, the workers and the walk, read
+// afresh on every request.
+func publishVars(p *fileprocessor.Processor) {
+	expvar.Publish("fileprocessor", expvar.Func(func() any {
+		s := p.Snapshot()
+		return map[string]any{
+			"counters":         p.Metrics().Counters,
+			"queue":            s.Queue,
+			"queue_size":       s.QueueSize,
+			"workers":          s.Workers,
+			"workers_busy":     len(s.Active),
+			"walked":           s.Walked,
+			"walk_done":        s.WalkDone,
+			"paused":           s.Paused,
+			"elapsed_seconds":  s.Elapsed.Seconds(),
+			"bytes_per_second": s.Throughput.Current,
+		}
+	}))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"fileprocessor"
+)
+
+// samplePass hashes every file the walk configured by walkOpts finds with a
+// sampled digest and returns a Walker over the files that share their
+// digest with another, the only candidates for a full duplicate check.
+func samplePass(ctx context.Context, walkOpts []fileprocessor.Option, workers int, handler fileprocessor.FileHandler, sample int64) (fileprocessor.Walker, error) {
+	h, ok := withSample(handler, sample)
+	if !ok {
+		return nil, fmt.Errorf("a -sample size requires -handler=hash")
+	}
+
+	p := fileprocessor.New(append(walkOpts[:len(walkOpts):len(walkOpts)],
+		fileprocessor.WithWorkers(workers),
+		fileprocessor.WithHandler(h),
+	)...)
+	var results []fileprocessor.Result
+	for res, err := range p.All(ctx) {
+		if err != nil && res.Path == "" {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	paths := fileprocessor.SampleCandidates(results)
+	fmt.Fprintf(os.Stderr, "Sample pass: %d of %d %s are duplicate candidates\n", len(paths), len(results), plural(len(results), "file", "files"))
+	return fileprocessor.WalkerFunc(func(ctx context.Context, visit func(string, fs.FileInfo) error) error {
+		for _, path := range paths {
+			if err := visit(path, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// withSample returns a copy of a hash handler, or of a Router over hash
+// handlers, that computes sampled digests.
+func withSample(handler fileprocessor.FileHandler, sample int64) (fileprocessor.FileHandler, bool) {
+	switch h := handler.(type) {
+	case fileprocessor.HashHandler:
+		h.Sample = sample
+		return h, true
+	case fileprocessor.Router:
+		def, ok := withSample(h.Default, sample)
+		if !ok {
+			return nil, false
+		}
+		routes := make([]fileprocessor.Route, len(h.Routes))
+		for i, r := range h.Routes {
+			if r.Handler, ok = withSample(r.Handler, sample); !ok {
+				return nil, false
+			}
+			routes[i] = r
+		}
+		return fileprocessor.Router{Routes: routes, Default: def}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a flag.Value for sizes such as 512, 64K, 8MiB or 1.5G.
+// Suffixes are binary multiples of 1024.
+type byteSize int64
+
+var sizeUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+func (s *byteSize) String() string {
+	n := int64(*s)
+	for _, u := range sizeUnits {
+		if n != 0 && n%u.mult == 0 {
+			return strconv.FormatInt(n/u.mult, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (s *byteSize) Set(v string) error {
+	num := strings.ToUpper(strings.TrimSpace(v))
+	num = strings.TrimSuffix(strings.TrimSuffix(num, "B"), "I")
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSuffix(num, u.suffix), u.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid size %q", v)
+	}
+	*s = byteSize(f * float64(mult))
+	return nil
+}
+
+// fraction is a flag.Value for a share of files, written as a percentage
+// such as 5% or 0.5%, or as a number between 0 and 1.
+type fraction float64
+
+func (f *fraction) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*f)*100, 'g', -1, 64) + "%"
+}
+
+func (f *fraction) Set(v string) error {
+	num, pct := strings.CutSuffix(strings.TrimSpace(v), "%")
+	x, err := strconv.ParseFloat(num, 64)
+	if pct {
+		x /= 100
+	}
+	if err != nil || x <= 0 || x > 1 {
+		return fmt.Errorf("invalid fraction %q: want a percentage up to 100%% or a number up to 1", v)
+	}
+	*f = fraction(x)
+	return nil
+}
+
+// sampleValue is the -sample flag, which takes either a percentage, for
+// the random sample of files -sample-rate also selects, or a size, for
+// the sampled-digest duplicate pre-pass.
+type sampleValue struct {
+	rate *fraction
+	size *byteSize
+}
+
+func (s sampleValue) String() string {
+	switch {
+	case s.rate != nil && *s.rate > 0:
+		return s.rate.String()
+	case s.size != nil && *s.size > 0:
+		return s.size.String()
+	}
+	return ""
+}
+
+func (s sampleValue) Set(v string) error {
+	if strings.HasSuffix(strings.TrimSpace(v), "%") {
+		return s.rate.Set(v)
+	}
+	return s.size.Set(v)
+}
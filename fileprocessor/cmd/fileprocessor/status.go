@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"fileprocessor"
+)
+
+// statusErrors is how many of the latest errors a status dump lists.
+const statusErrors = 10
+
+// dumpStatus writes the status of p's run to the file name, replacing
+// it, or to diag if name is empty.
+func dumpStatus(p *fileprocessor.Processor, name string, diag io.Writer) {
+	var b bytes.Buffer
+	writeStatus(&b, p, time.Now())
+	if name == "" {
+		diag.Write(b.Bytes())
+		return
+	}
+	if err := os.WriteFile(name, b.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: -status-file:", err)
+	}
+}
+
+// writeStatus describes the run as of now for someone wondering whether
+// it is hung: the counters, the queue, what every worker is on and the
+// latest errors.
+func writeStatus(w io.Writer, p *fileprocessor.Processor, now time.Time) {
+	s := p.Snapshot()
+	fmt.Fprintf(w, "=== fileprocessor status at %s ===\n", now.Format(time.RFC3339))
+	walk := "walking"
+	if s.WalkDone {
+		walk = "walk done"
+	}
+	fmt.Fprintf(w, "Elapsed: %s | Paused: %t | Walked: %d (%s)\n", s.Elapsed.Round(time.Second), s.Paused, s.Walked, walk)
+	fmt.Fprintf(w, "Processed: %d | Failed: %d | Bytes: %d | MB/s: %.2f (1m: %.2f, 5m: %.2f)\n",
+		s.Processed, s.Failed, s.Bytes, s.Throughput.Current/1e6, s.Throughput.Avg1m/1e6, s.Throughput.Avg5m/1e6)
+	fmt.Fprintf(w, "Queue: %d/%d | Workers: %d (%d busy) | Goroutines: %d\n", s.Queue, s.QueueSize, s.Workers, len(s.Active), s.Goroutines)
+
+	fmt.Fprintln(w, "Workers:")
+	busy := make(map[int]fileprocessor.WorkerState, len(s.Active))
+	for _, a := range s.Active {
+		busy[a.ID] = a
+	}
+	for _, u := range s.Usage {
+		if u.Exited {
+			continue
+		}
+		if a, ok := busy[u.ID]; ok {
+			fmt.Fprintf(w, "  #%-3d %10s  %s\n", u.ID, a.Elapsed.Round(time.Millisecond), a.Path)
+		} else {
+			fmt.Fprintf(w, "  #%-3d %10s\n", u.ID, "idle")
+		}
+	}
+
+	errs := p.Errors()
+	if len(errs) == 0 {
+		fmt.Fprintln(w, "Errors: none")
+		return
+	}
+	fmt.Fprintf(w, "Errors (latest %d of %d):\n", min(len(errs), statusErrors), len(errs))
+	recent := errs[max(len(errs)-statusErrors, 0):]
+	for _, err := range slices.Backward(recent) {
+		fmt.Fprintln(w, " ", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"io"
+
+	"fileprocessor"
+)
+
+// dumpStatusOn would write the status of p's run on SIGUSR1, which this
+// system doesn't have.
+func dumpStatusOn(*fileprocessor.Processor, string, io.Writer) {}
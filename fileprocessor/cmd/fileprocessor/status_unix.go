@@ -0,0 +1,24 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"fileprocessor"
+)
+
+// dumpStatusOn writes the status of p's run, as dumpStatus does, every
+// time the process gets SIGUSR1, until it exits.
+func dumpStatusOn(p *fileprocessor.Processor, name string, diag io.Writer) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		for range sig {
+			dumpStatus(p, name, diag)
+		}
+	}()
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+// terminal reports whether f is a character device, the best the standard
+// library can tell elsewhere than on Linux. /dev/null passes too.
+func terminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// cbreak would switch the terminal to reading key by key. Elsewhere than
+// on Linux keys are read a line at a time, so they take effect on Enter.
+func cbreak(*os.File) (restore func(), err error) {
+	return nil, errors.New("key-by-key terminal input is not supported on this system")
+}
+
+// termWidth returns the number of columns $COLUMNS gives, or 0.
+func termWidth(*os.File) int {
+	n, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	return n
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"fileprocessor/otlp"
+)
+
+// newExporter returns the OTLP exporter for -otlp-endpoint, sending the
+// headers in OTEL_EXPORTER_OTLP_HEADERS as the OpenTelemetry SDKs do.
+func newExporter(endpoint string, sample float64) (*otlp.Exporter, error) {
+	if sample <= 0 || sample > 1 {
+		return nil, fmt.Errorf("-trace-sample %v is not above 0 and at most 1", sample)
+	}
+	headers, err := otlp.ParseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	return otlp.New(otlp.Config{Endpoint: endpoint, Headers: headers, SampleRate: sample})
+}
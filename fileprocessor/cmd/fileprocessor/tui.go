@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"fileprocessor"
+)
+
+// tuiKeys is the key help line of the -tui dashboard.
+const tuiKeys = "p pause/resume  q quit"
+
+// readKeys acts on the -tui keys typed on stdin until the process exits:
+// p or space pauses and resumes p, q stops the run as Ctrl+C does. It
+// returns the function that gives the terminal back its line mode.
+func readKeys(p *fileprocessor.Processor, cancel context.CancelFunc) (restore func()) {
+	restore, err := cbreak(os.Stdin)
+	if err != nil {
+		restore = func() {}
+	}
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(buf); err != nil {
+				return
+			}
+			switch buf[0] {
+			case 'p', ' ':
+				if p.Paused() {
+					p.Resume()
+				} else {
+					p.Pause()
+				}
+			case 'q':
+				cancel()
+				return
+			}
+		}
+	}()
+	return restore
+}
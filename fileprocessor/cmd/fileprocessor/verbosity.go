@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fileprocessor"
+	"fileprocessor/report"
+)
+
+// console is the console reporter split between its per-file lines,
+// which are results and go to stdout, and the metrics lines and summary,
+// which go to stderr.
+type console struct {
+	files, diag *report.Console
+}
+
+func (c console) Report(s fileprocessor.Snapshot) { c.diag.Report(s) }
+
+func (c console) ReportFile(res fileprocessor.Result) { c.files.ReportFile(res) }
+
+func (c console) ReportSummary(s fileprocessor.Summary) { c.diag.ReportSummary(s) }
+
+// SetColor colors the summary; the per-file lines have no colors.
+func (c console) SetColor(on bool) { c.diag.SetColor(on) }
+
+// summaryOnly is what -quiet leaves of the console and progress
+// reporters: the summary.
+type summaryOnly struct {
+	r fileprocessor.SummaryReporter
+}
+
+func (summaryOnly) Report(fileprocessor.Snapshot) {}
+
+func (q summaryOnly) ReportSummary(s fileprocessor.Summary) { q.r.ReportSummary(s) }
+
+// withoutFiles is the console reporter without its per-file lines, which
+// only -verbose prints.
+type withoutFiles struct {
+	r interface {
+		fileprocessor.Reporter
+		fileprocessor.SummaryReporter
+	}
+}
+
+func (w withoutFiles) Report(s fileprocessor.Snapshot) { w.r.Report(s) }
+
+func (w withoutFiles) ReportSummary(s fileprocessor.Summary) { w.r.ReportSummary(s) }
+
+// withoutSnapshots is a reporter with its metrics lines, progress bar or
+// other use of snapshots turned off by -metrics-interval 0.
+type withoutSnapshots struct {
+	r fileprocessor.Reporter
+}
+
+func (withoutSnapshots) Report(fileprocessor.Snapshot) {}
+
+func (w withoutSnapshots) ReportFile(res fileprocessor.Result) {
+	if r, ok := w.r.(fileprocessor.FileReporter); ok {
+		r.ReportFile(res)
+	}
+}
+
+func (w withoutSnapshots) ReportSummary(s fileprocessor.Summary) {
+	if r, ok := w.r.(fileprocessor.SummaryReporter); ok {
+		r.ReportSummary(s)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moment is a flag.Value for a point in time, given either as an age
+// relative to now (90m, 24h, 7d, 2w) or as a date or time such as
+// 2023-01-01, 2023-01-01T15:04:05 (both local time) or an RFC 3339
+// timestamp.
+type moment struct{ t time.Time }
+
+var momentLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func (m *moment) String() string {
+	if m.t.IsZero() {
+		return ""
+	}
+	return m.t.Format(time.RFC3339)
+}
+
+func (m *moment) Set(v string) error {
+	v = strings.TrimSpace(v)
+	for _, layout := range momentLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			m.t = t
+			return nil
+		}
+	}
+	age, err := parseAge(v)
+	if err != nil {
+		return fmt.Errorf("invalid time %q: want an age such as 24h or 7d, or a date such as 2023-01-01", v)
+	}
+	m.t = time.Now().Add(-age)
+	return nil
+}
+
+// parseAge is time.ParseDuration with d (days) and w (weeks) added.
+func parseAge(v string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if num, ok := strings.CutSuffix(v, suffix); ok {
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("invalid age %q", v)
+			}
+			return time.Duration(f * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", v)
+	}
+	return d, nil
+}
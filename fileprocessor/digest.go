@@ -0,0 +1,95 @@
+package fileprocessor
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DigestEncoding selects how HashHandler writes Result.Hash. The zero
+// value is lowercase hex, as printed by sha256sum.
+type DigestEncoding string
+
+const (
+	EncodingHex DigestEncoding = "hex"
+	// EncodingBase64 is standard, padded base64 (RFC 4648 section 4), as
+	// used by Subresource Integrity.
+	EncodingBase64 DigestEncoding = "base64"
+	// EncodingBase64URL is the unpadded URL-safe alphabet (RFC 4648
+	// section 5), as used in JWS and most URL-embedded digests.
+	EncodingBase64URL DigestEncoding = "base64url"
+	// EncodingBase32 is standard, padded, uppercase base32.
+	EncodingBase32 DigestEncoding = "base32"
+)
+
+// multibasePrefix maps each encoding to its multibase code. Multibase
+// variants are named "multibase-<encoding>" and follow the multibase
+// table: lowercase where it distinguishes case, never padded.
+var multibasePrefix = map[DigestEncoding]string{
+	EncodingHex:       "f",
+	EncodingBase64:    "m",
+	EncodingBase64URL: "u",
+	EncodingBase32:    "b",
+}
+
+// DigestEncodings lists the names ParseDigestEncoding accepts.
+func DigestEncodings() []string {
+	bases := []string{"hex", "base64", "base64url", "base32"}
+	names := bases
+	for _, name := range bases {
+		names = append(names, "multibase-"+name)
+	}
+	return names
+}
+
+// ParseDigestEncoding checks s against DigestEncodings. The empty string
+// means hex.
+func ParseDigestEncoding(s string) (DigestEncoding, error) {
+	if s == "" {
+		return EncodingHex, nil
+	}
+	if _, ok := multibasePrefix[DigestEncoding(strings.TrimPrefix(s, "multibase-"))]; !ok {
+		return "", fmt.Errorf("unknown digest encoding %q", s)
+	}
+	return DigestEncoding(s), nil
+}
+
+// Encode formats a raw digest.
+func (e DigestEncoding) Encode(digest []byte) string {
+	base, multibase := strings.CutPrefix(string(e), "multibase-")
+	if !multibase {
+		switch DigestEncoding(base) {
+		case EncodingBase64:
+			return base64.StdEncoding.EncodeToString(digest)
+		case EncodingBase64URL:
+			return base64.RawURLEncoding.EncodeToString(digest)
+		case EncodingBase32:
+			return base32.StdEncoding.EncodeToString(digest)
+		}
+		return hex.EncodeToString(digest)
+	}
+
+	var s string
+	switch DigestEncoding(base) {
+	case EncodingBase64:
+		s = base64.RawStdEncoding.EncodeToString(digest)
+	case EncodingBase64URL:
+		s = base64.RawURLEncoding.EncodeToString(digest)
+	case EncodingBase32:
+		s = strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(digest))
+	default:
+		s = hex.EncodeToString(digest)
+	}
+	return multibasePrefix[DigestEncoding(base)] + s
+}
+
+// EncodeDigest re-encodes a hex digest, such as Summary.Fingerprint.
+func EncodeDigest(hexDigest string, e DigestEncoding) (string, error) {
+	digest, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		return "", err
+	}
+	return e.Encode(digest), nil
+}
@@ -0,0 +1,131 @@
+package fileprocessor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"fileprocessor/manifest"
+)
+
+// DirStat rolls up the files below one directory, at any depth: how many
+// were processed and failed, their total size, and Hash, the SHA256 of a
+// sha256sum-style manifest of the processed files with paths relative to
+// the directory, sorted bytewise. When a single directory is walked, its
+// own Hash is Summary.Fingerprint; a subtree whose Hash is unchanged
+// between two runs holds the same names and contents.
+type DirStat struct {
+	Path   string
+	Files  int64
+	Bytes  int64
+	Failed int64
+	Hash   string
+}
+
+// dirStats collects the files of each walked root for Summary.DirStats.
+type dirStats struct {
+	enabled bool
+
+	mu    sync.Mutex
+	roots []map[string]dirFile
+}
+
+type dirFile struct {
+	size   int64
+	hash   string
+	failed bool
+}
+
+// add records the file at rel, a slash-separated path relative to root i.
+func (d *dirStats) add(i int, rel string, res Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.roots) <= i {
+		d.roots = append(d.roots, nil)
+	}
+	if d.roots[i] == nil {
+		d.roots[i] = make(map[string]dirFile)
+	}
+	d.roots[i][rel] = dirFile{size: res.Size, hash: res.Hash, failed: res.Err != nil}
+}
+
+// stats returns a DirStat for every directory holding files of root i,
+// sorted by path, with top as the root's path.
+func (d *dirStats) stats(i int, top string) []DirStat {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i >= len(d.roots) || len(d.roots[i]) == 0 {
+		return nil
+	}
+	files := d.roots[i]
+	rels := make([]string, 0, len(files))
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	// Files sorted by their path below top are also sorted by their path
+	// below each directory, so every manifest is hashed in a single pass.
+	type acc struct {
+		DirStat
+		h hash.Hash
+	}
+	dirs := make(map[string]*acc)
+	for _, rel := range rels {
+		f := files[rel]
+		for dir := path.Dir(rel); ; dir = path.Dir(dir) {
+			a, ok := dirs[dir]
+			if !ok {
+				a = &acc{DirStat: DirStat{Path: filepath.Join(top, filepath.FromSlash(dir))}, h: sha256.New()}
+				dirs[dir] = a
+			}
+			if f.failed {
+				a.Failed++
+			} else {
+				a.Files++
+				a.Bytes += f.size
+				name := rel
+				if dir != "." {
+					name = strings.TrimPrefix(rel, dir+"/")
+				}
+				a.h.Write([]byte(manifest.Format(f.hash, name) + "\n"))
+			}
+			if dir == "." {
+				break
+			}
+		}
+	}
+	out := make([]DirStat, 0, len(dirs))
+	for _, a := range dirs {
+		a.Hash = hex.EncodeToString(a.h.Sum(nil))
+		out = append(out, a.DirStat)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// recordDirStats adds a result to the directory statistics if they are
+// being collected.
+func (p *Processor) recordDirStats(path string, res Result) {
+	if !p.dirStats.enabled {
+		return
+	}
+	if i, rel, ok := p.rootOf(path); ok {
+		p.dirStats.add(i, filepath.ToSlash(rel), res)
+	}
+}
+
+// allDirStats returns the directory statistics of every walked root in
+// turn.
+func (p *Processor) allDirStats() []DirStat {
+	var out []DirStat
+	for i, root := range p.roots() {
+		out = append(out, p.dirStats.stats(i, root)...)
+	}
+	return out
+}
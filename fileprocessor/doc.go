@@ -0,0 +1,60 @@
+// Package fileprocessor scans directory trees and processes every file
+// concurrently on an autoscaling pool of worker goroutines.
+//
+// A Processor is configured with functional options and driven by Run:
+//
+//	p := fileprocessor.New(fileprocessor.WithDir("/data"), fileprocessor.WithWorkers(8))
+//	summary, err := p.Run(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(summary.Processed, "files,", summary.Bytes, "bytes")
+//
+// # Extension points
+//
+// These interfaces make up the extension surface of the package:
+//
+//   - FileHandler does the per-file work (hashing, copying, scanning).
+//     Middleware wraps handlers and Pipeline chains several stages.
+//   - Walker decides which files are processed.
+//   - Reporter receives periodic Snapshots of a running Processor, and
+//     per-file Results and the final Summary if it also implements
+//     FileReporter or SummaryReporter.
+//   - Metrics receives every counter and observation a run records.
+//   - The lifecycle hooks (OnStart, OnFile, OnError, OnComplete) observe
+//     a run without changing it.
+//   - Tracer receives timed spans of the walk, the queue and every file.
+//
+// # Compatibility
+//
+// This is version 1 of the API (see Version). Every exported identifier
+// of this package and of the pool, clock, report, manifest, remote, otlp,
+// statsd and fileprocessortest packages follows the Go 1 compatibility promise within major version 1:
+// it will not be removed or changed incompatibly. New fields may be added to
+// structs and new methods to concrete types, so use keyed struct
+// literals. The interfaces listed above will not gain methods; new
+// optional capabilities are added as separate interfaces that
+// implementations may choose to satisfy.
+//
+// Anything below internal/ is an implementation detail and can change in
+// any release. Identifiers scheduled for removal are marked with a
+// "Deprecated:" paragraph, keep working for at least two minor releases
+// and are only deleted in a new major version.
+package fileprocessor
+
+// Version is the semantic version of the fileprocessor API.
+const Version = "1.0.0"
+
+// Compile-time checks that the built-in implementations satisfy the
+// public interfaces.
+var (
+	_ FileHandler = HandlerFunc(nil)
+	_ FileHandler = HashHandler{}
+	_ FileHandler = StatHandler{}
+	_ FileHandler = CopyHandler{}
+	_ FileHandler = (*Pipeline)(nil)
+	_ Walker      = WalkerFunc(nil)
+	_ Walker      = (*treeWalker)(nil)
+	_ Reporter    = nopReporter{}
+	_ Metrics     = (*MemoryMetrics)(nil)
+)
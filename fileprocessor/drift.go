@@ -0,0 +1,96 @@
+package fileprocessor
+
+import (
+	"sort"
+	"sync"
+)
+
+// Drift classifies the files of a run against the baseline given to
+// WithBaseline. Files that failed are in none of the lists.
+type Drift struct {
+	Unchanged int
+	// Modified and Added hold paths from this run and Deleted paths as
+	// spelled in the baseline, each sorted.
+	Modified []string
+	Added    []string
+	Deleted  []string
+}
+
+// Changed reports whether any file was modified, added or deleted.
+func (d *Drift) Changed() bool {
+	return len(d.Modified)+len(d.Added)+len(d.Deleted) > 0
+}
+
+// drift matches results against a baseline during a Run. Paths on both
+// sides are compared as named by Processor.relPath, so a baseline written
+// by sha256sum inside the tree matches a run over it.
+type drift struct {
+	baseline map[string]string // relative path -> hash
+	names    map[string]string // relative path -> baseline spelling
+
+	mu         sync.Mutex
+	seen       map[string]bool
+	unchanged  int
+	modified   []string
+	added      []string
+	incomplete bool
+}
+
+func (d *drift) setBaseline(hashes map[string]string, relPath func(string) string) {
+	d.baseline = make(map[string]string, len(hashes))
+	d.names = make(map[string]string, len(hashes))
+	for path, hash := range hashes {
+		rel := relPath(path)
+		d.baseline[rel], d.names[rel] = hash, path
+	}
+}
+
+// add classifies res, whose path is rel as named by relPath.
+func (d *drift) add(rel string, res Result) {
+	if d.baseline == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[string]bool)
+	}
+	d.seen[rel] = true
+	if res.Err != nil {
+		return
+	}
+	switch hash, ok := d.baseline[rel]; {
+	case !ok:
+		d.added = append(d.added, res.Path)
+	case hash != res.Hash:
+		d.modified = append(d.modified, res.Path)
+	default:
+		d.unchanged++
+	}
+}
+
+// report returns nil without a baseline. Baseline files are only counted
+// as deleted after a complete walk; an interrupted run hasn't looked.
+func (d *drift) report() *Drift {
+	if d.baseline == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := &Drift{
+		Unchanged: d.unchanged,
+		Modified:  append([]string(nil), d.modified...),
+		Added:     append([]string(nil), d.added...),
+	}
+	if !d.incomplete {
+		for rel, name := range d.names {
+			if !d.seen[rel] {
+				r.Deleted = append(r.Deleted, name)
+			}
+		}
+	}
+	sort.Strings(r.Modified)
+	sort.Strings(r.Added)
+	sort.Strings(r.Deleted)
+	return r
+}
@@ -0,0 +1,75 @@
+package fileprocessor
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// DuplicateGroup is a set of two or more processed files with the same
+// size and hash, that is, copies of the same content.
+type DuplicateGroup struct {
+	Algorithm string
+	Hash      string
+	// Size is the size of each copy.
+	Size int64
+	// Paths lists the copies, sorted.
+	Paths []string
+}
+
+// Wasted returns the bytes that every copy but one takes up.
+func (g DuplicateGroup) Wasted() int64 {
+	return g.Size * int64(len(g.Paths)-1)
+}
+
+// duplicates collects the paths of every hashed file for
+// Summary.Duplicates.
+type duplicates struct {
+	enabled bool
+
+	mu    sync.Mutex
+	paths map[duplicateKey][]string
+}
+
+type duplicateKey struct {
+	algorithm, hash string
+	size            int64
+}
+
+// add records a successfully processed file. Empty files are all equal
+// and left out, as are files without a hash.
+func (d *duplicates) add(path string, res Result) {
+	if !d.enabled || res.Hash == "" || res.Size == 0 {
+		return
+	}
+	key := duplicateKey{res.Algorithm, res.Hash, res.Size}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.paths == nil {
+		d.paths = make(map[duplicateKey][]string)
+	}
+	d.paths[key] = append(d.paths[key], path)
+}
+
+// groups returns every group of two or more files, the one wasting the
+// most bytes first and ties by hash.
+func (d *duplicates) groups() []DuplicateGroup {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var groups []DuplicateGroup
+	for key, paths := range d.paths {
+		if len(paths) < 2 {
+			continue
+		}
+		groups = append(groups, DuplicateGroup{
+			Algorithm: key.algorithm,
+			Hash:      key.hash,
+			Size:      key.size,
+			Paths:     slices.Sorted(slices.Values(paths)),
+		})
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int {
+		return cmp.Or(cmp.Compare(b.Wasted(), a.Wasted()), cmp.Compare(a.Hash, b.Hash), cmp.Compare(a.Algorithm, b.Algorithm))
+	})
+	return groups
+}
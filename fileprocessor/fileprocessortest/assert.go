@@ -0,0 +1,87 @@
+package fileprocessortest
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"fileprocessor"
+)
+
+// Collect runs p to completion and returns every Result sorted by path,
+// failing the test if the run itself errors.
+func Collect(tb testing.TB, ctx context.Context, p *fileprocessor.Processor) []fileprocessor.Result {
+	tb.Helper()
+	var results []fileprocessor.Result
+	for res, err := range p.All(ctx) {
+		if res.Path == "" && err != nil {
+			tb.Fatalf("run failed: %v", err)
+		}
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results
+}
+
+// ExpectPaths fails the test unless results cover exactly paths.
+func ExpectPaths(tb testing.TB, results []fileprocessor.Result, paths ...string) {
+	tb.Helper()
+	want := append([]string(nil), paths...)
+	sort.Strings(want)
+	got := make([]string, len(results))
+	for i, r := range results {
+		got[i] = r.Path
+	}
+	sort.Strings(got)
+	if len(got) != len(want) {
+		tb.Fatalf("got %d results %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			tb.Fatalf("got paths %v, want %v", got, want)
+		}
+	}
+}
+
+// ExpectHashes fails the test unless every path in want has the given
+// digest.
+func ExpectHashes(tb testing.TB, results []fileprocessor.Result, want map[string]string) {
+	tb.Helper()
+	byPath := make(map[string]fileprocessor.Result, len(results))
+	for _, r := range results {
+		byPath[r.Path] = r
+	}
+	for path, hash := range want {
+		r, ok := byPath[path]
+		switch {
+		case !ok:
+			tb.Errorf("%s: no result", path)
+		case r.Err != nil:
+			tb.Errorf("%s: unexpected error %v", path, r.Err)
+		case r.Hash != hash:
+			tb.Errorf("%s: hash %s, want %s", path, r.Hash, hash)
+		}
+	}
+}
+
+// ExpectNoErrors fails the test if any result carries an error.
+func ExpectNoErrors(tb testing.TB, results []fileprocessor.Result) {
+	tb.Helper()
+	for _, r := range results {
+		if r.Err != nil {
+			tb.Errorf("%s: unexpected error %v", r.Path, r.Err)
+		}
+	}
+}
+
+// ExpectFailed fails the test unless exactly paths failed.
+func ExpectFailed(tb testing.TB, results []fileprocessor.Result, paths ...string) {
+	tb.Helper()
+	var failed []fileprocessor.Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	ExpectPaths(tb, failed, paths...)
+}
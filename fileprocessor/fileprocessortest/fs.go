@@ -0,0 +1,47 @@
+// Package fileprocessortest provides utilities for testing code that
+// embeds a fileprocessor.Processor: an in-memory filesystem builder, a
+// deterministic clock and assertions on emitted results.
+package fileprocessortest
+
+import (
+	"io/fs"
+	"testing/fstest"
+	"time"
+)
+
+// FSBuilder assembles an in-memory filesystem for WithFS.
+type FSBuilder struct {
+	fs fstest.MapFS
+}
+
+// NewFS returns an empty FSBuilder.
+func NewFS() *FSBuilder {
+	return &FSBuilder{fs: fstest.MapFS{}}
+}
+
+// File adds a regular file with the given contents.
+func (b *FSBuilder) File(name, contents string) *FSBuilder {
+	return b.Bytes(name, []byte(contents))
+}
+
+// Bytes adds a regular file with binary contents.
+func (b *FSBuilder) Bytes(name string, data []byte) *FSBuilder {
+	b.fs[name] = &fstest.MapFile{Data: data, Mode: 0o644}
+	return b
+}
+
+// Sized adds a regular file of n zero bytes.
+func (b *FSBuilder) Sized(name string, n int) *FSBuilder {
+	return b.Bytes(name, make([]byte, n))
+}
+
+// Entry adds a file with full control over mode and modification time.
+func (b *FSBuilder) Entry(name string, data []byte, mode fs.FileMode, modTime time.Time) *FSBuilder {
+	b.fs[name] = &fstest.MapFile{Data: data, Mode: mode, ModTime: modTime}
+	return b
+}
+
+// Build returns the filesystem.
+func (b *FSBuilder) Build() fstest.MapFS {
+	return b.fs
+}
@@ -0,0 +1,264 @@
+package fileprocessor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fileprocessor/internal/expr"
+)
+
+// Filter decides whether a file found by the walk is processed. info
+// describes the file itself, with links resolved, or is nil if the file
+// couldn't be looked up; filters should keep such files so that the
+// handler reports the problem. Filters are called from the walk, before
+// anything is queued, and must be safe for concurrent use.
+type Filter func(path string, info fs.FileInfo) bool
+
+// MatchRegexp keeps files whose path matches at least one of res. Paths
+// are matched in full as the Walker reports them, with forward slashes on
+// every platform.
+func MatchRegexp(res ...*regexp.Regexp) Filter {
+	return func(path string, _ fs.FileInfo) bool {
+		path = filepath.ToSlash(path)
+		for _, re := range res {
+			if re.MatchString(path) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SkipRegexp drops files whose path matches any of res, matched like
+// MatchRegexp.
+func SkipRegexp(res ...*regexp.Regexp) Filter {
+	match := MatchRegexp(res...)
+	return func(path string, info fs.FileInfo) bool {
+		return !match(path, info)
+	}
+}
+
+// SizeRange keeps files of at least min and at most max bytes. A max of
+// 0 or less sets no upper limit.
+func SizeRange(min, max int64) Filter {
+	return func(_ string, info fs.FileInfo) bool {
+		if info == nil {
+			return true
+		}
+		size := info.Size()
+		return size >= min && (max <= 0 || size <= max)
+	}
+}
+
+// ModifiedBetween keeps files last modified after after and before
+// before. A zero time leaves that side open.
+func ModifiedBetween(after, before time.Time) Filter {
+	return func(_ string, info fs.FileInfo) bool {
+		if info == nil {
+			return true
+		}
+		mtime := info.ModTime()
+		return (after.IsZero() || mtime.After(after)) && (before.IsZero() || mtime.Before(before))
+	}
+}
+
+// OwnedBy keeps files whose owner has user id uid. Files without Unix
+// ownership, such as on Windows, are dropped.
+func OwnedBy(uid uint32) Filter {
+	return func(_ string, info fs.FileInfo) bool {
+		if info == nil {
+			return true
+		}
+		owner, _, ok := fileOwner(info)
+		return ok && owner == uid
+	}
+}
+
+// InGroup keeps files whose group has id gid, dropping those without Unix
+// ownership like OwnedBy.
+func InGroup(gid uint32) Filter {
+	return func(_ string, info fs.FileInfo) bool {
+		if info == nil {
+			return true
+		}
+		_, group, ok := fileOwner(info)
+		return ok && group == gid
+	}
+}
+
+// Permissions returns a filter on permission bits written as for
+// find -perm: MODE keeps files whose mode is exactly MODE, -MODE those
+// with all of its bits set and /MODE those with any of them. MODE is
+// octal (644, 4000) or symbolic (u+x, o+w, g=rw, a+s), with clauses
+// separated by commas.
+func Permissions(spec string) (Filter, error) {
+	how, modeSpec := byte(0), spec
+	if spec != "" && (spec[0] == '-' || spec[0] == '/') {
+		how, modeSpec = spec[0], spec[1:]
+	}
+	want, err := parseMode(modeSpec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid permissions %q: %w", spec, err)
+	}
+	return func(_ string, info fs.FileInfo) bool {
+		if info == nil {
+			return true
+		}
+		mode := unixMode(info.Mode())
+		switch how {
+		case '-':
+			return mode&want == want
+		case '/':
+			return want == 0 || mode&want != 0
+		}
+		return mode == want
+	}, nil
+}
+
+// Expression compiles src, a boolean expression over a file's attributes,
+// into a filter:
+//
+//	size > 1MB && ext in ["log", "gz"] && mtime > now() - 72h
+//
+// The attributes are path, name, dir and ext (lower case, without the
+// dot), size in bytes, mtime, age (now() - mtime), mode (octal permission
+// bits, 0o644) and uid and gid, which are -1 without Unix ownership.
+// Expressions combine them with && || !, the comparisons == != < <= > >=,
+// in and not in (a list, or a substring of a string) and + - on numbers,
+// times and durations. Numbers take binary size units (64K, 1.5MB, 2GiB)
+// or duration units (ns us ms s m h d w); note that 1M is a MiB but 1m a
+// minute. The functions are now(), date("2006-01-02"), matches(s, re),
+// glob(s, pattern) and lower(s). now() is the time Expression was called.
+func Expression(src string) (Filter, error) {
+	prog, err := expr.Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", src, err)
+	}
+	now := time.Now()
+	return func(path string, info fs.FileInfo) bool {
+		if info == nil {
+			return true
+		}
+		env := expr.Env{
+			Path:  filepath.ToSlash(path),
+			Size:  info.Size(),
+			MTime: info.ModTime(),
+			Mode:  unixMode(info.Mode()),
+			UID:   -1,
+			GID:   -1,
+			Now:   now,
+		}
+		if uid, gid, ok := fileOwner(info); ok {
+			env.UID, env.GID = int64(uid), int64(gid)
+		}
+		return prog.Eval(&env)
+	}, nil
+}
+
+// parseMode reads an octal or symbolic mode as Unix permission bits.
+func parseMode(s string) (uint32, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty mode")
+	}
+	if s[0] >= '0' && s[0] <= '7' {
+		n, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || n > 0o7777 {
+			return 0, fmt.Errorf("bad octal mode")
+		}
+		return uint32(n), nil
+	}
+	var mode uint32
+	for _, clause := range strings.Split(s, ",") {
+		who := strings.TrimLeft(clause, "ugoa")
+		users := clause[:len(clause)-len(who)]
+		if users == "" || strings.Contains(users, "a") {
+			users = "ugo"
+		}
+		if who == "" || who[0] != '+' && who[0] != '=' {
+			return 0, fmt.Errorf("clause %q: want [ugoa]+perms", clause)
+		}
+		for _, perm := range who[1:] {
+			for _, u := range users {
+				bit, ok := permBit(u, perm)
+				if !ok {
+					return 0, fmt.Errorf("clause %q: unknown permission %q", clause, perm)
+				}
+				mode |= bit
+			}
+		}
+	}
+	return mode, nil
+}
+
+// permBit returns the Unix mode bit of permission perm for user class u.
+// Setuid and setgid apply to u and g only, and the sticky bit to o.
+func permBit(u, perm rune) (uint32, bool) {
+	shift := map[rune]uint{'u': 6, 'g': 3, 'o': 0}[u]
+	switch perm {
+	case 'r':
+		return 4 << shift, true
+	case 'w':
+		return 2 << shift, true
+	case 'x':
+		return 1 << shift, true
+	case 's':
+		return map[rune]uint32{'u': 0o4000, 'g': 0o2000}[u], true
+	case 't':
+		if u == 'o' {
+			return 0o1000, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// admit decides whether a file the walk found is queued: special files
+// are handled per WithSpecialFiles, then every filter must keep the file.
+// err, set for SpecialFail, ends the walk.
+func (p *Processor) admit(path string, info fs.FileInfo) (ok bool, err error) {
+	checkSpecial := p.special == SpecialSkip || p.special == SpecialFail
+	if !checkSpecial && len(p.filters) == 0 {
+		return true, nil
+	}
+	info = p.lookup(path, info)
+	if checkSpecial && info != nil && isSpecial(info.Mode()) {
+		if p.special == SpecialFail {
+			return false, fmt.Errorf("%s: %w", path, ErrSpecialFile)
+		}
+		return false, nil
+	}
+	for _, keep := range p.filters {
+		if !keep(path, info) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lookup returns info if it describes a file and otherwise stats path,
+// following links. It returns nil for URLs and archive members, and for
+// files that can't be looked up.
+func (p *Processor) lookup(path string, info fs.FileInfo) fs.FileInfo {
+	if info != nil && info.Mode()&fs.ModeSymlink == 0 {
+		return info
+	}
+	if IsURL(path) {
+		return nil
+	}
+	var err error
+	if p.fsys != nil {
+		info, err = fs.Stat(p.fsys, path)
+	} else {
+		info, err = os.Stat(path)
+	}
+	if err != nil {
+		return nil
+	}
+	return info
+}
@@ -0,0 +1,94 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const defaultFilterBatch = 1000
+
+// filterCmd runs the command set by WithFilterCommand on batches of the
+// files the walk admits: it writes their paths to the command's standard
+// input, each ended by a NUL byte, and passes on the files whose paths the
+// command writes back the same way, in walk order.
+type filterCmd struct {
+	name  string
+	args  []string
+	batch int
+
+	mu      sync.Mutex
+	pending []sampled
+}
+
+// add holds a file for the next batch and runs the command once the
+// batch is full. keep is called for the files the command passes and
+// drop for the others.
+func (c *filterCmd) add(ctx context.Context, path string, info fs.FileInfo, keep func(string, fs.FileInfo) error, drop func()) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, sampled{path: path, info: info})
+	if len(c.pending) < c.batch {
+		return nil
+	}
+	return c.run(ctx, keep, drop)
+}
+
+// flush runs the command on the files still held.
+func (c *filterCmd) flush(ctx context.Context, keep func(string, fs.FileInfo) error, drop func()) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return nil
+	}
+	return c.run(ctx, keep, drop)
+}
+
+func (c *filterCmd) run(ctx context.Context, keep func(string, fs.FileInfo) error, drop func()) error {
+	files := c.pending
+	c.pending = nil
+
+	var in bytes.Buffer
+	for _, f := range files {
+		in.WriteString(f.path)
+		in.WriteByte(0)
+	}
+	cmd := exec.CommandContext(ctx, c.name, c.args...)
+	cmd.Stdin = &in
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 && len(out) == 0 {
+		err = nil // passes none, as grep reports no match
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if exit != nil && len(exit.Stderr) > 0 {
+			return fmt.Errorf("filter command %s: %w: %s", c.name, err, strings.TrimSpace(string(exit.Stderr)))
+		}
+		return fmt.Errorf("filter command %s: %w", c.name, err)
+	}
+
+	passed := make(map[string]bool)
+	for _, p := range bytes.Split(out, []byte{0}) {
+		if len(p) > 0 {
+			passed[string(p)] = true
+		}
+	}
+	for _, f := range files {
+		if !passed[f.path] {
+			drop()
+			continue
+		}
+		if err := keep(f.path, f.info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
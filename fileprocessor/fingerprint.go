@@ -0,0 +1,60 @@
+package fileprocessor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"fileprocessor/manifest"
+)
+
+// fingerprint collects the content hash of every file during a Run and
+// reduces them to one digest for the whole tree.
+//
+// The digest is the SHA256 of a sha256sum-style manifest of the tree with
+// slash-separated paths relative to the walked directory (see relPath),
+// sorted bytewise.
+// It depends only on file names and contents, never on walk order, worker
+// count or the absolute location of the tree.
+type fingerprint struct {
+	enabled bool
+
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func (f *fingerprint) add(rel, hash string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.files == nil {
+		f.files = make(map[string]string)
+	}
+	f.files[rel] = hash
+}
+
+func (f *fingerprint) sum() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	paths := make([]string, 0, len(f.files))
+	for p := range f.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, p := range paths {
+		h.Write([]byte(manifest.Format(f.files[p], p) + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordFingerprint adds a successful result to the fingerprint if one is being built.
+func (p *Processor) recordFingerprint(res Result) {
+	if !p.fingerprint.enabled {
+		return
+	}
+	p.fingerprint.add(filepath.ToSlash(p.relPath(res.Path)), res.Hash)
+}
@@ -0,0 +1,60 @@
+package fileprocessor
+
+import (
+	"context"
+	"io/fs"
+	"os"
+)
+
+type fsKey struct{}
+
+// ContextWithFS returns a context whose Open and Stat calls resolve paths
+// in fsys. Processors configured with WithFS do this for every handler
+// call; it is exported so handlers can also be driven directly.
+func ContextWithFS(ctx context.Context, fsys fs.FS) context.Context {
+	return context.WithValue(ctx, fsKey{}, fsys)
+}
+
+// FSFromContext returns the fs.FS the Processor is reading from, or nil
+// when it reads the local disk.
+func FSFromContext(ctx context.Context) fs.FS {
+	fsys, _ := ctx.Value(fsKey{}).(fs.FS)
+	return fsys
+}
+
+// Open opens the file at path, either from the Processor's fs.FS or from
+// disk. With WithArchives it also opens archive members by their virtual
+// path, and with WithURLs it downloads http:// and https:// URLs. On disk
+// it refuses special files with ErrSpecialFile instead of blocking on
+// them. Handlers should use it instead of os.Open so they work with any
+// input source.
+func Open(ctx context.Context, path string) (fs.File, error) {
+	if a := archivesFromContext(ctx); a != nil {
+		if f, ok, err := a.open(path); ok {
+			return f, err
+		}
+	}
+	if u := urlsFromContext(ctx); u != nil && IsURL(path) {
+		return u.open(ctx, path)
+	}
+	if fsys := FSFromContext(ctx); fsys != nil {
+		return fsys.Open(path)
+	}
+	return openFile(path)
+}
+
+// Stat is the fs.FS-aware counterpart of os.Stat.
+func Stat(ctx context.Context, path string) (fs.FileInfo, error) {
+	if a := archivesFromContext(ctx); a != nil {
+		if info, ok, err := a.stat(path); ok {
+			return info, err
+		}
+	}
+	if u := urlsFromContext(ctx); u != nil && IsURL(path) {
+		return u.stat(ctx, path)
+	}
+	if fsys := FSFromContext(ctx); fsys != nil {
+		return fs.Stat(fsys, path)
+	}
+	return os.Stat(path)
+}
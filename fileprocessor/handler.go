@@ -0,0 +1,452 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fileprocessor/internal/fuzzy"
+	ihash "fileprocessor/internal/hash"
+)
+
+// Result describes the outcome of handling a single file.
+type Result struct {
+	Path string
+	Size int64
+	// ModTime is the modification time of the file when the handler read
+	// it, set by the built-in handlers. WithSkipUnchanged compares it with
+	// a previous run.
+	ModTime time.Time
+	// Hash is the hex-encoded digest of the file contents and Algorithm
+	// the name of the hash that produced it. Both are empty for handlers
+	// that don't hash.
+	Hash      string
+	Algorithm string
+	// Duration and Err are filled in by the Processor: how long the
+	// handler ran and the error it returned, if any.
+	Duration time.Duration
+	Err      error
+	// Merkle is the file's chunk hash tree, set by a HashHandler with
+	// Merkle enabled.
+	Merkle *MerkleTree
+	// Fuzzy is the similarity digest set by a HashHandler with Fuzzy
+	// enabled. It is empty for files too small or uniform to digest.
+	Fuzzy string
+	// Chunks lists the file's content-defined chunks when the HashHandler
+	// has ChunkAvg set.
+	Chunks []Chunk
+	// ContentType is the Content-Type the server sent for a URL input
+	// read with WithURLs, or the type ContentTypes sniffed.
+	ContentType string
+	// Links lists the other paths of the file, hard links to it found by
+	// a walk with WithHardlinks, in walk order.
+	Links []string
+}
+
+// FileHandler processes a single file. Handle is called concurrently from
+// every worker, so implementations must be safe for concurrent use.
+type FileHandler interface {
+	Handle(ctx context.Context, path string) (Result, error)
+}
+
+// ErrSkip is returned by a handler or middleware that decides to leave a
+// file out. The file counts as skipped rather than failed and produces no
+// Result.
+var ErrSkip = errors.New("skip file")
+
+// HandlerFunc adapts an ordinary function to the FileHandler interface.
+type HandlerFunc func(ctx context.Context, path string) (Result, error)
+
+// Handle calls f(ctx, path).
+func (f HandlerFunc) Handle(ctx context.Context, path string) (Result, error) {
+	return f(ctx, path)
+}
+
+// defaultTreeThreshold is the smallest file HashHandler splits across
+// goroutines for tree hashes when no ChunkThreshold is set; below it the
+// coordination costs more than it saves.
+const defaultTreeThreshold = 4 << 20
+
+// HashHandler hashes file contents. The zero value uses SHA256.
+type HashHandler struct {
+	// New returns the hash used for every file. Defaults to sha256.New.
+	New func() hash.Hash
+	// Name is reported as Result.Algorithm. Defaults to "sha256" when
+	// New is nil.
+	Name string
+	// Workers is the number of goroutines used to hash a single large
+	// file that supports random access. Zero or one hashes every file
+	// sequentially.
+	Workers int
+	// ChunkThreshold is the size from which a file is split up for
+	// Workers. Tree hashes (blake3) still produce their usual digest and
+	// split from 4 MiB when ChunkThreshold is zero. Other algorithms only
+	// split when it is set: each 8 MiB chunk is hashed on its own and the
+	// digest is the hash of the concatenated chunk digests, reported with
+	// a "-chunked" suffix on Result.Algorithm since it differs from the
+	// plain digest.
+	ChunkThreshold int64
+	// Merkle also builds a MerkleTree over the file's chunks and sets
+	// Result.Merkle. The file is then read in a single sequential pass.
+	Merkle bool
+	// Fuzzy names a similarity digest from FuzzyAlgorithms ("ssdeep" or
+	// "tlsh") to compute alongside the hash into Result.Fuzzy. Like
+	// Merkle, it makes the handler read sequentially.
+	Fuzzy string
+	// ChunkAvg, when positive, also splits the file into content-defined
+	// chunks of about this many bytes (rounded down to a power of two) and
+	// lists them in Result.Chunks. Like Merkle, it makes the handler read
+	// sequentially.
+	ChunkAvg int
+	// Metadata mixes the selected file metadata into Hash, so the digest
+	// also changes when, say, permissions do. Result.Algorithm then
+	// carries the fields as a suffix, e.g. "sha256+mode,mtime".
+	Metadata MetadataField
+	// Sample, when positive, hashes only Sample bytes each from the start,
+	// middle and end of the file plus its size, reported with a
+	// "-sampled" suffix on Result.Algorithm. It is far faster than a full
+	// hash on large files and meant as a pre-pass for duplicate search:
+	// files with different sampled digests differ, equal ones may not
+	// (see SampleCandidates). Merkle, Fuzzy, ChunkAvg and Workers don't
+	// apply to sampled files.
+	Sample int64
+	// Encoding sets how Result.Hash is written. It defaults to lowercase
+	// hex; Merkle roots and chunk hashes stay in hex regardless.
+	Encoding DigestEncoding
+
+	// gitBlob prefixes the digest input with git's blob header. It is set
+	// by NewHashHandler for the git-* algorithms.
+	gitBlob bool
+}
+
+// NewHashHandler returns a HashHandler for one of the algorithms listed by
+// HashAlgorithms, or for "auto", the fastest cryptographic hash on this
+// machine (see ResolveAlgorithm).
+//
+// The git-sha1 and git-sha256 algorithms produce the object ids git
+// hash-object computes for the file as a blob, for reconciling a working
+// tree against a repository. They always hash sequentially.
+func NewHashHandler(algorithm string) (HashHandler, error) {
+	algorithm = ihash.Resolve(algorithm)
+	if newHash, ok := ihash.GitBlob(algorithm); ok {
+		return HashHandler{New: newHash, Name: algorithm, gitBlob: true}, nil
+	}
+	newHash, err := ihash.New(algorithm)
+	if err != nil {
+		return HashHandler{}, err
+	}
+	return HashHandler{New: newHash, Name: algorithm}, nil
+}
+
+// NewHMACHandler returns a HashHandler computing keyed HMAC digests with
+// one of the cryptographic algorithms listed by HashAlgorithms (md5, sha1,
+// sha256, sha512, sha3-256 or blake3), reported as "hmac-<algorithm>". A
+// manifest of HMACs can't be regenerated by someone who modified the files
+// but doesn't hold the key. The checksums (crc32c, xxh64, xxh3) and git
+// object hashes are refused: an HMAC is no stronger than its hash.
+func NewHMACHandler(algorithm string, key []byte) (HashHandler, error) {
+	algorithm = ihash.Resolve(algorithm)
+	newHash, err := ihash.New(algorithm)
+	_, git := ihash.GitBlob(algorithm)
+	switch {
+	case git:
+		return HashHandler{}, fmt.Errorf("HMAC is not supported for %s", algorithm)
+	case err == nil && !ihash.Cryptographic(algorithm):
+		return HashHandler{}, fmt.Errorf("HMAC is not supported for %s, which is not a cryptographic hash", algorithm)
+	case err != nil:
+		return HashHandler{}, err
+	}
+	if len(key) == 0 {
+		return HashHandler{}, errors.New("empty HMAC key")
+	}
+	key = bytes.Clone(key)
+	return HashHandler{
+		New:  func() hash.Hash { return hmac.New(newHash, key) },
+		Name: "hmac-" + algorithm,
+	}, nil
+}
+
+// HashAlgorithms lists the algorithm names NewHashHandler accepts.
+func HashAlgorithms() []string {
+	return ihash.Names()
+}
+
+// ResolveAlgorithm returns algorithm unchanged unless it is "auto", which
+// selects sha256 on CPUs with SHA-256 instructions (SHA-NI, Armv8) and
+// sha512 elsewhere on 64-bit platforms. Digests from "auto" are only
+// comparable between hosts that resolve it the same way.
+func ResolveAlgorithm(algorithm string) string {
+	return ihash.Resolve(algorithm)
+}
+
+// HashBackend names the implementation used for algorithm on this CPU,
+// such as "SHA-NI", "AVX2", "Armv8.0" or "generic". Hardware support can
+// make the same algorithm several times faster on one host than another.
+func HashBackend(algorithm string) string {
+	return ihash.Backend(ihash.Resolve(algorithm))
+}
+
+// Handle hashes the file at path.
+func (h HashHandler) Handle(ctx context.Context, path string) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	file, err := Open(ctx, path)
+	if err != nil {
+		return Result{}, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	newHash, name := h.New, h.Name
+	if newHash == nil {
+		newHash, name = sha256.New, ihash.Default
+	}
+
+	res, err := h.hash(ctx, file, path, name, newHash)
+	if err != nil {
+		return Result{}, err
+	}
+	if info, err := file.Stat(); err == nil {
+		res.ModTime = info.ModTime()
+	}
+	if h.Metadata != 0 {
+		if err := h.Metadata.apply(&res, file, newHash); err != nil {
+			return Result{}, fmt.Errorf("hash %s: %w", path, err)
+		}
+	}
+	if h.Encoding != "" && h.Encoding != EncodingHex {
+		if res.Hash, err = EncodeDigest(res.Hash, h.Encoding); err != nil {
+			return Result{}, fmt.Errorf("hash %s: %w", path, err)
+		}
+	}
+	return res, nil
+}
+
+// hash computes the content digest of file and everything configured to
+// be built alongside it.
+func (h HashHandler) hash(ctx context.Context, file fs.File, path, name string, newHash func() hash.Hash) (Result, error) {
+	if h.Sample > 0 {
+		digest, n, err := sampleDigest(ctx, file, h.Sample, newHash)
+		if err != nil {
+			return Result{}, fmt.Errorf("hash %s: %w", path, err)
+		}
+		return Result{Path: path, Size: n, Hash: digest, Algorithm: name + "-sampled"}, nil
+	}
+
+	// Merkle trees, fuzzy digests and chunk lists are built from the same
+	// read as the digest, which rules out splitting the file across
+	// goroutines.
+	var extra []io.Writer
+	var mw *merkleWriter
+	if h.Merkle {
+		mw = newMerkleWriter(newHash)
+		extra = append(extra, mw)
+	}
+	var fz fuzzy.Hasher
+	if h.Fuzzy != "" {
+		newFuzzy, err := fuzzy.New(h.Fuzzy)
+		if err != nil {
+			return Result{}, err
+		}
+		fz = newFuzzy()
+		extra = append(extra, fz)
+	}
+	var cw *chunkWriter
+	if h.ChunkAvg > 0 {
+		cw = newChunkWriter(h.ChunkAvg, newHash)
+		extra = append(extra, cw)
+	}
+
+	if len(extra) == 0 && !h.gitBlob {
+		if digest, algorithm, n, ok, err := h.sumParallel(ctx, file, name, newHash); ok {
+			if err != nil {
+				return Result{}, fmt.Errorf("hash %s: %w", path, err)
+			}
+			return Result{Path: path, Size: n, Hash: digest, Algorithm: algorithm}, nil
+		}
+	}
+
+	digestHash := newHash
+	var size int64
+	if h.gitBlob {
+		info, err := file.Stat()
+		if err != nil {
+			return Result{}, fmt.Errorf("hash %s: %w", path, err)
+		}
+		size = info.Size()
+		digestHash = func() hash.Hash {
+			d := newHash()
+			d.Write(ihash.GitBlobHeader(size))
+			return d
+		}
+	}
+
+	var r io.Reader = ctxReader{ctx, file}
+	if len(extra) > 0 {
+		r = io.TeeReader(r, io.MultiWriter(extra...))
+	}
+	digest, n, err := ihash.Sum(r, digestHash)
+	if err != nil {
+		return Result{}, fmt.Errorf("hash %s: %w", path, err)
+	}
+	if h.gitBlob && n != size {
+		return Result{}, fmt.Errorf("hash %s: file changed size while hashing", path)
+	}
+
+	res := Result{Path: path, Size: n, Hash: digest, Algorithm: name}
+	if mw != nil {
+		res.Merkle = mw.tree()
+	}
+	if fz != nil {
+		res.Fuzzy = fz.Digest()
+	}
+	if cw != nil {
+		res.Chunks = cw.list()
+	}
+	return res, nil
+}
+
+// sumParallel hashes file on h.Workers goroutines when the algorithm and
+// the file allow it. ok is false when the caller should hash sequentially.
+func (h HashHandler) sumParallel(ctx context.Context, file fs.File, name string, newHash func() hash.Hash) (digest, algorithm string, n int64, ok bool, err error) {
+	if h.Workers <= 1 {
+		return "", "", 0, false, nil
+	}
+	ra, isReaderAt := file.(io.ReaderAt)
+	if !isReaderAt {
+		return "", "", 0, false, nil
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return "", "", 0, false, nil
+	}
+	size := info.Size()
+
+	treeThreshold := h.ChunkThreshold
+	if treeThreshold <= 0 {
+		treeThreshold = defaultTreeThreshold
+	}
+	if size >= treeThreshold {
+		if digest, ok, err := ihash.SumParallel(ctx, name, ra, size, h.Workers); ok {
+			return digest, name, size, true, err
+		}
+	}
+
+	if h.ChunkThreshold <= 0 || size < h.ChunkThreshold {
+		return "", "", 0, false, nil
+	}
+	digest, err = ihash.SumChunks(ctx, ra, size, h.Workers, newHash)
+	return digest, name + "-chunked", size, true, err
+}
+
+// StatHandler records file sizes without reading contents.
+type StatHandler struct{}
+
+// Handle stats the file at path.
+func (StatHandler) Handle(ctx context.Context, path string) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	info, err := Stat(ctx, path)
+	if err != nil {
+		return Result{}, fmt.Errorf("stat %s: %w", path, err)
+	}
+	return Result{Path: path, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// CopyHandler copies every file below Root into Dest on disk, preserving
+// the relative directory layout. When reading from an fs.FS, Root is the
+// directory within the FS that was walked. Files outside Root, such as
+// those a -files-from list names, fail rather than land outside Dest, and
+// so does a file whose copy would overwrite the file itself.
+type CopyHandler struct {
+	Root string
+	Dest string
+}
+
+// Handle copies the file at path into the destination tree.
+func (c CopyHandler) Handle(ctx context.Context, path string) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	rel, err := filepath.Rel(c.Root, path)
+	if err != nil {
+		return Result{}, fmt.Errorf("copy %s: %w", path, err)
+	}
+	if !filepath.IsLocal(rel) {
+		return Result{}, fmt.Errorf("copy %s: not below %s", path, c.Root)
+	}
+	target := filepath.Join(c.Dest, rel)
+	if sameFile(path, target) {
+		return Result{}, fmt.Errorf("copy %s: would overwrite itself", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return Result{}, fmt.Errorf("copy %s: %w", path, err)
+	}
+
+	src, err := Open(ctx, path)
+	if err != nil {
+		return Result{}, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(target)
+	if err != nil {
+		return Result{}, fmt.Errorf("create %s: %w", target, err)
+	}
+	n, err := io.Copy(dst, ctxReader{ctx, src})
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("copy %s: %w", path, err)
+	}
+
+	res := Result{Path: path, Size: n}
+	if info, err := src.Stat(); err == nil {
+		res.ModTime = info.ModTime()
+	}
+	return res, nil
+}
+
+// sameFile reports whether the files a and b on disk are one and the same,
+// by path or through links.
+func sameFile(a, b string) bool {
+	if absA, err := filepath.Abs(a); err == nil {
+		if absB, err := filepath.Abs(b); err == nil && absA == absB {
+			return true
+		}
+	}
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
+
+// ctxReader stops a long copy as soon as ctx is done, so timeouts and
+// shutdown don't wait for a multi-GB file to finish.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
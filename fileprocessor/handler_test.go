@@ -0,0 +1,98 @@
+package fileprocessor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fileprocessor"
+)
+
+func TestCopyHandlerRefusesPathsOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	src, dst, other := filepath.Join(dir, "src"), filepath.Join(dir, "dst"), filepath.Join(dir, "other")
+	for _, d := range []string{src, dst, other} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outside := filepath.Join(other, "file")
+	if err := os.WriteFile(outside, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := fileprocessor.CopyHandler{Root: src, Dest: dst}
+	if _, err := h.Handle(context.Background(), outside); err == nil {
+		t.Fatal("copying a file outside Root succeeded")
+	}
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "keep me" {
+		t.Errorf("source after the refused copy = %q, %v; want it untouched", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "file")); !os.IsNotExist(err) {
+		t.Errorf("a copy landed outside Dest: %v", err)
+	}
+}
+
+func TestCopyHandlerRefusesToOverwriteTheSource(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dest is Root itself, once by the same name and once through a link.
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, dest := range []string{dir, link} {
+		h := fileprocessor.CopyHandler{Root: dir, Dest: dest}
+		if _, err := h.Handle(context.Background(), file); err == nil {
+			t.Errorf("Dest %s: copying a file onto itself succeeded", dest)
+		}
+		if data, err := os.ReadFile(file); err != nil || string(data) != "keep me" {
+			t.Fatalf("Dest %s: source after the refused copy = %q, %v; want it untouched", dest, data, err)
+		}
+	}
+}
+
+func TestCopyHandlerCopiesBelowRoot(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	file := filepath.Join(src, "sub", "file")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := fileprocessor.CopyHandler{Root: src, Dest: dst}
+	res, err := h.Handle(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Size != 4 {
+		t.Errorf("Size = %d, want 4", res.Size)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "sub", "file")); err != nil || string(data) != "data" {
+		t.Errorf("copy = %q, %v; want %q", data, err, "data")
+	}
+}
+
+func TestNewHMACHandlerRefusesNonCryptographicHashes(t *testing.T) {
+	key := []byte("key")
+	for _, alg := range []string{"crc32c", "xxh64", "xxh3", "git-sha1", "git-sha256"} {
+		if _, err := fileprocessor.NewHMACHandler(alg, key); err == nil {
+			t.Errorf("NewHMACHandler(%q) succeeded", alg)
+		}
+	}
+	for _, alg := range []string{"md5", "sha1", "sha256", "sha512", "sha3-256", "blake3"} {
+		h, err := fileprocessor.NewHMACHandler(alg, key)
+		if err != nil {
+			t.Errorf("NewHMACHandler(%q): %v", alg, err)
+		} else if h.Name != "hmac-"+alg {
+			t.Errorf("NewHMACHandler(%q).Name = %q", alg, h.Name)
+		}
+	}
+}
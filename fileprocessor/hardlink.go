@@ -0,0 +1,97 @@
+package fileprocessor
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// hardlinks folds the hard links of a file into one job, so that
+// WithHardlinks processes the file once. The first path the walk reports
+// is processed; its Result is held back until the walk has found every
+// link or ends, and then lists the others in Result.Links.
+type hardlinks struct {
+	mu      sync.Mutex
+	byID    map[linkKey]*linkGroup
+	primary map[string]*linkGroup
+}
+
+// linkGroup is one file with several links.
+type linkGroup struct {
+	nlink uint64
+	links []string // the paths after the first, in walk order
+	done  bool     // the first path's Result is in res
+	seq   int
+	res   Result
+}
+
+func newHardlinks() *hardlinks {
+	return &hardlinks{byID: make(map[linkKey]*linkGroup), primary: make(map[string]*linkGroup)}
+}
+
+// see records the walk reaching path. It reports whether path is another
+// link of a file already queued, and returns that file's Result if path
+// was the last link it was waiting for.
+func (h *hardlinks) see(path string, info fs.FileInfo) (dup bool, seq int, res *Result) {
+	if info == nil || !info.Mode().IsRegular() {
+		return false, 0, nil
+	}
+	key, nlink, ok := linkID(info)
+	if !ok || nlink < 2 {
+		return false, 0, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.byID[key]
+	if !ok {
+		g = &linkGroup{nlink: nlink}
+		h.byID[key] = g
+		h.primary[path] = g
+		return false, 0, nil
+	}
+	g.links = append(g.links, path)
+	if g.done && g.complete() {
+		return true, g.seq, h.release(g)
+	}
+	return true, 0, nil
+}
+
+// finish takes the Result of job seq. It reports false if the Result has
+// to wait for more links, which see or flush hand on later.
+func (h *hardlinks) finish(seq int, res Result) (Result, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.primary[res.Path]
+	if !ok {
+		return res, true
+	}
+	g.done, g.seq, g.res = true, seq, res
+	if !g.complete() {
+		return Result{}, false
+	}
+	return *h.release(g), true
+}
+
+// flush hands on every Result still waiting, once nothing is processing any
+// more.
+func (h *hardlinks) flush(out func(seq int, res Result)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, g := range h.byID {
+		if g.done {
+			out(g.seq, *h.release(g))
+		}
+	}
+}
+
+func (g *linkGroup) complete() bool {
+	return uint64(len(g.links))+1 >= g.nlink
+}
+
+// release returns g's Result with its links and forgets g.
+func (h *hardlinks) release(g *linkGroup) *Result {
+	res := g.res
+	res.Links = g.links
+	g.done = false
+	delete(h.primary, res.Path)
+	return &res
+}
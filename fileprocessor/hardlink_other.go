@@ -0,0 +1,12 @@
+//go:build !unix
+
+package fileprocessor
+
+import "io/fs"
+
+type linkKey struct{}
+
+// linkID reports no link count on platforms without Unix inodes.
+func linkID(fs.FileInfo) (key linkKey, nlink uint64, ok bool) {
+	return linkKey{}, 0, false
+}
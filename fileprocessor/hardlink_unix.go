@@ -0,0 +1,20 @@
+//go:build unix
+
+package fileprocessor
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+type linkKey struct{ dev, ino uint64 }
+
+// linkID identifies the file behind info and reports how many hard links
+// it has.
+func linkID(info fs.FileInfo) (key linkKey, nlink uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return linkKey{}, 0, false
+	}
+	return linkKey{uint64(st.Dev), uint64(st.Ino)}, uint64(st.Nlink), true
+}
@@ -0,0 +1,145 @@
+package fileprocessor
+
+import (
+	"sync"
+	"time"
+)
+
+// StartEvent is delivered to OnStart hooks when Run begins.
+type StartEvent struct {
+	// Dir is the first directory walked and Dirs all of them.
+	Dir     string
+	Dirs    []string
+	Workers int
+	Time    time.Time
+	// TotalFiles and TotalBytes are what WithPrescan counted, or zero.
+	TotalFiles int64
+	TotalBytes int64
+}
+
+// FileEvent is delivered to OnFile hooks for every successfully
+// processed file.
+type FileEvent struct {
+	Result Result
+	Worker int
+	Time   time.Time
+}
+
+// ErrorEvent is delivered to OnError hooks for every file that failed.
+type ErrorEvent struct {
+	Path   string
+	Err    error
+	Worker int
+	Time   time.Time
+}
+
+// CompleteEvent is delivered to OnComplete hooks when Run returns.
+type CompleteEvent struct {
+	Summary Summary
+	// Err is the error Run returns, if any.
+	Err  error
+	Time time.Time
+}
+
+// ScaleEvent is delivered to OnScale hooks for every decision of the
+// autoscaler, including to keep the number of workers as it is.
+type ScaleEvent struct {
+	// Action is "up", "down" or "keep", and From and To the number of
+	// workers before and after.
+	Action   string
+	From, To int
+	// Queue is the number of files waiting, of QueueSize.
+	Queue     int
+	QueueSize int
+	Time      time.Time
+}
+
+// hooks holds registered callbacks. Calls are serialized by mu, so hooks
+// never run concurrently with each other.
+type hooks struct {
+	mu         sync.Mutex
+	onStart    []func(StartEvent)
+	onFile     []func(FileEvent)
+	onError    []func(ErrorEvent)
+	onComplete []func(CompleteEvent)
+	onScale    []func(ScaleEvent)
+}
+
+// OnStart registers fn to be called when Run begins.
+//
+// Hooks are invoked one at a time, so they may share state without extra
+// locking, but OnFile and OnError run on worker goroutines and slow hooks
+// slow down processing. Hooks must be registered before Run.
+func (p *Processor) OnStart(fn func(StartEvent)) {
+	p.hooks.mu.Lock()
+	defer p.hooks.mu.Unlock()
+	p.hooks.onStart = append(p.hooks.onStart, fn)
+}
+
+// OnFile registers fn to be called after every successfully processed file.
+func (p *Processor) OnFile(fn func(FileEvent)) {
+	p.hooks.mu.Lock()
+	defer p.hooks.mu.Unlock()
+	p.hooks.onFile = append(p.hooks.onFile, fn)
+}
+
+// OnError registers fn to be called for every file that failed.
+func (p *Processor) OnError(fn func(ErrorEvent)) {
+	p.hooks.mu.Lock()
+	defer p.hooks.mu.Unlock()
+	p.hooks.onError = append(p.hooks.onError, fn)
+}
+
+// OnComplete registers fn to be called once when Run returns.
+func (p *Processor) OnComplete(fn func(CompleteEvent)) {
+	p.hooks.mu.Lock()
+	defer p.hooks.mu.Unlock()
+	p.hooks.onComplete = append(p.hooks.onComplete, fn)
+}
+
+// OnScale registers fn to be called for every decision of the autoscaler.
+func (p *Processor) OnScale(fn func(ScaleEvent)) {
+	p.hooks.mu.Lock()
+	defer p.hooks.mu.Unlock()
+	p.hooks.onScale = append(p.hooks.onScale, fn)
+}
+
+func (h *hooks) start(e StartEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, fn := range h.onStart {
+		fn(e)
+	}
+}
+
+func (h *hooks) file(e FileEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, fn := range h.onFile {
+		fn(e)
+	}
+}
+
+func (h *hooks) error(e ErrorEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, fn := range h.onError {
+		fn(e)
+	}
+}
+
+func (h *hooks) complete(e CompleteEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, fn := range h.onComplete {
+		fn(e)
+	}
+}
+
+func (h *hooks) scale(e ScaleEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, fn := range h.onScale {
+		fn(e)
+	}
+}
@@ -0,0 +1,256 @@
+// Package blake3 implements the BLAKE3 hash function, following the
+// reference implementation, plus a tree-parallel mode that hashes one
+// large input on several goroutines.
+package blake3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	// Size is the length of a BLAKE3 digest in bytes.
+	Size = 32
+	// BlockSize is the BLAKE3 block length in bytes.
+	BlockSize = 64
+
+	chunkLen = 1024
+
+	flagChunkStart = 1 << 0
+	flagChunkEnd   = 1 << 1
+	flagParent     = 1 << 2
+	flagRoot       = 1 << 3
+)
+
+var iv = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+var msgPermutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func g(s *[16]uint32, a, b, c, d int, mx, my uint32) {
+	s[a] = s[a] + s[b] + mx
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] = s[c] + s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] = s[a] + s[b] + my
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] = s[c] + s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
+
+func round(s *[16]uint32, m *[16]uint32) {
+	// Mix the columns.
+	g(s, 0, 4, 8, 12, m[0], m[1])
+	g(s, 1, 5, 9, 13, m[2], m[3])
+	g(s, 2, 6, 10, 14, m[4], m[5])
+	g(s, 3, 7, 11, 15, m[6], m[7])
+	// Mix the diagonals.
+	g(s, 0, 5, 10, 15, m[8], m[9])
+	g(s, 1, 6, 11, 12, m[10], m[11])
+	g(s, 2, 7, 8, 13, m[12], m[13])
+	g(s, 3, 4, 9, 14, m[14], m[15])
+}
+
+func permute(m *[16]uint32) {
+	var p [16]uint32
+	for i := range p {
+		p[i] = m[msgPermutation[i]]
+	}
+	*m = p
+}
+
+func compress(cv *[8]uint32, block *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		iv[0], iv[1], iv[2], iv[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	m := *block
+	for r := 0; r < 7; r++ {
+		round(&s, &m)
+		if r < 6 {
+			permute(&m)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+	return s
+}
+
+func wordsFromBlock(b []byte, words *[16]uint32) {
+	var buf [BlockSize]byte
+	copy(buf[:], b)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+}
+
+func first8(w [16]uint32) [8]uint32 {
+	var cv [8]uint32
+	copy(cv[:], w[:8])
+	return cv
+}
+
+// output is a node that can produce either a chaining value or, for the
+// root, the final digest.
+type output struct {
+	inputCV  [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o output) chainingValue() [8]uint32 {
+	return first8(compress(&o.inputCV, &o.block, o.counter, o.blockLen, o.flags))
+}
+
+func (o output) rootBytes(out []byte) {
+	var counter uint64
+	for len(out) > 0 {
+		w := compress(&o.inputCV, &o.block, counter, o.blockLen, o.flags|flagRoot)
+		var buf [64]byte
+		for i, word := range w {
+			binary.LittleEndian.PutUint32(buf[4*i:], word)
+		}
+		n := copy(out, buf[:])
+		out = out[n:]
+		counter++
+	}
+}
+
+func parentOutput(left, right [8]uint32) output {
+	o := output{inputCV: iv, blockLen: BlockSize, flags: flagParent}
+	copy(o.block[:8], left[:])
+	copy(o.block[8:], right[:])
+	return o
+}
+
+type chunkState struct {
+	cv               [8]uint32
+	counter          uint64
+	block            [BlockSize]byte
+	blockLen         int
+	blocksCompressed int
+}
+
+func newChunkState(counter uint64) chunkState {
+	return chunkState{cv: iv, counter: counter}
+}
+
+func (c *chunkState) len() int {
+	return BlockSize*c.blocksCompressed + c.blockLen
+}
+
+func (c *chunkState) startFlag() uint32 {
+	if c.blocksCompressed == 0 {
+		return flagChunkStart
+	}
+	return 0
+}
+
+func (c *chunkState) update(p []byte) {
+	for len(p) > 0 {
+		if c.blockLen == BlockSize {
+			var words [16]uint32
+			wordsFromBlock(c.block[:], &words)
+			c.cv = first8(compress(&c.cv, &words, c.counter, BlockSize, c.startFlag()))
+			c.blocksCompressed++
+			c.block = [BlockSize]byte{}
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+func (c *chunkState) output() output {
+	o := output{
+		inputCV:  c.cv,
+		counter:  c.counter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | flagChunkEnd,
+	}
+	wordsFromBlock(c.block[:c.blockLen], &o.block)
+	return o
+}
+
+// Hasher is an incremental BLAKE3 hasher implementing hash.Hash.
+type Hasher struct {
+	chunk chunkState
+	// base is the index of the first chunk this hasher covers. It is
+	// zero for whole inputs and the subtree offset in parallel mode.
+	base   uint64
+	stack  [54][8]uint32
+	stackN int
+}
+
+var _ hash.Hash = (*Hasher)(nil)
+
+// New returns a Hasher computing the 32-byte BLAKE3 digest.
+func New() *Hasher {
+	return newAt(0)
+}
+
+func newAt(base uint64) *Hasher {
+	return &Hasher{chunk: newChunkState(base), base: base}
+}
+
+func (h *Hasher) pushCV(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		h.stackN--
+		cv = parentOutput(h.stack[h.stackN], cv).chainingValue()
+		total >>= 1
+	}
+	h.stack[h.stackN] = cv
+	h.stackN++
+}
+
+// Write absorbs p. It never fails.
+func (h *Hasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if h.chunk.len() == chunkLen {
+			cv := h.chunk.output().chainingValue()
+			total := h.chunk.counter - h.base + 1
+			h.pushCV(cv, total)
+			h.chunk = newChunkState(h.chunk.counter + 1)
+		}
+		take := min(chunkLen-h.chunk.len(), len(p))
+		h.chunk.update(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// rootOutput folds the stack into the node that covers everything written.
+func (h *Hasher) rootOutput() output {
+	o := h.chunk.output()
+	for i := h.stackN - 1; i >= 0; i-- {
+		o = parentOutput(h.stack[i], o.chainingValue())
+	}
+	return o
+}
+
+// Sum appends the digest to b without changing the hasher's state.
+func (h *Hasher) Sum(b []byte) []byte {
+	var out [Size]byte
+	o := h.rootOutput()
+	o.rootBytes(out[:])
+	return append(b, out[:]...)
+}
+
+// Reset returns the hasher to its initial state.
+func (h *Hasher) Reset() { *h = *newAt(0) }
+
+// Size returns the digest length.
+func (h *Hasher) Size() int { return Size }
+
+// BlockSize returns the block length.
+func (h *Hasher) BlockSize() int { return BlockSize }
@@ -0,0 +1,67 @@
+package blake3
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// input returns the test_vectors.json input of n bytes.
+func input(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		in := input(v.length)
+
+		h := New()
+		h.Write(in)
+		if got := hex.EncodeToString(h.Sum(nil)); got != v.hash[:2*Size] {
+			t.Errorf("%d bytes: Sum = %s, want %s", v.length, got, v.hash[:2*Size])
+		}
+		out := make([]byte, len(v.hash)/2)
+		h.rootOutput().rootBytes(out)
+		if got := hex.EncodeToString(out); got != v.hash {
+			t.Errorf("%d bytes: extended output = %s, want %s", v.length, got, v.hash)
+		}
+
+		// Writes of every size must add up to the same digest.
+		for _, step := range []int{1, 63, 64, 65, 1023, 1024, 1025} {
+			h.Reset()
+			for b := in; len(b) > 0; b = b[min(step, len(b)):] {
+				h.Write(b[:min(step, len(b))])
+			}
+			if got := hex.EncodeToString(h.Sum(nil)); got != v.hash[:2*Size] {
+				t.Errorf("%d bytes in writes of %d: Sum = %s, want %s", v.length, step, got, v.hash[:2*Size])
+			}
+		}
+	}
+}
+
+func TestSumReaderAtMatchesSequential(t *testing.T) {
+	data := input(4*SegmentSize + 1)
+	for _, size := range []int{0, 1, SegmentSize - 1, SegmentSize, SegmentSize + 1, SegmentSize + chunkLen,
+		2 * SegmentSize, 2*SegmentSize + 1, 3*SegmentSize - 1, 3 * SegmentSize, 4*SegmentSize + 1} {
+		h := New()
+		h.Write(data[:size])
+		want := h.Sum(nil)
+		for _, workers := range []int{1, 2, 3, 8} {
+			t.Run(fmt.Sprintf("size=%d/workers=%d", size, workers), func(t *testing.T) {
+				got, err := SumReaderAt(context.Background(), bytes.NewReader(data[:size]), int64(size), workers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got[:], want) {
+					t.Errorf("SumReaderAt = %x, want %x", got, want)
+				}
+			})
+		}
+	}
+}
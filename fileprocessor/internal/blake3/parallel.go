@@ -0,0 +1,115 @@
+package blake3
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// SegmentSize is the unit of work in parallel mode: a complete subtree of
+// 1024 chunks. Every segment but the last is hashed to a chaining value
+// independently and the results are merged exactly as the sequential
+// hasher would merge them, so both modes produce identical digests.
+const SegmentSize = 1024 * chunkLen
+
+// SumReaderAt hashes the first size bytes of r using up to workers
+// goroutines and returns the digest. It fails with io.ErrUnexpectedEOF if r
+// holds fewer than size bytes, as when the file shrinks while it is read.
+func SumReaderAt(ctx context.Context, r io.ReaderAt, size int64, workers int) ([Size]byte, error) {
+	var digest [Size]byte
+
+	segments := int((size + SegmentSize - 1) / SegmentSize)
+	if segments <= 1 || workers <= 1 {
+		h := New()
+		buf := make([]byte, min(size, 64<<10))
+		for off := int64(0); off < size; off += int64(len(buf)) {
+			b := buf[:min(int64(len(buf)), size-off)]
+			if err := readAt(r, b, off); err != nil {
+				return digest, err
+			}
+			h.Write(b)
+		}
+		h.Sum(digest[:0])
+		return digest, nil
+	}
+
+	cvs := make([][8]uint32, segments-1)
+	var last output
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) { errOnce.Do(func() { firstErr = err }) }
+
+	for w := 0; w < min(workers, segments); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, SegmentSize)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= segments {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					return
+				}
+
+				off := int64(i) * SegmentSize
+				n := min(SegmentSize, size-off)
+				if err := readAt(r, buf[:n], off); err != nil {
+					fail(err)
+					return
+				}
+
+				h := newAt(uint64(i) * (SegmentSize / chunkLen))
+				h.Write(buf[:n])
+				if i == segments-1 {
+					last = h.rootOutput()
+				} else {
+					cvs[i] = h.rootOutput().chainingValue()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return digest, firstErr
+	}
+
+	// Merge the segment subtrees the way Hasher merges chunks.
+	var stack [54][8]uint32
+	stackN := 0
+	for i, cv := range cvs {
+		total := uint64(i + 1)
+		for total&1 == 0 {
+			stackN--
+			cv = parentOutput(stack[stackN], cv).chainingValue()
+			total >>= 1
+		}
+		stack[stackN] = cv
+		stackN++
+	}
+	o := last
+	for i := stackN - 1; i >= 0; i-- {
+		o = parentOutput(stack[i], o.chainingValue())
+	}
+	o.rootBytes(digest[:])
+	return digest, nil
+}
+
+// readAt fills b from r at off. ReadAt may report io.EOF along with the
+// last full read; fewer bytes than asked for mean the input shrank.
+func readAt(r io.ReaderAt, b []byte, off int64) error {
+	n, err := r.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package blake3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// shrunkReaderAt is a file that lost its tail after its size was taken:
+// reads past len(data) come back short, with io.EOF or, if noEOF, with no
+// error at all.
+type shrunkReaderAt struct {
+	data  []byte
+	noEOF bool
+}
+
+func (r shrunkReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := bytes.NewReader(r.data).ReadAt(p, off)
+	if r.noEOF && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func TestSumReaderAtShortRead(t *testing.T) {
+	size := int64(3*SegmentSize + 100)
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	for _, cut := range []int64{1, 100, SegmentSize + 100} {
+		for _, noEOF := range []bool{false, true} {
+			for _, workers := range []int{1, 4} {
+				r := shrunkReaderAt{data[:size-cut], noEOF}
+				if _, err := SumReaderAt(context.Background(), r, size, workers); !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("%d bytes missing, noEOF %t, %d workers: err = %v, want io.ErrUnexpectedEOF", cut, noEOF, workers, err)
+				}
+			}
+		}
+	}
+}
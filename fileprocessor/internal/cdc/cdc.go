@@ -0,0 +1,81 @@
+// Package cdc implements FastCDC content-defined chunking: chunk
+// boundaries are chosen by a rolling gear hash of the content, so an edit
+// only moves the boundaries next to it and the remaining chunks of a file
+// keep their hashes.
+package cdc
+
+import "math/bits"
+
+// DefaultAvg is the average chunk size used when none is given.
+const DefaultAvg = 64 << 10
+
+// gear is the table of random values the rolling hash mixes in per byte.
+// It is generated from a fixed seed, so boundaries are stable across runs
+// and builds.
+var gear = func() [256]uint64 {
+	var t [256]uint64
+	x := uint64(0)
+	for i := range t {
+		// splitmix64
+		x += 0x9E3779B97F4A7C15
+		z := x
+		z = (z ^ z>>30) * 0xBF58476D1CE4E5B9
+		z = (z ^ z>>27) * 0x94D049BB133111EB
+		t[i] = z ^ z>>31
+	}
+	return t
+}()
+
+// Chunker finds chunk boundaries in a stream. Chunks average Avg bytes and
+// are never shorter than Avg/4, except the last, nor longer than Avg*4.
+type Chunker struct {
+	min, avg, max int
+	// maskS is used before the average size and maskL after it
+	// (normalized chunking), which narrows the chunk size distribution.
+	maskS, maskL uint64
+
+	h uint64
+	n int
+}
+
+// New returns a Chunker for the given average size, rounded down to a
+// power of two. Sizes below 256 bytes select DefaultAvg.
+func New(avg int) *Chunker {
+	if avg < 256 {
+		avg = DefaultAvg
+	}
+	b := bits.Len(uint(avg)) - 1
+	avg = 1 << b
+	// The top bits of the gear hash depend on the most input, so the masks
+	// select those.
+	return &Chunker{
+		min:   avg / 4,
+		avg:   avg,
+		max:   avg * 4,
+		maskS: ^uint64(0) << (64 - (b + 1)),
+		maskL: ^uint64(0) << (64 - (b - 1)),
+	}
+}
+
+// Next consumes p up to the end of the current chunk. It returns how many
+// bytes of p belong to the chunk and whether the chunk ends there; if not,
+// all of p was consumed and the chunk continues in the next call.
+func (c *Chunker) Next(p []byte) (n int, cut bool) {
+	for i, b := range p {
+		c.n++
+		if c.n <= c.min {
+			// Cut-point skipping: no boundary can fall this early.
+			continue
+		}
+		c.h = c.h<<1 + gear[b]
+		mask := c.maskL
+		if c.n < c.avg {
+			mask = c.maskS
+		}
+		if c.h&mask == 0 || c.n >= c.max {
+			c.n, c.h = 0, 0
+			return i + 1, true
+		}
+	}
+	return len(p), false
+}
@@ -0,0 +1,129 @@
+package cdc
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+func random(seed byte, n int) []byte {
+	rng := rand.New(rand.NewChaCha8([32]byte{seed}))
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.Uint32())
+	}
+	return b
+}
+
+// chunks splits data, fed to a new Chunker step bytes at a time.
+func chunks(avg int, data []byte, step int) [][]byte {
+	c := New(avg)
+	var out [][]byte
+	start := 0
+	for off := 0; off < len(data); {
+		p := data[off:min(off+step, len(data))]
+		n, cut := c.Next(p)
+		off += n
+		if cut {
+			out = append(out, data[start:off])
+			start = off
+		}
+	}
+	if start < len(data) {
+		out = append(out, data[start:])
+	}
+	return out
+}
+
+func TestGear(t *testing.T) {
+	// The first outputs of splitmix64 from a zero seed.
+	for i, want := range []uint64{0xe220a8397b1dcdaf, 0x6e789e6aa1b965f4, 0x06c45d188009454f} {
+		if gear[i] != want {
+			t.Errorf("gear[%d] = %#x, want %#x", i, gear[i], want)
+		}
+	}
+}
+
+func TestNewRoundsAvg(t *testing.T) {
+	for _, tc := range []struct{ in, avg int }{
+		{0, DefaultAvg}, {255, DefaultAvg}, {256, 256}, {1000, 512}, {100000, 65536}, {1 << 20, 1 << 20},
+	} {
+		c := New(tc.in)
+		if c.avg != tc.avg || c.min != tc.avg/4 || c.max != tc.avg*4 {
+			t.Errorf("New(%d): min %d, avg %d, max %d; want avg %d", tc.in, c.min, c.avg, c.max, tc.avg)
+		}
+	}
+}
+
+func TestChunkSizes(t *testing.T) {
+	for _, avg := range []int{256, 4096, DefaultAvg} {
+		data := random(1, 400*avg)
+		got := chunks(avg, data, len(data))
+		var total int
+		for i, c := range got {
+			total += len(c)
+			if i < len(got)-1 && (len(c) < avg/4 || len(c) > avg*4) {
+				t.Errorf("avg %d: chunk %d of %d bytes", avg, i, len(c))
+			}
+		}
+		if total != len(data) {
+			t.Fatalf("avg %d: chunks hold %d bytes, want %d", avg, total, len(data))
+		}
+		// Normalized chunking keeps the mean near the average.
+		if mean := len(data) / len(got); mean < avg/2 || mean > 2*avg {
+			t.Errorf("avg %d: mean chunk size %d over %d chunks", avg, mean, len(got))
+		}
+	}
+
+	// Content without boundaries is cut at the maximum.
+	for i, c := range chunks(4096, make([]byte, 100000), 100000) {
+		if want := min(4096*4, 100000-i*4096*4); len(c) != want {
+			t.Errorf("zeros: chunk %d of %d bytes, want %d", i, len(c), want)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	data := random(2, 1<<20)
+	want := chunks(4096, data, len(data))
+	for _, step := range []int{1, 7, 4096, 100000} {
+		got := chunks(4096, data, step)
+		if len(got) != len(want) {
+			t.Fatalf("fed %d bytes at a time: %d chunks, want %d", step, len(got), len(want))
+		}
+		for i := range want {
+			if len(got[i]) != len(want[i]) {
+				t.Fatalf("fed %d bytes at a time: chunk %d of %d bytes, want %d", step, i, len(got[i]), len(want[i]))
+			}
+		}
+	}
+}
+
+func TestBoundariesAreStable(t *testing.T) {
+	data := random(3, 1<<20)
+	orig := chunks(4096, data, len(data))
+	seen := map[string]bool{}
+	for _, c := range orig {
+		seen[string(c)] = true
+	}
+	for name, edited := range map[string][]byte{
+		"prefix":   append(random(4, 100), data...),
+		"deletion": append(bytes.Clone(data[:len(data)/2]), data[len(data)/2+10:]...),
+		"edit": func() []byte {
+			b := bytes.Clone(data)
+			b[len(b)/3] ^= 1
+			return b
+		}(),
+	} {
+		// An edit only changes the chunks around it.
+		var changed int
+		for _, c := range chunks(4096, edited, len(edited)) {
+			if !seen[string(c)] {
+				changed++
+			}
+		}
+		if changed > 3 {
+			t.Errorf("%s: %d of %d chunks changed", name, changed, len(orig))
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// Package cpu detects the instruction set extensions the standard
+// library's hash implementations select at run time, so the CLI can tell
+// users which code path is hashing their files.
+package cpu
+
+// X86 holds the amd64 features relevant to hashing. All fields are false
+// on other architectures.
+var X86 struct {
+	HasSSSE3 bool
+	HasSSE41 bool
+	HasSSE42 bool
+	HasAVX   bool // set only if the OS saves YMM state
+	HasAVX2  bool
+	HasBMI1  bool
+	HasBMI2  bool
+	HasSHA   bool // SHA-NI
+}
+
+// ARM64 holds the arm64 features relevant to hashing. All fields are false
+// on other architectures and on systems where they can't be queried.
+var ARM64 struct {
+	HasSHA1   bool
+	HasSHA2   bool
+	HasSHA512 bool
+	HasSHA3   bool
+	HasCRC32  bool
+}
+
+func init() {
+	doinit()
+}
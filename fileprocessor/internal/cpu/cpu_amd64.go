@@ -0,0 +1,32 @@
+package cpu
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+func doinit() {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	X86.HasSSSE3 = ecx1&(1<<9) != 0
+	X86.HasSSE41 = ecx1&(1<<19) != 0
+	X86.HasSSE42 = ecx1&(1<<20) != 0
+
+	// AVX is only usable when the OS saves the XMM and YMM registers on
+	// context switches.
+	if ecx1&(1<<27) != 0 && ecx1&(1<<28) != 0 {
+		xcr0, _ := xgetbv()
+		X86.HasAVX = xcr0&6 == 6
+	}
+
+	if maxID < 7 {
+		return
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	X86.HasBMI1 = ebx7&(1<<3) != 0
+	X86.HasAVX2 = X86.HasAVX && ebx7&(1<<5) != 0
+	X86.HasBMI2 = ebx7&(1<<8) != 0
+	X86.HasSHA = ebx7&(1<<29) != 0
+}
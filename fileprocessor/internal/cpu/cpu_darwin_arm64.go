@@ -0,0 +1,10 @@
+package cpu
+
+// doinit reports the features every Apple silicon CPU has.
+func doinit() {
+	ARM64.HasSHA1 = true
+	ARM64.HasSHA2 = true
+	ARM64.HasSHA512 = true
+	ARM64.HasSHA3 = true
+	ARM64.HasCRC32 = true
+}
@@ -0,0 +1,38 @@
+package cpu
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+const (
+	atHWCAP = 16
+
+	hwcapSHA1   = 1 << 5
+	hwcapSHA2   = 1 << 6
+	hwcapCRC32  = 1 << 7
+	hwcapSHA3   = 1 << 17
+	hwcapSHA512 = 1 << 21
+)
+
+// doinit reads the kernel's hardware capability bits from the auxiliary
+// vector. If /proc isn't mounted every feature reads as absent.
+func doinit() {
+	auxv, err := os.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return
+	}
+	for len(auxv) >= 16 {
+		tag, val := binary.LittleEndian.Uint64(auxv), binary.LittleEndian.Uint64(auxv[8:])
+		auxv = auxv[16:]
+		if tag != atHWCAP {
+			continue
+		}
+		ARM64.HasSHA1 = val&hwcapSHA1 != 0
+		ARM64.HasSHA2 = val&hwcapSHA2 != 0
+		ARM64.HasCRC32 = val&hwcapCRC32 != 0
+		ARM64.HasSHA3 = val&hwcapSHA3 != 0
+		ARM64.HasSHA512 = val&hwcapSHA512 != 0
+		return
+	}
+}
@@ -0,0 +1,64 @@
+//go:build amd64 || arm64
+
+package cpu
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// cpuinfoFlags returns the feature flags the kernel lists for the first
+// CPU in /proc/cpuinfo.
+func cpuinfoFlags(t *testing.T) map[string]bool {
+	t.Helper()
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		t.Skip(err)
+	}
+	key := map[string]string{"amd64": "flags", "arm64": "Features"}[runtime.GOARCH]
+	for _, line := range strings.Split(string(data), "\n") {
+		name, list, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+		flags := map[string]bool{}
+		for _, f := range strings.Fields(list) {
+			flags[f] = true
+		}
+		return flags
+	}
+	t.Skipf("no %s line in /proc/cpuinfo", key)
+	return nil
+}
+
+// TestMatchesCPUInfo checks the detected features against the kernel's
+// own detection, under the names it lists them by.
+func TestMatchesCPUInfo(t *testing.T) {
+	flags := cpuinfoFlags(t)
+	features := map[string]bool{
+		"ssse3":  X86.HasSSSE3,
+		"sse4_1": X86.HasSSE41,
+		"sse4_2": X86.HasSSE42,
+		"avx":    X86.HasAVX,
+		"avx2":   X86.HasAVX2,
+		"bmi1":   X86.HasBMI1,
+		"bmi2":   X86.HasBMI2,
+		"sha_ni": X86.HasSHA,
+	}
+	if runtime.GOARCH == "arm64" {
+		features = map[string]bool{
+			"sha1":   ARM64.HasSHA1,
+			"sha2":   ARM64.HasSHA2,
+			"sha512": ARM64.HasSHA512,
+			"sha3":   ARM64.HasSHA3,
+			"crc32":  ARM64.HasCRC32,
+		}
+	}
+	for name, has := range features {
+		if has != flags[name] {
+			t.Errorf("%s: detected %t, /proc/cpuinfo says %t", name, has, flags[name])
+		}
+	}
+}
@@ -0,0 +1,617 @@
+// Package expr is the small filter language of -filter: boolean
+// expressions over a file's attributes, such as
+//
+//	size > 1MB && ext in ["log", "gz"] && mtime > now() - 72h
+//
+// Expressions are type-checked when compiled, so a Program never fails
+// while it runs.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Env holds the attributes of the file a Program is evaluated for.
+type Env struct {
+	Path  string // with forward slashes
+	Size  int64
+	MTime time.Time
+	Mode  uint32 // Unix permission bits, including setuid, setgid and sticky
+	UID   int64  // -1 when unknown
+	GID   int64
+	// Now is the value of now(), fixed for a whole run.
+	Now time.Time
+}
+
+// Program is a compiled expression.
+type Program struct {
+	eval func(*Env) value
+}
+
+// Eval reports whether the file described by env satisfies the
+// expression.
+func (p *Program) Eval(env *Env) bool {
+	return p.eval(env).b
+}
+
+// Compile parses and type-checks src, which must be a boolean expression.
+func Compile(src string) (*Program, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	ps := &parser{toks: toks}
+	n, err := ps.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := ps.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	if n.typ != tBool {
+		return nil, fmt.Errorf("expression is a %s, not a bool", n.typ)
+	}
+	return &Program{eval: n.eval}, nil
+}
+
+type typ int
+
+const (
+	tAny typ = iota // element type of the empty list
+	tBool
+	tNum
+	tStr
+	tTime
+	tDur
+	tList
+)
+
+func (t typ) String() string {
+	return [...]string{"value", "bool", "number", "string", "time", "duration", "list"}[t]
+}
+
+// value is the result of evaluating a node; the field in use follows the
+// node's type.
+type value struct {
+	b bool
+	n float64
+	s string
+	t time.Time
+	d time.Duration
+	l []value
+}
+
+// node is a type-checked subexpression. elem is the element type of
+// lists; konst marks literals, whose value needs no file.
+type node struct {
+	typ   typ
+	elem  typ
+	konst bool
+	eval  func(*Env) value
+}
+
+func constant(t typ, v value) *node {
+	return &node{typ: t, konst: true, eval: func(*Env) value { return v }}
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the operator or keyword s if it comes next.
+func (p *parser) accept(s string) bool {
+	if t := p.peek(); (t.kind == tokOp || t.kind == tokIdent) && t.text == s {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		t := p.peek()
+		return fmt.Errorf("expected %q at %d, found %q", s, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) or() (*node, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if l.typ != tBool || r.typ != tBool {
+			return nil, fmt.Errorf("|| needs bools, not %s and %s", l.typ, r.typ)
+		}
+		a, b := l.eval, r.eval
+		l = &node{typ: tBool, eval: func(e *Env) value { return value{b: a(e).b || b(e).b} }}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (*node, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		if l.typ != tBool || r.typ != tBool {
+			return nil, fmt.Errorf("&& needs bools, not %s and %s", l.typ, r.typ)
+		}
+		a, b := l.eval, r.eval
+		l = &node{typ: tBool, eval: func(e *Env) value { return value{b: a(e).b && b(e).b} }}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (*node, error) {
+	if p.accept("!") {
+		n, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		if n.typ != tBool {
+			return nil, fmt.Errorf("! needs a bool, not a %s", n.typ)
+		}
+		f := n.eval
+		return &node{typ: tBool, eval: func(e *Env) value { return value{b: !f(e).b} }}, nil
+	}
+	return p.cmp()
+}
+
+func (p *parser) cmp() (*node, error) {
+	l, err := p.add()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	negate := false
+	switch {
+	case t.kind == tokOp && slices.Contains([]string{"==", "!=", "<", "<=", ">", ">="}, t.text):
+		p.next()
+	case t.kind == tokIdent && t.text == "in":
+		p.next()
+	case t.kind == tokIdent && t.text == "not" && p.toks[p.i+1].text == "in":
+		p.i += 2
+		negate, t.text = true, "in"
+	default:
+		return l, nil
+	}
+	r, err := p.add()
+	if err != nil {
+		return nil, err
+	}
+	if t.text == "in" {
+		return in(l, r, negate)
+	}
+	return compare(t.text, l, r)
+}
+
+func compare(op string, l, r *node) (*node, error) {
+	if l.typ != r.typ || l.typ == tList || (l.typ == tBool && op != "==" && op != "!=") {
+		return nil, fmt.Errorf("can't compare %s %s %s", l.typ, op, r.typ)
+	}
+	typ, a, b := l.typ, l.eval, r.eval
+	return &node{typ: tBool, eval: func(e *Env) value {
+		x, y := a(e), b(e)
+		var c int
+		switch typ {
+		case tBool:
+			if x.b != y.b {
+				c = 1
+			}
+		case tNum:
+			c = cmpFloat(x.n, y.n)
+		case tStr:
+			c = strings.Compare(x.s, y.s)
+		case tTime:
+			c = x.t.Compare(y.t)
+		case tDur:
+			c = cmpFloat(float64(x.d), float64(y.d))
+		}
+		var ok bool
+		switch op {
+		case "==":
+			ok = c == 0
+		case "!=":
+			ok = c != 0
+		case "<":
+			ok = c < 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case ">=":
+			ok = c >= 0
+		}
+		return value{b: ok}
+	}}, nil
+}
+
+func cmpFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// in tests membership in a list, or a substring of a string.
+func in(l, r *node, negate bool) (*node, error) {
+	a, b := l.eval, r.eval
+	switch {
+	case l.typ == tStr && r.typ == tStr:
+		return &node{typ: tBool, eval: func(e *Env) value {
+			return value{b: strings.Contains(b(e).s, a(e).s) != negate}
+		}}, nil
+	case r.typ == tList && (r.elem == l.typ || r.elem == tAny) && l.typ != tList:
+		typ := l.typ
+		return &node{typ: tBool, eval: func(e *Env) value {
+			x := a(e)
+			for _, y := range b(e).l {
+				if equal(typ, x, y) {
+					return value{b: !negate}
+				}
+			}
+			return value{b: negate}
+		}}, nil
+	}
+	return nil, fmt.Errorf("can't test %s in %s", l.typ, r.typ)
+}
+
+func equal(t typ, x, y value) bool {
+	switch t {
+	case tBool:
+		return x.b == y.b
+	case tNum:
+		return x.n == y.n
+	case tStr:
+		return x.s == y.s
+	case tTime:
+		return x.t.Equal(y.t)
+	case tDur:
+		return x.d == y.d
+	}
+	return false
+}
+
+func (p *parser) add() (*node, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("+"):
+			op = "+"
+		case p.accept("-"):
+			op = "-"
+		default:
+			return l, nil
+		}
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if l, err = arith(op, l, r); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func arith(op string, l, r *node) (*node, error) {
+	a, b := l.eval, r.eval
+	sign := 1.0
+	if op == "-" {
+		sign = -1
+	}
+	switch {
+	case l.typ == tNum && r.typ == tNum:
+		return &node{typ: tNum, eval: func(e *Env) value { return value{n: a(e).n + sign*b(e).n} }}, nil
+	case l.typ == tDur && r.typ == tDur:
+		return &node{typ: tDur, eval: func(e *Env) value { return value{d: a(e).d + time.Duration(sign)*b(e).d} }}, nil
+	case l.typ == tTime && r.typ == tDur:
+		return &node{typ: tTime, eval: func(e *Env) value { return value{t: a(e).t.Add(time.Duration(sign) * b(e).d)} }}, nil
+	case l.typ == tDur && r.typ == tTime && op == "+":
+		return &node{typ: tTime, eval: func(e *Env) value { return value{t: b(e).t.Add(a(e).d)} }}, nil
+	case l.typ == tTime && r.typ == tTime && op == "-":
+		return &node{typ: tDur, eval: func(e *Env) value { return value{d: a(e).t.Sub(b(e).t)} }}, nil
+	case l.typ == tStr && r.typ == tStr && op == "+":
+		return &node{typ: tStr, eval: func(e *Env) value { return value{s: a(e).s + b(e).s} }}, nil
+	}
+	return nil, fmt.Errorf("can't compute %s %s %s", l.typ, op, r.typ)
+}
+
+func (p *parser) unary() (*node, error) {
+	if p.accept("-") {
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		f := n.eval
+		switch n.typ {
+		case tNum:
+			return &node{typ: tNum, eval: func(e *Env) value { return value{n: -f(e).n} }}, nil
+		case tDur:
+			return &node{typ: tDur, eval: func(e *Env) value { return value{d: -f(e).d} }}, nil
+		}
+		return nil, fmt.Errorf("can't negate a %s", n.typ)
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return number(t)
+	case tokString:
+		s, err := unquote(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("bad string at %d: %w", t.pos, err)
+		}
+		return constant(tStr, value{s: s}), nil
+	case tokIdent:
+		if p.accept("(") {
+			return p.call(t)
+		}
+		return variable(t)
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			return p.list()
+		}
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+func (p *parser) list() (*node, error) {
+	var items []*node
+	elem := tAny
+	for !p.accept("]") {
+		if len(items) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			if p.accept("]") {
+				break
+			}
+		}
+		n, err := p.add()
+		if err != nil {
+			return nil, err
+		}
+		if n.typ == tList || elem != tAny && n.typ != elem {
+			return nil, fmt.Errorf("list mixes %s and %s", elem, n.typ)
+		}
+		elem = n.typ
+		items = append(items, n)
+	}
+	return &node{typ: tList, elem: elem, eval: func(e *Env) value {
+		l := make([]value, len(items))
+		for i, n := range items {
+			l[i] = n.eval(e)
+		}
+		return value{l: l}
+	}}, nil
+}
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond, "us": time.Microsecond, "ms": time.Millisecond,
+	"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+}
+
+var sizeUnits = map[string]float64{
+	"B": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40,
+}
+
+// number reads a number literal: a plain or octal (0o755, 0755) number,
+// a size with a binary unit (64K, 1.5MB, 2GiB) or a duration (72h, 7d).
+func number(t token) (*node, error) {
+	i := strings.IndexFunc(t.text, func(r rune) bool { return r != '.' && (r < '0' || r > '9') })
+	if strings.HasPrefix(t.text, "0o") || len(t.text) > 1 && t.text[0] == '0' && i < 0 && !strings.Contains(t.text, ".") {
+		n, err := strconv.ParseUint(strings.TrimPrefix(t.text, "0o"), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad octal number %q at %d", t.text, t.pos)
+		}
+		return constant(tNum, value{n: float64(n)}), nil
+	}
+	num, unit := t.text, ""
+	if i >= 0 {
+		num, unit = t.text[:i], t.text[i:]
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("bad number %q at %d", t.text, t.pos)
+	}
+	if unit == "" {
+		return constant(tNum, value{n: f}), nil
+	}
+	if d, ok := durationUnits[unit]; ok {
+		return constant(tDur, value{d: time.Duration(f * float64(d))}), nil
+	}
+	mult, ok := sizeUnits[strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "i")]
+	if unit == "B" {
+		mult, ok = 1, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %q at %d", unit, t.pos)
+	}
+	return constant(tNum, value{n: f * mult}), nil
+}
+
+func variable(t token) (*node, error) {
+	str := func(f func(*Env) string) *node {
+		return &node{typ: tStr, eval: func(e *Env) value { return value{s: f(e)} }}
+	}
+	num := func(f func(*Env) float64) *node {
+		return &node{typ: tNum, eval: func(e *Env) value { return value{n: f(e)} }}
+	}
+	switch t.text {
+	case "true", "false":
+		return constant(tBool, value{b: t.text == "true"}), nil
+	case "path":
+		return str(func(e *Env) string { return e.Path }), nil
+	case "name":
+		return str(func(e *Env) string { return path.Base(e.Path) }), nil
+	case "dir":
+		return str(func(e *Env) string { return path.Dir(e.Path) }), nil
+	case "ext":
+		return str(func(e *Env) string {
+			return strings.ToLower(strings.TrimPrefix(path.Ext(e.Path), "."))
+		}), nil
+	case "size":
+		return num(func(e *Env) float64 { return float64(e.Size) }), nil
+	case "mode":
+		return num(func(e *Env) float64 { return float64(e.Mode) }), nil
+	case "uid":
+		return num(func(e *Env) float64 { return float64(e.UID) }), nil
+	case "gid":
+		return num(func(e *Env) float64 { return float64(e.GID) }), nil
+	case "mtime":
+		return &node{typ: tTime, eval: func(e *Env) value { return value{t: e.MTime} }}, nil
+	case "age":
+		return &node{typ: tDur, eval: func(e *Env) value { return value{d: e.Now.Sub(e.MTime)} }}, nil
+	}
+	return nil, fmt.Errorf("unknown name %q at %d", t.text, t.pos)
+}
+
+func (p *parser) call(fn token) (*node, error) {
+	var args []*node
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, n)
+	}
+	want := func(types ...typ) error {
+		if len(args) != len(types) {
+			return fmt.Errorf("%s() takes %d arguments", fn.text, len(types))
+		}
+		for i, t := range types {
+			if args[i].typ != t {
+				return fmt.Errorf("argument %d of %s() must be a %s", i+1, fn.text, t)
+			}
+		}
+		return nil
+	}
+	// literal returns the value of a string literal argument, which date,
+	// matches and glob check when compiling.
+	literal := func(i int, what string) (string, error) {
+		if !args[i].konst {
+			return "", fmt.Errorf("%s() needs a literal %s", fn.text, what)
+		}
+		return args[i].eval(nil).s, nil
+	}
+
+	switch fn.text {
+	case "now":
+		if err := want(); err != nil {
+			return nil, err
+		}
+		return &node{typ: tTime, eval: func(e *Env) value { return value{t: e.Now} }}, nil
+	case "date":
+		if err := want(tStr); err != nil {
+			return nil, err
+		}
+		s, err := literal(0, "date")
+		if err != nil {
+			return nil, err
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return constant(tTime, value{t: t}), nil
+			}
+		}
+		return nil, fmt.Errorf("date(%q): want 2006-01-02, 2006-01-02T15:04:05 or RFC 3339", s)
+	case "matches":
+		if err := want(tStr, tStr); err != nil {
+			return nil, err
+		}
+		s, err := literal(1, "regexp")
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("matches(): %w", err)
+		}
+		f := args[0].eval
+		return &node{typ: tBool, eval: func(e *Env) value { return value{b: re.MatchString(f(e).s)} }}, nil
+	case "glob":
+		if err := want(tStr, tStr); err != nil {
+			return nil, err
+		}
+		pattern, err := literal(1, "pattern")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("glob(%q): %w", pattern, err)
+		}
+		f := args[0].eval
+		return &node{typ: tBool, eval: func(e *Env) value {
+			ok, _ := path.Match(pattern, f(e).s)
+			return value{b: ok}
+		}}, nil
+	case "lower":
+		if err := want(tStr); err != nil {
+			return nil, err
+		}
+		f := args[0].eval
+		return &node{typ: tStr, eval: func(e *Env) value { return value{s: strings.ToLower(f(e).s)} }}, nil
+	}
+	return nil, fmt.Errorf("unknown function %s() at %d", fn.text, fn.pos)
+}
@@ -0,0 +1,208 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	env = &Env{
+		Path:  "dir/sub/File.TXT",
+		Size:  2 << 20,
+		MTime: now.Add(-48 * time.Hour),
+		Mode:  0o4755,
+		UID:   1000,
+		GID:   100,
+		Now:   now,
+	}
+)
+
+func TestEval(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want bool
+	}{
+		{"true", true},
+		{"false", false},
+
+		// Precedence: || below &&, below !, below comparisons, below + and
+		// -, below unary minus; binary operators group to the left.
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"false && false || true", true},
+		{"!true || true", true},
+		{"!(true || true)", false},
+		{"!!true", true},
+		{"!size > 1MB", false},
+		{"10 - 2 - 3 == 5", true},
+		{"-2 + 3 == 1", true},
+		{"- -1 == 1", true},
+		{"1 + 2 == 3 && 3 == 1 + 2", true},
+
+		// Comparisons.
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 < 2", true},
+		{"2 < 2", false},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"3 > 2", true},
+		{"2 > 2", false},
+		{"2 >= 2", true},
+		{"1 >= 2", false},
+		{`"a" < "b"`, true},
+		{`"b" >= "a"`, true},
+		{"mtime < now()", true},
+		{"1h < 2h", true},
+		{"true == true", true},
+		{"true != false", true},
+
+		// Numbers with units.
+		{"size == 2MB", true},
+		{"size == 2MiB", true},
+		{"size == 2048K", true},
+		{"size > 1.5M", true},
+		{"1B == 1", true},
+		{"1KB == 1024", true},
+		{"1T == 1024G", true},
+		{".5K == 512", true},
+		{"mode == 0o4755", true},
+		{"mode == 04755", true},
+		{"0 == 0", true},
+		{"age == 48h", true},
+		{"age == 2d", true},
+		{"1w == 7d", true},
+		{"1.5h == 90m", true},
+		{"1000ms == 1s", true},
+		{"1000us == 1ms", true},
+		{"1000ns == 1us", true},
+		{"-1h < 0h", true},
+
+		// Times and durations.
+		{"mtime == now() - 48h", true},
+		{"mtime + 48h == now()", true},
+		{"48h + mtime == now()", true},
+		{"now() - mtime == 2d", true},
+		{"1h + 30m == 90m", true},
+		{"2h - 30m == 90m", true},
+
+		// Attributes and strings.
+		{`path == "dir/sub/File.TXT"`, true},
+		{`name == "File.TXT"`, true},
+		{`dir == "dir/sub"`, true},
+		{`ext == "txt"`, true},
+		{"uid == 1000 && gid == 100", true},
+		{`'it\'s' == "it's"`, true},
+		{`'say "hi"' == "say \"hi\""`, true},
+		{`"a" + "b" == 'ab'`, true},
+		{`"\t" == "	"`, true},
+
+		// in and not in.
+		{`"sub" in path`, true},
+		{`"x" in path`, false},
+		{`"x" not in path`, true},
+		{`ext in ["log", "txt"]`, true},
+		{`ext in ["log", "gz"]`, false},
+		{`ext not in ["log"]`, true},
+		{"size in [1, 2MB]", true},
+		{"size in [1, 2, ]", false},
+		{"ext in []", false},
+		{"ext not in []", true},
+		{"age in [1d, 2d]", true},
+		{"mtime in [now() - 48h]", true},
+		{"true in [true]", true},
+
+		// Functions.
+		{`matches(name, "^F.*\\.TXT$")`, true},
+		{`matches(path, "^sub")`, false},
+		{`glob(name, "*.TXT")`, true},
+		{`glob(path, "*.TXT")`, false},
+		{`lower(name) == "file.txt"`, true},
+		{`date("2024-01-02") < now()`, true},
+		{`mtime > date("2024-05-30T11:59:59")`, true},
+		{`date("2024-01-02T03:04:05Z") == date("2024-01-02T05:04:05+02:00")`, true},
+		{`glob(lower(name), "*.txt") && !matches(dir, "tmp")`, true},
+	} {
+		p, err := Compile(tc.src)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", tc.src, err)
+			continue
+		}
+		if got := p.Eval(env); got != tc.want {
+			t.Errorf("%s = %t, want %t", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tc := range []struct{ src, err string }{
+		{"", "unexpected end of expression"},
+		{"size > ", "unexpected end of expression"},
+		{"size", "expression is a number, not a bool"},
+		{"size > 1 2", `unexpected "2" at 9`},
+		{"(size > 1", `expected ")" at 9`},
+		{"size > 1)", `unexpected ")" at 8`},
+		{"size @ 1", `unexpected '@' at 5`},
+		{"é", `unexpected 'é' at 0`},
+		{`name == "abc`, "unterminated string at 8"},
+		{`name == "\q"`, "bad string at 8"},
+		{"size == name", "can't compare number == string"},
+		{"true < false", "can't compare bool < bool"},
+		{"[1] == [1]", "can't compare list == list"},
+		{"size && true", "&& needs bools, not number and bool"},
+		{"true || name", "|| needs bools, not bool and string"},
+		{"!size", "! needs a bool, not a number"},
+		{"-name == 1", "can't negate a string"},
+		{"size - name > 1", "can't compute number - string"},
+		{`"a" - "b" == ""`, "can't compute string - string"},
+		{"now() + now() > now()", "can't compute time + time"},
+		{"1h - now() > now()", "can't compute duration - time"},
+		{"size in name", "can't test number in string"},
+		{"[1] in [[1]]", "list mixes"},
+		{`size in [1, "a"]`, "list mixes number and string"},
+		{"size in [1 2]", `expected "," at 11`},
+		{"size > 10x", `unknown unit "x" at 7`},
+		{"mode == 08", `bad octal number "08" at 8`},
+		{"size > 1.2.3", `bad number "1.2.3" at 7`},
+		{"foo > 1", `unknown name "foo" at 0`},
+		{"not", `unknown name "not" at 0`},
+		{"foo()", "unknown function foo() at 0"},
+		{"now(1) > mtime", "now() takes 0 arguments"},
+		{"lower(1) == ''", "argument 1 of lower() must be a string"},
+		{"date(name) < now()", "date() needs a literal date"},
+		{`date("yesterday") < now()`, `date("yesterday"): want`},
+		{`matches(name, "(")`, "matches(): "},
+		{"matches(name, name)", "matches() needs a literal regexp"},
+		{`glob(name, "[")`, `glob("["): `},
+		{"glob(name, path)", "glob() needs a literal pattern"},
+		{`matches(name)`, "matches() takes 2 arguments"},
+	} {
+		_, err := Compile(tc.src)
+		if err == nil {
+			t.Errorf("Compile(%s) succeeded", tc.src)
+		} else if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Compile(%s) = %v, want %q", tc.src, err, tc.err)
+		}
+	}
+}
+
+func TestProgramIsReusable(t *testing.T) {
+	p, err := Compile("size > 1MB && age < 1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := *env
+	small.Size = 100
+	recent := *env
+	recent.MTime = now.Add(-time.Hour)
+	for _, tc := range []struct {
+		env  *Env
+		want bool
+	}{{env, false}, {&small, false}, {&recent, true}} {
+		if got := p.Eval(tc.env); got != tc.want {
+			t.Errorf("Eval(size %d, mtime %v) = %t, want %t", tc.env.Size, tc.env.MTime, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package fuzzy computes similarity digests (ssdeep and TLSH) whose
+// distance reflects how alike two inputs are, unlike cryptographic hashes
+// that change completely on a one-byte edit.
+package fuzzy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Hasher accumulates input and produces a similarity digest.
+type Hasher interface {
+	io.Writer
+	// Digest returns the digest of everything written, or "" when the
+	// input is too small for the algorithm to say anything about it.
+	Digest() string
+}
+
+var algorithms = map[string]func() Hasher{
+	"ssdeep": func() Hasher { return newSSDeep() },
+	"tlsh":   func() Hasher { return &tlsh{} },
+}
+
+// New returns the constructor for the named algorithm.
+func New(name string) (func() Hasher, error) {
+	fn, ok := algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fuzzy hash %q", name)
+	}
+	return fn, nil
+}
+
+// Names lists the supported algorithms in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Similarity scores two digests of the same algorithm from 0 (unrelated)
+// to 100 (identical). The algorithm is recognised from the digest format.
+// ssdeep scores are used as is; a TLSH distance d maps to 100-d, floored
+// at 0.
+func Similarity(a, b string) (int, error) {
+	if strings.HasPrefix(a, "T1") != strings.HasPrefix(b, "T1") {
+		return 0, fmt.Errorf("cannot compare digests of different algorithms")
+	}
+	if strings.HasPrefix(a, "T1") {
+		da, err := parseTLSH(a)
+		if err != nil {
+			return 0, err
+		}
+		db, err := parseTLSH(b)
+		if err != nil {
+			return 0, err
+		}
+		return max(0, 100-tlshDistance(da, db)), nil
+	}
+	da, err := parseSSDeep(a)
+	if err != nil {
+		return 0, err
+	}
+	db, err := parseSSDeep(b)
+	if err != nil {
+		return 0, err
+	}
+	return compareSSDeep(da, db), nil
+}
@@ -0,0 +1,216 @@
+package fuzzy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ssdeepVectors are digests printed by the reference implementation,
+// libfuzzy: the empty input, and the examples of the python-ssdeep
+// documentation.
+var ssdeepVectors = []struct{ in, digest string }{
+	{"", "3::"},
+	{"Also called fuzzy hashes, Ctph can match inputs that have homologies.", "3:AXGBicFlgVNhBGcL6wCrFQEv:AXGHsNhxLsr2C"},
+	{"Also called fuzzy hashes, CTPH can match inputs that have homologies.", "3:AXGBicFlIHBGcL6wCrFQEv:AXGH6xLsr2C"},
+}
+
+func digest(t *testing.T, name string, in []byte, chunk int) string {
+	t.Helper()
+	newHasher, err := New(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHasher()
+	for p := in; len(p) > 0; p = p[min(chunk, len(p)):] {
+		h.Write(p[:min(chunk, len(p))])
+	}
+	return h.Digest()
+}
+
+func TestSSDeepVectors(t *testing.T) {
+	for _, v := range ssdeepVectors {
+		if got := digest(t, "ssdeep", []byte(v.in), len(v.in)+1); got != v.digest {
+			t.Errorf("ssdeep(%q) = %s, want %s", v.in, got, v.digest)
+		}
+	}
+	// The documented score of the two examples.
+	if got, err := Similarity(ssdeepVectors[1].digest, ssdeepVectors[2].digest); err != nil || got != 22 {
+		t.Errorf("Similarity of the examples = %d, %v; want 22", got, err)
+	}
+}
+
+// text is n bytes of lines that vary, so that digests have something to
+// pick out.
+func text(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < n; i++ {
+		fmt.Fprintf(&b, "line %d of the file, with %d words in it\n", i, i*7919%1009)
+	}
+	return b.Bytes()[:n]
+}
+
+func TestSSDeepLargeInputs(t *testing.T) {
+	for _, n := range []int{192, 193, 10000, 1 << 20} {
+		in := text(n)
+		d := digest(t, "ssdeep", in, n)
+		parsed, err := parseSSDeep(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// No larger than the smallest block size that can give at most 64
+		// characters; it is then halved while that gives fewer than 32.
+		if parsed.blockSize > minBlockSize && parsed.blockSize*spamsumLength/2 >= uint64(n) {
+			t.Errorf("%d bytes: block size %d", n, parsed.blockSize)
+		}
+		if len(parsed.s1) > spamsumLength || len(parsed.s2) > spamsumLength/2 {
+			t.Errorf("%d bytes: digest %s is too long", n, d)
+		}
+		if got := digest(t, "ssdeep", in, 1000); got != d {
+			t.Errorf("%d bytes written 1000 at a time: %s, want %s", n, got, d)
+		}
+
+		// A one-byte edit in the middle changes one piece.
+		edited := bytes.Clone(in)
+		edited[n/2] ^= 0x20
+		if score, _ := Similarity(d, digest(t, "ssdeep", edited, n)); n >= 10000 && score < 80 {
+			t.Errorf("%d bytes: one-byte edit scores %d", n, score)
+		}
+	}
+}
+
+func TestSSDeepScores(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"96:abcdefghijklmnop:abcdefgh", "96:abcdefghijklmnop:abcdefgh", 100},
+		// Block sizes a factor of two apart compare the matching halves.
+		{"96:abcdefghijklmnop:ABCDEFGHIJ", "192:ABCDEFGHIJ:xyz", 100},
+		{"96:abcdefghijklmnop:ABCDEFGHIJ", "384:ABCDEFGHIJ:xyz", 0},
+		// No common run of seven characters.
+		{"96:abcdefghijklmnop:x", "96:abcXefghiXklmnop:y", 0},
+		// Long runs of one character are cut to three before comparing.
+		{"96:aaaaaaaaaabcdefgh:x", "96:aaabcdefgh:x", 100},
+		// One substitution in 16 characters: distance 2 of 32.
+		{"96:abcdefghijklmnop:x", "96:abcdefghijklmnoX:y", 94},
+		// Small block sizes cap the score by the digest length.
+		{"3:abcdefghij:x", "3:abcdefghijk:x", 10},
+	} {
+		if got, err := Similarity(tc.a, tc.b); err != nil || got != tc.want {
+			t.Errorf("Similarity(%s, %s) = %d, %v; want %d", tc.a, tc.b, got, err, tc.want)
+		}
+	}
+}
+
+func TestPearsonIsAPermutation(t *testing.T) {
+	var seen [256]bool
+	for _, v := range pearson {
+		if seen[v] {
+			t.Fatalf("%d appears twice", v)
+		}
+		seen[v] = true
+	}
+}
+
+func TestTLSHRefusesSmallAndUniformInputs(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"empty":    nil,
+		"49 bytes": text(49),
+		"uniform":  bytes.Repeat([]byte{'a'}, 10000),
+		"two byte": bytes.Repeat([]byte("ab"), 10000),
+	} {
+		if d := digest(t, "tlsh", in, len(in)+1); d != "" {
+			t.Errorf("%s: TLSH %s", name, d)
+		}
+	}
+}
+
+func TestTLSHDigest(t *testing.T) {
+	for _, n := range []int{50, 656, 657, 3199, 3200, 1 << 20} {
+		in := text(n)
+		d := digest(t, "tlsh", in, n)
+		if len(d) != 2+2*(3+tlshCodeSize) || !strings.HasPrefix(d, "T1") || strings.ToUpper(d) != d {
+			t.Fatalf("%d bytes: digest %q", n, d)
+		}
+		parsed, err := parseTLSH(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.lvalue != lCapture(uint64(n)) || parsed.String() != d {
+			t.Errorf("%d bytes: %s parses to %+v", n, d, parsed)
+		}
+		if got := digest(t, "tlsh", in, 7); got != d {
+			t.Errorf("%d bytes written 7 at a time: %s, want %s", n, got, d)
+		}
+	}
+	// The length is coded with the reference's logarithmic steps.
+	for _, tc := range []struct {
+		n    uint64
+		want byte
+	}{{50, 9}, {656, 15}, {657, 16}, {3199, 22}, {3200, 22}, {1 << 20, 82}} {
+		if got := lCapture(tc.n); got != tc.want {
+			t.Errorf("lCapture(%d) = %d, want %d", tc.n, got, tc.want)
+		}
+	}
+}
+
+// TestTLSHDistance works through the scoring rules of the reference
+// implementation on digests differing in one field at a time.
+func TestTLSHDistance(t *testing.T) {
+	base := tlshDigest{checksum: 0x5a, lvalue: 0x10, q1: 2, q2: 0}
+	for _, tc := range []struct {
+		name string
+		edit func(*tlshDigest)
+		want int
+	}{
+		{"identical", func(*tlshDigest) {}, 0},
+		{"checksum", func(d *tlshDigest) { d.checksum = 0x5b }, 1},
+		{"length by one", func(d *tlshDigest) { d.lvalue = 0x11 }, 1},
+		{"length by two", func(d *tlshDigest) { d.lvalue = 0x12 }, 24},
+		{"length around the circle", func(d *tlshDigest) { d.lvalue = 0x0f }, 1},
+		{"q1 by two", func(d *tlshDigest) { d.q1 = 4 }, 12},
+		{"q2 around the circle", func(d *tlshDigest) { d.q2 = 15 }, 1},
+		{"a bucket by one", func(d *tlshDigest) { d.code[0] = 0x04 }, 1},
+		{"a bucket by two", func(d *tlshDigest) { d.code[31] = 0x80 }, 2},
+		{"a bucket by three", func(d *tlshDigest) { d.code[5] = 0x03 }, 6},
+		{"everything", func(d *tlshDigest) {
+			d.checksum, d.lvalue, d.q1, d.q2 = 0, 0x12, 4, 15
+			d.code[0], d.code[5] = 0x04, 0x03
+		}, 1 + 24 + 12 + 1 + 1 + 6},
+	} {
+		d := base
+		tc.edit(&d)
+		if got := tlshDistance(base, d); got != tc.want {
+			t.Errorf("%s: distance %d, want %d", tc.name, got, tc.want)
+		}
+		if got, err := Similarity(base.String(), d.String()); err != nil || got != max(0, 100-tc.want) {
+			t.Errorf("%s: Similarity = %d, %v; want %d", tc.name, got, err, 100-tc.want)
+		}
+	}
+	far := base
+	for i := range far.code {
+		far.code[i] = 0xff
+	}
+	if got, _ := Similarity(base.String(), far.String()); got != 0 {
+		t.Errorf("Similarity of opposite digests = %d, want 0", got)
+	}
+}
+
+func TestSimilarityErrors(t *testing.T) {
+	tlsh := digest(t, "tlsh", text(1000), 1000)
+	for _, pair := range [][2]string{
+		{tlsh, ssdeepVectors[1].digest},
+		{"T1XYZ", tlsh},
+		{"3:abc", "3:abc:def"},
+		{"x:abc:def", "3:abc:def"},
+	} {
+		if _, err := Similarity(pair[0], pair[1]); err == nil {
+			t.Errorf("Similarity(%q, %q) succeeded", pair[0], pair[1])
+		}
+	}
+	if _, err := New("sdhash"); err == nil {
+		t.Error("New(sdhash) succeeded")
+	}
+}
@@ -0,0 +1,309 @@
+package fuzzy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ssdeep (context triggered piecewise hashing) parameters, as in spamsum.
+const (
+	spamsumLength = 64
+	minBlockSize  = 3
+	rollingWindow = 7
+	numBlockHash  = 31
+
+	hashPrime = 0x01000193
+	hashInit  = 0x28021967
+)
+
+const b64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+func blockSize(i int) uint64 { return minBlockSize << i }
+
+type rollState struct {
+	window     [rollingWindow]byte
+	h1, h2, h3 uint32
+	n          uint32
+}
+
+func (r *rollState) roll(c byte) {
+	r.h2 -= r.h1
+	r.h2 += rollingWindow * uint32(c)
+	r.h1 += uint32(c)
+	r.h1 -= uint32(r.window[r.n%rollingWindow])
+	r.window[r.n%rollingWindow] = c
+	r.n++
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+}
+
+func (r *rollState) sum() uint32 { return r.h1 + r.h2 + r.h3 }
+
+func sumHash(c byte, h uint32) uint32 { return h*hashPrime ^ uint32(c) }
+
+type blockHash struct {
+	h, halfh   uint32
+	digest     [spamsumLength]byte
+	halfdigest byte
+	dlen       int
+}
+
+// ssdeep computes a spamsum digest for every candidate block size at once,
+// so the input is read a single time, and drops block sizes as soon as
+// they can no longer be chosen.
+type ssdeep struct {
+	bh             [numBlockHash]blockHash
+	bhstart, bhend int
+	total          uint64
+	roll           rollState
+	lasth          uint32
+	needLastHash   bool
+}
+
+func newSSDeep() *ssdeep {
+	s := &ssdeep{bhend: 1}
+	s.bh[0].h, s.bh[0].halfh = hashInit, hashInit
+	return s
+}
+
+func (s *ssdeep) Write(p []byte) (int, error) {
+	s.total += uint64(len(p))
+	for _, c := range p {
+		s.step(c)
+	}
+	return len(p), nil
+}
+
+func (s *ssdeep) tryFork() {
+	old := &s.bh[s.bhend-1]
+	if s.bhend < numBlockHash {
+		s.bh[s.bhend] = blockHash{h: old.h, halfh: old.halfh}
+		s.bhend++
+	} else if !s.needLastHash {
+		s.needLastHash = true
+		s.lasth = old.h
+	}
+}
+
+func (s *ssdeep) tryReduce() {
+	if s.bhend-s.bhstart < 2 {
+		return
+	}
+	// The initial block size guess could still pick this one.
+	if blockSize(s.bhstart)*spamsumLength >= s.total {
+		return
+	}
+	// The guess adjustment could still fall back to it.
+	if s.bh[s.bhstart+1].dlen < spamsumLength/2 {
+		return
+	}
+	s.bhstart++
+}
+
+func (s *ssdeep) step(c byte) {
+	s.roll.roll(c)
+	h := uint64(s.roll.sum())
+
+	for i := s.bhstart; i < s.bhend; i++ {
+		s.bh[i].h = sumHash(c, s.bh[i].h)
+		s.bh[i].halfh = sumHash(c, s.bh[i].halfh)
+	}
+	if s.needLastHash {
+		s.lasth = sumHash(c, s.lasth)
+	}
+
+	// bhend can grow inside the loop; a freshly forked block hash may hit
+	// its reset point on the same byte.
+	for i := s.bhstart; i < s.bhend; i++ {
+		if h%blockSize(i) != blockSize(i)-1 {
+			break
+		}
+		b := &s.bh[i]
+		if b.dlen == 0 {
+			s.tryFork()
+		}
+		b.digest[b.dlen] = b64[b.h%64]
+		b.halfdigest = b64[b.halfh%64]
+		if b.dlen < spamsumLength-1 {
+			// Only reset while there is room for more characters; at the
+			// end the last pieces of the input share one character.
+			b.dlen++
+			b.digest[b.dlen] = 0
+			b.h = hashInit
+			if b.dlen < spamsumLength/2 {
+				b.halfh = hashInit
+				b.halfdigest = 0
+			}
+		} else {
+			s.tryReduce()
+		}
+	}
+}
+
+// Digest returns the "blocksize:digest1:digest2" string.
+func (s *ssdeep) Digest() string {
+	bi := s.bhstart
+	h := s.roll.sum()
+
+	for blockSize(bi)*spamsumLength < s.total {
+		bi++
+		if bi >= numBlockHash {
+			return ""
+		}
+	}
+	for bi >= s.bhend {
+		bi--
+	}
+	for bi > s.bhstart && s.bh[bi].dlen < spamsumLength/2 {
+		bi--
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatUint(blockSize(bi), 10))
+	sb.WriteByte(':')
+
+	b := &s.bh[bi]
+	sb.Write(b.digest[:b.dlen])
+	if h != 0 {
+		sb.WriteByte(b64[b.h%64])
+	} else if b.digest[b.dlen] != 0 {
+		sb.WriteByte(b.digest[b.dlen])
+	}
+	sb.WriteByte(':')
+
+	if bi < s.bhend-1 {
+		b := &s.bh[bi+1]
+		sb.Write(b.digest[:min(b.dlen, spamsumLength/2-1)])
+		if h != 0 {
+			sb.WriteByte(b64[b.halfh%64])
+		} else if b.halfdigest != 0 {
+			sb.WriteByte(b.halfdigest)
+		}
+	} else if h != 0 {
+		if bi == 0 {
+			sb.WriteByte(b64[s.bh[bi].h%64])
+		} else {
+			sb.WriteByte(b64[s.lasth%64])
+		}
+	}
+	return sb.String()
+}
+
+type ssdeepDigest struct {
+	blockSize uint64
+	s1, s2    string
+}
+
+func parseSSDeep(d string) (ssdeepDigest, error) {
+	parts := strings.SplitN(d, ":", 3)
+	if len(parts) != 3 {
+		return ssdeepDigest{}, fmt.Errorf("invalid ssdeep digest %q", d)
+	}
+	bs, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return ssdeepDigest{}, fmt.Errorf("invalid ssdeep digest %q", d)
+	}
+	// A trailing ",filename" as written by the ssdeep tool is ignored.
+	s2, _, _ := strings.Cut(parts[2], ",")
+	return ssdeepDigest{bs, eliminateSequences(parts[1]), eliminateSequences(s2)}, nil
+}
+
+// eliminateSequences shortens runs of more than three identical characters,
+// which carry little information and skew the edit distance.
+func eliminateSequences(s string) string {
+	b := []byte(s)
+	out := b[:0]
+	for i, c := range b {
+		if i >= 3 && c == b[i-1] && c == b[i-2] && c == b[i-3] {
+			continue
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+// compareSSDeep scores two digests from 0 (unrelated) to 100 (identical
+// pieces), following the ssdeep scoring rules. Only digests whose block
+// sizes are equal or differ by a factor of two can be compared.
+func compareSSDeep(a, b ssdeepDigest) int {
+	switch {
+	case a.blockSize == b.blockSize:
+		if a.s1 == b.s1 && a.s2 == b.s2 {
+			return 100
+		}
+		return max(scoreStrings(a.s1, b.s1, a.blockSize), scoreStrings(a.s2, b.s2, a.blockSize*2))
+	case a.blockSize == b.blockSize*2:
+		return scoreStrings(a.s1, b.s2, a.blockSize)
+	case a.blockSize*2 == b.blockSize:
+		return scoreStrings(a.s2, b.s1, b.blockSize)
+	default:
+		return 0
+	}
+}
+
+func scoreStrings(s1, s2 string, bs uint64) int {
+	if len(s1) > spamsumLength || len(s2) > spamsumLength {
+		return 0
+	}
+	if !hasCommonSubstring(s1, s2) {
+		return 0
+	}
+	score := editDistance(s1, s2)
+	score = score * spamsumLength / (len(s1) + len(s2))
+	score = 100 * score / spamsumLength
+	if score >= 100 {
+		return 0
+	}
+	score = 100 - score
+
+	// Small block sizes can't produce a meaningful match between short
+	// digests; cap the score by how much data the digests cover.
+	if bs >= (99+rollingWindow)/rollingWindow*minBlockSize {
+		return score
+	}
+	if limit := int(bs/minBlockSize) * min(len(s1), len(s2)); score > limit {
+		score = limit
+	}
+	return score
+}
+
+// hasCommonSubstring reports whether s1 and s2 share a run of
+// rollingWindow characters.
+func hasCommonSubstring(s1, s2 string) bool {
+	if len(s1) < rollingWindow || len(s2) < rollingWindow {
+		return false
+	}
+	seen := make(map[string]bool, len(s1))
+	for i := 0; i+rollingWindow <= len(s1); i++ {
+		seen[s1[i:i+rollingWindow]] = true
+	}
+	for i := 0; i+rollingWindow <= len(s2); i++ {
+		if seen[s2[i:i+rollingWindow]] {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance with insertions and deletions
+// costing 1 and substitutions 2, as used by ssdeep.
+func editDistance(s1, s2 string) int {
+	prev := make([]int, len(s2)+1)
+	cur := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		cur[0] = i
+		for j := 1; j <= len(s2); j++ {
+			sub := prev[j-1]
+			if s1[i-1] != s2[j-1] {
+				sub += 2
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, sub)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(s2)]
+}
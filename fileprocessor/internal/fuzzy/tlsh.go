@@ -0,0 +1,207 @@
+package fuzzy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// TLSH parameters for the standard 128-bucket, 1-byte checksum variant.
+const (
+	tlshBuckets   = 128
+	tlshCodeSize  = tlshBuckets / 4
+	tlshWindow    = 5
+	tlshMinLength = 50
+)
+
+// pearson is the TLSH Pearson permutation (v_table).
+var pearson = [256]byte{
+	1, 87, 49, 12, 176, 178, 102, 166, 121, 193, 6, 84, 249, 230, 44, 163,
+	14, 197, 213, 181, 161, 85, 218, 80, 64, 239, 24, 226, 236, 142, 38, 200,
+	110, 177, 104, 103, 141, 253, 255, 50, 77, 101, 81, 18, 45, 96, 31, 222,
+	25, 107, 190, 70, 86, 237, 240, 34, 72, 242, 20, 214, 244, 227, 149, 235,
+	97, 234, 57, 22, 60, 250, 82, 175, 208, 5, 127, 199, 111, 62, 135, 248,
+	174, 169, 211, 58, 66, 154, 106, 195, 245, 171, 17, 187, 182, 179, 0, 243,
+	132, 56, 148, 75, 128, 133, 158, 100, 130, 126, 91, 13, 153, 246, 216, 219,
+	119, 68, 223, 78, 83, 88, 201, 99, 122, 11, 92, 32, 136, 114, 52, 10,
+	138, 30, 48, 183, 156, 35, 61, 26, 143, 74, 251, 94, 129, 162, 63, 152,
+	170, 7, 115, 167, 241, 206, 3, 150, 55, 59, 151, 220, 90, 53, 23, 131,
+	125, 173, 15, 238, 79, 95, 89, 16, 105, 137, 225, 224, 217, 160, 37, 123,
+	118, 73, 2, 157, 46, 116, 9, 145, 134, 228, 207, 212, 202, 215, 69, 229,
+	27, 188, 67, 124, 168, 252, 42, 4, 29, 108, 21, 247, 19, 205, 39, 203,
+	233, 40, 186, 147, 198, 192, 155, 33, 164, 191, 98, 204, 165, 180, 117, 76,
+	140, 36, 210, 172, 41, 54, 159, 8, 185, 232, 113, 196, 231, 47, 146, 120,
+	51, 65, 28, 144, 254, 221, 93, 189, 194, 139, 112, 43, 71, 109, 184, 209,
+}
+
+func bMapping(salt, i, j, k byte) byte {
+	h := pearson[salt]
+	h = pearson[h^i]
+	h = pearson[h^j]
+	return pearson[h^k]
+}
+
+// tlsh counts byte triplets from a sliding window into buckets.
+type tlsh struct {
+	buckets  [256]uint32
+	window   [tlshWindow]byte
+	checksum byte
+	n        uint64
+}
+
+func (t *tlsh) Write(p []byte) (int, error) {
+	for _, c := range p {
+		// window[0] is the newest byte, window[4] the oldest.
+		copy(t.window[1:], t.window[:tlshWindow-1])
+		t.window[0] = c
+		t.n++
+		if t.n < tlshWindow {
+			continue
+		}
+		w := &t.window
+		t.checksum = bMapping(0, w[0], w[1], t.checksum)
+		t.buckets[bMapping(2, w[0], w[1], w[2])]++
+		t.buckets[bMapping(3, w[0], w[1], w[3])]++
+		t.buckets[bMapping(5, w[0], w[2], w[3])]++
+		t.buckets[bMapping(7, w[0], w[2], w[4])]++
+		t.buckets[bMapping(11, w[0], w[1], w[4])]++
+		t.buckets[bMapping(13, w[0], w[3], w[4])]++
+	}
+	return len(p), nil
+}
+
+// lCapture encodes the input length on a logarithmic scale.
+func lCapture(n uint64) byte {
+	l := math.Log(float64(n))
+	var i int
+	switch {
+	case n <= 656:
+		i = int(math.Floor(l / 0.4054651))
+	case n <= 3199:
+		i = int(math.Floor(l/0.26236426 - 8.72777))
+	default:
+		i = int(math.Floor(l/0.095310180 - 62.5472))
+	}
+	return byte(i & 0xFF)
+}
+
+type tlshDigest struct {
+	checksum byte
+	lvalue   byte
+	q1, q2   byte
+	code     [tlshCodeSize]byte
+}
+
+func swapNibbles(b byte) byte { return b<<4 | b>>4 }
+
+// digest returns the T1 hex form, or "" when the input is too short or too
+// uniform to produce a meaningful TLSH.
+func (t *tlsh) Digest() string {
+	if t.n < tlshMinLength {
+		return ""
+	}
+	sorted := slices.Clone(t.buckets[:tlshBuckets])
+	slices.Sort(sorted)
+	q1, q2, q3 := sorted[tlshBuckets/4-1], sorted[tlshBuckets/2-1], sorted[tlshBuckets-tlshBuckets/4-1]
+	if q3 == 0 {
+		return ""
+	}
+	nonzero := 0
+	for _, c := range t.buckets[:tlshBuckets] {
+		if c > 0 {
+			nonzero++
+		}
+	}
+	if nonzero <= tlshBuckets/2 {
+		return ""
+	}
+
+	d := tlshDigest{
+		checksum: t.checksum,
+		lvalue:   lCapture(t.n),
+		q1:       byte(uint32(float32(q1*100)/float32(q3)) % 16),
+		q2:       byte(uint32(float32(q2*100)/float32(q3)) % 16),
+	}
+	for i := 0; i < tlshCodeSize; i++ {
+		var h byte
+		for j := 0; j < 4; j++ {
+			switch k := t.buckets[4*i+j]; {
+			case q3 < k:
+				h |= 3 << (2 * j)
+			case q2 < k:
+				h |= 2 << (2 * j)
+			case q1 < k:
+				h |= 1 << (2 * j)
+			}
+		}
+		d.code[tlshCodeSize-1-i] = h
+	}
+	return d.String()
+}
+
+func (d tlshDigest) String() string {
+	b := append([]byte{swapNibbles(d.checksum), swapNibbles(d.lvalue), d.q1<<4 | d.q2}, d.code[:]...)
+	return "T1" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+func parseTLSH(s string) (tlshDigest, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(s, "T1"))
+	if err != nil || len(raw) != 3+tlshCodeSize {
+		return tlshDigest{}, fmt.Errorf("invalid TLSH digest %q", s)
+	}
+	d := tlshDigest{
+		checksum: swapNibbles(raw[0]),
+		lvalue:   swapNibbles(raw[1]),
+		q1:       raw[2] >> 4,
+		q2:       raw[2] & 0x0F,
+	}
+	copy(d.code[:], raw[3:])
+	return d, nil
+}
+
+func modDiff(x, y, r int) int {
+	var dl, dr int
+	if y > x {
+		dl, dr = y-x, x+r-y
+	} else {
+		dl, dr = x-y, y+r-x
+	}
+	return min(dl, dr)
+}
+
+// tlshDistance is the TLSH difference score including the length
+// component: 0 for identical digests, growing without a fixed bound.
+func tlshDistance(a, b tlshDigest) int {
+	diff := 0
+	if l := modDiff(int(a.lvalue), int(b.lvalue), 256); l <= 1 {
+		diff += l
+	} else {
+		diff += l * 12
+	}
+	for _, q := range [2]int{modDiff(int(a.q1), int(b.q1), 16), modDiff(int(a.q2), int(b.q2), 16)} {
+		if q <= 1 {
+			diff += q
+		} else {
+			diff += (q - 1) * 12
+		}
+	}
+	if a.checksum != b.checksum {
+		diff++
+	}
+	for i := range a.code {
+		x, y := a.code[i], b.code[i]
+		for j := 0; j < 4; j++ {
+			d := int(x>>(2*j)&3) - int(y>>(2*j)&3)
+			if d < 0 {
+				d = -d
+			}
+			if d == 3 {
+				d = 6
+			}
+			diff += d
+		}
+	}
+	return diff
+}
@@ -0,0 +1,63 @@
+package hash
+
+import (
+	"runtime"
+	"strconv"
+
+	"fileprocessor/internal/cpu"
+)
+
+// Auto is the pseudo-algorithm resolved by Resolve to the fastest
+// cryptographic hash on this machine.
+const Auto = "auto"
+
+// Resolve returns name unchanged unless it is Auto, which becomes sha256
+// where the CPU has SHA-256 instructions and sha512 otherwise on 64-bit
+// platforms, where it runs faster than sha256 in software.
+func Resolve(name string) string {
+	if name != Auto {
+		return name
+	}
+	if hasSHA256() || strconv.IntSize == 32 {
+		return "sha256"
+	}
+	return "sha512"
+}
+
+// Backend names the implementation the standard library selects for the
+// named algorithm on this CPU, mirroring its own feature checks: for
+// example "SHA-NI" or "AVX2" on amd64 and "Armv8.0" on arm64. Algorithms
+// and platforms without hardware-specific code report "generic".
+func Backend(name string) string {
+	switch runtime.GOARCH {
+	case "amd64":
+		x := cpu.X86
+		shani := x.HasAVX && x.HasSHA && x.HasSSE41 && x.HasSSSE3
+		switch {
+		case (name == "sha1" || name == "sha256") && shani:
+			return "SHA-NI"
+		case name == "sha1" && x.HasAVX2 && x.HasBMI1 && x.HasBMI2,
+			(name == "sha256" || name == "sha512") && x.HasAVX2 && x.HasBMI2:
+			return "AVX2"
+		case name == "crc32c" && x.HasSSE42:
+			return "SSE4.2"
+		}
+	case "arm64":
+		a := cpu.ARM64
+		switch {
+		case name == "sha1" && a.HasSHA1, name == "sha256" && a.HasSHA2:
+			return "Armv8.0"
+		case name == "sha512" && a.HasSHA512,
+			name == "sha3-256" && a.HasSHA3 && runtime.GOOS == "darwin":
+			return "Armv8.2"
+		case name == "crc32c" && a.HasCRC32:
+			return "Armv8.0 CRC32"
+		}
+	}
+	return "generic"
+}
+
+func hasSHA256() bool {
+	b := Backend("sha256")
+	return b == "SHA-NI" || b == "Armv8.0"
+}
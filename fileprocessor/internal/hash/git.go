@@ -0,0 +1,29 @@
+package hash
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"strconv"
+)
+
+// gitAlgorithms hash files the way git hash-object does: the content is
+// preceded by a "blob <size>\x00" header, so the size must be known before
+// the first byte is hashed and the constructors can't stand alone in the
+// algorithms registry.
+var gitAlgorithms = map[string]func() hash.Hash{
+	"git-sha1":   sha1.New,
+	"git-sha256": sha256.New,
+}
+
+// GitBlob returns the underlying hash of a git object algorithm.
+func GitBlob(name string) (func() hash.Hash, bool) {
+	fn, ok := gitAlgorithms[name]
+	return fn, ok
+}
+
+// GitBlobHeader returns the object header git hashes before a blob of
+// size bytes.
+func GitBlobHeader(size int64) []byte {
+	return []byte("blob " + strconv.FormatInt(size, 10) + "\x00")
+}
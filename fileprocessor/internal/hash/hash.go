@@ -0,0 +1,163 @@
+// Package hash maps algorithm names to hash constructors and computes
+// encoded digests.
+package hash
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"fileprocessor/internal/blake3"
+	"fileprocessor/internal/xxhash"
+)
+
+// Default is the algorithm used when none is configured.
+const Default = "sha256"
+
+var algorithms = map[string]func() hash.Hash{
+	"md5":      md5.New,
+	"sha1":     sha1.New,
+	"sha256":   sha256.New,
+	"sha512":   sha512.New,
+	"sha3-256": func() hash.Hash { return sha3.New256() },
+	"blake3":   func() hash.Hash { return blake3.New() },
+
+	// Non-cryptographic checksums for deduplication and change detection,
+	// where throughput matters more than collision resistance.
+	"xxh64":  func() hash.Hash { return xxhash.New64() },
+	"xxh3":   func() hash.Hash { return xxhash.New3() },
+	"crc32c": func() hash.Hash { return crc32.New(castagnoli) },
+}
+
+// Cryptographic reports whether name is a cryptographic hash, the only
+// kind an HMAC can be built on. md5 is one for compatibility with existing
+// HMAC-MD5 manifests, broken as it is.
+func Cryptographic(name string) bool {
+	switch name {
+	case "md5", "sha1", "sha256", "sha512", "sha3-256", "blake3":
+		return true
+	}
+	return false
+}
+
+// castagnoli uses the SSE4.2/ARMv8 CRC32 instructions where available.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// New returns the constructor for the named algorithm.
+func New(name string) (func() hash.Hash, error) {
+	fn, ok := algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %q", name)
+	}
+	return fn, nil
+}
+
+// Names lists the supported algorithms, including git object hashes, in
+// sorted order.
+func Names() []string {
+	names := make([]string, 0, len(algorithms)+len(gitAlgorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	for name := range gitAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sum reads r to EOF and returns its hex-encoded digest and length.
+func Sum(r io.Reader, newHash func() hash.Hash) (string, int64, error) {
+	h := newHash()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// SumParallel hashes the first size bytes of r on up to workers goroutines.
+// Only algorithms with a tree mode can split a single input; for the rest
+// ok is false and the caller should fall back to Sum.
+func SumParallel(ctx context.Context, name string, r io.ReaderAt, size int64, workers int) (digest string, ok bool, err error) {
+	if name != "blake3" {
+		return "", false, nil
+	}
+	sum, err := blake3.SumReaderAt(ctx, r, size, workers)
+	if err != nil {
+		return "", true, err
+	}
+	return hex.EncodeToString(sum[:]), true, nil
+}
+
+// ChunkSize is the piece length SumChunks hashes independently. It is part
+// of the chunked digest's definition and must not change.
+const ChunkSize = 8 << 20
+
+// SumChunks splits the first size bytes of r into ChunkSize pieces, hashes
+// them on up to workers goroutines and returns the hex digest of the
+// concatenated raw chunk digests. It fails with io.ErrUnexpectedEOF if r
+// holds fewer than size bytes.
+func SumChunks(ctx context.Context, r io.ReaderAt, size int64, workers int, newHash func() hash.Hash) (string, error) {
+	chunks := int((size + ChunkSize - 1) / ChunkSize)
+	sums := make([][]byte, chunks)
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) { errOnce.Do(func() { firstErr = err }) }
+
+	for w := 0; w < min(workers, chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 32*1024)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= chunks {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					return
+				}
+
+				off := int64(i) * ChunkSize
+				n := min(ChunkSize, size-off)
+				h := newHash()
+				section := io.NewSectionReader(r, off, n)
+				if copied, err := io.CopyBuffer(h, section, buf); err != nil {
+					fail(err)
+					return
+				} else if copied < n {
+					// The file shrank while it was read.
+					fail(io.ErrUnexpectedEOF)
+					return
+				}
+				sums[i] = h.Sum(nil)
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	h := newHash()
+	for _, sum := range sums {
+		h.Write(sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
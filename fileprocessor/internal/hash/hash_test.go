@@ -0,0 +1,126 @@
+package hash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// vectors are digests of "" and "abc" published for each algorithm, or
+// printed by git hash-object for the git ones.
+var vectors = map[string][2]string{
+	"md5":        {"d41d8cd98f00b204e9800998ecf8427e", "900150983cd24fb0d6963f7d28e17f72"},
+	"sha1":       {"da39a3ee5e6b4b0d3255bfef95601890afd80709", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+	"sha256":     {"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	"sha512":     {"cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e", "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+	"sha3-256":   {"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a", "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	"blake3":     {"af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262", "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85"},
+	"xxh64":      {"ef46db3751d8e999", "44bc2cf5ad770999"},
+	"xxh3":       {"2d06800538d394c2", "78af5f94892f3950"},
+	"crc32c":     {"00000000", "364b3fb7"},
+	"git-sha1":   {"e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", "f2ba8f84ab5c1bce84a7b441cb1959cfc7093b7f"},
+	"git-sha256": {"473a0f4c3be8a93681a267e3b1e9a7dcda1185436fe141f7749120a303721813", "c1cf6e465077930e88dc5136641d402f72a229ddd996f627d60e9639eaba35a6"},
+}
+
+func TestVectors(t *testing.T) {
+	for _, name := range Names() {
+		want, ok := vectors[name]
+		if !ok {
+			t.Errorf("no test vectors for %s", name)
+			continue
+		}
+		for i, in := range []string{"", "abc"} {
+			var r io.Reader = strings.NewReader(in)
+			newHash, git := GitBlob(name)
+			if git {
+				r = io.MultiReader(bytes.NewReader(GitBlobHeader(int64(len(in)))), r)
+			} else {
+				var err error
+				if newHash, err = New(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, _, err := Sum(r, newHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want[i] {
+				t.Errorf("%s(%q) = %s, want %s", name, in, got, want[i])
+			}
+		}
+	}
+}
+
+func TestNewUnknown(t *testing.T) {
+	for _, name := range []string{"", "sha384", "SHA256", "git-sha1"} {
+		if _, err := New(name); err == nil {
+			t.Errorf("New(%q) succeeded", name)
+		}
+	}
+}
+
+func TestCryptographic(t *testing.T) {
+	for _, name := range Names() {
+		_, git := GitBlob(name)
+		want := !git && name != "xxh64" && name != "xxh3" && name != "crc32c"
+		if got := Cryptographic(name); got != want {
+			t.Errorf("Cryptographic(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestSumParallelMatchesSum(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1 MiB, many blake3 chunks
+	newHash, _ := New("blake3")
+	want, _, err := Sum(bytes.NewReader(data), newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := SumParallel(context.Background(), "blake3", bytes.NewReader(data), int64(len(data)), 4)
+	if err != nil || !ok {
+		t.Fatalf("SumParallel = %v, %v", ok, err)
+	}
+	if got != want {
+		t.Errorf("SumParallel = %s, want %s", got, want)
+	}
+	if _, ok, _ := SumParallel(context.Background(), "sha256", bytes.NewReader(data), int64(len(data)), 4); ok {
+		t.Error("SumParallel split a sha256 input")
+	}
+}
+
+func TestSumChunks(t *testing.T) {
+	// A little over two chunks: the digest is that of the chunk digests.
+	data := bytes.Repeat([]byte{'x'}, 2*ChunkSize+1)
+	newHash, _ := New("sha256")
+	var raw []byte
+	for off := 0; off < len(data); off += ChunkSize {
+		h := newHash()
+		h.Write(data[off:min(off+ChunkSize, len(data))])
+		raw = h.Sum(raw)
+	}
+	want, _, _ := Sum(bytes.NewReader(raw), newHash)
+	got, err := SumChunks(context.Background(), bytes.NewReader(data), int64(len(data)), 3, newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("SumChunks = %s, want %s", got, want)
+	}
+}
+
+func TestSumChunksTruncated(t *testing.T) {
+	// The file lost its tail after its size was taken, in the last chunk
+	// or in an earlier one.
+	size := int64(3 * ChunkSize)
+	data := bytes.Repeat([]byte{'x'}, int(size))
+	newHash, _ := New("sha256")
+	for _, cut := range []int64{1, ChunkSize + 1} {
+		_, err := SumChunks(context.Background(), bytes.NewReader(data[:size-cut]), size, 3, newHash)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%d bytes missing: err = %v, want io.ErrUnexpectedEOF", cut, err)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// Package parquet writes flat Apache Parquet files: one row group per
+// RowGroupRows rows, PLAIN-encoded values in GZIP-compressed version 1
+// data pages, and RLE definition levels for optional columns, which
+// Athena, Spark, DuckDB and pyarrow all read.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Kind is the type of a column.
+type Kind int
+
+const (
+	String    Kind = iota // UTF-8 text; values are strings
+	Int64                 // values are int64
+	Timestamp             // UTC, to the microsecond; values are time.Time
+)
+
+// Column describes a column. Optional columns take nil for null.
+type Column struct {
+	Name     string
+	Kind     Kind
+	Optional bool
+}
+
+const (
+	// RowGroupRows is how many rows a row group holds, and so how many
+	// the Writer buffers.
+	RowGroupRows = 1 << 17
+	// pageRows is how many values a data page holds.
+	pageRows = 1 << 14
+)
+
+// Parquet enum values.
+const (
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repRequired = 0
+	repOptional = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageData = 0
+)
+
+var magic = []byte("PAR1")
+
+// Writer writes rows to a Parquet file. Its methods must not be called
+// concurrently.
+type Writer struct {
+	w         io.Writer
+	columns   []Column
+	createdBy string
+
+	off    int64
+	rows   [][]any // buffered row group, by column
+	n      int     // rows buffered
+	total  int64
+	groups []rowGroup
+	err    error
+	zw     *gzip.Writer
+}
+
+type rowGroup struct {
+	rows   int64
+	chunks []chunk
+}
+
+type chunk struct {
+	offset       int64 // of the first page
+	values       int64
+	uncompressed int64
+	compressed   int64
+}
+
+// NewWriter returns a Writer of rows with the given columns to w.
+// createdBy names the writing application in the file's metadata.
+func NewWriter(w io.Writer, createdBy string, columns ...Column) *Writer {
+	pw := &Writer{w: w, columns: columns, createdBy: createdBy, rows: make([][]any, len(columns))}
+	pw.write(magic)
+	return pw
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	var n int
+	n, w.err = w.w.Write(b)
+	w.off += int64(n)
+}
+
+// Write adds a row, one value per column.
+func (w *Writer) Write(row ...any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: %d values for %d columns", len(row), len(w.columns))
+	}
+	for i, v := range row {
+		c := w.columns[i]
+		ok := v == nil && c.Optional
+		switch v.(type) {
+		case string:
+			ok = c.Kind == String
+		case int64:
+			ok = c.Kind == Int64
+		case time.Time:
+			ok = c.Kind == Timestamp
+		}
+		if !ok {
+			return fmt.Errorf("parquet: value of type %T for column %s", v, c.Name)
+		}
+	}
+	for i, v := range row {
+		w.rows[i] = append(w.rows[i], v)
+	}
+	w.n++
+	if w.n == RowGroupRows {
+		w.flush()
+	}
+	return w.err
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() {
+	if w.n == 0 {
+		return
+	}
+	g := rowGroup{rows: int64(w.n)}
+	for i, c := range w.columns {
+		g.chunks = append(g.chunks, w.chunk(c, w.rows[i]))
+		w.rows[i] = w.rows[i][:0]
+	}
+	w.groups = append(w.groups, g)
+	w.total += int64(w.n)
+	w.n = 0
+}
+
+// chunk writes the data pages of one column of a row group.
+func (w *Writer) chunk(c Column, vals []any) chunk {
+	ch := chunk{offset: w.off, values: int64(len(vals))}
+	for lo := 0; lo < len(vals); lo += pageRows {
+		page := vals[lo:min(lo+pageRows, len(vals))]
+		var body []byte
+		if c.Optional {
+			levels := defLevels(page)
+			body = binary.LittleEndian.AppendUint32(body, uint32(len(levels)))
+			body = append(body, levels...)
+		}
+		for _, v := range page {
+			switch v := v.(type) {
+			case string:
+				body = binary.LittleEndian.AppendUint32(body, uint32(len(v)))
+				body = append(body, v...)
+			case int64:
+				body = binary.LittleEndian.AppendUint64(body, uint64(v))
+			case time.Time:
+				body = binary.LittleEndian.AppendUint64(body, uint64(v.UnixMicro()))
+			}
+		}
+		data := w.compress(body)
+
+		var h compact
+		h.open()
+		h.i32(1, pageData)
+		h.i32(2, int32(len(body)))
+		h.i32(3, int32(len(data)))
+		h.begin(5)
+		h.i32(1, int32(len(page)))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.end()
+
+		w.write(h.buf)
+		w.write(data)
+		ch.uncompressed += int64(len(h.buf) + len(body))
+		ch.compressed += int64(len(h.buf) + len(data))
+	}
+	return ch
+}
+
+func (w *Writer) compress(b []byte) []byte {
+	var buf bytes.Buffer
+	if w.zw == nil {
+		w.zw = gzip.NewWriter(&buf)
+	} else {
+		w.zw.Reset(&buf)
+	}
+	w.zw.Write(b)
+	w.zw.Close()
+	return buf.Bytes()
+}
+
+// defLevels encodes whether each value is present, 1, or null, 0, as
+// runs of the RLE/bit-packing hybrid with a bit width of 1.
+func defLevels(vals []any) []byte {
+	var out []byte
+	for i := 0; i < len(vals); {
+		present := vals[i] != nil
+		j := i + 1
+		for j < len(vals) && (vals[j] != nil) == present {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if present {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// Close writes the last row group and the file footer. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.flush()
+
+	var m compact
+	m.open()
+	m.i32(1, 1) // version
+	m.list(2, tStruct, len(w.columns)+1)
+	m.open()
+	m.str(4, "schema")
+	m.i32(5, int32(len(w.columns)))
+	m.end()
+	for _, c := range w.columns {
+		m.open()
+		rep := int32(repRequired)
+		if c.Optional {
+			rep = repOptional
+		}
+		switch c.Kind {
+		case String:
+			m.i32(1, typeByteArray)
+			m.i32(3, rep)
+			m.str(4, c.Name)
+			m.i32(6, convertedUTF8)
+			m.begin(10) // LogicalType
+			m.begin(1)  // STRING
+			m.end()
+			m.end()
+		case Int64:
+			m.i32(1, typeInt64)
+			m.i32(3, rep)
+			m.str(4, c.Name)
+		case Timestamp:
+			m.i32(1, typeInt64)
+			m.i32(3, rep)
+			m.str(4, c.Name)
+			m.i32(6, convertedTimestampMicros)
+			m.begin(10) // LogicalType
+			m.begin(8)  // TIMESTAMP
+			m.bool(1, true)
+			m.begin(2) // unit
+			m.begin(2) // MICROS
+			m.end()
+			m.end()
+			m.end()
+			m.end()
+		default:
+			return errors.New("parquet: unknown column kind")
+		}
+		m.end()
+	}
+	m.i64(3, w.total)
+	m.list(4, tStruct, len(w.groups))
+	for _, g := range w.groups {
+		m.open()
+		m.list(1, tStruct, len(g.chunks))
+		var uncompressed, compressed int64
+		for i, ch := range g.chunks {
+			c := w.columns[i]
+			m.open()
+			m.i64(2, ch.offset)
+			m.begin(3) // ColumnMetaData
+			if c.Kind == String {
+				m.i32(1, typeByteArray)
+			} else {
+				m.i32(1, typeInt64)
+			}
+			m.list(2, tI32, 2)
+			m.varint(encodingPlain)
+			m.varint(encodingRLE)
+			m.list(3, tBinary, 1)
+			m.rawStr(c.Name)
+			m.i32(4, codecGzip)
+			m.i64(5, ch.values)
+			m.i64(6, ch.uncompressed)
+			m.i64(7, ch.compressed)
+			m.i64(9, ch.offset)
+			m.end()
+			m.end()
+			uncompressed += ch.uncompressed
+			compressed += ch.compressed
+		}
+		m.i64(2, uncompressed)
+		m.i64(3, g.rows)
+		m.i64(5, g.chunks[0].offset)
+		m.i64(6, compressed)
+		m.end()
+	}
+	if w.createdBy != "" {
+		m.str(6, w.createdBy)
+	}
+	m.end()
+
+	w.write(m.buf)
+	w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(m.buf))))
+	w.write(magic)
+	return w.err
+}
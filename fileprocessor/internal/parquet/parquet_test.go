@@ -0,0 +1,368 @@
+package parquet_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"fileprocessor/internal/parquet"
+)
+
+// The tests read the files back with a decoder written from the Parquet
+// and Thrift compact protocol specifications, independently of the
+// writer: field ids below are those of parquet.thrift.
+
+// thrift decodes Thrift compact protocol structs into maps from field id
+// to value: int64 for integers, bool, []byte for binary, []any for lists
+// and map[int16]any for structs.
+type thrift struct {
+	b   []byte
+	err error
+}
+
+func (d *thrift) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (d *thrift) byte() byte {
+	if len(d.b) == 0 {
+		d.fail("truncated")
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *thrift) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail("bad varint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *thrift) varint() int64 {
+	u := d.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *thrift) value(typ byte) any {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(d.byte()))
+	case 4, 5, 6:
+		return d.varint()
+	case 8:
+		n := d.uvarint()
+		if uint64(len(d.b)) < n {
+			d.fail("truncated binary")
+			return nil
+		}
+		v := d.b[:n]
+		d.b = d.b[n:]
+		return v
+	case 9:
+		h := d.byte()
+		n, elem := uint64(h>>4), h&0x0f
+		if n == 15 {
+			n = d.uvarint()
+		}
+		var list []any
+		for range n {
+			if d.err != nil {
+				break
+			}
+			if elem == 1 || elem == 2 {
+				list = append(list, d.byte() == 1)
+			} else {
+				list = append(list, d.value(elem))
+			}
+		}
+		return list
+	case 12:
+		return d.strct()
+	}
+	d.fail("unknown type %d", typ)
+	return nil
+}
+
+func (d *thrift) strct() map[int16]any {
+	s := map[int16]any{}
+	var last int16
+	for d.err == nil {
+		h := d.byte()
+		if h == 0 {
+			return s
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(d.varint())
+		}
+		s[id] = d.value(h & 0x0f)
+		last = id
+	}
+	return s
+}
+
+func i64(v any) int64         { n, _ := v.(int64); return n }
+func str(v any) string        { b, _ := v.([]byte); return string(b) }
+func sub(v any) map[int16]any { s, _ := v.(map[int16]any); return s }
+func elems(v any) []map[int16]any {
+	var out []map[int16]any
+	l, _ := v.([]any)
+	for _, e := range l {
+		out = append(out, sub(e))
+	}
+	return out
+}
+
+// readFile checks the framing of a Parquet file and returns its metadata
+// and its rows, by column.
+func readFile(t *testing.T, file []byte, columns []parquet.Column) (meta map[int16]any, rows [][]any) {
+	t.Helper()
+	if len(file) < 12 || string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatalf("no PAR1 magic at both ends")
+	}
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := file[len(file)-8-n : len(file)-8]
+	d := &thrift{b: footer}
+	meta = d.strct()
+	if d.err != nil || len(d.b) != 0 {
+		t.Fatalf("footer: %v, %d bytes left", d.err, len(d.b))
+	}
+
+	rows = make([][]any, len(columns))
+	for g, group := range elems(meta[4]) {
+		chunks := elems(group[1])
+		if len(chunks) != len(columns) {
+			t.Fatalf("row group %d: %d column chunks, want %d", g, len(chunks), len(columns))
+		}
+		var compressed int64
+		for i, c := range chunks {
+			cm := sub(c[3])
+			compressed += i64(cm[7])
+			vals := readChunk(t, file, cm, columns[i])
+			if int64(len(vals)) != i64(cm[5]) {
+				t.Fatalf("row group %d, %s: %d values, metadata says %d", g, columns[i].Name, len(vals), i64(cm[5]))
+			}
+			if int64(len(vals)) != i64(group[3]) {
+				t.Fatalf("row group %d, %s: %d values in %d rows", g, columns[i].Name, len(vals), i64(group[3]))
+			}
+			rows[i] = append(rows[i], vals...)
+		}
+		if i64(group[6]) != compressed {
+			t.Errorf("row group %d: total_compressed_size %d, chunks add up to %d", g, i64(group[6]), compressed)
+		}
+	}
+	return meta, rows
+}
+
+// readChunk decodes the data pages of a column chunk.
+func readChunk(t *testing.T, file []byte, cm map[int16]any, c parquet.Column) []any {
+	t.Helper()
+	if i64(cm[4]) != 2 {
+		t.Fatalf("%s: codec %d, want GZIP", c.Name, i64(cm[4]))
+	}
+	off := i64(cm[9])
+	end := off + i64(cm[7])
+	var vals []any
+	for off < end {
+		d := &thrift{b: file[off:end]}
+		h := d.strct()
+		if d.err != nil {
+			t.Fatalf("%s: page header at %d: %v", c.Name, off, d.err)
+		}
+		if i64(h[1]) != 0 {
+			t.Fatalf("%s: page type %d, want DATA_PAGE", c.Name, i64(h[1]))
+		}
+		dh := sub(h[5])
+		size := i64(h[3])
+		data := d.b[:size]
+		off = end - int64(len(d.b)) + size
+
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+		if int64(len(body)) != i64(h[2]) {
+			t.Fatalf("%s: page of %d bytes, header says %d", c.Name, len(body), i64(h[2]))
+		}
+		vals = append(vals, decodePage(t, body, int(i64(dh[1])), c)...)
+	}
+	return vals
+}
+
+// decodePage decodes n PLAIN values after the RLE definition levels of an
+// optional column.
+func decodePage(t *testing.T, body []byte, n int, c parquet.Column) []any {
+	t.Helper()
+	present := make([]bool, 0, n)
+	if c.Optional {
+		l := binary.LittleEndian.Uint32(body)
+		levels := body[4 : 4+l]
+		body = body[4+l:]
+		for len(levels) > 0 {
+			h, k := binary.Uvarint(levels)
+			if h&1 != 0 {
+				t.Fatalf("%s: bit-packed run, the writer only writes RLE runs", c.Name)
+			}
+			v := levels[k]
+			levels = levels[k+1:]
+			for range h >> 1 {
+				present = append(present, v == 1)
+			}
+		}
+	} else {
+		for range n {
+			present = append(present, true)
+		}
+	}
+	if len(present) != n {
+		t.Fatalf("%s: %d definition levels for %d values", c.Name, len(present), n)
+	}
+	vals := make([]any, n)
+	for i := range vals {
+		if !present[i] {
+			continue
+		}
+		switch c.Kind {
+		case parquet.String:
+			l := binary.LittleEndian.Uint32(body)
+			vals[i] = string(body[4 : 4+l])
+			body = body[4+l:]
+		case parquet.Int64:
+			vals[i] = int64(binary.LittleEndian.Uint64(body))
+			body = body[8:]
+		case parquet.Timestamp:
+			vals[i] = time.UnixMicro(int64(binary.LittleEndian.Uint64(body))).UTC()
+			body = body[8:]
+		}
+	}
+	if len(body) != 0 {
+		t.Fatalf("%s: %d bytes after the values", c.Name, len(body))
+	}
+	return vals
+}
+
+var columns = []parquet.Column{
+	{Name: "path", Kind: parquet.String},
+	{Name: "size", Kind: parquet.Int64},
+	{Name: "mtime", Kind: parquet.Timestamp, Optional: true},
+	{Name: "error", Kind: parquet.String, Optional: true},
+}
+
+func row(i int) []any {
+	r := []any{fmt.Sprintf("dir/file-%d", i), int64(i) * 1000, nil, nil}
+	if i%3 != 0 {
+		r[2] = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Add(time.Duration(i) * time.Microsecond)
+	}
+	if i%7 == 0 {
+		r[3] = "permission denied"
+	}
+	return r
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Enough rows for several pages and a second row group.
+	for _, n := range []int{0, 1, 20000, parquet.RowGroupRows + 5} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			var b bytes.Buffer
+			w := parquet.NewWriter(&b, "fileprocessor test", columns...)
+			for i := range n {
+				if err := w.Write(row(i)...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			meta, rows := readFile(t, b.Bytes(), columns)
+			if i64(meta[3]) != int64(n) {
+				t.Errorf("num_rows = %d, want %d", i64(meta[3]), n)
+			}
+			if got := str(meta[6]); got != "fileprocessor test" {
+				t.Errorf("created_by = %q", got)
+			}
+			for i := range n {
+				for c, want := range row(i) {
+					if got := rows[c][i]; got != want {
+						t.Fatalf("row %d, %s = %v, want %v", i, columns[c].Name, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var b bytes.Buffer
+	w := parquet.NewWriter(&b, "", columns...)
+	w.Write(row(1)...)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := readFile(t, b.Bytes(), columns)
+	schema := elems(meta[2])
+	if len(schema) != len(columns)+1 {
+		t.Fatalf("%d schema elements, want the root and %d columns", len(schema), len(columns))
+	}
+	if root := schema[0]; str(root[4]) != "schema" || i64(root[5]) != int64(len(columns)) {
+		t.Errorf("root element = %v", root)
+	}
+	for i, want := range []struct {
+		typ, repetition int64
+		converted       any // nil if none
+		logical         int16
+	}{
+		{6, 0, int64(0), 1},  // BYTE_ARRAY, REQUIRED, UTF8, STRING
+		{2, 0, nil, 0},       // INT64, REQUIRED
+		{2, 1, int64(10), 8}, // INT64, OPTIONAL, TIMESTAMP_MICROS, TIMESTAMP
+		{6, 1, int64(0), 1},  // BYTE_ARRAY, OPTIONAL, UTF8, STRING
+	} {
+		e := schema[i+1]
+		if str(e[4]) != columns[i].Name || i64(e[1]) != want.typ || i64(e[3]) != want.repetition || e[6] != want.converted {
+			t.Errorf("schema element %s = %v", columns[i].Name, e)
+		}
+		if want.logical != 0 {
+			if _, ok := sub(e[10])[want.logical]; !ok {
+				t.Errorf("%s: logical type %v, want field %d", columns[i].Name, e[10], want.logical)
+			}
+		}
+	}
+	ts := sub(sub(schema[3][10])[8])
+	if ts[1] != true || sub(ts[2])[2] == nil {
+		t.Errorf("mtime timestamp type = %v, want UTC-adjusted MICROS", ts)
+	}
+}
+
+func TestWriteRejectsMismatchedValues(t *testing.T) {
+	w := parquet.NewWriter(io.Discard, "", columns...)
+	for _, r := range [][]any{
+		{"a", int64(1), nil},                  // too few
+		{"a", "1", nil, nil},                  // string for Int64
+		{nil, int64(1), nil, nil},             // null in a required column
+		{"a", int64(1), time.Now(), int64(3)}, // int64 for String
+	} {
+		if err := w.Write(r...); err == nil {
+			t.Errorf("Write(%v) succeeded", r)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package parquet
+
+import "encoding/binary"
+
+// Types of the Thrift compact protocol, in which Parquet metadata is
+// serialized.
+const (
+	tBoolTrue  = 1
+	tBoolFalse = 2
+	tI32       = 5
+	tI64       = 6
+	tBinary    = 8
+	tList      = 9
+	tStruct    = 12
+)
+
+// compact writes a Thrift struct in the compact protocol. Field ids are
+// written as deltas from the previous field of the same struct, so begin
+// and end keep a stack of them.
+type compact struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (c *compact) uvarint(v uint64) {
+	c.buf = binary.AppendUvarint(c.buf, v)
+}
+
+func (c *compact) varint(v int64) {
+	c.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (c *compact) field(id int16, typ byte) {
+	if d := id - c.last; d > 0 && d <= 15 {
+		c.buf = append(c.buf, byte(d)<<4|typ)
+	} else {
+		c.buf = append(c.buf, typ)
+		c.varint(int64(id))
+	}
+	c.last = id
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, tI32)
+	c.varint(int64(v))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, tI64)
+	c.varint(v)
+}
+
+func (c *compact) bool(id int16, v bool) {
+	if v {
+		c.field(id, tBoolTrue)
+	} else {
+		c.field(id, tBoolFalse)
+	}
+}
+
+func (c *compact) str(id int16, s string) {
+	c.field(id, tBinary)
+	c.rawStr(s)
+}
+
+func (c *compact) rawStr(s string) {
+	c.uvarint(uint64(len(s)))
+	c.buf = append(c.buf, s...)
+}
+
+// list starts a list field of n elements of type elem, which follow
+// without field headers: structs between open and end, others raw.
+func (c *compact) list(id int16, elem byte, n int) {
+	c.field(id, tList)
+	if n < 15 {
+		c.buf = append(c.buf, byte(n)<<4|elem)
+	} else {
+		c.buf = append(c.buf, 0xf0|elem)
+		c.uvarint(uint64(n))
+	}
+}
+
+// begin starts a struct field; end closes it.
+func (c *compact) begin(id int16) {
+	c.field(id, tStruct)
+	c.open()
+}
+
+// open starts a struct whose header has been written, such as a list
+// element or the message itself.
+func (c *compact) open() {
+	c.stack = append(c.stack, c.last)
+	c.last = 0
+}
+
+func (c *compact) end() {
+	c.buf = append(c.buf, 0)
+	c.last = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
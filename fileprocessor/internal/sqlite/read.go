@@ -0,0 +1,208 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxDepth bounds the b-tree depth Open follows, so that a corrupt file
+// with a cycle of pages fails rather than recursing forever.
+const maxDepth = 64
+
+// Object is an entry of a database's schema table.
+type Object struct {
+	Type     string // "table", "index", "view" or "trigger"
+	Name     string
+	Table    string
+	RootPage int
+	SQL      string
+}
+
+// DB is a database file opened for reading.
+type DB struct {
+	r        io.ReaderAt
+	pageSize int
+	usable   int
+	pages    uint32
+	Schema   []Object
+}
+
+// Open reads the header and schema of the database in r.
+func Open(r io.ReaderAt) (*DB, error) {
+	var h [100]byte
+	if _, err := r.ReadAt(h[:], 0); err != nil {
+		return nil, fmt.Errorf("sqlite: reading header: %w", err)
+	}
+	if string(h[:16]) != "SQLite format 3\x00" {
+		return nil, errors.New("sqlite: not a SQLite 3 database")
+	}
+	db := &DB{r: r, pageSize: int(binary.BigEndian.Uint16(h[16:]))}
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	if db.pageSize < 512 || db.pageSize&(db.pageSize-1) != 0 {
+		return nil, errCorrupt
+	}
+	db.usable = db.pageSize - int(h[20])
+	// The page count is only trusted if the last writer kept it, as
+	// writers before SQLite 3.7.0 did not.
+	if binary.BigEndian.Uint32(h[92:]) == binary.BigEndian.Uint32(h[24:]) {
+		db.pages = binary.BigEndian.Uint32(h[28:])
+	}
+	if enc := binary.BigEndian.Uint32(h[56:]); enc != 1 && enc != 0 {
+		return nil, errors.New("sqlite: only UTF-8 databases are supported")
+	}
+
+	rows, err := db.scan(1)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if len(row) < 5 {
+			return nil, errCorrupt
+		}
+		o := Object{}
+		o.Type, _ = row[0].(string)
+		o.Name, _ = row[1].(string)
+		o.Table, _ = row[2].(string)
+		root, _ := row[3].(int64)
+		o.RootPage = int(root)
+		o.SQL, _ = row[4].(string)
+		db.Schema = append(db.Schema, o)
+	}
+	return db, nil
+}
+
+// Rows returns the rows of the named table in rowid order.
+func (db *DB) Rows(table string) ([][]any, error) {
+	for _, o := range db.Schema {
+		if o.Type == "table" && o.Name == table {
+			return db.scan(uint32(o.RootPage))
+		}
+	}
+	return nil, fmt.Errorf("sqlite: no table %q", table)
+}
+
+func (db *DB) read(pgno uint32) ([]byte, error) {
+	if pgno < 1 || db.pages != 0 && pgno > db.pages {
+		return nil, errCorrupt
+	}
+	p := make([]byte, db.pageSize)
+	if _, err := db.r.ReadAt(p, int64(pgno-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("sqlite: reading page %d: %w", pgno, err)
+	}
+	return p[:db.usable], nil
+}
+
+// scan returns the records of the table b-tree rooted at root.
+func (db *DB) scan(root uint32) ([][]any, error) {
+	var rows [][]any
+	var walk func(pgno uint32, depth int) error
+	walk = func(pgno uint32, depth int) error {
+		if depth > maxDepth {
+			return errCorrupt
+		}
+		p, err := db.read(pgno)
+		if err != nil {
+			return err
+		}
+		h := p
+		if pgno == 1 {
+			h = p[100:]
+		}
+		kind := h[0]
+		if kind != interiorTable && kind != leafTable {
+			return fmt.Errorf("sqlite: page %d is not a table page", pgno)
+		}
+		n := int(binary.BigEndian.Uint16(h[3:]))
+		ptrs := h[8:]
+		if kind == interiorTable {
+			ptrs = h[12:]
+		}
+		if len(ptrs) < 2*n {
+			return errCorrupt
+		}
+		for i := range n {
+			off := int(binary.BigEndian.Uint16(ptrs[2*i:]))
+			if off >= len(p) {
+				return errCorrupt
+			}
+			cell := p[off:]
+			switch kind {
+			case interiorTable:
+				if len(cell) < 4 {
+					return errCorrupt
+				}
+				if err := walk(binary.BigEndian.Uint32(cell), depth+1); err != nil {
+					return err
+				}
+			default:
+				row, err := db.leafCell(cell)
+				if err != nil {
+					return err
+				}
+				rows = append(rows, row)
+			}
+		}
+		if kind == interiorTable {
+			return walk(binary.BigEndian.Uint32(h[8:]), depth+1)
+		}
+		return nil
+	}
+	if err := walk(root, 0); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// leafCell decodes the record of a table leaf cell, following its
+// overflow pages.
+func (db *DB) leafCell(cell []byte) ([]any, error) {
+	size, n := varint(cell)
+	if n == 0 {
+		return nil, errCorrupt
+	}
+	cell = cell[n:]
+	if _, n = varint(cell); n == 0 {
+		return nil, errCorrupt
+	}
+	cell = cell[n:]
+
+	// The split of payload and overflow follows the database's usable
+	// page size, not PageSize.
+	u := db.usable
+	x := u - 35
+	keep := int(size)
+	if int(size) > x {
+		m := (u-12)*32/255 - 23
+		keep = m + (int(size)-m)%(u-4)
+		if keep > x {
+			keep = m
+		}
+	}
+	if len(cell) < keep {
+		return nil, errCorrupt
+	}
+	rec := append([]byte(nil), cell[:keep]...)
+	if keep < int(size) {
+		if len(cell) < keep+4 {
+			return nil, errCorrupt
+		}
+		next := binary.BigEndian.Uint32(cell[keep:])
+		for hops := uint32(0); len(rec) < int(size); hops++ {
+			if next == 0 || db.pages != 0 && hops > db.pages {
+				return nil, errCorrupt
+			}
+			p, err := db.read(next)
+			if err != nil {
+				return nil, err
+			}
+			chunk := min(int(size)-len(rec), len(p)-4)
+			rec = append(rec, p[4:4+chunk]...)
+			next = binary.BigEndian.Uint32(p)
+		}
+	}
+	return decode(rec)
+}
@@ -0,0 +1,214 @@
+// Package sqlite writes and reads SQLite 3 database files without the
+// SQLite library: Write lays out tables and their indexes as the b-trees
+// of a fresh database, and Open reads back the rows of tables in any
+// database file, including ones SQLite itself has modified since.
+//
+// Values are nil, int64, float64, string or []byte, SQLite's NULL,
+// INTEGER, REAL, TEXT and BLOB.
+package sqlite
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errCorrupt = errors.New("sqlite: malformed database")
+
+// appendVarint appends v in SQLite's big-endian variable-length encoding:
+// seven bits per byte, high bit set on all but the last, and all eight
+// bits of a ninth byte for values of 57 bits or more.
+func appendVarint(b []byte, v uint64) []byte {
+	if v>>56 != 0 {
+		var buf [9]byte
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(b, buf[:]...)
+	}
+	var buf [8]byte
+	n := len(buf)
+	for {
+		n--
+		buf[n] = byte(v&0x7f) | 0x80
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	buf[len(buf)-1] &= 0x7f
+	return append(b, buf[n:]...)
+}
+
+func varintLen(v uint64) int {
+	n := 1
+	for v >>= 7; v != 0 && n < 9; v >>= 7 {
+		n++
+	}
+	return n
+}
+
+// varint decodes a varint from the start of b, returning its length, or
+// 0 if b ends first.
+func varint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	if len(b) < 9 {
+		return 0, 0
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// intSizes are the widths of serial types 1 to 6.
+var intSizes = [...]int{1, 2, 3, 4, 6, 8}
+
+// record encodes vals in the record format: a header of serial types
+// followed by the values.
+func record(vals []any) ([]byte, error) {
+	var types, body []byte
+	for _, v := range vals {
+		switch v := v.(type) {
+		case nil:
+			types = appendVarint(types, 0)
+		case int64:
+			switch {
+			case v == 0:
+				types = appendVarint(types, 8)
+			case v == 1:
+				types = appendVarint(types, 9)
+			default:
+				t := 0
+				for t < len(intSizes)-1 && (v < -1<<(8*intSizes[t]-1) || v >= 1<<(8*intSizes[t]-1)) {
+					t++
+				}
+				types = appendVarint(types, uint64(t+1))
+				var buf [8]byte
+				binary.BigEndian.PutUint64(buf[:], uint64(v))
+				body = append(body, buf[8-intSizes[t]:]...)
+			}
+		case float64:
+			types = appendVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = appendVarint(types, uint64(13+2*len(v)))
+			body = append(body, v...)
+		case []byte:
+			types = appendVarint(types, uint64(12+2*len(v)))
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("sqlite: unsupported value of type %T", v)
+		}
+	}
+	// The header size counts its own varint.
+	size := len(types) + 1
+	for varintLen(uint64(size))+len(types) != size {
+		size = varintLen(uint64(size)) + len(types)
+	}
+	rec := appendVarint(make([]byte, 0, size+len(body)), uint64(size))
+	rec = append(rec, types...)
+	return append(rec, body...), nil
+}
+
+// decode splits a record into its values.
+func decode(rec []byte) ([]any, error) {
+	size, n := varint(rec)
+	if n == 0 || size > uint64(len(rec)) || int(size) < n {
+		return nil, errCorrupt
+	}
+	types, body := rec[n:size], rec[size:]
+	var vals []any
+	for len(types) > 0 {
+		t, n := varint(types)
+		if n == 0 {
+			return nil, errCorrupt
+		}
+		types = types[n:]
+		var width int
+		switch {
+		case t >= 1 && t <= 6:
+			width = intSizes[t-1]
+		case t == 7:
+			width = 8
+		case t >= 12:
+			width = int((t - 12) / 2)
+		}
+		if width > len(body) {
+			return nil, errCorrupt
+		}
+		field := body[:width]
+		body = body[width:]
+		switch {
+		case t == 0:
+			vals = append(vals, nil)
+		case t <= 6:
+			var buf [8]byte
+			if field[0]&0x80 != 0 {
+				buf = [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+			}
+			copy(buf[8-width:], field)
+			vals = append(vals, int64(binary.BigEndian.Uint64(buf[:])))
+		case t == 7:
+			vals = append(vals, math.Float64frombits(binary.BigEndian.Uint64(field)))
+		case t == 8, t == 9:
+			vals = append(vals, int64(t-8))
+		case t >= 12 && t%2 == 0:
+			vals = append(vals, bytes.Clone(field))
+		case t >= 13:
+			vals = append(vals, string(field))
+		default:
+			return nil, errCorrupt
+		}
+	}
+	return vals, nil
+}
+
+// compare orders values as SQLite does with the BINARY collation: NULL
+// first, then numbers, text and blobs.
+func compare(a, b any) int {
+	class := func(v any) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case int64, float64:
+			return 1
+		case string:
+			return 2
+		default:
+			return 3
+		}
+	}
+	if ca, cb := class(a), class(b); ca != cb {
+		return ca - cb
+	}
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+		return cmp.Compare(float64(a), b.(float64))
+	case float64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, float64(b))
+		}
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	}
+	return 0
+}
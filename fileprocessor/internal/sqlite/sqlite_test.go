@@ -0,0 +1,239 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fileprocessor/internal/sqlite"
+)
+
+var table = sqlite.Table{
+	Name: "t",
+	SQL:  "CREATE TABLE t (n INTEGER, r REAL, s TEXT, b BLOB)",
+	Indexes: []sqlite.Index{
+		{Name: "t_s", SQL: "CREATE INDEX t_s ON t (s)", Columns: []int{2}},
+		{Name: "t_nb", SQL: "CREATE INDEX t_nb ON t (n, b)", Columns: []int{0, 3}},
+	},
+}
+
+// ints covers every integer serial type, and its edges.
+var ints = []int64{0, 1, -1, 127, -128, 128, 1 << 15, -1<<23 - 1, 1 << 31, -1 << 47, 1 << 47, math.MaxInt64, math.MinInt64}
+
+// row returns row i of a test table: every kind of value, NULLs, and
+// every 97th row a payload too large for its page.
+func row(i int) []any {
+	r := []any{ints[i%len(ints)], float64(i) / 8, fmt.Sprintf("row-%05d", i), []byte{byte(i), byte(i >> 8)}}
+	if i%7 == 0 {
+		r[1] = nil
+	}
+	if i%11 == 0 {
+		r[3] = nil
+	}
+	if i%97 == 0 {
+		r[2] = strings.Repeat(fmt.Sprint(i), 5000)
+		r[3] = bytes.Repeat([]byte{byte(i)}, 3*sqlite.PageSize+i)
+	}
+	return r
+}
+
+func rows(n int) [][]any {
+	rs := make([][]any, n)
+	for i := range rs {
+		rs[i] = row(i)
+	}
+	return rs
+}
+
+// write writes tables to a database file in a temporary directory.
+func write(t *testing.T, tables ...sqlite.Table) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := sqlite.Write(f, tables...); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func open(t *testing.T, path string) *sqlite.DB {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	db, err := sqlite.Open(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func expectRows(t *testing.T, db *sqlite.DB, name string, want [][]any) {
+	t.Helper()
+	got, err := db.Rows(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d rows, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("%s: row %d = %.80v, want %.80v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	// From an empty table to one with interior pages above its leaves.
+	for _, n := range []int{0, 1, 100, 5000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			tab := table
+			tab.Rows = rows(n)
+			other := sqlite.Table{Name: "u", SQL: "CREATE TABLE u (x)", Rows: [][]any{{"other"}}}
+			db := open(t, write(t, tab, other))
+
+			var names []string
+			for _, o := range db.Schema {
+				names = append(names, o.Type+" "+o.Name+" on "+o.Table)
+			}
+			if want := []string{"table t on t", "index t_s on t", "index t_nb on t", "table u on u"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("schema = %q, want %q", names, want)
+			}
+			if db.Schema[0].SQL != table.SQL {
+				t.Errorf("table SQL = %q", db.Schema[0].SQL)
+			}
+			expectRows(t, db, "t", tab.Rows)
+			expectRows(t, db, "u", other.Rows)
+			if _, err := db.Rows("missing"); err == nil {
+				t.Error("Rows of a missing table succeeded")
+			}
+		})
+	}
+}
+
+func TestWriteRejectsUnsupportedValues(t *testing.T) {
+	tab := sqlite.Table{Name: "t", SQL: "CREATE TABLE t (x)", Rows: [][]any{{int32(1)}}}
+	if err := sqlite.Write(&fileBuffer{}, tab); err == nil {
+		t.Error("Write of an int32 succeeded")
+	}
+}
+
+func TestOpenRejectsOtherFiles(t *testing.T) {
+	path := write(t, sqlite.Table{Name: "t", SQL: "CREATE TABLE t (x)"})
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"text":      bytes.Repeat([]byte("not a database\n"), 10),
+		"header":    file[:100],
+		"page size": append(append([]byte(nil), file[:16]...), append([]byte{0x03, 0x00}, file[18:]...)...),
+	} {
+		if _, err := sqlite.Open(bytes.NewReader(data)); err == nil {
+			t.Errorf("Open of %s succeeded", name)
+		}
+	}
+}
+
+// fileBuffer is an in-memory io.WriterAt.
+type fileBuffer struct{ b []byte }
+
+func (f *fileBuffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(f.b) {
+		f.b = append(f.b, make([]byte, end-len(f.b))...)
+	}
+	return copy(f.b[off:], p), nil
+}
+
+// sqlite3 runs statements with the sqlite3 shell on the database at path,
+// skipping the test if it is not installed.
+func sqlite3(t *testing.T, path, sql string) string {
+	t.Helper()
+	bin, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	out, err := exec.Command(bin, "-batch", path, sql).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 %q: %v\n%s", sql, err, out)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+func TestSQLiteReadsWrittenDatabase(t *testing.T) {
+	tab := table
+	tab.Rows = rows(5000)
+	path := write(t, tab)
+
+	// integrity_check also checks every index against its table.
+	for sql, want := range map[string]string{
+		"PRAGMA integrity_check":                                                   "ok",
+		"SELECT count(*), count(r), count(b) FROM t":                               "5000|4285|4550",
+		"SELECT rowid, n, r FROM t INDEXED BY t_s WHERE s = 'row-01234'":           "1235|-9223372036854775808|154.25",
+		"SELECT typeof(n), typeof(r), typeof(s), typeof(b) FROM t WHERE rowid = 2": "integer|real|text|blob",
+		"SELECT length(s), length(b), hex(substr(b, -1)) FROM t WHERE rowid = 195": "15000|12482|C2",
+		"SELECT count(*) FROM t INDEXED BY t_nb WHERE n = 9223372036854775807":     "384",
+		"SELECT min(n), max(n) FROM t":                                             "-9223372036854775808|9223372036854775807",
+	} {
+		if got := sqlite3(t, path, sql); got != want {
+			t.Errorf("%s = %q, want %q", sql, got, want)
+		}
+	}
+
+	// Rows SQLite has added, changed and deleted since are read as it
+	// left them.
+	sqlite3(t, path, "UPDATE t SET s = 'changed' WHERE rowid = 2; DELETE FROM t WHERE rowid % 2 = 1; INSERT INTO t VALUES (42, NULL, 'added', x'00')")
+	var want [][]any
+	for i, r := range tab.Rows {
+		if i%2 == 1 {
+			if i == 1 {
+				r = append([]any(nil), r...)
+				r[2] = "changed"
+			}
+			want = append(want, r)
+		}
+	}
+	want = append(want, []any{int64(42), nil, "added", []byte{0}})
+	expectRows(t, open(t, path), "t", want)
+}
+
+// testdata/sqlite3.db was made by the sqlite3 shell from sqlite3.sql:
+// a table of several pages, with an index, an overflowing row and rows
+// deleted since they were inserted.
+func TestOpenSQLite3Database(t *testing.T) {
+	db := open(t, "testdata/sqlite3.db")
+	if len(db.Schema) != 2 || db.Schema[0].Name != "t" || db.Schema[1].Type != "index" || db.Schema[1].Table != "t" {
+		t.Errorf("schema = %+v", db.Schema)
+	}
+
+	var want [][]any
+	for i := int64(1); i <= 600; i++ {
+		if i%50 == 0 {
+			continue
+		}
+		r := []any{[...]int64{0, i, -i * i * 1000003, i * i * i * i * i * i}[i%4], float64(i) + 0.5, fmt.Sprintf("row-%d", i), []byte(fmt.Sprintf("%08x", i))}
+		if i%6 == 0 {
+			r[1] = nil
+		}
+		if i%5 == 0 {
+			r[3] = nil
+		}
+		want = append(want, r)
+	}
+	want = append(want, []any{int64(1), 0.5, strings.Repeat("x", 20000), make([]byte, 9000)})
+	expectRows(t, db, "t", want)
+}
@@ -0,0 +1,12 @@
+-- sqlite3 testdata/sqlite3.db < testdata/sqlite3.sql
+CREATE TABLE t (n INTEGER, r REAL, s TEXT, b BLOB);
+CREATE INDEX t_s ON t (s);
+WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 600)
+INSERT INTO t SELECT
+  CASE i % 4 WHEN 0 THEN 0 WHEN 1 THEN i WHEN 2 THEN -i * i * 1000003 ELSE i * i * i * i * i * i END,
+  CASE WHEN i % 6 = 0 THEN NULL ELSE i + 0.5 END,
+  'row-' || i,
+  CASE WHEN i % 5 = 0 THEN NULL ELSE CAST(printf('%08x', i) AS BLOB) END
+FROM c;
+INSERT INTO t VALUES (1, 0.5, substr(replace(hex(zeroblob(10000)), '0', 'x'), 1, 20000), zeroblob(9000));
+DELETE FROM t WHERE rowid % 50 = 0;
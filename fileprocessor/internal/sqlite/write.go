@@ -0,0 +1,329 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// PageSize is the page size of the databases Write creates.
+const PageSize = 4096
+
+// versionNumber is the SQLITE_VERSION_NUMBER recorded in the header of
+// written databases, that of the release whose file format they follow.
+const versionNumber = 3046000
+
+const (
+	interiorIndex = 0x02
+	interiorTable = 0x05
+	leafIndex     = 0x0a
+	leafTable     = 0x0d
+)
+
+// Table is a table to write, with its rows and indexes. SQL is the CREATE
+// TABLE statement SQLite will read the columns from; rows get rowids from
+// 1 in order.
+type Table struct {
+	Name    string
+	SQL     string
+	Rows    [][]any
+	Indexes []Index
+}
+
+// Index is an index on a Table. SQL is its CREATE INDEX statement and
+// Columns the positions of the indexed columns in the table's rows.
+type Index struct {
+	Name    string
+	SQL     string
+	Columns []int
+}
+
+// Write writes a database holding tables to w, which should be empty.
+func Write(w io.WriterAt, tables ...Table) error {
+	b := &builder{w: w, next: 2} // page 1 is written last
+	var schema [][]any
+	for _, t := range tables {
+		root := b.table(t.Rows)
+		schema = append(schema, []any{"table", t.Name, t.Name, int64(root), t.SQL})
+		for _, ix := range t.Indexes {
+			root := b.index(t.Rows, ix.Columns)
+			schema = append(schema, []any{"index", ix.Name, t.Name, int64(root), ix.SQL})
+		}
+	}
+	if b.err != nil {
+		return b.err
+	}
+
+	// The schema table is rooted on page 1, after the file header.
+	var cells [][]byte
+	used := 100 + 8
+	for i, row := range schema {
+		cell, err := b.tableCell(int64(i+1), row)
+		if err != nil {
+			return err
+		}
+		cells = append(cells, cell)
+		used += 2 + len(cell)
+	}
+	if used > PageSize {
+		return errors.New("sqlite: schema does not fit on the first page")
+	}
+	page := b.page(leafTable, cells, 0, 100)
+	h := page[:100]
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], PageSize)
+	h[18], h[19] = 1, 1                          // rollback journal, not WAL
+	h[21], h[22], h[23] = 64, 32, 32             // payload fractions
+	binary.BigEndian.PutUint32(h[24:], 1)        // change counter
+	binary.BigEndian.PutUint32(h[28:], b.next-1) // pages in the file
+	binary.BigEndian.PutUint32(h[40:], 1)        // schema cookie
+	binary.BigEndian.PutUint32(h[44:], 4)        // schema format
+	binary.BigEndian.PutUint32(h[56:], 1)        // UTF-8
+	binary.BigEndian.PutUint32(h[92:], 1)        // version-valid-for
+	binary.BigEndian.PutUint32(h[96:], versionNumber)
+	b.put(1, page)
+	return b.err
+}
+
+// builder lays out b-trees page by page, bottom up, writing each page as
+// soon as it is complete.
+type builder struct {
+	w    io.WriterAt
+	next uint32
+	err  error
+}
+
+func (b *builder) alloc() uint32 {
+	n := b.next
+	b.next++
+	return n
+}
+
+func (b *builder) put(n uint32, page []byte) {
+	if b.err == nil {
+		_, b.err = b.w.WriteAt(page, int64(n-1)*PageSize)
+	}
+}
+
+// page lays out a b-tree page with its header at offset, cell pointers
+// after it and cells packed from the end.
+func (b *builder) page(kind byte, cells [][]byte, right uint32, offset int) []byte {
+	p := make([]byte, PageSize)
+	h := p[offset:]
+	h[0] = kind
+	binary.BigEndian.PutUint16(h[3:], uint16(len(cells)))
+	ptr := offset + 8
+	if kind == interiorIndex || kind == interiorTable {
+		binary.BigEndian.PutUint32(h[8:], right)
+		ptr += 4
+	}
+	end := PageSize
+	for _, c := range cells {
+		end -= len(c)
+		copy(p[end:], c)
+		binary.BigEndian.PutUint16(p[ptr:], uint16(end))
+		ptr += 2
+	}
+	binary.BigEndian.PutUint16(h[5:], uint16(end))
+	return p
+}
+
+// local is how much of a payload of n bytes stays in its cell, the rest
+// going to overflow pages.
+func local(n int, table bool) int {
+	const u = PageSize
+	x := u - 35
+	if !table {
+		x = (u-12)*64/255 - 23
+	}
+	if n <= x {
+		return n
+	}
+	m := (u-12)*32/255 - 23
+	if k := m + (n-m)%(u-4); k <= x {
+		return k
+	}
+	return m
+}
+
+// cellSize is the size of a cell holding a payload of n bytes after a
+// prefix of the given size.
+func cellSize(prefix, n int, table bool) int {
+	size := prefix + local(n, table)
+	if size-prefix < n {
+		size += 4
+	}
+	return size
+}
+
+// payload appends the part of p that stays in a cell, writing the rest
+// to a chain of overflow pages whose first page number ends the cell.
+func (b *builder) payload(cell, p []byte, table bool) []byte {
+	n := local(len(p), table)
+	cell = append(cell, p[:n]...)
+	rest := p[n:]
+	if len(rest) == 0 {
+		return cell
+	}
+	first := b.next
+	cell = binary.BigEndian.AppendUint32(cell, first)
+	for len(rest) > 0 {
+		page := make([]byte, PageSize)
+		pgno := b.alloc()
+		chunk := min(len(rest), PageSize-4)
+		copy(page[4:], rest[:chunk])
+		rest = rest[chunk:]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(page, pgno+1)
+		}
+		b.put(pgno, page)
+	}
+	return cell
+}
+
+func (b *builder) tableCell(rowid int64, row []any) ([]byte, error) {
+	rec, err := record(row)
+	if err != nil {
+		return nil, err
+	}
+	cell := appendVarint(nil, uint64(len(rec)))
+	cell = appendVarint(cell, uint64(rowid))
+	return b.payload(cell, rec, true), nil
+}
+
+// table writes the b-tree of a table and returns its root page.
+func (b *builder) table(rows [][]any) uint32 {
+	var leaves []uint32
+	var keys [][]byte
+	var cells [][]byte
+	used := 8
+	flush := func(last int64) {
+		n := b.alloc()
+		b.put(n, b.page(leafTable, cells, 0, 0))
+		leaves = append(leaves, n)
+		keys = append(keys, appendVarint(nil, uint64(last)))
+		cells, used = nil, 8
+	}
+	for i, row := range rows {
+		rowid := int64(i + 1)
+		rec, err := record(row)
+		if err != nil {
+			b.err = err
+			return 0
+		}
+		size := cellSize(varintLen(uint64(len(rec)))+varintLen(uint64(rowid)), len(rec), true)
+		if used+2+size > PageSize && len(cells) > 0 {
+			flush(rowid - 1)
+		}
+		cell := appendVarint(nil, uint64(len(rec)))
+		cell = appendVarint(cell, uint64(rowid))
+		cells = append(cells, b.payload(cell, rec, true))
+		used += 2 + size
+	}
+	flush(int64(len(rows)))
+	return b.interior(interiorTable, leaves, keys[:len(keys)-1])
+}
+
+// index writes the b-tree of an index on the given columns of rows and
+// returns its root page. Unlike a table's, an index b-tree keeps entries
+// in its interior pages too: the entry between two leaves is in neither.
+func (b *builder) index(rows [][]any, columns []int) uint32 {
+	entries := make([][]any, len(rows))
+	for i, row := range rows {
+		e := make([]any, 0, len(columns)+1)
+		for _, c := range columns {
+			e = append(e, row[c])
+		}
+		entries[i] = append(e, int64(i+1))
+	}
+	slices.SortFunc(entries, func(x, y []any) int {
+		for i := range x {
+			if c := compare(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	var leaves []uint32
+	var seps [][]byte
+	var cells [][]byte
+	used := 8
+	flush := func() {
+		n := b.alloc()
+		b.put(n, b.page(leafIndex, cells, 0, 0))
+		leaves = append(leaves, n)
+		cells, used = nil, 8
+	}
+	for i, e := range entries {
+		rec, err := record(e)
+		if err != nil {
+			b.err = err
+			return 0
+		}
+		size := cellSize(varintLen(uint64(len(rec))), len(rec), false)
+		cell := b.payload(appendVarint(nil, uint64(len(rec))), rec, false)
+		if used+2+size <= PageSize || len(cells) == 0 {
+			cells = append(cells, cell)
+			used += 2 + size
+			continue
+		}
+		// The entry separates this leaf from the next, unless it is the
+		// last one, which would leave the next leaf empty: then the last
+		// entry of this leaf separates instead.
+		if i == len(entries)-1 {
+			sep := cells[len(cells)-1]
+			cells = cells[:len(cells)-1]
+			flush()
+			seps = append(seps, sep)
+			cells, used = [][]byte{cell}, 8+2+size
+			continue
+		}
+		flush()
+		seps = append(seps, cell)
+	}
+	flush()
+	return b.interior(interiorIndex, leaves, seps)
+}
+
+// interior writes the interior levels above children, seps[i] being the
+// key between children[i] and children[i+1], and returns the root page.
+func (b *builder) interior(kind byte, children []uint32, seps [][]byte) uint32 {
+	for len(children) > 1 {
+		var upChildren []uint32
+		var upSeps [][]byte
+		var cells [][]byte
+		used := 12
+		flush := func(right uint32) {
+			n := b.alloc()
+			b.put(n, b.page(kind, cells, right, 0))
+			upChildren = append(upChildren, n)
+			cells, used = nil, 12
+		}
+		for i, sep := range seps {
+			cell := binary.BigEndian.AppendUint32(nil, children[i])
+			cell = append(cell, sep...)
+			if used+2+len(cell) <= PageSize || len(cells) == 0 {
+				cells = append(cells, cell)
+				used += 2 + len(cell)
+				continue
+			}
+			// As for index leaves, keep the page after a split from
+			// ending up with no cells.
+			if i == len(seps)-1 {
+				last := cells[len(cells)-1]
+				cells = cells[:len(cells)-1]
+				flush(binary.BigEndian.Uint32(last))
+				upSeps = append(upSeps, last[4:])
+				cells, used = [][]byte{cell}, 12+2+len(cell)
+				continue
+			}
+			flush(children[i])
+			upSeps = append(upSeps, sep)
+		}
+		flush(children[len(children)-1])
+		children, seps = upChildren, upSeps
+	}
+	return children[0]
+}
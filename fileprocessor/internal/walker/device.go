@@ -0,0 +1,51 @@
+package walker
+
+import (
+	"io/fs"
+	"os"
+)
+
+// device keeps a OneFileSystem walk on the filesystem of its root. A nil
+// *device accepts everything.
+type device struct{ id uint64 }
+
+func newDevice(root string, opts Options) *device {
+	if !opts.OneFileSystem {
+		return nil
+	}
+	var info fs.FileInfo
+	var err error
+	if opts.FS != nil {
+		info, err = fs.Stat(opts.FS, root)
+	} else {
+		info, err = os.Stat(root)
+	}
+	if err != nil {
+		return nil
+	}
+	id, ok := deviceID(info)
+	if !ok {
+		return nil
+	}
+	return &device{id: id}
+}
+
+// same reports whether the directory described by info is on the root's
+// filesystem. Directories whose device can't be told are accepted.
+func (d *device) same(info fs.FileInfo) bool {
+	if d == nil {
+		return true
+	}
+	id, ok := deviceID(info)
+	return !ok || id == d.id
+}
+
+// sameEntry is same for a directory entry, skipping it if it can't be
+// examined.
+func (d *device) sameEntry(e fs.DirEntry) bool {
+	if d == nil {
+		return true
+	}
+	info, err := e.Info()
+	return err == nil && d.same(info)
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package walker
+
+import "io/fs"
+
+// hiddenAttr is false: outside Windows only the leading dot hides files.
+func hiddenAttr(fs.DirEntry) bool { return false }
@@ -0,0 +1,18 @@
+//go:build windows
+
+package walker
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// hiddenAttr reports whether d has the hidden file attribute.
+func hiddenAttr(d fs.DirEntry) bool {
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
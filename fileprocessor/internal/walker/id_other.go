@@ -0,0 +1,16 @@
+//go:build !unix
+
+package walker
+
+import "io/fs"
+
+// fileID has no portable identity to offer; visited falls back to
+// resolved paths.
+func fileID(fs.FileInfo) (any, bool) {
+	return nil, false
+}
+
+// deviceID is unknown too, so OneFileSystem has no effect.
+func deviceID(fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
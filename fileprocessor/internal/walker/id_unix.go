@@ -0,0 +1,26 @@
+//go:build unix
+
+package walker
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+type devIno struct{ dev, ino uint64 }
+
+func fileID(info fs.FileInfo) (any, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, false
+	}
+	return devIno{uint64(st.Dev), uint64(st.Ino)}, true
+}
+
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
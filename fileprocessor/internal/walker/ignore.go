@@ -0,0 +1,257 @@
+package walker
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles are read in every directory of a Gitignore walk, in
+// increasing order of precedence. .ignore is ripgrep's tool-neutral
+// variant of .gitignore.
+var ignoreFiles = []string{".git/info/exclude", ".gitignore", ".ignore"}
+
+// ignoreRule is one pattern line of an ignore file.
+type ignoreRule struct {
+	segs    []string // path.Match patterns, "**" for any number of segments
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnore reads gitignore(5) syntax. Patterns that path.Match can't
+// compile are dropped.
+func parseIgnore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		s := strings.TrimSuffix(string(line), "\r")
+		for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+			s = s[:len(s)-1]
+		}
+		if s == "" || s[0] == '#' {
+			continue
+		}
+		var r ignoreRule
+		switch {
+		case s[0] == '!':
+			r.negate, s = true, s[1:]
+		case strings.HasPrefix(s, `\!`), strings.HasPrefix(s, `\#`):
+			s = s[1:]
+		}
+		if strings.HasSuffix(s, "/") {
+			r.dirOnly, s = true, strings.TrimRight(s, "/")
+		}
+		if s == "" {
+			continue
+		}
+		// A slash anywhere but at the end ties the pattern to the
+		// directory of the ignore file; otherwise it matches at any depth.
+		anchored := strings.Contains(s, "/")
+		r.segs = strings.Split(strings.TrimPrefix(s, "/"), "/")
+		valid := true
+		for i, seg := range r.segs {
+			seg = strings.ReplaceAll(seg, "[!", "[^")
+			if _, err := path.Match(seg, ""); err != nil {
+				valid = false
+			}
+			r.segs[i] = seg
+		}
+		if !valid {
+			continue
+		}
+		if !anchored {
+			r.segs = append([]string{"**"}, r.segs...)
+		}
+		if r.segs[len(r.segs)-1] == "**" {
+			// "dir/**" matches what is inside dir, not dir itself.
+			r.segs = append(r.segs, "*")
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// matchSegs matches a slash-split path against a rule's segments.
+func matchSegs(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegs(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegs(pattern[1:], name[1:])
+}
+
+// ignores is the chain of ignore files in effect in a directory, the
+// innermost first. A nil *ignores ignores nothing.
+type ignores struct {
+	parent *ignores
+	dir    string // the directory the rules apply below, in walk form
+	prefix string // dir relative to the ignore file's own directory, if above the root
+	sep    string
+	rules  []ignoreRule
+}
+
+// newIgnores returns the chain a walk of root starts with: opts.Exclude,
+// anchored at root, and for a Gitignore walk the ignore files of root's
+// parent directories up to the repository holding it, if any. On an fs.FS
+// nothing above root is read.
+func newIgnores(root string, opts Options) *ignores {
+	var base *ignores
+	if len(opts.Exclude) > 0 {
+		sep, clean := string(filepath.Separator), filepath.Clean
+		if opts.FS != nil {
+			sep, clean = "/", path.Clean
+		}
+		base = &ignores{dir: clean(root), sep: sep, rules: parseIgnore([]byte(strings.Join(opts.Exclude, "\n")))}
+	}
+	if !opts.Gitignore || opts.FS != nil {
+		return base
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return base
+	}
+	if _, err := os.Stat(filepath.Join(abs, ".git")); err == nil {
+		return base
+	}
+	root = filepath.Clean(root)
+	sep := string(filepath.Separator)
+	var chain []*ignores
+	prefix := filepath.Base(abs)
+	for dir := filepath.Dir(abs); ; {
+		if rules := readIgnores(dir, opts); len(rules) > 0 {
+			chain = append(chain, &ignores{dir: root, prefix: prefix, sep: sep, rules: rules})
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			// Link the chain outermost first, so that inner files win.
+			ig := base
+			for i := len(chain) - 1; i >= 0; i-- {
+				chain[i].parent, ig = ig, chain[i]
+			}
+			return ig
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return base
+		}
+		prefix = filepath.Base(dir) + "/" + prefix
+		dir = parent
+	}
+}
+
+// readIgnores returns the rules of the ignore files in dir, lowest
+// precedence first.
+func readIgnores(dir string, opts Options) []ignoreRule {
+	var rules []ignoreRule
+	for _, name := range ignoreFiles {
+		var data []byte
+		var err error
+		if opts.FS != nil {
+			data, err = fs.ReadFile(opts.FS, path.Join(dir, name))
+		} else {
+			data, err = os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		if err == nil {
+			rules = append(rules, parseIgnore(data)...)
+		}
+	}
+	return rules
+}
+
+// enter returns the chain in effect inside dir: ig and the ignore files
+// dir holds. Without Gitignore it returns ig.
+func (ig *ignores) enter(dir string, opts Options) *ignores {
+	if !opts.Gitignore {
+		return ig
+	}
+	sep, clean := string(filepath.Separator), filepath.Clean
+	if opts.FS != nil {
+		sep, clean = "/", path.Clean
+	}
+	dir = clean(dir)
+	if rules := readIgnores(dir, opts); len(rules) > 0 {
+		return &ignores{parent: ig, dir: dir, sep: sep, rules: rules}
+	}
+	return ig
+}
+
+// rel returns p relative to the directory of n's ignore file, or false if
+// p is not below n.dir.
+func (n *ignores) rel(p string) (string, bool) {
+	var rel string
+	switch {
+	case n.dir == ".":
+		rel = p
+	case strings.HasSuffix(n.dir, n.sep):
+		rel, _ = strings.CutPrefix(p, n.dir)
+		if len(rel) == len(p) {
+			return "", false
+		}
+	default:
+		var ok bool
+		if rel, ok = strings.CutPrefix(p, n.dir+n.sep); !ok {
+			return "", false
+		}
+	}
+	rel = strings.ReplaceAll(rel, n.sep, "/")
+	if n.prefix != "" {
+		rel = n.prefix + "/" + rel
+	}
+	return rel, true
+}
+
+// clean cleans p the way enter cleans the directories of the chain.
+func (n *ignores) clean(p string) string {
+	if n.sep == "/" {
+		return path.Clean(p)
+	}
+	return filepath.Clean(p)
+}
+
+// ignored reports whether the file or directory at p is excluded. The
+// innermost ignore file with a matching pattern decides, and within a
+// file the last matching pattern.
+func (ig *ignores) ignored(p string, isDir bool) bool {
+	for n := ig; n != nil; n = n.parent {
+		rel, ok := n.rel(p)
+		if !ok {
+			continue
+		}
+		name := strings.Split(rel, "/")
+		for i := len(n.rules) - 1; i >= 0; i-- {
+			r := n.rules[i]
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if matchSegs(r.segs, name) {
+				return !r.negate
+			}
+		}
+	}
+	return false
+}
+
+// within trims the chain of a depth-first walk to p and the directories
+// that contain it, which is what remains in effect once the walk has moved
+// on to p.
+func (ig *ignores) within(p string) *ignores {
+	for ig != nil && ig.prefix == "" {
+		if _, ok := ig.rel(p); ok || ig.clean(p) == ig.dir {
+			break
+		}
+		ig = ig.parent
+	}
+	return ig
+}
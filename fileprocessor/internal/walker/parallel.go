@@ -0,0 +1,178 @@
+package walker
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// queuedDir is a directory waiting to be read, its depth below the root
+// and the ignore files in effect in its parent.
+type queuedDir struct {
+	path    string
+	depth   int
+	ignores *ignores
+}
+
+// dirQueue is the shared list of directories still to be read. pending
+// counts directories queued or being read; the walk is over when it
+// drops to zero.
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    sync.Cond
+	dirs    []queuedDir
+	pending int
+	err     error
+}
+
+func (q *dirQueue) push(dir queuedDir) {
+	q.mu.Lock()
+	q.dirs = append(q.dirs, dir)
+	q.pending++
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// pop waits for a directory. ok is false once the walk is over or has
+// failed.
+func (q *dirQueue) pop() (dir queuedDir, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.dirs) == 0 && q.pending > 0 && q.err == nil {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 || q.err != nil {
+		return queuedDir{}, false
+	}
+	dir = q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	return dir, true
+}
+
+func (q *dirQueue) done(err error) {
+	q.mu.Lock()
+	q.pending--
+	if err != nil && q.err == nil {
+		q.err = err
+	}
+	finished := q.pending == 0 || q.err != nil
+	q.mu.Unlock()
+	if finished {
+		q.cond.Broadcast()
+	}
+}
+
+// walkParallel is Walk with opts.Workers goroutines reading directories
+// concurrently, for trees where listing directories is the bottleneck,
+// such as millions of small files on a network filesystem. fn is called
+// concurrently and files arrive in no particular order. Entries are
+// examined as by the sequential walk, including its handling of symbolic
+// links.
+func walkParallel(ctx context.Context, root string, opts Options, fn func(Entry) error) error {
+	readDir, lstat, join := os.ReadDir, os.Lstat, filepath.Join
+	if opts.FS != nil {
+		readDir = func(name string) ([]fs.DirEntry, error) { return fs.ReadDir(opts.FS, name) }
+		lstat = func(name string) (fs.FileInfo, error) { return fs.Stat(opts.FS, name) }
+		join = path.Join
+	}
+
+	info, err := lstat(root)
+	if err != nil {
+		return err
+	}
+	if opts.FS == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := os.Stat(root); err == nil && target.IsDir() {
+			info = target
+		}
+	}
+	if !info.IsDir() {
+		return fn(Entry{Path: root, Info: info})
+	}
+
+	seen := newVisited(opts)
+	dev := newDevice(root, opts)
+	res := newResume(root, opts)
+	q := &dirQueue{}
+	q.cond.L = &q.mu
+	q.push(queuedDir{path: root, ignores: newIgnores(root, opts)})
+
+	var wg sync.WaitGroup
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dir, ok := q.pop()
+				if !ok {
+					return
+				}
+				if !seen.enter(dir.path) {
+					q.done(nil)
+					continue
+				}
+				q.done(readOne(ctx, q, dir, opts, dev, res, readDir, join, fn))
+			}
+		}()
+	}
+	wg.Wait()
+	return q.err
+}
+
+// readOne lists dir, queueing its subdirectories and passing its other
+// entries to fn.
+func readOne(ctx context.Context, q *dirQueue, dir queuedDir, opts Options, dev *device, res *resume,
+	readDir func(string) ([]fs.DirEntry, error), join func(...string) string, fn func(Entry) error) error {
+	entries, err := readDir(dir.path)
+	if err != nil {
+		if dir.depth == 0 {
+			return err
+		}
+		return nil
+	}
+	ig := dir.ignores.enter(dir.path, opts)
+	for _, d := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p := join(dir.path, d.Name())
+		sub := queuedDir{path: p, depth: dir.depth + 1, ignores: ig}
+		if opts.hidden(d) {
+			continue
+		}
+		if d.IsDir() {
+			if opts.descend(d.Name(), sub.depth) && !opts.marked(p) && dev.sameEntry(d) && !res.skipDir(p) && !ig.ignored(p, true) {
+				q.push(sub)
+			}
+			continue
+		}
+		if opts.FS == nil && d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Stat(p)
+			if err != nil && opts.SkipDangling {
+				continue
+			}
+			if err == nil && target.IsDir() {
+				if opts.FollowSymlinks && opts.descend(d.Name(), sub.depth) && !opts.marked(p) && dev.same(target) && !res.skipDir(p) && !ig.ignored(p, true) {
+					q.push(sub)
+				}
+				continue
+			}
+		}
+		if res.skipFile(p, false) || ig.ignored(p, false) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			if opts.OnSkip != nil {
+				opts.OnSkip(p, err)
+			}
+			continue
+		}
+		if err := fn(Entry{Path: p, Info: info}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package walker
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cacheDirTag is the name of the marker file of the Cache Directory
+// Tagging Specification, and cacheDirSignature the line it must start with.
+const (
+	cacheDirTag       = "CACHEDIR.TAG"
+	cacheDirSignature = "Signature: 8a477f597d28d172789f06886806bc55"
+)
+
+// descend reports whether the walk enters the directory called name at
+// depth, counting the root's children as depth 1.
+func (o Options) descend(name string, depth int) bool {
+	if o.MaxDepth > 0 && depth >= o.MaxDepth {
+		return false
+	}
+	if o.Gitignore && name == ".git" {
+		return false
+	}
+	for _, pattern := range o.Prune {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// marked reports whether the directory at dir holds one of the files
+// named in o.PruneMarkers. A CACHEDIR.TAG only counts if it carries the
+// signature, as tar --exclude-caches requires.
+func (o Options) marked(dir string) bool {
+	for _, name := range o.PruneMarkers {
+		var f fs.File
+		var err error
+		if o.FS != nil {
+			f, err = o.FS.Open(path.Join(dir, name))
+		} else {
+			f, err = os.Open(filepath.Join(dir, name))
+		}
+		if err != nil {
+			continue
+		}
+		ok := true
+		if name == cacheDirTag {
+			head := make([]byte, len(cacheDirSignature))
+			_, err := io.ReadFull(f, head)
+			ok = err == nil && bytes.Equal(head, []byte(cacheDirSignature))
+		}
+		f.Close()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hidden reports whether a SkipHidden walk leaves out the entry d.
+func (o Options) hidden(d fs.DirEntry) bool {
+	return o.SkipHidden && (strings.HasPrefix(d.Name(), ".") || hiddenAttr(d))
+}
+
+// diskDepth returns how many levels below root the disk path p is.
+func diskDepth(root, p string) int {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// fsDepth is diskDepth for slash-separated fs.FS paths.
+func fsDepth(root, p string) int {
+	if p == root {
+		return 0
+	}
+	if root != "." {
+		p = strings.TrimPrefix(p, root+"/")
+	}
+	return strings.Count(p, "/") + 1
+}
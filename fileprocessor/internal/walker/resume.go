@@ -0,0 +1,89 @@
+package walker
+
+import (
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// resume skips everything a walk reaches up to and including the marker
+// path, in the order of the sequential walk: directory entries sorted by
+// name, a directory's contents before its next sibling. Directories that
+// lie entirely before the marker are not entered. A nil *resume skips
+// nothing.
+type resume struct {
+	rel    func(path string) []string
+	marker []string
+	// passed is set once a file after the marker has been seen; in a
+	// sequential walk everything later comes after it too.
+	passed atomic.Bool
+}
+
+func newResume(root string, opts Options) *resume {
+	if opts.StartAfter == "" {
+		return nil
+	}
+	r := &resume{}
+	if opts.FS != nil {
+		r.rel = func(p string) []string {
+			if p == root {
+				return nil
+			}
+			if root != "." {
+				p = strings.TrimPrefix(p, root+"/")
+			}
+			return strings.Split(p, "/")
+		}
+	} else {
+		r.rel = func(p string) []string {
+			rel, err := filepath.Rel(root, p)
+			if err != nil || rel == "." {
+				return nil
+			}
+			return strings.Split(rel, string(filepath.Separator))
+		}
+	}
+	r.marker = r.rel(opts.StartAfter)
+	return r
+}
+
+// compare orders path against the marker in walk order. ancestor reports
+// whether path is a directory on the way to the marker.
+func (r *resume) compare(path string) (cmp int, ancestor bool) {
+	parts := r.rel(path)
+	for i := 0; i < len(parts) && i < len(r.marker); i++ {
+		if c := strings.Compare(parts[i], r.marker[i]); c != 0 {
+			return c, false
+		}
+	}
+	switch {
+	case len(parts) < len(r.marker):
+		return -1, true
+	case len(parts) > len(r.marker):
+		return 1, false
+	}
+	return 0, false
+}
+
+// skipDir reports whether the directory at path holds nothing after the
+// marker.
+func (r *resume) skipDir(path string) bool {
+	if r == nil || r.passed.Load() {
+		return false
+	}
+	cmp, ancestor := r.compare(path)
+	return cmp < 0 && !ancestor
+}
+
+// skipFile reports whether the file at path comes no later than the
+// marker.
+func (r *resume) skipFile(path string, sequential bool) bool {
+	if r == nil || r.passed.Load() {
+		return false
+	}
+	cmp, _ := r.compare(path)
+	if cmp > 0 && sequential {
+		r.passed.Store(true)
+	}
+	return cmp <= 0
+}
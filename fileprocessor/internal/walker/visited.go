@@ -0,0 +1,50 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// visited records the directories a link-following walk has entered, by
+// file identity where the platform provides one and by resolved path
+// otherwise. A nil *visited accepts everything.
+type visited struct {
+	mu   sync.Mutex
+	dirs map[any]bool
+}
+
+func newVisited(opts Options) *visited {
+	if !opts.FollowSymlinks {
+		return nil
+	}
+	return &visited{dirs: make(map[any]bool)}
+}
+
+// enter reports whether dir is seen for the first time.
+func (v *visited) enter(dir string) bool {
+	if v == nil {
+		return true
+	}
+	var key any
+	if info, err := os.Stat(dir); err == nil {
+		if id, ok := fileID(info); ok {
+			key = id
+		}
+	}
+	if key == nil {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return true
+		}
+		key = resolved
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.dirs[key] {
+		return false
+	}
+	v.dirs[key] = true
+	return true
+}
@@ -0,0 +1,204 @@
+// Package walker enumerates the files below a root directory.
+package walker
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Entry is a regular file found by the walker.
+type Entry struct {
+	Path string
+	Info fs.FileInfo
+}
+
+// Options controls a walk.
+type Options struct {
+	// FS, when set, is walked instead of the local disk and root is a
+	// slash-separated path within it. Symbolic links in an FS are never
+	// followed.
+	FS fs.FS
+	// OnSkip is called for every entry the walker could not examine.
+	OnSkip func(path string, err error)
+	// Workers, when above one, reads that many directories concurrently.
+	// fn and OnSkip are then called concurrently and in no particular
+	// order.
+	Workers int
+	// FollowSymlinks descends into symbolic links to directories. Each
+	// directory is entered at most once, so link cycles end the descent.
+	// Without it, links to directories are ignored; a root that is itself
+	// a link is always followed.
+	FollowSymlinks bool
+	// SkipDangling ignores links whose target doesn't exist or can't be
+	// examined. By default they are passed to fn like any other entry, so
+	// the handler reports them when it fails to open them.
+	SkipDangling bool
+	// MaxDepth, when positive, stops the walk that many levels below the
+	// root: 1 visits only the root's own files.
+	MaxDepth int
+	// Prune lists filepath.Match patterns; directories whose name matches
+	// one are not descended into. The root itself is never pruned.
+	Prune []string
+	// OneFileSystem keeps the walk on the filesystem of the root: mount
+	// points of other filesystems are not descended into. It has no effect
+	// where the platform reports no device numbers, such as Windows.
+	OneFileSystem bool
+	// StartAfter resumes an interrupted walk: files up to and including
+	// this path are skipped, in sequential walk order, and directories
+	// holding only such files are not entered. It is a path below root as
+	// the walk reports it, and need not exist any more.
+	StartAfter string
+	// Gitignore skips what .gitignore, .ignore and .git/info/exclude files
+	// exclude, read in every directory of the walk and, on disk, in the
+	// parents of root up to its repository. .git directories are skipped.
+	Gitignore bool
+	// Exclude lists patterns in .gitignore syntax, anchored at root, for
+	// files and directories to skip. Ignore files read for Gitignore take
+	// precedence, as they do over git's core.excludesFile.
+	Exclude []string
+	// PruneMarkers lists file names, such as .nobackup; directories that
+	// hold one are not descended into. A CACHEDIR.TAG needs the signature
+	// of the Cache Directory Tagging Specification. The root itself is
+	// never pruned.
+	PruneMarkers []string
+	// SkipHidden leaves out files and directories whose name starts with
+	// a dot and, on Windows, those with the hidden attribute. The root is
+	// always walked.
+	SkipHidden bool
+}
+
+// Walk calls fn for every file below root, in lexical order. Links to
+// files are passed to fn with the link's own FileInfo. Entries that can't
+// be read are reported to opts.OnSkip and otherwise ignored, except for
+// root: Walk returns the error if root itself can't be examined or listed.
+// Walk stops at the first error returned by fn or when ctx is done; a
+// cancelled walk is not reported as a failure.
+func Walk(ctx context.Context, root string, opts Options, fn func(Entry) error) error {
+	var err error
+	switch {
+	case opts.Workers > 1:
+		err = walkParallel(ctx, root, opts, fn)
+	case opts.FS != nil:
+		err = walkFS(ctx, root, opts, newDevice(root, opts), newResume(root, opts), fn)
+	default:
+		err = walkDisk(ctx, root, root, opts, walkState{newVisited(opts), newDevice(root, opts), newResume(root, opts), newIgnores(root, opts)}, fn)
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// walkState is what the nested walks of followed links share.
+type walkState struct {
+	seen    *visited
+	dev     *device
+	resume  *resume
+	ignores *ignores // in effect at dir
+}
+
+// walkDisk walks the tree at dir, where root is the path the walk started
+// from.
+func walkDisk(ctx context.Context, root, dir string, opts Options, st walkState, fn func(Entry) error) error {
+	ig := st.ignores
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if filepath.Clean(path) == filepath.Clean(root) {
+				return err
+			}
+			if (d == nil || !d.IsDir()) && opts.OnSkip != nil {
+				opts.OnSkip(path, err)
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ig = ig.within(path)
+		if d.IsDir() {
+			if path != dir && (!opts.descend(d.Name(), diskDepth(root, path)) || opts.hidden(d) || opts.marked(path) || !st.dev.sameEntry(d) || st.resume.skipDir(path) || ig.ignored(path, true)) {
+				return filepath.SkipDir
+			}
+			if !st.seen.enter(path) {
+				return filepath.SkipDir
+			}
+			ig = ig.enter(path, opts)
+			return nil
+		}
+		if path != dir && opts.hidden(d) || ig.ignored(path, false) {
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			switch {
+			case err != nil && opts.SkipDangling:
+				return nil
+			case err == nil && target.IsDir():
+				if path == root || opts.FollowSymlinks && opts.descend(d.Name(), diskDepth(root, path)) && !opts.marked(path) && st.dev.same(target) && !st.resume.skipDir(path) && !ig.ignored(path, true) {
+					// A trailing separator makes WalkDir resolve the link
+					// instead of reporting it again.
+					st.ignores = ig
+					return walkDisk(ctx, root, path+string(filepath.Separator), opts, st, fn)
+				}
+				return nil
+			}
+		}
+
+		if st.resume.skipFile(path, true) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if opts.OnSkip != nil {
+				opts.OnSkip(path, err)
+			}
+			return nil
+		}
+		return fn(Entry{Path: path, Info: info})
+	})
+}
+
+func walkFS(ctx context.Context, root string, opts Options, dev *device, res *resume, fn func(Entry) error) error {
+	ig := newIgnores(root, opts)
+	return fs.WalkDir(opts.FS, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			if (d == nil || !d.IsDir()) && opts.OnSkip != nil {
+				opts.OnSkip(path, err)
+			}
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		ig = ig.within(path)
+		if d.IsDir() {
+			if path != root && (!opts.descend(d.Name(), fsDepth(root, path)) || opts.hidden(d) || opts.marked(path) || !dev.sameEntry(d) || res.skipDir(path) || ig.ignored(path, true)) {
+				return fs.SkipDir
+			}
+			ig = ig.enter(path, opts)
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path != root && opts.hidden(d) || res.skipFile(path, true) || ig.ignored(path, false) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if opts.OnSkip != nil {
+				opts.OnSkip(path, err)
+			}
+			return nil
+		}
+		return fn(Entry{Path: path, Info: info})
+	})
+}
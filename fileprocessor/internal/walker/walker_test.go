@@ -0,0 +1,174 @@
+package walker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"fileprocessor/fileprocessortest"
+	"fileprocessor/internal/walker"
+)
+
+func TestWalkMissingRoot(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	fsys := fileprocessortest.NewFS().File("a", "a").Build()
+	for _, tc := range []struct {
+		name string
+		root string
+		opts walker.Options
+	}{
+		{"disk", missing, walker.Options{}},
+		{"disk parallel", missing, walker.Options{Workers: 4}},
+		{"fs", "missing", walker.Options{FS: fsys}},
+		{"fs parallel", "missing", walker.Options{FS: fsys, Workers: 4}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var skipped []string
+			tc.opts.OnSkip = func(path string, err error) { skipped = append(skipped, path) }
+			err := walker.Walk(context.Background(), tc.root, tc.opts, func(walker.Entry) error {
+				t.Error("fn called for a missing root")
+				return nil
+			})
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Walk = %v, want an error wrapping fs.ErrNotExist", err)
+			}
+			if len(skipped) != 0 {
+				t.Errorf("OnSkip called for %q; the root's error should come from Walk", skipped)
+			}
+		})
+	}
+}
+
+// walk returns the paths Walk passes to fn, sorted, and those it skips.
+func walk(t *testing.T, root string, opts walker.Options) (paths, skipped []string) {
+	t.Helper()
+	var mu sync.Mutex
+	opts.OnSkip = func(path string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		skipped = append(skipped, path)
+	}
+	err := walker.Walk(context.Background(), root, opts, func(e walker.Entry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, e.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(paths)
+	return paths, skipped
+}
+
+func TestWalkFilters(t *testing.T) {
+	fsys := fileprocessortest.NewFS().
+		File("a", "").
+		File(".hidden", "").
+		File("debug.log", "").
+		File("sub/b", "").
+		File("sub/deep/c", "").
+		File("node_modules/d", "").
+		File("marked/.nobackup", "").
+		File("marked/e", "").
+		Build()
+	all := []string{".hidden", "a", "debug.log", "marked/.nobackup", "marked/e", "node_modules/d", "sub/b", "sub/deep/c"}
+	for _, tc := range []struct {
+		name string
+		opts walker.Options
+		want []string
+	}{
+		{"none", walker.Options{}, all},
+		{"MaxDepth", walker.Options{MaxDepth: 2}, []string{".hidden", "a", "debug.log", "marked/.nobackup", "marked/e", "node_modules/d", "sub/b"}},
+		{"Prune", walker.Options{Prune: []string{"node_*"}}, slices.DeleteFunc(slices.Clone(all), func(p string) bool { return p == "node_modules/d" })},
+		{"PruneMarkers", walker.Options{PruneMarkers: []string{".nobackup"}}, []string{".hidden", "a", "debug.log", "node_modules/d", "sub/b", "sub/deep/c"}},
+		{"Exclude", walker.Options{Exclude: []string{"*.log", "/sub/deep/"}}, []string{".hidden", "a", "marked/.nobackup", "marked/e", "node_modules/d", "sub/b"}},
+		{"SkipHidden", walker.Options{SkipHidden: true}, []string{"a", "debug.log", "marked/e", "node_modules/d", "sub/b", "sub/deep/c"}},
+	} {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/workers=%d", tc.name, workers), func(t *testing.T) {
+				opts := tc.opts
+				opts.FS, opts.Workers = fsys, workers
+				got, skipped := walk(t, ".", opts)
+				if !slices.Equal(got, tc.want) {
+					t.Errorf("walked %q, want %q", got, tc.want)
+				}
+				if len(skipped) != 0 {
+					t.Errorf("skipped %q", skipped)
+				}
+			})
+		}
+	}
+}
+
+// brokenFS is a MapFS where the entry at broken can be listed but not
+// examined.
+type brokenFS struct {
+	fstest.MapFS
+	broken string
+}
+
+func (f brokenFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := f.MapFS.ReadDir(name)
+	for i, e := range entries {
+		if path.Join(name, e.Name()) == f.broken {
+			entries[i] = brokenEntry{e}
+		}
+	}
+	return entries, err
+}
+
+type brokenEntry struct{ fs.DirEntry }
+
+func (brokenEntry) Info() (fs.FileInfo, error) { return nil, fs.ErrPermission }
+
+func TestWalkSkipsWhatItCantExamine(t *testing.T) {
+	fsys := brokenFS{fileprocessortest.NewFS().File("a", "").File("sub/broken", "").File("sub/c", "").Build(), "sub/broken"}
+	for _, workers := range []int{1, 4} {
+		got, skipped := walk(t, ".", walker.Options{FS: fsys, Workers: workers})
+		if want := []string{"a", "sub/c"}; !slices.Equal(got, want) {
+			t.Errorf("workers=%d: walked %q, want %q", workers, got, want)
+		}
+		if want := []string{"sub/broken"}; !slices.Equal(skipped, want) {
+			t.Errorf("workers=%d: skipped %q, want %q", workers, skipped, want)
+		}
+	}
+}
+
+func TestWalkDanglingLinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "gone"), filepath.Join(dir, "dangling")); err != nil {
+		t.Skip("no symbolic links:", err)
+	}
+	for _, workers := range []int{1, 4} {
+		got, _ := walk(t, dir, walker.Options{Workers: workers})
+		if want := []string{filepath.Join(dir, "dangling"), filepath.Join(dir, "file")}; !slices.Equal(got, want) {
+			t.Errorf("workers=%d: walked %q, want %q", workers, got, want)
+		}
+		got, _ = walk(t, dir, walker.Options{Workers: workers, SkipDangling: true})
+		if want := []string{filepath.Join(dir, "file")}; !slices.Equal(got, want) {
+			t.Errorf("workers=%d, SkipDangling: walked %q, want %q", workers, got, want)
+		}
+	}
+}
+
+func TestWalkRootFile(t *testing.T) {
+	fsys := fileprocessortest.NewFS().File("only", "x").Build()
+	for _, workers := range []int{1, 4} {
+		got, _ := walk(t, "only", walker.Options{FS: fsys, Workers: workers})
+		if want := []string{"only"}; !slices.Equal(got, want) {
+			t.Errorf("workers=%d: walked %q, want %q", workers, got, want)
+		}
+	}
+}
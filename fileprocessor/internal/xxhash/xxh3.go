@@ -0,0 +1,224 @@
+package xxhash
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	prime32_1 = 0x9E3779B1
+	prime32_2 = 0x85EBCA77
+	prime32_3 = 0xC2B2AE3D
+
+	primeMx1 = 0x165667919E3779F9
+	primeMx2 = 0x9FB21C651E98DF25
+
+	stripeLen       = 64
+	secretConsume   = 8
+	stripesPerBlock = (len(secret) - stripeLen) / secretConsume
+	midSizeMax      = 240
+	// bufLen must be a multiple of stripeLen.
+	bufLen = 256
+)
+
+// secret is the default XXH3 secret (kSecret).
+var secret = [192]byte{
+	0xb8, 0xfe, 0x6c, 0x39, 0x23, 0xa4, 0x4b, 0xbe, 0x7c, 0x01, 0x81, 0x2c, 0xf7, 0x21, 0xad, 0x1c,
+	0xde, 0xd4, 0x6d, 0xe9, 0x83, 0x90, 0x97, 0xdb, 0x72, 0x40, 0xa4, 0xa4, 0xb7, 0xb3, 0x67, 0x1f,
+	0xcb, 0x79, 0xe6, 0x4e, 0xcc, 0xc0, 0xe5, 0x78, 0x82, 0x5a, 0xd0, 0x7d, 0xcc, 0xff, 0x72, 0x21,
+	0xb8, 0x08, 0x46, 0x74, 0xf7, 0x43, 0x24, 0x8e, 0xe0, 0x35, 0x90, 0xe6, 0x81, 0x3a, 0x26, 0x4c,
+	0x3c, 0x28, 0x52, 0xbb, 0x91, 0xc3, 0x00, 0xcb, 0x88, 0xd0, 0x65, 0x8b, 0x1b, 0x53, 0x2e, 0xa3,
+	0x71, 0x64, 0x48, 0x97, 0xa2, 0x0d, 0xf9, 0x4e, 0x38, 0x19, 0xef, 0x46, 0xa9, 0xde, 0xac, 0xd8,
+	0xa8, 0xfa, 0x76, 0x3f, 0xe3, 0x9c, 0x34, 0x3f, 0xf9, 0xdc, 0xbb, 0xc7, 0xc7, 0x0b, 0x4f, 0x1d,
+	0x8a, 0x51, 0xe0, 0x4b, 0xcd, 0xb4, 0x59, 0x31, 0xc8, 0x9f, 0x7e, 0xc9, 0xd9, 0x78, 0x73, 0x64,
+	0xea, 0xc5, 0xac, 0x83, 0x34, 0xd3, 0xeb, 0xc3, 0xc5, 0x81, 0xa0, 0xff, 0xfa, 0x13, 0x63, 0xeb,
+	0x17, 0x0d, 0xdd, 0x51, 0xb7, 0xf0, 0xda, 0x49, 0xd3, 0x16, 0x55, 0x26, 0x29, 0xd4, 0x68, 0x9e,
+	0x2b, 0x16, 0xbe, 0x58, 0x7d, 0x47, 0xa1, 0xfc, 0x8f, 0xf8, 0xb8, 0xd1, 0x7a, 0xd0, 0x31, 0xce,
+	0x45, 0xcb, 0x3a, 0x8f, 0x95, 0x16, 0x04, 0x28, 0xaf, 0xd7, 0xfb, 0xca, 0xbb, 0x4b, 0x40, 0x7e,
+}
+
+func u64(b []byte) uint64 { return binary.LittleEndian.Uint64(b) }
+func u32(b []byte) uint32 { return binary.LittleEndian.Uint32(b) }
+
+func mulFold64(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func avalanche3(h uint64) uint64 {
+	h ^= h >> 37
+	h *= primeMx1
+	h ^= h >> 32
+	return h
+}
+
+func rrmxmx(h, n uint64) uint64 {
+	h ^= bits.RotateLeft64(h, 49) ^ bits.RotateLeft64(h, 24)
+	h *= primeMx2
+	h ^= (h >> 35) + n
+	h *= primeMx2
+	h ^= h >> 28
+	return h
+}
+
+func mix16(in, sec []byte) uint64 {
+	return mulFold64(u64(in)^u64(sec), u64(in[8:])^u64(sec[8:]))
+}
+
+// hashShort hashes inputs of at most midSizeMax bytes, which XXH3 handles
+// without the stripe accumulator.
+func hashShort(in []byte) uint64 {
+	n := uint64(len(in))
+	s := secret[:]
+	switch {
+	case n == 0:
+		return avalanche64(u64(s[56:]) ^ u64(s[64:]))
+	case n <= 3:
+		combined := uint32(in[0])<<16 | uint32(in[n>>1])<<24 | uint32(in[n-1]) | uint32(n)<<8
+		return avalanche64(uint64(combined) ^ uint64(u32(s)^u32(s[4:])))
+	case n <= 8:
+		input := uint64(u32(in[n-4:])) | uint64(u32(in))<<32
+		return rrmxmx(input^(u64(s[8:])^u64(s[16:])), n)
+	case n <= 16:
+		lo := u64(in) ^ (u64(s[24:]) ^ u64(s[32:]))
+		hi := u64(in[n-8:]) ^ (u64(s[40:]) ^ u64(s[48:]))
+		return avalanche3(n + bits.ReverseBytes64(lo) + hi + mulFold64(lo, hi))
+	case n <= 128:
+		acc := n * prime64_1
+		if n > 32 {
+			if n > 64 {
+				if n > 96 {
+					acc += mix16(in[48:], s[96:])
+					acc += mix16(in[n-64:], s[112:])
+				}
+				acc += mix16(in[32:], s[64:])
+				acc += mix16(in[n-48:], s[80:])
+			}
+			acc += mix16(in[16:], s[32:])
+			acc += mix16(in[n-32:], s[48:])
+		}
+		acc += mix16(in, s)
+		acc += mix16(in[n-16:], s[16:])
+		return avalanche3(acc)
+	default:
+		acc := n * prime64_1
+		for i := 0; i < 8; i++ {
+			acc += mix16(in[16*i:], s[16*i:])
+		}
+		acc = avalanche3(acc)
+		for i := 8; i < int(n/16); i++ {
+			acc += mix16(in[16*i:], s[16*(i-8)+3:])
+		}
+		acc += mix16(in[n-16:], s[136-17:])
+		return avalanche3(acc)
+	}
+}
+
+func accumulate(acc *[8]uint64, in, sec []byte) {
+	for i := 0; i < 8; i++ {
+		v := u64(in[8*i:])
+		k := v ^ u64(sec[8*i:])
+		acc[i^1] += v
+		acc[i] += uint64(uint32(k)) * (k >> 32)
+	}
+}
+
+func scramble(acc *[8]uint64, sec []byte) {
+	for i := range acc {
+		a := acc[i]
+		a ^= a >> 47
+		a ^= u64(sec[8*i:])
+		acc[i] = a * prime32_1
+	}
+}
+
+// XXH3 is a streaming XXH3 (64-bit) hasher implementing hash.Hash64.
+type XXH3 struct {
+	acc     [8]uint64
+	stripes int // stripes consumed in the current block
+	total   uint64
+	buf     [bufLen]byte
+	n       int
+}
+
+var _ hash.Hash64 = (*XXH3)(nil)
+
+// New3 returns an XXH3 hasher.
+func New3() *XXH3 {
+	h := &XXH3{}
+	h.Reset()
+	return h
+}
+
+// Reset returns the hasher to its initial state.
+func (h *XXH3) Reset() {
+	*h = XXH3{acc: [8]uint64{prime32_3, prime64_1, prime64_2, prime64_3, prime64_4, prime32_2, prime64_5, prime32_1}}
+}
+
+// consume feeds whole stripes into acc, scrambling at each block end.
+func consume(acc *[8]uint64, stripes *int, in []byte) {
+	for ; len(in) >= stripeLen; in = in[stripeLen:] {
+		accumulate(acc, in, secret[*stripes*secretConsume:])
+		*stripes++
+		if *stripes == stripesPerBlock {
+			scramble(acc, secret[len(secret)-stripeLen:])
+			*stripes = 0
+		}
+	}
+}
+
+// Write absorbs p. It never fails.
+//
+// The buffer is only flushed once more input arrives, so at Sum time it
+// always holds the final bytes; after a flush its old tail is left in place
+// because the last stripe may need to reach back into it.
+func (h *XXH3) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+	for len(p) > 0 {
+		if h.n == bufLen {
+			consume(&h.acc, &h.stripes, h.buf[:])
+			h.n = 0
+		}
+		c := copy(h.buf[h.n:], p)
+		h.n += c
+		p = p[c:]
+	}
+	return n, nil
+}
+
+// Sum64 returns the digest of everything written so far.
+func (h *XXH3) Sum64() uint64 {
+	if h.total <= midSizeMax {
+		return hashShort(h.buf[:h.n])
+	}
+
+	acc, stripes := h.acc, h.stripes
+	var last [stripeLen]byte
+	if h.n >= stripeLen {
+		consume(&acc, &stripes, h.buf[:(h.n-1)/stripeLen*stripeLen])
+		copy(last[:], h.buf[h.n-stripeLen:h.n])
+	} else {
+		k := copy(last[:], h.buf[bufLen-(stripeLen-h.n):])
+		copy(last[k:], h.buf[:h.n])
+	}
+	accumulate(&acc, last[:], secret[len(secret)-stripeLen-7:])
+
+	result := h.total * prime64_1
+	for i := 0; i < 4; i++ {
+		result += mulFold64(acc[2*i]^u64(secret[11+16*i:]), acc[2*i+1]^u64(secret[11+16*i+8:]))
+	}
+	return avalanche3(result)
+}
+
+// Sum appends the big-endian digest to b.
+func (h *XXH3) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+// Size returns the digest length.
+func (h *XXH3) Size() int { return 8 }
+
+// BlockSize returns the stripe length.
+func (h *XXH3) BlockSize() int { return stripeLen }
@@ -0,0 +1,136 @@
+// Package xxhash implements the non-cryptographic XXH64 and XXH3 (64-bit)
+// hashes with a zero seed. Digests are written big-endian, matching the
+// canonical form printed by xxhsum.
+package xxhash
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	prime64_1 = 0x9E3779B185EBCA87
+	prime64_2 = 0xC2B2AE3D27D4EB4F
+	prime64_3 = 0x165667B19E3779F9
+	prime64_4 = 0x85EBCA77C2B2AE63
+	prime64_5 = 0x27D4EB2F165667C5
+)
+
+// XXH64 is a streaming XXH64 hasher implementing hash.Hash64.
+type XXH64 struct {
+	v     [4]uint64
+	total uint64
+	buf   [32]byte
+	n     int
+}
+
+var _ hash.Hash64 = (*XXH64)(nil)
+
+// New64 returns an XXH64 hasher.
+func New64() *XXH64 {
+	h := &XXH64{}
+	h.Reset()
+	return h
+}
+
+// Reset returns the hasher to its initial state.
+func (h *XXH64) Reset() {
+	p1, p2 := uint64(prime64_1), uint64(prime64_2)
+	h.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	h.total = 0
+	h.n = 0
+}
+
+func round64(acc, input uint64) uint64 {
+	acc += input * prime64_2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime64_1
+}
+
+func mergeRound64(acc, val uint64) uint64 {
+	acc ^= round64(0, val)
+	return acc*prime64_1 + prime64_4
+}
+
+func (h *XXH64) stripe(b []byte) {
+	h.v[0] = round64(h.v[0], binary.LittleEndian.Uint64(b[0:]))
+	h.v[1] = round64(h.v[1], binary.LittleEndian.Uint64(b[8:]))
+	h.v[2] = round64(h.v[2], binary.LittleEndian.Uint64(b[16:]))
+	h.v[3] = round64(h.v[3], binary.LittleEndian.Uint64(b[24:]))
+}
+
+// Write absorbs p. It never fails.
+func (h *XXH64) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+
+	if h.n > 0 {
+		c := copy(h.buf[h.n:], p)
+		h.n += c
+		p = p[c:]
+		if h.n < len(h.buf) {
+			return n, nil
+		}
+		h.stripe(h.buf[:])
+		h.n = 0
+	}
+	for len(p) >= 32 {
+		h.stripe(p)
+		p = p[32:]
+	}
+	h.n = copy(h.buf[:], p)
+	return n, nil
+}
+
+// Sum64 returns the digest of everything written so far.
+func (h *XXH64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		v := h.v
+		acc = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) +
+			bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, x := range v {
+			acc = mergeRound64(acc, x)
+		}
+	} else {
+		acc = prime64_5
+	}
+	acc += h.total
+
+	p := h.buf[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= round64(0, binary.LittleEndian.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*prime64_1 + prime64_4
+	}
+	if len(p) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(p)) * prime64_1
+		acc = bits.RotateLeft64(acc, 23)*prime64_2 + prime64_3
+		p = p[4:]
+	}
+	for _, c := range p {
+		acc ^= uint64(c) * prime64_5
+		acc = bits.RotateLeft64(acc, 11) * prime64_1
+	}
+	return avalanche64(acc)
+}
+
+func avalanche64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= prime64_2
+	h ^= h >> 29
+	h *= prime64_3
+	h ^= h >> 32
+	return h
+}
+
+// Sum appends the big-endian digest to b.
+func (h *XXH64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+// Size returns the digest length.
+func (h *XXH64) Size() int { return 8 }
+
+// BlockSize returns the stripe length.
+func (h *XXH64) BlockSize() int { return 32 }
@@ -0,0 +1,109 @@
+package xxhash
+
+import (
+	"encoding/binary"
+	"hash"
+	"testing"
+)
+
+// vectors are digests of the first n bytes of the sequence (i+1)%251
+// used by the xxh3 Go port's compatibility tests: the XXH3 ones up to 4095
+// bytes are those printed there by the C reference, and the rest come from
+// github.com/cespare/xxhash/v2 and github.com/zeebo/xxh3. The lengths sit
+// either side of the boundaries between the algorithms' code paths.
+var vectors = []struct {
+	n           int
+	xxh64, xxh3 uint64
+}{
+	{0, 0xef46db3751d8e999, 0x2d06800538d394c2},
+	{1, 0x8a4127811b21e730, 0xe12ef9d2eb86ceeb},
+	{3, 0x743e13ee0c4ee5a5, 0xebce9b7632ae733b},
+	{4, 0x542620e3a2a92ed1, 0x988b7b9033ac4622},
+	{8, 0x814c43eb29646e14, 0x16f217ea16232297},
+	{9, 0x9dd18224df94c8bd, 0x17d143e7f447850a},
+	{16, 0x3b90396ee396dd85, 0xeb5aeb9a32450f6a},
+	{17, 0x9d3939ce4b56a27f, 0x6d458e1fff494078},
+	{128, 0x31996c131a9333c6, 0xce22cae9106851df},
+	{129, 0x5e4db144ec9aa43d, 0x7d4fc663f5958d40},
+	{240, 0x41aac5b98a8b3294, 0xa5a910b2d7e065b0},
+	{241, 0xf9d6b7f8f92689c5, 0xb6515f490cdd4ce5},
+	{1024, 0x36d3f2a83cacb2f7, 0x546f61a5b0b850c1},
+	{4095, 0x9bb1c4fc644b0b22, 0x268198759d7bdf74},
+	{100000, 0x8319faa02263ed3c, 0x2bb0eb81c8de66a3},
+	{1<<20 + 13, 0xd5ed29eebf03faac, 0xaace1afadc8a8e6b},
+}
+
+func input(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte((i + 1) % 251)
+	}
+	return b
+}
+
+// sum writes in to h step bytes at a time.
+func sum(h hash.Hash64, in []byte, step int) uint64 {
+	h.Reset()
+	for p := in; len(p) > 0; p = p[min(step, len(p)):] {
+		h.Write(p[:min(step, len(p))])
+	}
+	return h.Sum64()
+}
+
+func TestVectors(t *testing.T) {
+	in := input(vectors[len(vectors)-1].n)
+	for _, alg := range []struct {
+		name string
+		h    hash.Hash64
+		want func(int) uint64
+	}{
+		{"XXH64", New64(), func(i int) uint64 { return vectors[i].xxh64 }},
+		{"XXH3", New3(), func(i int) uint64 { return vectors[i].xxh3 }},
+	} {
+		for i, v := range vectors {
+			want := alg.want(i)
+			// Whole, and in pieces that straddle stripes, blocks and the
+			// buffer in every alignment.
+			for _, step := range []int{v.n + 1, 1, 7, 63, 64, 65, 1024, 1025} {
+				if v.n > 5000 && step < 63 {
+					continue
+				}
+				if got := sum(alg.h, in[:v.n], step); got != want {
+					t.Errorf("%s of %d bytes, written %d at a time = %016x, want %016x", alg.name, v.n, step, got, want)
+				}
+			}
+			if got := alg.h.Sum(nil); binary.BigEndian.Uint64(got) != want || len(got) != alg.h.Size() {
+				t.Errorf("%s of %d bytes: Sum = %x", alg.name, v.n, got)
+			}
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	// The XXH64 test strings of github.com/cespare/xxhash/v2.
+	for _, tc := range []struct {
+		in   string
+		want uint64
+	}{
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"as", 0x1c330fb2d66be179},
+		{"asd", 0x631c37ce72a97393},
+		{"asdf", 0x415872f599cea71e},
+	} {
+		if got := sum(New64(), []byte(tc.in), len(tc.in)); got != tc.want {
+			t.Errorf("XXH64(%q) = %016x, want %016x", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSumDoesNotChangeState(t *testing.T) {
+	in := input(3000)
+	for _, h := range []hash.Hash64{New64(), New3()} {
+		h.Write(in[:1000])
+		h.Sum64()
+		h.Write(in[1000:])
+		if got, want := h.Sum64(), sum(h, in, len(in)); got != want {
+			t.Errorf("%T: %016x after an early Sum64, want %016x", h, got, want)
+		}
+	}
+}
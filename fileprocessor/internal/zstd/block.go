@@ -0,0 +1,195 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	minMatch = 4
+	hashLog  = 14
+)
+
+// sequence copies litLen literals and then matchLen bytes from offset
+// bytes back.
+type sequence struct {
+	litLen, offset, matchLen uint32
+}
+
+// encoder holds the scratch space of block compression.
+type encoder struct {
+	table [1 << hashLog]int32
+	seqs  []sequence
+	lits  []byte
+	out   []byte
+}
+
+// compress returns the content of a compressed block holding src, or nil
+// if src is too short to bother.
+func (e *encoder) compress(src []byte) []byte {
+	if len(src) < 2*minMatch {
+		return nil
+	}
+	e.seqs, e.lits = e.seqs[:0], e.lits[:0]
+	clear(e.table[:])
+
+	// Greedy matching against the last position with the same hash of
+	// four bytes. Positions are stored plus one, so that zero is empty.
+	anchor, i := 0, 0
+	for i+8 <= len(src) {
+		cur := binary.LittleEndian.Uint32(src[i:])
+		h := (cur * 2654435761) >> (32 - hashLog)
+		cand := int(e.table[h]) - 1
+		e.table[h] = int32(i + 1)
+		if cand < 0 || binary.LittleEndian.Uint32(src[cand:]) != cur {
+			// Skip ahead faster through data that doesn't match.
+			i += 1 + (i-anchor)>>6
+			continue
+		}
+		n := minMatch
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		// A short match far back costs more bits than the literals it
+		// replaces, reckoned at about five bits each.
+		start := i
+		for i > anchor && cand > 0 && src[i-1] == src[cand-1] {
+			i, cand, n = i-1, cand-1, n+1
+		}
+		if 5*n < bits.Len(uint(i-cand))+10 {
+			i = start + 1
+			continue
+		}
+		e.seqs = append(e.seqs, sequence{litLen: uint32(i - anchor), offset: uint32(i - cand), matchLen: uint32(n)})
+		e.lits = append(e.lits, src[anchor:i]...)
+		// Index the matched bytes too, for later matches to find.
+		for j := i + 1; j < i+n && j+minMatch <= len(src); j++ {
+			e.table[(binary.LittleEndian.Uint32(src[j:])*2654435761)>>(32-hashLog)] = int32(j + 1)
+		}
+		i += n
+		anchor = i
+	}
+	e.lits = append(e.lits, src[anchor:]...)
+
+	e.out = appendLiterals(e.out[:0], e.lits)
+	e.out = appendSequences(e.out, e.seqs)
+	return e.out
+}
+
+// Literal length and match length codes: the smallest value each code
+// stands for, and the number of extra bits that follow it.
+var (
+	llBase = [...]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	llBits = [...]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	mlBase = [...]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	mlBits = [...]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// Predefined distributions of the literal length, match length and
+// offset codes.
+var (
+	llTable = newFSETable([]int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}, 6)
+	mlTable = newFSETable([]int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}, 6)
+	ofTable = newFSETable([]int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}, 5)
+)
+
+// code returns the code whose range holds v.
+func code(base []uint32, v uint32) uint8 {
+	lo, hi := 0, len(base)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if base[mid] <= v {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return uint8(lo)
+}
+
+// appendSequences appends the sequences section, coded with the
+// predefined tables. Offsets are always sent as new offsets, never as
+// repeats, which the format allows.
+func appendSequences(out []byte, seqs []sequence) []byte {
+	n := len(seqs)
+	switch {
+	case n < 128:
+		out = append(out, byte(n))
+	case n < 0x7F00:
+		out = append(out, byte(n>>8)+128, byte(n))
+	default:
+		out = append(out, 255, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	if n == 0 {
+		return out
+	}
+	out = append(out, 0) // predefined mode for all three codes
+
+	type coded struct {
+		ll, ml, of       uint8
+		llExtra, mlExtra uint32
+		ofExtra          uint32
+	}
+	codes := make([]coded, n)
+	for i, s := range seqs {
+		ll, ml := code(llBase[:], s.litLen), code(mlBase[:], s.matchLen)
+		off := s.offset + 3 // offset values 1-3 are the repeat codes
+		of := uint8(bits.Len32(off) - 1)
+		codes[i] = coded{ll, ml, of, s.litLen - llBase[ll], s.matchLen - mlBase[ml], off - 1<<of}
+	}
+
+	var bw bitWriter
+	extra := func(c coded) {
+		bw.add(uint64(c.llExtra), uint(llBits[c.ll]))
+		bw.add(uint64(c.mlExtra), uint(mlBits[c.ml]))
+		bw.add(uint64(c.ofExtra), uint(c.of))
+	}
+	// The decoder reads the stream backward, so the last sequence goes
+	// first and the states that start decoding go last.
+	last := codes[n-1]
+	mlState := mlTable.init(last.ml)
+	ofState := ofTable.init(last.of)
+	llState := llTable.init(last.ll)
+	extra(last)
+	for i := n - 2; i >= 0; i-- {
+		c := codes[i]
+		ofState = ofTable.encode(&bw, ofState, c.of)
+		mlState = mlTable.encode(&bw, mlState, c.ml)
+		llState = llTable.encode(&bw, llState, c.ll)
+		extra(c)
+	}
+	mlTable.flush(&bw, mlState)
+	ofTable.flush(&bw, ofState)
+	llTable.flush(&bw, llState)
+	return append(out, bw.close()...)
+}
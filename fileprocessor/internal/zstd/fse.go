@@ -0,0 +1,96 @@
+package zstd
+
+import "math/bits"
+
+// fseTable encodes symbols with a finite state entropy table built from a
+// normalized distribution, as the reference encoder does, so that a
+// decoder building its table from the same distribution follows it.
+type fseTable struct {
+	accuracy uint
+	states   []uint16
+	// Per symbol: how many bits a state sheds, offset by the state, in
+	// the high half; and where the symbol's next states start.
+	deltaBits  []uint32
+	deltaState []int32
+}
+
+// newFSETable builds the table for norm, a distribution summing to
+// 1<<accuracy in which -1 marks a symbol of less than one slot.
+func newFSETable(norm []int16, accuracy uint) *fseTable {
+	size := 1 << accuracy
+	t := &fseTable{
+		accuracy:   accuracy,
+		states:     make([]uint16, size),
+		deltaBits:  make([]uint32, len(norm)),
+		deltaState: make([]int32, len(norm)),
+	}
+
+	// Spread the symbols over the table: those of less than one slot
+	// take the last slots, the others are stepped through the rest.
+	spread := make([]byte, size)
+	cumul := make([]int, len(norm)+1)
+	high := size - 1
+	for s, n := range norm {
+		if n == -1 {
+			spread[high] = byte(s)
+			high--
+			cumul[s+1] = cumul[s] + 1
+		} else {
+			cumul[s+1] = cumul[s] + int(n)
+		}
+	}
+	pos, step := 0, size>>1+size>>3+3
+	for s, n := range norm {
+		for range max(int(n), 0) {
+			spread[pos] = byte(s)
+			pos = (pos + step) & (size - 1)
+			for pos > high {
+				pos = (pos + step) & (size - 1)
+			}
+		}
+	}
+	next := append([]int(nil), cumul[:len(norm)]...)
+	for u, s := range spread {
+		t.states[next[s]] = uint16(size + u)
+		next[s]++
+	}
+
+	total := 0
+	for s, n := range norm {
+		switch n {
+		case 0:
+			t.deltaBits[s] = uint32(accuracy+1)<<16 - uint32(size)
+		case -1, 1:
+			t.deltaBits[s] = uint32(accuracy)<<16 - uint32(size)
+			t.deltaState[s] = int32(total - 1)
+			total++
+		default:
+			maxBits := accuracy - uint(bits.Len16(uint16(n-1))-1)
+			minState := uint32(n) << maxBits
+			t.deltaBits[s] = uint32(maxBits)<<16 - minState
+			t.deltaState[s] = int32(total - int(n))
+			total += int(n)
+		}
+	}
+	return t
+}
+
+// init returns the first state, which encodes s without writing bits.
+func (t *fseTable) init(s uint8) uint32 {
+	nb := (t.deltaBits[s] + 1<<15) >> 16
+	v := nb<<16 - t.deltaBits[s]
+	return uint32(t.states[int32(v>>nb)+t.deltaState[s]])
+}
+
+// encode writes the bits that state sheds to encode s, and returns the
+// next state.
+func (t *fseTable) encode(bw *bitWriter, state uint32, s uint8) uint32 {
+	nb := (state + t.deltaBits[s]) >> 16
+	bw.add(uint64(state), uint(nb))
+	return uint32(t.states[int32(state>>nb)+t.deltaState[s]])
+}
+
+// flush writes the final state, where the decoder starts.
+func (t *fseTable) flush(bw *bitWriter, state uint32) {
+	bw.add(uint64(state), t.accuracy)
+}
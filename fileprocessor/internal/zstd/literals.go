@@ -0,0 +1,203 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+const (
+	literalsRaw        = 0
+	literalsRLE        = 1
+	literalsCompressed = 2
+
+	// maxCodeBits is the longest Huffman code the format allows.
+	maxCodeBits = 11
+	// maxDirectSymbol is the largest literal a Huffman table sent as
+	// plain weights can hold: 128 weights, and one implied.
+	maxDirectSymbol = 128
+)
+
+// appendLiterals appends the literals section: Huffman-coded when that is
+// possible and smaller, repeated when all the literals are the same byte,
+// and raw otherwise.
+func appendLiterals(out []byte, lits []byte) []byte {
+	var freq [256]int
+	for _, b := range lits {
+		freq[b]++
+	}
+	maxSym, distinct := 0, 0
+	for s, n := range freq {
+		if n > 0 {
+			maxSym, distinct = s, distinct+1
+		}
+	}
+	switch {
+	case len(lits) > 0 && distinct == 1:
+		return append(appendRawHeader(out, literalsRLE, len(lits)), lits[0])
+	case len(lits) >= 32 && maxSym <= maxDirectSymbol:
+		if huff := appendHuffman(out, lits, &freq, maxSym); huff != nil && len(huff)-len(out) < len(lits) {
+			return huff
+		}
+	}
+	return append(appendRawHeader(out, literalsRaw, len(lits)), lits...)
+}
+
+// appendRawHeader appends the header of a raw or repeated literals
+// section of n bytes.
+func appendRawHeader(out []byte, kind, n int) []byte {
+	switch {
+	case n < 1<<5:
+		return append(out, byte(kind|n<<3))
+	case n < 1<<12:
+		return append(out, byte(kind|1<<2|n<<4), byte(n>>4))
+	}
+	return append(out, byte(kind|3<<2|n<<4), byte(n>>4), byte(n>>12))
+}
+
+// appendHuffman appends lits Huffman-coded, in one stream if short and
+// four otherwise, or returns nil if it can't.
+func appendHuffman(out []byte, lits []byte, freq *[256]int, maxSym int) []byte {
+	lengths := codeLengths(freq[:maxSym+1])
+	maxBits := uint8(slices.Max(lengths))
+	var codes [256]uint16
+	next := uint16(0)
+	for l := maxBits; l >= 1; l-- {
+		for s, n := range lengths {
+			if n == l {
+				codes[s] = next
+				next++
+			}
+		}
+		next >>= 1
+	}
+
+	// The table goes as 4-bit weights, the last symbol's implied.
+	table := []byte{byte(127 + maxSym)}
+	weight := func(s int) byte {
+		if lengths[s] == 0 {
+			return 0
+		}
+		return maxBits + 1 - lengths[s]
+	}
+	for s := 0; s < maxSym; s += 2 {
+		b := weight(s) << 4
+		if s+1 < maxSym {
+			b |= weight(s + 1)
+		}
+		table = append(table, b)
+	}
+
+	stream := func(seg []byte) []byte {
+		var bw bitWriter
+		for i := len(seg) - 1; i >= 0; i-- {
+			bw.add(uint64(codes[seg[i]]), uint(lengths[seg[i]]))
+		}
+		return bw.close()
+	}
+	body := table
+	single := len(lits) < 1<<10
+	if single {
+		body = append(body, stream(lits)...)
+		if len(body) >= 1<<10 {
+			return nil
+		}
+	} else {
+		quarter := (len(lits) + 3) / 4
+		var streams [4][]byte
+		for i := range streams {
+			lo, hi := min(i*quarter, len(lits)), min((i+1)*quarter, len(lits))
+			streams[i] = stream(lits[lo:hi])
+		}
+		for _, s := range streams[:3] {
+			if len(s) > 0xFFFF {
+				return nil
+			}
+			body = binary.LittleEndian.AppendUint16(body, uint16(len(s)))
+		}
+		for _, s := range streams {
+			body = append(body, s...)
+		}
+	}
+
+	regen, comp := uint64(len(lits)), uint64(len(body))
+	h := uint64(literalsCompressed)
+	switch size := max(regen, comp); {
+	case single:
+		out = append(out, byte(h|regen<<4), byte(regen>>4|comp<<6), byte(comp>>2))
+	case size < 1<<10:
+		h |= 1 << 2
+		out = append(out, byte(h|regen<<4), byte(regen>>4|comp<<6), byte(comp>>2))
+	case size < 1<<14:
+		h |= 2<<2 | regen<<4 | comp<<18
+		out = binary.LittleEndian.AppendUint32(out, uint32(h))
+	case size < 1<<18:
+		h |= 3<<2 | regen<<4 | comp<<22
+		out = append(out, byte(h), byte(h>>8), byte(h>>16), byte(h>>24), byte(h>>32))
+	default:
+		return nil
+	}
+	return append(out, body...)
+}
+
+// codeLengths returns Huffman code lengths for freq, none longer than
+// maxCodeBits. Lengths are capped the simple way: by flattening the
+// frequencies until the tree is shallow enough.
+func codeLengths(freq []int) []uint8 {
+	weights := slices.Clone(freq)
+	for {
+		lengths := huffman(weights)
+		if slices.Max(lengths) <= maxCodeBits {
+			return lengths
+		}
+		for i, w := range weights {
+			if w > 0 {
+				weights[i] = (w + 1) / 2
+			}
+		}
+	}
+}
+
+// huffman returns the depth of every symbol with a non-zero frequency in
+// a Huffman tree, built with the two-queue method.
+func huffman(freq []int) []uint8 {
+	type node struct {
+		weight      int
+		left, right int // children, or -1 and the symbol for a leaf
+	}
+	var nodes []node
+	for s, w := range freq {
+		if w > 0 {
+			nodes = append(nodes, node{w, -1, s})
+		}
+	}
+	slices.SortStableFunc(nodes, func(a, b node) int { return a.weight - b.weight })
+	leaves := len(nodes)
+	// Leaves are taken from the front of nodes, and the inner nodes
+	// built from them are appended in order of weight.
+	li, ii := 0, leaves
+	take := func() int {
+		if li < leaves && (ii >= len(nodes) || nodes[li].weight <= nodes[ii].weight) {
+			li++
+			return li - 1
+		}
+		ii++
+		return ii - 1
+	}
+	for range leaves - 1 {
+		a, b := take(), take()
+		nodes = append(nodes, node{nodes[a].weight + nodes[b].weight, a, b})
+	}
+
+	lengths := make([]uint8, len(freq))
+	var walk func(i int, depth uint8)
+	walk = func(i int, depth uint8) {
+		if n := nodes[i]; n.left < 0 {
+			lengths[n.right] = depth
+		} else {
+			walk(n.left, depth+1)
+			walk(n.right, depth+1)
+		}
+	}
+	walk(len(nodes)-1, 0)
+	return lengths
+}
@@ -0,0 +1,159 @@
+// Package zstd writes Zstandard (RFC 8878) streams. The encoder is a
+// simple one: each block of up to 128 KiB is searched greedily for
+// matches within the block that save bits, the sequences are coded
+// with the predefined FSE tables, and the literals are Huffman-coded when
+// that pays and every literal byte is below 0x80, as in most text. The
+// output is a single frame with a content checksum, which any conforming
+// decoder, such as zstd -d, reads.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"fileprocessor/internal/xxhash"
+)
+
+const (
+	magic = 0xFD2FB528
+	// blockSize is the largest block, and the window: matches never
+	// reach back past the start of their block.
+	blockSize = 128 << 10
+	// windowDescriptor declares a window of 1<<(10+7) bytes.
+	windowDescriptor = 7 << 3
+
+	blockRaw        = 0
+	blockCompressed = 2
+)
+
+// Writer compresses what is written to it into an underlying writer. Its
+// methods must not be called concurrently.
+type Writer struct {
+	w       io.Writer
+	buf     []byte
+	sum     *xxhash.XXH64
+	enc     encoder
+	started bool
+	closed  bool
+	err     error
+}
+
+// NewWriter returns a Writer compressing to w. Close must be called to
+// complete the stream.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, buf: make([]byte, 0, blockSize), sum: xxhash.New64()}
+}
+
+// Write compresses p. Data is written to the underlying writer a block at
+// a time.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, errors.New("zstd: write after Close")
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	n := len(p)
+	z.sum.Write(p)
+	// A full block is held back until more data arrives, so that the
+	// last block of the stream is never an empty one.
+	for len(z.buf)+len(p) > blockSize {
+		k := blockSize - len(z.buf)
+		z.buf = append(z.buf, p[:k]...)
+		p = p[k:]
+		if err := z.block(false); err != nil {
+			return 0, err
+		}
+	}
+	z.buf = append(z.buf, p...)
+	return n, nil
+}
+
+// Close writes the last block and the checksum. It does not close the
+// underlying writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	z.closed = true
+	if z.err != nil {
+		return z.err
+	}
+	if err := z.block(true); err != nil {
+		return err
+	}
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], uint32(z.sum.Sum64()))
+	return z.write(sum[:])
+}
+
+// block writes the buffered data as a block, compressed if that makes it
+// smaller.
+func (z *Writer) block(last bool) error {
+	if !z.started {
+		z.started = true
+		// Frame header: no content size, since the stream is not
+		// known in advance, a content checksum and no dictionary.
+		hdr := binary.LittleEndian.AppendUint32(nil, magic)
+		hdr = append(hdr, 1<<2, windowDescriptor)
+		if err := z.write(hdr); err != nil {
+			return err
+		}
+	}
+	body, kind := z.enc.compress(z.buf), blockCompressed
+	if body == nil || len(body) >= len(z.buf) {
+		body, kind = z.buf, blockRaw
+	}
+	h := uint32(len(body))<<3 | uint32(kind)<<1
+	if last {
+		h |= 1
+	}
+	if err := z.write([]byte{byte(h), byte(h >> 8), byte(h >> 16)}); err != nil {
+		return err
+	}
+	if err := z.write(body); err != nil {
+		return err
+	}
+	z.buf = z.buf[:0]
+	return nil
+}
+
+func (z *Writer) write(p []byte) error {
+	if _, err := z.w.Write(p); err != nil {
+		z.err = err
+		return err
+	}
+	return nil
+}
+
+// bitWriter packs values least significant bit first, as the backward
+// bitstreams of Huffman and FSE coding are written.
+type bitWriter struct {
+	out []byte
+	acc uint64
+	n   uint
+}
+
+// add appends the low n bits of v; n is at most 32.
+func (b *bitWriter) add(v uint64, n uint) {
+	b.acc |= (v & (1<<n - 1)) << b.n
+	b.n += n
+	for b.n >= 8 {
+		b.out = append(b.out, byte(b.acc))
+		b.acc >>= 8
+		b.n -= 8
+	}
+}
+
+// close ends the stream with the 1 bit that marks its end to a decoder
+// reading it backward.
+func (b *bitWriter) close() []byte {
+	b.add(1, 1)
+	if b.n > 0 {
+		b.out = append(b.out, byte(b.acc))
+	}
+	out := b.out
+	*b = bitWriter{}
+	return out
+}
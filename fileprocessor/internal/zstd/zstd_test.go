@@ -0,0 +1,221 @@
+package zstd_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"fileprocessor/internal/xxhash"
+	"fileprocessor/internal/zstd"
+)
+
+const blockSize = 128 << 10
+
+// inputs are named test inputs: the smallest ones, text that compresses
+// well, bytes that don't, and sizes either side of a block.
+func inputs() map[string][]byte {
+	rng := rand.New(rand.NewChaCha8([32]byte{1}))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(rng.Uint32())
+		}
+		return b
+	}
+	text := func(n int) []byte {
+		var b strings.Builder
+		for i := 0; b.Len() < n; i++ {
+			fmt.Fprintf(&b, "%d: the quick brown fox jumps over the lazy dog %d times\n", i, i*i%97)
+		}
+		return []byte(b.String()[:n])
+	}
+	// Above 0x80, so that the literals can't be Huffman-coded.
+	high := text(50000)
+	for i := range high {
+		high[i] |= 0x80
+	}
+	return map[string][]byte{
+		"empty":            nil,
+		"one byte":         {'x'},
+		"seven bytes":      []byte("abcdefg"),
+		"text":             text(1000),
+		"block":            text(blockSize),
+		"block plus one":   text(blockSize + 1),
+		"several blocks":   text(3*blockSize + 17),
+		"random":           random(300 << 10),
+		"random and text":  append(random(blockSize-100), text(2*blockSize)...),
+		"zeros":            make([]byte, 1<<20),
+		"high bytes":       high,
+		"short literals":   bytes.Repeat([]byte("abcdefgh12"), 5),
+		"one literal byte": append(bytes.Repeat([]byte{'a'}, 100), bytes.Repeat([]byte("xyzw"), 50)...),
+	}
+}
+
+func compress(t *testing.T, in []byte, chunk int) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	z := zstd.NewWriter(&b)
+	for p := in; len(p) > 0; {
+		n := min(chunk, len(p))
+		if _, err := z.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// checkFrame checks out is a single frame holding in, as far as that can
+// be told without decoding compressed blocks: the frame header, block
+// headers and sizes, the content of raw blocks, and the checksum. The
+// encoder writes no RLE blocks.
+func checkFrame(t *testing.T, out, in []byte) {
+	t.Helper()
+	if len(out) < 6 || binary.LittleEndian.Uint32(out) != 0xFD2FB528 {
+		t.Fatalf("no zstd magic: % x", out[:min(len(out), 6)])
+	}
+	// Checksum, no content size, no dictionary; a 128 KiB window.
+	if out[4] != 0x04 || out[5] != 7<<3 {
+		t.Fatalf("frame header % x", out[4:6])
+	}
+	p := out[6:]
+	content := 0
+	for blocks := 0; ; blocks++ {
+		if len(p) < 3 {
+			t.Fatalf("block %d: truncated header", blocks)
+		}
+		h := uint32(p[0]) | uint32(p[1])<<8 | uint32(p[2])<<16
+		last, kind, size := h&1 == 1, h>>1&3, int(h>>3)
+		p = p[3:]
+		if size > blockSize {
+			t.Fatalf("block %d: %d bytes", blocks, size)
+		}
+		switch kind {
+		case 0:
+			if !bytes.Equal(p[:size], in[content:content+size]) {
+				t.Fatalf("block %d: raw content differs from the input at %d", blocks, content)
+			}
+			content += size
+		case 1:
+			t.Fatalf("block %d: RLE block", blocks)
+		case 2:
+			// A block of the input, smaller compressed.
+			content += min(blockSize, len(in)-content)
+			if size >= blockSize {
+				t.Errorf("block %d: compressed to %d bytes", blocks, size)
+			}
+		default:
+			t.Fatalf("block %d: reserved block type", blocks)
+		}
+		p = p[size:]
+		if last {
+			break
+		}
+	}
+	if content != len(in) {
+		t.Errorf("blocks hold %d bytes, want %d", content, len(in))
+	}
+	if len(p) != 4 {
+		t.Fatalf("%d bytes after the last block, want a 4-byte checksum", len(p))
+	}
+	sum := xxhash.New64()
+	sum.Write(in)
+	if got, want := binary.LittleEndian.Uint32(p), uint32(sum.Sum64()); got != want {
+		t.Errorf("checksum %08x, want %08x", got, want)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	for name, in := range inputs() {
+		t.Run(name, func(t *testing.T) {
+			out := compress(t, in, len(in)+1)
+			checkFrame(t, out, in)
+			// Blocks are cut the same however the input is written.
+			for _, chunk := range []int{1, 1000, blockSize - 1} {
+				if len(in) > 100000 && chunk == 1 {
+					continue
+				}
+				if got := compress(t, in, chunk); !bytes.Equal(got, out) {
+					t.Errorf("written %d bytes at a time: different stream", chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestCompresses(t *testing.T) {
+	in := inputs()
+	for _, name := range []string{"several blocks", "zeros", "high bytes"} {
+		if n := len(compress(t, in[name], len(in[name]))); n > len(in[name])/4 {
+			t.Errorf("%s: %d bytes compressed to %d", name, len(in[name]), n)
+		}
+	}
+	// Incompressible data costs only the block headers and the frame.
+	if n, limit := len(compress(t, in["random"], 1<<20)), len(in["random"])+6+3*3+4; n > limit {
+		t.Errorf("random: %d bytes compressed to %d, want at most %d", len(in["random"]), n, limit)
+	}
+}
+
+// TestDecompress checks the streams with the reference decoder, zstd -d,
+// which verifies the checksum too.
+func TestDecompress(t *testing.T) {
+	bin, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd is not installed")
+	}
+	for name, in := range inputs() {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command(bin, "-d", "-c", "-q")
+			cmd.Stdin = bytes.NewReader(compress(t, in, len(in)+1))
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("zstd -d: %v\n%s", err, stderr.Bytes())
+			}
+			if !bytes.Equal(out, in) {
+				t.Errorf("zstd -d gave %d bytes, want the %d written", len(out), len(in))
+			}
+		})
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	z := zstd.NewWriter(&bytes.Buffer{})
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := z.Write([]byte("x")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+	if err := z.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+type failingWriter struct{}
+
+var errFull = errors.New("disk full")
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errFull }
+
+func TestWriteError(t *testing.T) {
+	z := zstd.NewWriter(failingWriter{})
+	if _, err := z.Write(make([]byte, 2*blockSize)); !errors.Is(err, errFull) {
+		t.Errorf("Write = %v, want the underlying error", err)
+	}
+	if _, err := z.Write([]byte("x")); !errors.Is(err, errFull) {
+		t.Errorf("Write after an error = %v", err)
+	}
+	if err := z.Close(); !errors.Is(err, errFull) {
+		t.Errorf("Close after an error = %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package fileprocessor
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Latency sums up how long files took to process. The percentiles are
+// accurate to within 1/64 of their value, about 1.6%, and never too low.
+type Latency struct {
+	// Count is the number of files measured, processed or failed, and Sum
+	// their total time.
+	Count int64
+	Sum   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+const (
+	// latencySub is the number of buckets of every power of two of
+	// microseconds, which sets the precision.
+	latencySub = 64
+	// latencyBuckets spans one microsecond to over a day.
+	latencyBuckets = latencySub * 32
+)
+
+// latencyHistogram counts file durations in log-linear buckets, as HDR
+// histograms do: exact below latencySub microseconds, then latencySub
+// buckets for every doubling. Adding is lock-free.
+type latencyHistogram struct {
+	counts [latencyBuckets]atomic.Int64
+	sum    atomic.Int64 // nanoseconds
+	max    atomic.Int64
+}
+
+// latencyIndex returns the bucket of us microseconds.
+func latencyIndex(us uint64) int {
+	if us < latencySub {
+		return int(us)
+	}
+	shift := bits.Len64(us) - 7 // leaves us>>shift in [latencySub, 2*latencySub)
+	return min(latencySub*(shift+1)+int(us>>shift)-latencySub, latencyBuckets-1)
+}
+
+// latencyUpper returns the upper bound of bucket i, the shortest duration
+// above it.
+func latencyUpper(i int) time.Duration {
+	if i < latencySub {
+		return time.Duration(i+1) * time.Microsecond
+	}
+	shift := i/latencySub - 1
+	sub := uint64(i%latencySub + latencySub)
+	return time.Duration((sub+1)<<shift) * time.Microsecond
+}
+
+func (h *latencyHistogram) add(d time.Duration) {
+	d = max(d, 0)
+	h.counts[latencyIndex(uint64(d/time.Microsecond))].Add(1)
+	h.sum.Add(int64(d))
+	for {
+		m := h.max.Load()
+		if int64(d) <= m || h.max.CompareAndSwap(m, int64(d)) {
+			break
+		}
+	}
+}
+
+// latency returns the percentiles of the durations added so far.
+func (h *latencyHistogram) latency() Latency {
+	var counts [latencyBuckets]int64
+	var l Latency
+	for i := range counts {
+		counts[i] = h.counts[i].Load()
+		l.Count += counts[i]
+	}
+	if l.Count == 0 {
+		return l
+	}
+	l.Sum = time.Duration(h.sum.Load())
+	l.Max = time.Duration(h.max.Load())
+	at := func(q float64) time.Duration {
+		rank := max(int64(math.Ceil(q*float64(l.Count))), 1)
+		var seen int64
+		for i, n := range counts {
+			if seen += n; seen >= rank {
+				return min(latencyUpper(i), l.Max)
+			}
+		}
+		return l.Max
+	}
+	l.P50, l.P95, l.P99 = at(0.50), at(0.95), at(0.99)
+	return l
+}
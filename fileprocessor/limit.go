@@ -0,0 +1,66 @@
+package fileprocessor
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+)
+
+// errLimit ends the walk once WithMaxFiles or WithMaxBytes is reached.
+var errLimit = errors.New("run limit reached")
+
+// limits caps how much a run queues.
+type limits struct {
+	maxFiles int64
+	maxBytes int64
+
+	mu      sync.Mutex
+	files   int64
+	bytes   int64
+	reached bool
+}
+
+func (l *limits) enabled() bool { return l.maxFiles > 0 || l.maxBytes > 0 }
+
+// reset readies l for a run.
+func (l *limits) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.files, l.bytes, l.reached = 0, 0, false
+}
+
+// take makes room for one more file of size bytes, reporting false once
+// the run is full.
+func (l *limits) take(size int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.reached || l.maxFiles > 0 && l.files >= l.maxFiles || l.maxBytes > 0 && l.bytes+size > l.maxBytes {
+		l.reached = true
+		return false
+	}
+	l.files++
+	l.bytes += size
+	return true
+}
+
+func (l *limits) truncated() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.reached
+}
+
+// admitWithin reserves the file at path against the run limits. Sizes
+// come from the walk, with links resolved; files whose size is unknown,
+// such as URLs, count as empty.
+func (p *Processor) admitWithin(path string, info fs.FileInfo) bool {
+	if !p.limits.enabled() {
+		return true
+	}
+	var size int64
+	if p.limits.maxBytes > 0 {
+		if info = p.lookup(path, info); info != nil {
+			size = info.Size()
+		}
+	}
+	return p.limits.take(size)
+}
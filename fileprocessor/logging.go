@@ -0,0 +1,71 @@
+package fileprocessor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"fileprocessor/pool"
+)
+
+// setLoggers points the pool's messages at the WithLogger and
+// WithDebugLogger loggers and at the WithSlog logger: lifecycle messages
+// and changes to the pool at level Info or, if the WithSlog logger is
+// enabled for level Debug, every decision of the autoscaler at that level.
+func (p *Processor) setLoggers(cfg *pool.Config) {
+	if p.logger != nil {
+		cfg.Logf = p.logger.Printf
+	}
+	if p.debugLogger != nil {
+		cfg.Debugf = p.debugLogger.Printf
+	}
+	l := p.slog
+	if l == nil {
+		return
+	}
+	tee := func(f func(string, ...any), level slog.Level) func(string, ...any) {
+		return func(format string, args ...any) {
+			if f != nil {
+				f(format, args...)
+			}
+			if l.Enabled(context.Background(), level) {
+				l.Log(context.Background(), level, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+			}
+		}
+	}
+	if l.Enabled(context.Background(), slog.LevelDebug) {
+		cfg.Debugf = tee(cfg.Debugf, slog.LevelDebug)
+	}
+	cfg.Logf = tee(cfg.Logf, slog.LevelInfo)
+}
+
+// logFile records the outcome of a file with the WithSlog logger: level
+// Info for a processed file and Warn for a failed one.
+func (p *Processor) logFile(ctx context.Context, id int, res Result) {
+	l := p.slog
+	if l == nil {
+		return
+	}
+	level, msg := slog.LevelInfo, "file processed"
+	if res.Err != nil {
+		level, msg = slog.LevelWarn, "file failed"
+	}
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("path", res.Path),
+		slog.Int("worker", id),
+		slog.Duration("duration", res.Duration),
+	}
+	if res.Err != nil {
+		attrs = append(attrs, slog.String("class", ErrorClass(res.Err)), slog.String("error", res.Err.Error()))
+	} else {
+		attrs = append(attrs, slog.Int64("size", res.Size))
+		if res.Hash != "" {
+			attrs = append(attrs, slog.String("hash", res.Hash))
+		}
+	}
+	l.LogAttrs(ctx, level, msg, attrs...)
+}
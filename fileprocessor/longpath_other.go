@@ -0,0 +1,6 @@
+//go:build !windows
+
+package fileprocessor
+
+// normPath returns p unchanged: only Windows has extended-length paths.
+func normPath(p string) string { return p }
@@ -0,0 +1,29 @@
+//go:build windows
+
+package fileprocessor
+
+import "strings"
+
+// normPath turns extended-length paths (\\?\C:\dir and
+// \\?\UNC\server\share\dir) into their ordinary forms (C:\dir and
+// \\server\share\dir), so that they compare, join and print like every
+// other path. Nothing is lost: the os package adds the prefix back to
+// any path that would exceed MAX_PATH. Other device paths, such as
+// \\?\Volume{...}\, are returned unchanged.
+func normPath(p string) string {
+	if len(p) < 4 || !isSlash(p[0]) || !isSlash(p[1]) || p[2] != '?' || !isSlash(p[3]) {
+		return p
+	}
+	rest := p[4:]
+	switch {
+	case len(rest) >= 4 && strings.EqualFold(rest[:3], "UNC") && isSlash(rest[3]):
+		return `\\` + rest[4:]
+	case len(rest) >= 3 && isLetter(rest[0]) && rest[1] == ':' && isSlash(rest[2]):
+		return rest
+	}
+	return p
+}
+
+func isSlash(c byte) bool { return c == '\\' || c == '/' }
+
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
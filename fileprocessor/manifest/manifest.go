@@ -0,0 +1,130 @@
+// Package manifest reads and writes checksum manifests in the format used
+// by coreutils sha256sum and its siblings: one "<hex digest>  <path>" line
+// per file. The BSD-style "SHA256 (<path>) = <hex digest>" lines written by
+// --tag, macOS shasum and md5 are supported as well.
+package manifest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Entry is one line of a manifest.
+type Entry struct {
+	Hash string
+	Path string
+	// Algorithm is the tag of a BSD-style line, such as "SHA256", and
+	// empty for coreutils lines.
+	Algorithm string
+	// Line is the 1-based line number the entry was read from.
+	Line int
+}
+
+// pathEscaper applies the coreutils escaping for names that would break
+// the line format.
+var pathEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+var pathUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+// Format returns the manifest line for path, without a trailing newline.
+// Like coreutils, a path containing a backslash or line break is escaped
+// and the line is prefixed with a backslash.
+func Format(hash, path string) string {
+	if strings.ContainsAny(path, "\\\n\r") {
+		return `\` + hash + "  " + pathEscaper.Replace(path)
+	}
+	return hash + "  " + path
+}
+
+// Write writes the manifest line for one file.
+func Write(w io.Writer, hash, path string) error {
+	_, err := io.WriteString(w, Format(hash, path)+"\n")
+	return err
+}
+
+// FormatTag returns the BSD-style line for path, such as
+// "SHA256 (a.txt) = <hash>", without a trailing newline. Paths are escaped
+// as by Format.
+func FormatTag(algorithm, hash, path string) string {
+	if strings.ContainsAny(path, "\\\n\r") {
+		return `\` + algorithm + " (" + pathEscaper.Replace(path) + ") = " + hash
+	}
+	return algorithm + " (" + path + ") = " + hash
+}
+
+// WriteTag writes the BSD-style line for one file.
+func WriteTag(w io.Writer, algorithm, hash, path string) error {
+	_, err := io.WriteString(w, FormatTag(algorithm, hash, path)+"\n")
+	return err
+}
+
+// Parse reads every entry of a manifest. Both text ("  ") and binary (" *")
+// separators are accepted, as are BSD-style lines, even mixed in one file;
+// blank lines are skipped.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		e, err := parseLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		e.Line = line
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func parseLine(text string) (Entry, error) {
+	escaped := strings.HasPrefix(text, `\`)
+	if escaped {
+		text = text[1:]
+	}
+	if e, ok := parseTag(text); ok {
+		if escaped {
+			e.Path = pathUnescaper.Replace(e.Path)
+		}
+		return e, nil
+	}
+	hash, rest, ok := strings.Cut(text, " ")
+	if !ok || len(rest) < 2 || (rest[0] != ' ' && rest[0] != '*') {
+		return Entry{}, fmt.Errorf("improperly formatted checksum line")
+	}
+	if _, err := hex.DecodeString(hash); err != nil || hash == "" {
+		return Entry{}, fmt.Errorf("invalid digest %q", hash)
+	}
+	path := rest[1:]
+	if escaped {
+		path = pathUnescaper.Replace(path)
+	}
+	return Entry{Hash: strings.ToLower(hash), Path: path}, nil
+}
+
+// parseTag parses a BSD-style line. The path is whatever lies between the
+// first " (" and the last ") = ", so it may contain either sequence.
+func parseTag(text string) (Entry, bool) {
+	algorithm, rest, ok := strings.Cut(text, " (")
+	if !ok || algorithm == "" || strings.ContainsAny(algorithm, " \t") {
+		return Entry{}, false
+	}
+	i := strings.LastIndex(rest, ") = ")
+	if i < 0 {
+		return Entry{}, false
+	}
+	path, hash := rest[:i], rest[i+len(") = "):]
+	if _, err := hex.DecodeString(hash); err != nil || hash == "" {
+		return Entry{}, false
+	}
+	return Entry{Hash: strings.ToLower(hash), Path: path, Algorithm: algorithm}, true
+}
@@ -0,0 +1,202 @@
+package fileprocessor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MerkleChunkSize is the leaf size of per-file Merkle trees.
+const MerkleChunkSize = 1 << 20
+
+// MerkleTree is a hash tree over a file's MerkleChunkSize chunks, built as
+// in RFC 6962: leaves hash 0x00 followed by the chunk, interior nodes hash
+// 0x01 followed by both children. Comparing Leaves between two runs shows
+// which chunks of a file changed.
+type MerkleTree struct {
+	Root   string
+	Leaves []string
+
+	newHash func() hash.Hash
+}
+
+// DirRoot is the Merkle root of one directory, aggregated from the roots
+// of the files and subdirectories below it.
+type DirRoot struct {
+	Path string
+	Root string
+}
+
+// merkleWriter splits a stream into leaves as it is written.
+type merkleWriter struct {
+	newHash func() hash.Hash
+	leaf    hash.Hash
+	n       int
+	leaves  [][]byte
+}
+
+func newMerkleWriter(newHash func() hash.Hash) *merkleWriter {
+	return &merkleWriter{newHash: newHash}
+}
+
+func (m *merkleWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if m.leaf == nil {
+			m.leaf = m.newHash()
+			m.leaf.Write([]byte{0x00})
+		}
+		k := min(MerkleChunkSize-m.n, len(p))
+		m.leaf.Write(p[:k])
+		m.n += k
+		p = p[k:]
+		if m.n == MerkleChunkSize {
+			m.flush()
+		}
+	}
+	return written, nil
+}
+
+func (m *merkleWriter) flush() {
+	m.leaves = append(m.leaves, m.leaf.Sum(nil))
+	m.leaf, m.n = nil, 0
+}
+
+func (m *merkleWriter) tree() *MerkleTree {
+	if m.leaf != nil {
+		m.flush()
+	}
+	t := &MerkleTree{
+		Root:    hex.EncodeToString(merkleRoot(m.leaves, m.newHash)),
+		Leaves:  make([]string, len(m.leaves)),
+		newHash: m.newHash,
+	}
+	for i, l := range m.leaves {
+		t.Leaves[i] = hex.EncodeToString(l)
+	}
+	return t
+}
+
+// merkleRoot computes the RFC 6962 tree hash over already hashed leaves.
+func merkleRoot(leaves [][]byte, newHash func() hash.Hash) []byte {
+	switch len(leaves) {
+	case 0:
+		return newHash().Sum(nil)
+	case 1:
+		return leaves[0]
+	}
+	k := 1
+	for k*2 < len(leaves) {
+		k *= 2
+	}
+	h := newHash()
+	h.Write([]byte{0x01})
+	h.Write(merkleRoot(leaves[:k], newHash))
+	h.Write(merkleRoot(leaves[k:], newHash))
+	return h.Sum(nil)
+}
+
+// merkleDirs collects per-file roots during a Run and folds them into
+// directory roots for the Summary.
+type merkleDirs struct {
+	mu      sync.Mutex
+	newHash func() hash.Hash
+	root    *merkleNode
+}
+
+type merkleNode struct {
+	files map[string][]byte
+	dirs  map[string]*merkleNode
+}
+
+func newMerkleNode() *merkleNode {
+	return &merkleNode{files: make(map[string][]byte), dirs: make(map[string]*merkleNode)}
+}
+
+// add records the tree of the file at rel, a slash-separated path relative
+// to the walked directory.
+func (d *merkleDirs) add(rel string, t *MerkleTree) {
+	sum, err := hex.DecodeString(t.Root)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.root == nil {
+		d.root, d.newHash = newMerkleNode(), t.newHash
+	}
+	if d.newHash == nil {
+		d.newHash = sha256.New
+	}
+
+	parts := strings.Split(rel, "/")
+	n := d.root
+	for _, dir := range parts[:len(parts)-1] {
+		child, ok := n.dirs[dir]
+		if !ok {
+			child = newMerkleNode()
+			n.dirs[dir] = child
+		}
+		n = child
+	}
+	n.files[parts[len(parts)-1]] = sum
+}
+
+// roots returns the root of every directory that contains processed files,
+// sorted by path, with top as the walked directory's path.
+func (d *merkleDirs) roots(top string) []DirRoot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.root == nil {
+		return nil
+	}
+	var out []DirRoot
+	d.fold(d.root, top, &out)
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// merkleRoots returns the directory roots of every walked root in turn.
+func (p *Processor) merkleRoots() []DirRoot {
+	var out []DirRoot
+	for i, root := range p.roots() {
+		out = append(out, p.merkle[i].roots(root)...)
+	}
+	return out
+}
+
+// fold hashes one directory: each entry becomes the leaf
+// 0x00 || kind || name || 0x00 || root, with kind 'f' for files and 'd' for
+// directories, and the leaves are ordered by name.
+func (d *merkleDirs) fold(n *merkleNode, path string, out *[]DirRoot) []byte {
+	type entry struct {
+		name string
+		kind byte
+		sum  []byte
+	}
+	entries := make([]entry, 0, len(n.files)+len(n.dirs))
+	for name, sum := range n.files {
+		entries = append(entries, entry{name, 'f', sum})
+	}
+	for name, child := range n.dirs {
+		entries = append(entries, entry{name, 'd', d.fold(child, filepath.Join(path, name), out)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	leaves := make([][]byte, len(entries))
+	for i, e := range entries {
+		h := d.newHash()
+		h.Write([]byte{0x00, e.kind})
+		h.Write([]byte(e.name))
+		h.Write([]byte{0x00})
+		h.Write(e.sum)
+		leaves[i] = h.Sum(nil)
+	}
+	sum := merkleRoot(leaves, d.newHash)
+	*out = append(*out, DirRoot{Path: path, Root: hex.EncodeToString(sum)})
+	return sum
+}
@@ -0,0 +1,123 @@
+package fileprocessor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/fs"
+	"strings"
+)
+
+// MetadataField selects file metadata for HashHandler.Metadata.
+type MetadataField uint8
+
+const (
+	MetaSize MetadataField = 1 << iota
+	MetaMode
+	MetaMtime
+	MetaOwner
+)
+
+var metadataNames = []struct {
+	field MetadataField
+	name  string
+}{
+	{MetaSize, "size"},
+	{MetaMode, "mode"},
+	{MetaMtime, "mtime"},
+	{MetaOwner, "owner"},
+}
+
+// ParseMetadataFields parses a comma-separated list of the names "size",
+// "mode", "mtime" and "owner".
+func ParseMetadataFields(s string) (MetadataField, error) {
+	var f MetadataField
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, m := range metadataNames {
+			if m.name == name {
+				f |= m.field
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown metadata field %q", name)
+		}
+	}
+	return f, nil
+}
+
+// String returns the fields as a comma-separated list in canonical order.
+func (f MetadataField) String() string {
+	var names []string
+	for _, m := range metadataNames {
+		if f&m.field != 0 {
+			names = append(names, m.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// apply replaces res.Hash with the hash of a canonical metadata record
+// followed by the raw content digest.
+//
+// The record has one "name:value" line per selected field: size in bytes,
+// mode as Unix octal permission and setuid/setgid/sticky bits, mtime in
+// whole Unix seconds so copies made by tools that drop sub-second
+// precision still verify, and owner as "uid:gid" where the platform has
+// them.
+func (f MetadataField) apply(res *Result, file fs.File, newHash func() hash.Hash) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	content, err := hex.DecodeString(res.Hash)
+	if err != nil {
+		return err
+	}
+
+	var rec strings.Builder
+	if f&MetaSize != 0 {
+		fmt.Fprintf(&rec, "size:%d\n", info.Size())
+	}
+	if f&MetaMode != 0 {
+		fmt.Fprintf(&rec, "mode:%04o\n", unixMode(info.Mode()))
+	}
+	if f&MetaMtime != 0 {
+		fmt.Fprintf(&rec, "mtime:%d\n", info.ModTime().Unix())
+	}
+	if f&MetaOwner != 0 {
+		uid, gid, ok := fileOwner(info)
+		if !ok {
+			return fmt.Errorf("owner not available for %s", res.Path)
+		}
+		fmt.Fprintf(&rec, "owner:%d:%d\n", uid, gid)
+	}
+
+	h := newHash()
+	h.Write([]byte(rec.String()))
+	h.Write(content)
+	res.Hash = hex.EncodeToString(h.Sum(nil))
+	res.Algorithm += "+" + f.String()
+	return nil
+}
+
+// unixMode converts the permission and special bits of m to their
+// traditional octal values.
+func unixMode(m fs.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	if m&fs.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m&fs.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m&fs.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return mode
+}
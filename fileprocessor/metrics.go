@@ -0,0 +1,137 @@
+package fileprocessor
+
+import "sync"
+
+// Names of the metrics a Processor records.
+const (
+	MetricFilesProcessed = "files_processed"
+	MetricFilesFailed    = "files_failed"
+	MetricFilesSkipped   = "files_skipped"
+	MetricBytesProcessed = "bytes_processed"
+	// MetricFilesUnchanged counts files WithSkipUnchanged carried over
+	// from an earlier run.
+	MetricFilesUnchanged = "files_unchanged"
+	// MetricHardlinks counts paths folded into the job of another link to
+	// the same file with WithHardlinks.
+	MetricHardlinks = "hardlinks_folded"
+	// MetricFileSeconds observes how long each file took to handle.
+	MetricFileSeconds = "file_duration_seconds"
+)
+
+// Metrics is a sink for the counters and observations a Processor
+// records. Implementations must be safe for concurrent use. Wire your own
+// backend with WithMetrics; the Processor always keeps an in-memory copy
+// for Snapshots and the Summary.
+type Metrics interface {
+	// Inc adds delta to the named counter.
+	Inc(name string, delta int64)
+	// Observe records one sample of the named distribution.
+	Observe(name string, value float64)
+	// Snapshot returns the current values.
+	Snapshot() MetricsSnapshot
+}
+
+// Observation summarises the samples of one distribution.
+type Observation struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// Mean returns the average sample, or zero without samples.
+func (o Observation) Mean() float64 {
+	if o.Count == 0 {
+		return 0
+	}
+	return o.Sum / float64(o.Count)
+}
+
+// MetricsSnapshot is a copy of all recorded metrics.
+type MetricsSnapshot struct {
+	Counters     map[string]int64
+	Observations map[string]Observation
+}
+
+// MemoryMetrics is an in-memory Metrics implementation.
+type MemoryMetrics struct {
+	mu           sync.Mutex
+	counters     map[string]int64
+	observations map[string]Observation
+}
+
+// NewMemoryMetrics returns an empty MemoryMetrics.
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{
+		counters:     make(map[string]int64),
+		observations: make(map[string]Observation),
+	}
+}
+
+// Inc adds delta to the named counter.
+func (m *MemoryMetrics) Inc(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name] += delta
+}
+
+// Observe records one sample of the named distribution.
+func (m *MemoryMetrics) Observe(name string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o := m.observations[name]
+	if o.Count == 0 || value < o.Min {
+		o.Min = value
+	}
+	if o.Count == 0 || value > o.Max {
+		o.Max = value
+	}
+	o.Count++
+	o.Sum += value
+	m.observations[name] = o
+}
+
+// Counter returns the current value of the named counter.
+func (m *MemoryMetrics) Counter(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+// Snapshot returns a copy of all metrics.
+func (m *MemoryMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := MetricsSnapshot{
+		Counters:     make(map[string]int64, len(m.counters)),
+		Observations: make(map[string]Observation, len(m.observations)),
+	}
+	for k, v := range m.counters {
+		s.Counters[k] = v
+	}
+	for k, v := range m.observations {
+		s.Observations[k] = v
+	}
+	return s
+}
+
+// inc records a counter in the Processor's own metrics and its sink.
+func (p *Processor) inc(name string, delta int64) {
+	p.metrics.Inc(name, delta)
+	if p.sink != nil {
+		p.sink.Inc(name, delta)
+	}
+}
+
+// observe records a sample in the Processor's own metrics and its sink.
+func (p *Processor) observe(name string, value float64) {
+	p.metrics.Observe(name, value)
+	if p.sink != nil {
+		p.sink.Observe(name, value)
+	}
+}
+
+// Metrics returns the metrics recorded so far.
+func (p *Processor) Metrics() MetricsSnapshot {
+	return p.metrics.Snapshot()
+}
@@ -0,0 +1,224 @@
+package fileprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a FileHandler with cross-cutting behaviour such as
+// retries, timeouts or rate limiting.
+type Middleware func(next FileHandler) FileHandler
+
+// Chain wraps h with mws. The first middleware is the outermost, so it
+// sees every call first and every result last.
+func Chain(h FileHandler, mws ...Middleware) FileHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Retry retries failed files up to attempts times in total, waiting
+// backoff after the first failure and doubling it after each further one.
+// Cancellation of ctx and ErrSkip are never retried.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next FileHandler) FileHandler {
+		return HandlerFunc(func(ctx context.Context, path string) (Result, error) {
+			wait := backoff
+			var res Result
+			var err error
+			for attempt := 1; ; attempt++ {
+				res, err = next.Handle(ctx, path)
+				if err == nil || attempt >= attempts || ctx.Err() != nil || errors.Is(err, ErrSkip) {
+					return res, err
+				}
+				if !sleep(ctx, wait) {
+					return res, err
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+// ContentTypes passes a file to next only if its MIME type matches one
+// of patterns, such as "image/*" or "application/pdf", and skips it with
+// ErrSkip otherwise. The type is sniffed from the first 512 bytes with
+// http.DetectContentType, parameters such as charset dropped, and stored
+// in Result.ContentType unless the handler set one. Sniffing opens the
+// file a second time, so it doesn't work on archive members, which can
+// only be read once.
+func ContentTypes(patterns ...string) Middleware {
+	return func(next FileHandler) FileHandler {
+		return HandlerFunc(func(ctx context.Context, name string) (Result, error) {
+			mime, err := sniff(ctx, name)
+			if err != nil {
+				return Result{}, err
+			}
+			matched := false
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, mime); ok {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return Result{}, ErrSkip
+			}
+			res, err := next.Handle(ctx, name)
+			if res.ContentType == "" {
+				res.ContentType = mime
+			}
+			return res, err
+		})
+	}
+}
+
+// sniff detects the MIME type of the file at name.
+func sniff(ctx context.Context, name string) (string, error) {
+	f, err := Open(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	mime, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	return mime, nil
+}
+
+// StableFor holds back files that may still be being written: a file
+// modified less than window ago is skipped with ErrSkip or, with wait,
+// handled once it has been left alone for window, the worker waiting
+// meanwhile. A file modified again during the wait, or whose size or
+// modification time changes while next reads it, is skipped as well, so
+// no Result describes a half-written file. URLs are passed through.
+func StableFor(window time.Duration, wait bool) Middleware {
+	return func(next FileHandler) FileHandler {
+		return HandlerFunc(func(ctx context.Context, path string) (Result, error) {
+			if IsURL(path) {
+				return next.Handle(ctx, path)
+			}
+			before, err := Stat(ctx, path)
+			if err != nil {
+				return Result{}, err
+			}
+			if age := time.Since(before.ModTime()); age < window {
+				if !wait {
+					return Result{}, ErrSkip
+				}
+				// A modification time in the future waits one window.
+				if !sleep(ctx, min(window-age, window)) {
+					return Result{}, ctx.Err()
+				}
+				again, err := Stat(ctx, path)
+				if err != nil {
+					return Result{}, err
+				}
+				if changed(before, again) {
+					return Result{}, ErrSkip
+				}
+			}
+			res, err := next.Handle(ctx, path)
+			if after, serr := Stat(ctx, path); serr == nil && changed(before, after) {
+				return Result{}, ErrSkip
+			}
+			return res, err
+		})
+	}
+}
+
+// changed reports whether a file's size or modification time differs
+// between two stats.
+func changed(a, b fs.FileInfo) bool {
+	return a.Size() != b.Size() || !a.ModTime().Equal(b.ModTime())
+}
+
+// Timeout bounds how long a single file may take.
+func Timeout(d time.Duration) Middleware {
+	return func(next FileHandler) FileHandler {
+		return HandlerFunc(func(ctx context.Context, path string) (Result, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Handle(ctx, path)
+		})
+	}
+}
+
+// RateLimit caps the number of files started per second across all
+// workers sharing the returned middleware.
+func RateLimit(perSecond float64) Middleware {
+	interval := time.Duration(float64(time.Second) / perSecond)
+	var mu sync.Mutex
+	var slot time.Time
+
+	return func(next FileHandler) FileHandler {
+		return HandlerFunc(func(ctx context.Context, path string) (Result, error) {
+			mu.Lock()
+			now := time.Now()
+			if slot.Before(now) {
+				slot = now
+			}
+			wait := slot.Sub(now)
+			slot = slot.Add(interval)
+			mu.Unlock()
+
+			if wait > 0 && !sleep(ctx, wait) {
+				return Result{}, ctx.Err()
+			}
+			return next.Handle(ctx, path)
+		})
+	}
+}
+
+// Delay adds d of simulated work after every successful file.
+func Delay(d time.Duration) Middleware {
+	return func(next FileHandler) FileHandler {
+		return HandlerFunc(func(ctx context.Context, path string) (Result, error) {
+			res, err := next.Handle(ctx, path)
+			if err == nil {
+				sleep(ctx, d)
+			}
+			return res, err
+		})
+	}
+}
+
+// Recover turns a panicking handler into a failed file instead of a
+// crashed process.
+func Recover() Middleware {
+	return func(next FileHandler) FileHandler {
+		return HandlerFunc(func(ctx context.Context, path string) (res Result, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic handling %s: %v", path, r)
+				}
+			}()
+			return next.Handle(ctx, path)
+		})
+	}
+}
+
+// sleep waits for d or until ctx is done, reporting whether the full
+// duration elapsed.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
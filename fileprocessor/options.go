@@ -0,0 +1,592 @@
+package fileprocessor
+
+import (
+	"hash"
+	"io/fs"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"fileprocessor/clock"
+)
+
+// Option configures a Processor.
+type Option func(*Processor)
+
+// WithDir sets the directory to scan. It defaults to the current directory.
+// On Windows it may be a UNC share (\\server\share) or an extended-length
+// path (\\?\C:\dir), which is walked and reported in its ordinary form.
+func WithDir(dir string) Option {
+	return func(p *Processor) {
+		p.dir, p.dirs = normPath(dir), nil
+	}
+}
+
+// WithDirs scans several directories in one run, in order, sharing the
+// worker pool, metrics and Summary. With more than one, the fingerprint
+// and drift report name files by their full path rather than relative to
+// a root, and Summary.Dirs holds the Merkle roots of each tree in turn.
+func WithDirs(dirs ...string) Option {
+	return func(p *Processor) {
+		if len(dirs) > 0 {
+			p.dirs = make([]string, len(dirs))
+			for i, dir := range dirs {
+				p.dirs[i] = normPath(dir)
+			}
+			p.dir = p.dirs[0]
+		}
+	}
+}
+
+// WithFS reads files from fsys instead of the local disk. Any fs.FS works:
+// os.DirFS, embed.FS, fstest.MapFS or a *zip.Reader. The directory set by
+// WithDir is then a slash-separated path within fsys and defaults to its
+// root. Handlers see FS paths and should open them with Open.
+func WithFS(fsys fs.FS) Option {
+	return func(p *Processor) {
+		p.fsys = fsys
+	}
+}
+
+// WithWalker replaces directory traversal entirely, for example to feed
+// the Processor a precomputed list of files. WithDir and WithFS no longer
+// affect which files are visited, but WithFS still governs how handlers
+// open them.
+func WithWalker(w Walker) Option {
+	return func(p *Processor) {
+		p.walker = w
+	}
+}
+
+// WithFingerprint makes Run compute Summary.Fingerprint, one stable
+// digest of the whole tree suitable for comparing build outputs or dataset
+// snapshots. It requires a handler that sets Result.Hash.
+func WithFingerprint(enabled bool) Option {
+	return func(p *Processor) {
+		p.fingerprint.enabled = enabled
+	}
+}
+
+// WithDirStats makes Run roll the results up by directory into
+// Summary.DirStats: for every directory holding files, at any depth, the
+// files processed and failed below it, their total size and a combined
+// hash.
+func WithDirStats(enabled bool) Option {
+	return func(p *Processor) {
+		p.dirStats.enabled = enabled
+	}
+}
+
+// WithDuplicates groups processed files with the same size and hash into
+// Summary.Duplicates. Hard links folded by WithHardlinks share their
+// storage and are not counted as copies. It keeps the path of every hashed
+// file until the end of the run.
+func WithDuplicates(enabled bool) Option {
+	return func(p *Processor) {
+		p.duplicates.enabled = enabled
+	}
+}
+
+// WithSimilarity groups files whose Result.Fuzzy digests score at least
+// threshold (1-100, see Similarity) into Summary.Clusters. It needs a
+// handler that sets Result.Fuzzy, such as a HashHandler with Fuzzy set.
+// Files are compared pairwise, which gets slow beyond tens of thousands of
+// digests.
+func WithSimilarity(threshold int) Option {
+	return func(p *Processor) {
+		if threshold > 0 {
+			p.similarity.threshold = threshold
+		}
+	}
+}
+
+// WithBaseline compares every file with a previous run's hashes, keyed by
+// path, and classifies it in Summary.Drift as unchanged, modified or
+// added; baseline paths not seen are reported deleted. Paths relative to
+// the walked directory and paths including it both match.
+func WithBaseline(hashes map[string]string) Option {
+	return func(p *Processor) {
+		p.baseline = hashes
+	}
+}
+
+// WithWorkers sets the initial number of worker goroutines.
+func WithWorkers(n int) Option {
+	return func(p *Processor) {
+		if n > 0 {
+			p.workers = n
+		}
+	}
+}
+
+// WithWorkerLimits sets the bounds the autoscaler keeps the pool within.
+// The defaults are 2 and 20.
+func WithWorkerLimits(min, max int) Option {
+	return func(p *Processor) {
+		if min > 0 && max >= min {
+			p.minWorkers = min
+			p.maxWorkers = max
+		}
+	}
+}
+
+// WithWalkWorkers makes the default Walker read up to n directories
+// concurrently, which helps when listing directories is the bottleneck,
+// for example on network filesystems. Files are then found in no
+// particular order. It defaults to 1, a sequential walk in lexical order.
+func WithWalkWorkers(n int) Option {
+	return func(p *Processor) {
+		if n > 0 {
+			p.walkWorkers = n
+		}
+	}
+}
+
+// WithFollowSymlinks makes the default Walker descend into symbolic links
+// to directories, entering every directory at most once so link cycles
+// terminate. Links to directories are otherwise ignored, except for the
+// walked directory itself. Links to files are always handled. It has no
+// effect on an fs.FS.
+func WithFollowSymlinks(follow bool) Option {
+	return func(p *Processor) {
+		p.followSymlinks = follow
+	}
+}
+
+// WithSkipDangling makes the default Walker silently ignore symbolic links
+// whose target is missing. By default they are handed to the handler,
+// which reports them as failed files.
+func WithSkipDangling(skip bool) Option {
+	return func(p *Processor) {
+		p.skipDangling = skip
+	}
+}
+
+// WithMaxDepth stops the default Walker n levels below each root, like
+// find -maxdepth: 1 processes only the files directly in the root.
+// Non-positive values mean no limit.
+func WithMaxDepth(n int) Option {
+	return func(p *Processor) {
+		if n > 0 {
+			p.maxDepth = n
+		}
+	}
+}
+
+// WithPruneDirs keeps the default Walker out of directories whose name
+// matches one of patterns (filepath.Match syntax, e.g. "node_modules" or
+// ".git"), so their contents are never listed. It may be given more than
+// once. The roots themselves are always walked.
+func WithPruneDirs(patterns ...string) Option {
+	return func(p *Processor) {
+		p.pruneDirs = append(p.pruneDirs, patterns...)
+	}
+}
+
+// WithPruneMarkers makes the default Walker skip every directory that
+// holds a file with one of names, such as ".nobackup", so users can opt
+// subtrees out from inside them, as with restic's --exclude-if-present.
+// A "CACHEDIR.TAG" only counts if it starts with the signature of the
+// Cache Directory Tagging Specification, as for tar --exclude-caches. The
+// roots are always walked. Repeated calls add names.
+func WithPruneMarkers(names ...string) Option {
+	return func(p *Processor) {
+		p.pruneMarkers = append(p.pruneMarkers, names...)
+	}
+}
+
+// WithOneFileSystem keeps the default Walker on the filesystem of each
+// root, like find -xdev: mount points of other filesystems, such as /proc,
+// NFS shares or bind mounts, are not descended into. It compares device
+// numbers and so has no effect on Windows.
+func WithOneFileSystem(enabled bool) Option {
+	return func(p *Processor) {
+		p.oneFileSystem = enabled
+	}
+}
+
+// WithFilters adds filters that decide which of the files the walk finds
+// are processed; a file must pass all of them. Files filtered out count
+// as skipped. Filters see the path and, for files listed by a custom
+// Walker or reached through a link, a FileInfo the Processor stats
+// for them.
+func WithFilters(filters ...Filter) Option {
+	return func(p *Processor) {
+		p.filters = append(p.filters, filters...)
+	}
+}
+
+// WithFilterCommand makes an external command decide which of the files
+// that pass the other filters are processed. The command is run on
+// batches of files, see WithFilterBatch, with their paths on its standard
+// input, each ended by a NUL byte, and the files whose paths it writes to
+// its standard output the same way are processed; the others count as
+// skipped. Paths are as the walk reports them, and files are processed
+// in walk order whatever the order of the command's output. Exit status 1
+// with no output passes none of the batch, as from grep -z; any other
+// failure of the command stops the run with an error.
+func WithFilterCommand(name string, args ...string) Option {
+	return func(p *Processor) {
+		p.filterCmd = &filterCmd{name: name, args: args}
+	}
+}
+
+// WithFilterBatch sets how many paths WithFilterCommand hands the command
+// at a time, 1000 by default. The files of a batch are only processed once
+// the command has seen all of them, so smaller batches start work sooner
+// and larger ones run the command less often.
+func WithFilterBatch(n int) Option {
+	return func(p *Processor) {
+		p.filterBatch = n
+	}
+}
+
+// WithSpecialFiles sets what happens to devices, named pipes, sockets and
+// other special files the walk finds. SpecialReport, the default, passes
+// them on, and handlers that open them fail with ErrSpecialFile.
+// SpecialSkip counts them as skipped, and SpecialFail stops the run with
+// an error wrapping ErrSpecialFile. No policy blocks a worker on one.
+func WithSpecialFiles(policy SpecialFiles) Option {
+	return func(p *Processor) {
+		p.special = policy
+	}
+}
+
+// WithGitignore makes the default Walker skip what .gitignore, .ignore
+// and .git/info/exclude files exclude, including nested files and
+// negated patterns, with the precedence git gives them. Excluded
+// directories are not entered. On disk, the ignore files of the parents
+// of each root up to the enclosing repository apply too. .git directories
+// are always skipped.
+func WithGitignore(enabled bool) Option {
+	return func(p *Processor) {
+		p.gitignore = enabled
+	}
+}
+
+// WithExclude makes the default Walker skip files and directories matching
+// patterns in .gitignore syntax, anchored at each root: "*.tmp" excludes
+// at any depth, "/build" only at the top, "cache/" only directories, and a
+// leading "!" re-includes. Blank lines and lines starting with # are
+// ignored, so the lines of an exclusion file can be passed as they are.
+// Excluded directories are not entered. With WithGitignore, the ignore
+// files found in the trees take precedence. Repeated calls add patterns.
+func WithExclude(patterns ...string) Option {
+	return func(p *Processor) {
+		p.exclude = append(p.exclude, patterns...)
+	}
+}
+
+// WithSkipHidden makes the default Walker leave out dotfiles and
+// dot-directories, and on Windows files and directories with the hidden
+// attribute, without entering hidden directories. The roots themselves are
+// always walked.
+func WithSkipHidden(skip bool) Option {
+	return func(p *Processor) {
+		p.skipHidden = skip
+	}
+}
+
+// WithHardlinks processes a file with several hard links once: the first
+// of its paths the walk reports is handed to a worker and the others, told
+// apart by device and inode number, are listed in that path's
+// Result.Links and counted in Summary.Hardlinks. The Result is held back
+// until the walk has found as many paths as the file has links, or has
+// ended. Symbolic links are not folded. It has no effect on platforms
+// without inode numbers, such as Windows.
+func WithHardlinks(enabled bool) Option {
+	return func(p *Processor) {
+		p.hardlinks = nil
+		if enabled {
+			p.hardlinks = newHardlinks()
+		}
+	}
+}
+
+// WithSampleRate processes a uniformly random fraction of the files the
+// walk finds and admits, between 0 and 1, each file being kept with that
+// probability; the rest count as skipped. It suits spot checks of trees
+// too large to process in full.
+func WithSampleRate(fraction float64) Option {
+	return func(p *Processor) {
+		p.sampling().rate = fraction
+	}
+}
+
+// WithSampleCount processes n files picked uniformly at random from those
+// the walk finds and admits, or all of them if there are fewer. The sample
+// is only known once the walk is over, so processing starts then, in walk
+// order; the walk holds up to n paths in memory meanwhile. With
+// WithSampleRate as well, the n files are picked from those the rate kept.
+func WithSampleCount(n int) Option {
+	return func(p *Processor) {
+		p.sampling().count = n
+	}
+}
+
+// WithSampleSeed makes WithSampleRate and WithSampleCount pick the same
+// sample on every run over the same files, found in the same order. The
+// default of 0 picks a new sample each time.
+func WithSampleSeed(seed uint64) Option {
+	return func(p *Processor) {
+		p.sampling().seed = seed
+	}
+}
+
+func (p *Processor) sampling() *subset {
+	if p.subset == nil {
+		p.subset = &subset{}
+	}
+	return p.subset
+}
+
+// WithMaxFiles stops the run after n files have been queued, for smoke
+// tests and scans with a budget. The walk ends there, the files already
+// queued are processed, and Summary.Truncated is set. 0 means no limit.
+func WithMaxFiles(n int64) Option {
+	return func(p *Processor) {
+		p.limits.maxFiles = n
+	}
+}
+
+// WithMaxBytes stops the run before the first file that would take the
+// total size of the queued files past n bytes, like WithMaxFiles. 0 means
+// no limit.
+func WithMaxBytes(n int64) Option {
+	return func(p *Processor) {
+		p.limits.maxBytes = n
+	}
+}
+
+// WithPrescan counts the files and bytes of the default Walker's trees in
+// a quick pass before processing starts, so Snapshots carry totals and
+// Snapshot.Progress can estimate the percentage done and the time left.
+// The count costs one extra directory listing of the trees. It is skipped
+// when WithWalker replaces the walk.
+func WithPrescan(enabled bool) Option {
+	return func(p *Processor) {
+		p.prescan = enabled
+	}
+}
+
+// WithSorted makes a run reproducible: files are submitted in
+// lexicographic path order and their Results reach the reporter and the
+// Results channel in that order, however the workers interleave. The walk
+// is completed before the first file is processed, and a slow file holds
+// back the results after it. Hooks still fire as files finish.
+func WithSorted(sorted bool) Option {
+	return func(p *Processor) {
+		p.sorted = sorted
+	}
+}
+
+// WithStartAfter resumes an interrupted run: files up to and including
+// path, the last one it reported, are skipped without being read. The
+// default walk compares paths in its own order and does not enter
+// directories that lie entirely before path; with WithWalkWorkers that
+// order is not the one files were reported in, so resume sequential walks
+// only. With WithSorted, files after path in lexicographic order are
+// processed, and a custom Walker skips everything up to the first visit of
+// path. The default walk fails if path doesn't lie below a walked
+// directory.
+func WithStartAfter(path string) Option {
+	return func(p *Processor) {
+		p.startAfter = normPath(path)
+	}
+}
+
+// WithArchives makes the Processor look inside zip, tar and tar.gz files
+// (recognised by their extension) instead of handling them as files. Each
+// regular member is streamed to the handler without extraction under the
+// path "<archive>::<member>", e.g. "backup.tar.gz::etc/passwd"; members
+// can be read only once per job, so they do not combine with Retry. An
+// archive that cannot be read is reported as a failed file.
+func WithArchives(enabled bool) Option {
+	return func(p *Processor) {
+		if enabled {
+			p.archives = newArchives()
+		} else {
+			p.archives = nil
+		}
+	}
+}
+
+// WithURLs lets handlers read http:// and https:// URLs as files: Open
+// downloads the response body and Stat sends a HEAD request, with client
+// or, if nil, http.DefaultClient. Combined with a Walker that yields URLs,
+// such as ListWalker over a list of them, the Processor becomes a
+// concurrent download verifier. Responses outside 2xx fail the file, and
+// Result.ContentType records the server's media type.
+func WithURLs(client *http.Client) Option {
+	return func(p *Processor) {
+		u := newURLs(client)
+		if p.urls != nil {
+			u.perSecond, u.concurrent = p.urls.perSecond, p.urls.concurrent
+		}
+		p.urls = u
+	}
+}
+
+// WithHostRateLimit spaces requests to any one host of a WithURLs run at
+// most perSecond apart; RateLimit limits all files together instead.
+// Non-positive values are ignored.
+func WithHostRateLimit(perSecond float64) Option {
+	return func(p *Processor) {
+		if perSecond > 0 {
+			if p.urls == nil {
+				p.urls = newURLs(nil)
+			}
+			p.urls.perSecond = perSecond
+		}
+	}
+}
+
+// WithHostConcurrency caps the downloads in flight from any one host of a
+// WithURLs run at n, however many workers there are. Non-positive values
+// are ignored.
+func WithHostConcurrency(n int) Option {
+	return func(p *Processor) {
+		if n > 0 {
+			if p.urls == nil {
+				p.urls = newURLs(nil)
+			}
+			p.urls.concurrent = n
+		}
+	}
+}
+
+// WithSpillQueue stops the walker from ever waiting for the workers: jobs
+// that don't fit the queue set by WithQueueSize are written to a temporary
+// file in dir (or os.TempDir if dir is empty) and fed back in order. This
+// suits slow storage, where blocking the walk would leave the listing and
+// the reading to take turns. By default the walker blocks on a full
+// queue, which bounds memory and disk use.
+func WithSpillQueue(dir string) Option {
+	return func(p *Processor) {
+		p.spill, p.spillDir = true, dir
+	}
+}
+
+// WithQueueSize sets the capacity of the job queue between the walker and
+// the workers. It defaults to 100.
+func WithQueueSize(n int) Option {
+	return func(p *Processor) {
+		if n >= 0 {
+			p.queueSize = n
+		}
+	}
+}
+
+// WithHandler sets the FileHandler run for every file. It defaults to a
+// SHA256 HashHandler.
+func WithHandler(h FileHandler) Option {
+	return func(p *Processor) {
+		if h != nil {
+			p.handler = h
+		}
+	}
+}
+
+// WithMiddleware wraps the handler with mws, outermost first. It may be
+// given more than once; later middleware wraps inside earlier middleware.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(p *Processor) {
+		p.middleware = append(p.middleware, mws...)
+	}
+}
+
+// WithHasher hashes files with newHash. It is shorthand for
+// WithHandler(HashHandler{New: newHash}); use NewHashHandler to select a
+// built-in algorithm by name.
+func WithHasher(newHash func() hash.Hash) Option {
+	return func(p *Processor) {
+		if newHash != nil {
+			p.handler = HashHandler{New: newHash}
+		}
+	}
+}
+
+// WithReporter sets where periodic metrics snapshots are sent. By default
+// snapshots are discarded.
+func WithReporter(r Reporter) Option {
+	return func(p *Processor) {
+		if r != nil {
+			p.reporter = r
+		}
+	}
+}
+
+// WithMetricsInterval sets how often the reporter gets a snapshot; 0 sends
+// none, leaving it the summary alone. Defaults to a second.
+func WithMetricsInterval(d time.Duration) Option {
+	return func(p *Processor) {
+		p.interval = max(d, 0)
+	}
+}
+
+// WithLogger receives worker lifecycle and autoscaler messages. By default
+// they are discarded.
+func WithLogger(l *log.Logger) Option {
+	return func(p *Processor) {
+		p.logger = l
+	}
+}
+
+// WithDebugLogger receives the autoscaler's decisions instead of the
+// WithLogger logger, including one every interval it leaves the pool as
+// it is.
+func WithDebugLogger(l *log.Logger) Option {
+	return func(p *Processor) {
+		p.debugLogger = l
+	}
+}
+
+// WithTracer hands the spans of every Run to t: one for the run, one for
+// the walk and two for every file, its wait in the queue and its
+// processing. See the otlp package for an exporter.
+func WithTracer(t Tracer) Option {
+	return func(p *Processor) {
+		p.tracer = t
+	}
+}
+
+// WithSlog sends structured records to l: one per file, "file processed"
+// at level Info and "file failed" at level Warn, with its path, worker and
+// duration; worker lifecycle messages at level Info; and the autoscaler's
+// changes to the pool at level Info or, if l is enabled for level Debug,
+// all its decisions at level Debug. It works besides WithLogger and
+// WithDebugLogger, though in the latter case the changes go to the
+// WithDebugLogger logger only.
+func WithSlog(l *slog.Logger) Option {
+	return func(p *Processor) {
+		p.slog = l
+	}
+}
+
+// WithClock replaces the wall clock driving the metrics reporter, the
+// autoscaler and per-file durations. It exists for tests; see
+// fileprocessortest.Clock.
+func WithClock(c clock.Clock) Option {
+	return func(p *Processor) {
+		if c != nil {
+			p.clock = c
+		}
+	}
+}
+
+// WithMetrics additionally records every counter and observation in m, so
+// runs can feed an external metrics backend.
+func WithMetrics(m Metrics) Option {
+	return func(p *Processor) {
+		p.sink = m
+	}
+}
+
+// WithTopFiles sets how many of the slowest and largest files the Summary
+// lists, 20 by default. Zero turns the lists off.
+func WithTopFiles(n int) Option {
+	return func(p *Processor) {
+		p.top.n = max(n, 0)
+	}
+}
@@ -0,0 +1,382 @@
+// Package otlp exports the spans of Runs as OpenTelemetry traces, over
+// OTLP/HTTP with JSON encoding, to a collector or any backend that takes
+// OTLP directly:
+//
+//	e, err := otlp.New(otlp.Config{Endpoint: "http://localhost:4318", SampleRate: 0.01})
+//	if err != nil {
+//		return err
+//	}
+//	defer e.Close()
+//	p := fileprocessor.New(fileprocessor.WithTracer(e))
+//
+// Every Run becomes one trace. Its root span, "run", holds one "walk" span
+// and, for every sampled file, a "queue" span for the time it waited for
+// a worker and a "file" span for the handler. Failed files always get
+// their file span, whatever the sample rate.
+//
+// Spans are sent in batches from a goroutine of their own, so the workers
+// never wait for the network. When the backend cannot keep up, spans of
+// files are dropped rather than queued without bound, and Close reports
+// how many.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fileprocessor"
+)
+
+const (
+	// batchSize is how many spans one request carries at most, and
+	// flushInterval how long a span waits for its batch to fill.
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	// bufferSize is how many spans wait for the exporter before spans of
+	// files are dropped.
+	bufferSize = 8192
+	// requestTimeout bounds a single export request.
+	requestTimeout = 10 * time.Second
+)
+
+// Config configures an Exporter.
+type Config struct {
+	// Endpoint is the URL of the OTLP/HTTP receiver, such as
+	// http://localhost:4318. /v1/traces is appended unless the URL has a
+	// path of its own.
+	Endpoint string
+	// Headers are sent with every request, such as an API key.
+	Headers map[string]string
+	// ServiceName is the service.name of the traces; "fileprocessor" if
+	// empty.
+	ServiceName string
+	// SampleRate is the share of files, from 0 to 1, that get queue and
+	// file spans. The choice depends on the path alone, so a file is
+	// traced in every run or in none. Zero traces every file.
+	SampleRate float64
+	// Client sends the requests; http.DefaultClient if nil.
+	Client *http.Client
+}
+
+var _ fileprocessor.Tracer = (*Exporter)(nil)
+
+// Exporter is a fileprocessor.Tracer sending spans to an OTLP receiver.
+// It traces one Run at a time.
+type Exporter struct {
+	url       string
+	headers   map[string]string
+	service   string
+	threshold uint64 // paths hashing below it are sampled
+	client    *http.Client
+
+	spans   chan fileprocessor.Span
+	done    chan struct{}
+	closing sync.Once
+	dropped atomic.Int64
+	err     error // the first failed export, set by the loop
+}
+
+// New returns an Exporter for cfg and starts its export goroutine. The
+// caller must Close it to send the last spans.
+func New(cfg Config) (*Exporter, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("otlp: endpoint %q is not an http or https URL", cfg.Endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("otlp: sample rate %v is not between 0 and 1", cfg.SampleRate)
+	}
+	e := &Exporter{
+		url:       u.String(),
+		headers:   cfg.Headers,
+		service:   cfg.ServiceName,
+		threshold: math.MaxUint64,
+		client:    cfg.Client,
+		spans:     make(chan fileprocessor.Span, bufferSize),
+		done:      make(chan struct{}),
+	}
+	if e.service == "" {
+		e.service = "fileprocessor"
+	}
+	if e.client == nil {
+		e.client = http.DefaultClient
+	}
+	if cfg.SampleRate > 0 && cfg.SampleRate < 1 {
+		e.threshold = uint64(cfg.SampleRate * math.MaxUint64)
+	}
+	go e.loop()
+	return e, nil
+}
+
+// Span queues s for export, unless it belongs to a file that is not
+// sampled. Spans of files are dropped when the queue is full; run and walk
+// spans wait for room, since the run span closes its trace.
+func (e *Exporter) Span(s fileprocessor.Span) {
+	switch s.Kind {
+	case fileprocessor.SpanQueue, fileprocessor.SpanFile:
+		if s.Err == nil && !e.sampled(s.Path) {
+			return
+		}
+		select {
+		case e.spans <- s:
+		default:
+			e.dropped.Add(1)
+		}
+	default:
+		e.spans <- s
+	}
+}
+
+// sampled reports whether the file at path is traced.
+func (e *Exporter) sampled(path string) bool {
+	if e.threshold == math.MaxUint64 {
+		return true
+	}
+	h := fnv.New64a()
+	io.WriteString(h, path)
+	return h.Sum64() < e.threshold
+}
+
+// Close sends the spans still queued and stops the exporter; Span must
+// not be called after it. It returns the first export that failed, and
+// how many spans were dropped.
+func (e *Exporter) Close() error {
+	e.closing.Do(func() { close(e.spans) })
+	<-e.done
+	err := e.err
+	if n := e.dropped.Load(); n > 0 {
+		err = errors.Join(err, fmt.Errorf("otlp: %d spans dropped", n))
+	}
+	return err
+}
+
+// loop encodes the queued spans and sends them every batchSize spans,
+// every flushInterval and at the end of every run.
+func (e *Exporter) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var (
+		batch []span
+		trace string // of the current run, empty between runs
+		run   string
+	)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil && e.err == nil {
+			e.err = err
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s, ok := <-e.spans:
+			if !ok {
+				flush()
+				return
+			}
+			if trace == "" {
+				trace, run = newID(16), newID(8)
+			}
+			out := e.encode(s)
+			out.TraceID = trace
+			if s.Kind == fileprocessor.SpanRun {
+				out.SpanID = run
+			} else {
+				out.SpanID, out.ParentSpanID = newID(8), run
+			}
+			batch = append(batch, out)
+			if s.Kind == fileprocessor.SpanRun {
+				trace, run = "", ""
+				flush()
+			} else if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// encode converts s to its OTLP form, without its IDs.
+func (e *Exporter) encode(s fileprocessor.Span) span {
+	out := span{
+		Name:              s.Kind,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+	}
+	switch s.Kind {
+	case fileprocessor.SpanRun:
+		if sum := s.Summary; sum != nil {
+			out.Attributes = []attribute{
+				intAttr("fileprocessor.files.processed", sum.Processed),
+				intAttr("fileprocessor.files.failed", sum.Failed),
+				intAttr("fileprocessor.files.skipped", sum.Skipped),
+				intAttr("fileprocessor.bytes", sum.Bytes),
+			}
+		}
+	case fileprocessor.SpanQueue:
+		out.Attributes = []attribute{
+			stringAttr("file.path", s.Path),
+			intAttr("fileprocessor.worker", int64(s.Worker)),
+		}
+	case fileprocessor.SpanFile:
+		out.Attributes = []attribute{
+			stringAttr("file.path", s.Path),
+			intAttr("fileprocessor.worker", int64(s.Worker)),
+		}
+		if s.Err == nil {
+			out.Attributes = append(out.Attributes, intAttr("file.size", s.Size))
+		} else {
+			out.Attributes = append(out.Attributes, stringAttr("error.type", fileprocessor.ErrorClass(s.Err)))
+		}
+	}
+	if s.Err != nil {
+		out.Status = status{Code: statusError, Message: s.Err.Error()}
+	}
+	return out
+}
+
+// export sends one batch of spans.
+func (e *Exporter) export(spans []span) error {
+	body, err := json.Marshal(exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: []attribute{stringAttr("service.name", e.service)}},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: "fileprocessor", Version: fileprocessor.Version},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err = fmt.Errorf("otlp: POST %s: %s", e.url, resp.Status)
+		if m := strings.TrimSpace(string(msg)); m != "" {
+			err = fmt.Errorf("%w: %s", err, m)
+		}
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// ParseHeaders parses headers in the form of OTEL_EXPORTER_OTLP_HEADERS:
+// comma-separated key=value pairs with URL-encoded values.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("otlp: header %q is not key=value", pair)
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("otlp: header %q: %w", k, err)
+		}
+		headers[k] = v
+	}
+	return headers, nil
+}
+
+// newID returns a random trace or span ID of n bytes, hex encoded.
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// The OTLP JSON encoding of an ExportTraceServiceRequest, as far as it is
+// used here. IDs are hex and 64-bit integers decimal strings.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []attribute `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope  `json:"scope"`
+		Spans []span `json:"spans"`
+	}
+	scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	span struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		ParentSpanID      string      `json:"parentSpanId,omitempty"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []attribute `json:"attributes,omitempty"`
+		Status            status      `json:"status"`
+	}
+	status struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	attribute struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+)
+
+const (
+	spanKindInternal = 1
+	statusError      = 2
+)
+
+func stringAttr(key, value string) attribute {
+	return attribute{Key: key, Value: map[string]string{"stringValue": value}}
+}
+
+func intAttr(key string, value int64) attribute {
+	return attribute{Key: key, Value: map[string]string{"intValue": strconv.FormatInt(value, 10)}}
+}
@@ -0,0 +1,151 @@
+package fileprocessor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WorkerState is what one busy worker is doing: the file it is processing
+// and, as of the Snapshot, for how long.
+type WorkerState struct {
+	ID      int
+	Path    string
+	Elapsed time.Duration
+}
+
+// WorkerUsage is how one worker has spent its time since it started: Busy
+// processing files and Idle waiting for them. Exited is set once the
+// autoscaler has retired it or the run is over.
+type WorkerUsage struct {
+	ID     int
+	Busy   time.Duration
+	Idle   time.Duration
+	Exited bool
+}
+
+// Utilization returns the share of the time u was busy, between 0 and 1.
+func (u WorkerUsage) Utilization() float64 {
+	if total := u.Busy + u.Idle; total > 0 {
+		return float64(u.Busy) / float64(total)
+	}
+	return 0
+}
+
+// busyShare returns the share of the workers' time spent busy between
+// the accounts since and usage.
+func busyShare(usage, since []WorkerUsage) (float64, bool) {
+	var busy, total time.Duration
+	for _, u := range usage {
+		busy += u.Busy
+		total += u.Busy + u.Idle
+	}
+	for _, u := range since {
+		busy -= u.Busy
+		total -= u.Busy + u.Idle
+	}
+	if total <= 0 {
+		return 0, false
+	}
+	return min(max(float64(busy)/float64(total), 0), 1), true
+}
+
+// usage returns the WorkerUsage of every worker started so far.
+func (p *Processor) usage() []WorkerUsage {
+	if p.pool == nil {
+		return nil
+	}
+	var out []WorkerUsage
+	for _, u := range p.pool.Usage() {
+		out = append(out, WorkerUsage(u))
+	}
+	return out
+}
+
+// busyWorkers tracks the file each busy worker is on, for Snapshot.Active.
+type busyWorkers struct {
+	mu   sync.Mutex
+	busy map[int]busyWorker
+}
+
+type busyWorker struct {
+	path  string
+	start time.Time
+}
+
+func (w *busyWorkers) begin(id int, path string, start time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.busy == nil {
+		w.busy = make(map[int]busyWorker)
+	}
+	w.busy[id] = busyWorker{path, start}
+}
+
+func (w *busyWorkers) end(id int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.busy, id)
+}
+
+func (w *busyWorkers) active(now time.Time) []WorkerState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]WorkerState, 0, len(w.busy))
+	for id, b := range w.busy {
+		out = append(out, WorkerState{ID: id, Path: b.path, Elapsed: now.Sub(b.start)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// pauser holds workers back between files while a run is paused. resumed
+// is non-nil while paused and closed by Resume.
+type pauser struct {
+	mu      sync.Mutex
+	resumed chan struct{}
+}
+
+// Pause stops workers from starting on further files until Resume. Files
+// already being processed finish, the walk goes on until the queue is
+// full, and the clock keeps running, so durations and throughput include
+// the pause. It may be called before or during Run.
+func (p *Processor) Pause() {
+	p.pauser.mu.Lock()
+	defer p.pauser.mu.Unlock()
+	if p.pauser.resumed == nil {
+		p.pauser.resumed = make(chan struct{})
+	}
+}
+
+// Resume lets the workers of a paused run carry on.
+func (p *Processor) Resume() {
+	p.pauser.mu.Lock()
+	defer p.pauser.mu.Unlock()
+	if p.pauser.resumed != nil {
+		close(p.pauser.resumed)
+		p.pauser.resumed = nil
+	}
+}
+
+// Paused reports whether the run is paused.
+func (p *Processor) Paused() bool {
+	p.pauser.mu.Lock()
+	defer p.pauser.mu.Unlock()
+	return p.pauser.resumed != nil
+}
+
+// wait blocks while the run is paused, until Resume or until ctx is done.
+func (g *pauser) wait(ctx context.Context) {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,255 @@
+package fileprocessor
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	ihash "fileprocessor/internal/hash"
+)
+
+// Item carries one file through a Pipeline. Stages fill in Result and
+// hand values to later stages with Set and Get.
+type Item struct {
+	Path   string
+	Result Result
+
+	mu     sync.Mutex
+	values map[string]any
+}
+
+// Set stores a value for later stages under key.
+func (it *Item) Set(key string, v any) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.values == nil {
+		it.values = make(map[string]any)
+	}
+	it.values[key] = v
+}
+
+// UpdateResult applies fn to the Result under the Item's lock. Streaming
+// stages run concurrently and must use it instead of writing Result
+// directly.
+func (it *Item) UpdateResult(fn func(r *Result)) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	fn(&it.Result)
+}
+
+// Get returns the value an earlier stage stored under key.
+func (it *Item) Get(key string) (any, bool) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	v, ok := it.values[key]
+	return v, ok
+}
+
+// Stage is one step of a Pipeline. Exactly one of Stream or Process must
+// be set.
+//
+// Consecutive streaming stages share a single read of the file: each one
+// receives its own reader over the same bytes, so hashing, sniffing and
+// uploading a file costs one pass over the disk. Process stages run after
+// the stages before them have finished and see their output on the Item.
+type Stage struct {
+	Name string
+	// Concurrency caps how many files this stage handles at once. Zero
+	// means the stage is only bounded by the worker pool.
+	Concurrency int
+
+	Stream  func(ctx context.Context, it *Item, r io.Reader) error
+	Process func(ctx context.Context, it *Item) error
+}
+
+// StageError reports which stage failed for which file.
+type StageError struct {
+	Stage string
+	Path  string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: stage %s: %v", e.Path, e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error { return e.Err }
+
+// Pipeline runs a fixed sequence of stages for every file. It implements
+// FileHandler, so it can be passed directly to WithHandler.
+type Pipeline struct {
+	stages []Stage
+	sems   []chan struct{}
+}
+
+// NewPipeline returns a Pipeline running stages in order.
+func NewPipeline(stages ...Stage) (*Pipeline, error) {
+	p := &Pipeline{
+		stages: stages,
+		sems:   make([]chan struct{}, len(stages)),
+	}
+	for i, s := range stages {
+		if (s.Stream == nil) == (s.Process == nil) {
+			return nil, fmt.Errorf("pipeline stage %q: exactly one of Stream or Process must be set", s.Name)
+		}
+		if s.Concurrency > 0 {
+			p.sems[i] = make(chan struct{}, s.Concurrency)
+		}
+	}
+	return p, nil
+}
+
+// Handle runs every stage for the file at path. The first failing stage
+// stops the file and is reported as a *StageError.
+func (p *Pipeline) Handle(ctx context.Context, path string) (Result, error) {
+	it := &Item{Path: path, Result: Result{Path: path}}
+
+	for i := 0; i < len(p.stages); {
+		if p.stages[i].Process != nil {
+			if err := p.process(ctx, i, it); err != nil {
+				return it.Result, err
+			}
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(p.stages) && p.stages[j].Stream != nil {
+			j++
+		}
+		if err := p.stream(ctx, i, j, it); err != nil {
+			return it.Result, err
+		}
+		i = j
+	}
+	return it.Result, nil
+}
+
+func (p *Pipeline) acquire(ctx context.Context, i int) error {
+	if p.sems[i] == nil {
+		return nil
+	}
+	select {
+	case p.sems[i] <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pipeline) release(i int) {
+	if p.sems[i] != nil {
+		<-p.sems[i]
+	}
+}
+
+func (p *Pipeline) process(ctx context.Context, i int, it *Item) error {
+	s := p.stages[i]
+	if err := p.acquire(ctx, i); err != nil {
+		return &StageError{Stage: s.Name, Path: it.Path, Err: err}
+	}
+	defer p.release(i)
+
+	if err := s.Process(ctx, it); err != nil {
+		return &StageError{Stage: s.Name, Path: it.Path, Err: err}
+	}
+	return nil
+}
+
+// stream reads the file once and fans its contents out to the streaming
+// stages in [from, to).
+func (p *Pipeline) stream(ctx context.Context, from, to int, it *Item) error {
+	for i := from; i < to; i++ {
+		if err := p.acquire(ctx, i); err != nil {
+			for k := from; k < i; k++ {
+				p.release(k)
+			}
+			return &StageError{Stage: p.stages[i].Name, Path: it.Path, Err: err}
+		}
+	}
+	defer func() {
+		for i := from; i < to; i++ {
+			p.release(i)
+		}
+	}()
+
+	file, err := Open(ctx, it.Path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", it.Path, err)
+	}
+	defer file.Close()
+
+	n := to - from
+	writers := make([]io.Writer, n)
+	pipes := make([]*io.PipeWriter, n)
+
+	// The first stage to fail is the one reported; the others only see
+	// their reader closed as a consequence.
+	var failMu sync.Mutex
+	var failed *StageError
+
+	var wg sync.WaitGroup
+	for k := 0; k < n; k++ {
+		pr, pw := io.Pipe()
+		writers[k], pipes[k] = pw, pw
+
+		wg.Add(1)
+		go func(k int, pr *io.PipeReader) {
+			defer wg.Done()
+			err := p.stages[from+k].Stream(ctx, it, pr)
+			if err != nil {
+				failMu.Lock()
+				if failed == nil {
+					failed = &StageError{Stage: p.stages[from+k].Name, Path: it.Path, Err: err}
+				}
+				failMu.Unlock()
+				pr.CloseWithError(err)
+				return
+			}
+			// Keep the fan-out moving for the other stages even if this
+			// one stopped reading early.
+			io.Copy(io.Discard, pr)
+		}(k, pr)
+	}
+
+	size, copyErr := io.Copy(io.MultiWriter(writers...), file)
+	for _, pw := range pipes {
+		pw.CloseWithError(copyErr)
+	}
+	wg.Wait()
+
+	if failed != nil {
+		return failed
+	}
+	if copyErr != nil {
+		return fmt.Errorf("read %s: %w", it.Path, copyErr)
+	}
+	it.Result.Size = size
+	if info, err := file.Stat(); err == nil {
+		it.Result.ModTime = info.ModTime()
+	}
+	return nil
+}
+
+// HashStage returns a streaming stage that stores the hex digest of the
+// file in Result.Hash. A nil newHash uses SHA256.
+func HashStage(newHash func() hash.Hash) Stage {
+	name := ""
+	if newHash == nil {
+		newHash, name = sha256.New, ihash.Default
+	}
+	return Stage{
+		Name: "hash",
+		Stream: func(ctx context.Context, it *Item, r io.Reader) error {
+			digest, _, err := ihash.Sum(r, newHash)
+			if err != nil {
+				return err
+			}
+			it.UpdateResult(func(r *Result) { r.Hash, r.Algorithm = digest, name })
+			return nil
+		},
+	}
+}
@@ -0,0 +1,413 @@
+// Package pool runs jobs on an autoscaling set of worker goroutines.
+//
+// A Pool is generic over the job type, so it can drive any kind of work,
+// not only file paths:
+//
+//	p := pool.New(pool.Config{Workers: 4, Max: 16, QueueSize: 64},
+//		func(ctx context.Context, worker int, url string) { fetch(ctx, url) })
+//	p.Start(ctx)
+//	for _, u := range urls {
+//		p.Submit(ctx, u)
+//	}
+//	p.Drain()
+package pool
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fileprocessor/clock"
+)
+
+// ErrClosed is returned by Submit once the pool has been drained.
+var ErrClosed = errors.New("pool: submit on drained pool")
+
+// Config sizes a Pool.
+type Config struct {
+	// Workers is the number of workers started initially. Defaults to 1.
+	Workers int
+	// Min and Max bound the autoscaler; Max also caps Resize. Max
+	// defaults to Workers, which disables scaling up.
+	Min, Max int
+	// QueueSize is the capacity of the job queue.
+	QueueSize int
+	// ScaleInterval is how often the autoscaler inspects the queue.
+	// Defaults to 2s.
+	ScaleInterval time.Duration
+	// Clock drives the autoscaler ticker. Defaults to clock.Real.
+	Clock clock.Clock
+	// Logf receives worker lifecycle messages and Debugf the
+	// autoscaler's decisions, including to leave the pool as it is. Nil
+	// Logf discards them; with a nil Debugf, Logf gets the changes to
+	// the pool and the other decisions are dropped.
+	Logf   func(format string, args ...any)
+	Debugf func(format string, args ...any)
+	// Decided, if set, receives every decision of the autoscaler as it
+	// is taken, on the autoscaler's goroutine.
+	Decided func(Decision)
+}
+
+// Decision is one verdict of the autoscaler: Action is "up", "down" or
+// "keep", taking the pool from From to To workers with Queued of
+// QueueSize jobs waiting.
+type Decision struct {
+	Action    string
+	From, To  int
+	Queued    int
+	QueueSize int
+}
+
+// Stats is a point-in-time view of a Pool.
+type Stats struct {
+	// Workers is the number of running worker goroutines and Busy how
+	// many of them are currently executing a job.
+	Workers int
+	Busy    int
+	// Queued is the number of jobs waiting for a worker.
+	Queued    int
+	Submitted int64
+	Completed int64
+}
+
+// WorkerUsage is how one worker has spent its time since it started: Busy
+// running jobs and Idle waiting for them. Exited is set once it is gone.
+type WorkerUsage struct {
+	ID     int
+	Busy   time.Duration
+	Idle   time.Duration
+	Exited bool
+}
+
+// handle is how the pool tells one worker to stop.
+type handle struct {
+	u    *usage
+	stop chan struct{} // closed to retire the worker
+}
+
+// usage is the running account of one worker, written by the worker and
+// read by Usage. Times are nanoseconds since the pool's epoch.
+type usage struct {
+	id      int
+	start   int64
+	busy    atomic.Int64 // finished jobs
+	running atomic.Int64 // start of the current job, or -1
+	exited  atomic.Int64 // or -1
+}
+
+// Pool hands jobs from a bounded queue to worker goroutines, grows the
+// number of workers while the queue stays full and retires idle ones while
+// it stays nearly empty.
+type Pool[T any] struct {
+	cfg  Config
+	work func(ctx context.Context, worker int, job T)
+
+	jobs chan T
+	wg   sync.WaitGroup
+
+	closeMu sync.RWMutex
+	closed  bool
+	done    chan struct{} // closed by Drain, stopping the autoscaler
+
+	mu     sync.Mutex
+	ctx    context.Context
+	target int
+	nextID int
+	epoch  time.Time
+	usage  []*usage  // by worker ID
+	live   []*handle // workers not told to stop, oldest first
+
+	active    int64
+	busy      int64
+	submitted int64
+	completed int64
+}
+
+// New returns a Pool running work for every submitted job. Workers are
+// started by Start.
+func New[T any](cfg Config, work func(ctx context.Context, worker int, job T)) *Pool[T] {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Max < cfg.Workers {
+		cfg.Max = cfg.Workers
+	}
+	if cfg.Min <= 0 || cfg.Min > cfg.Max {
+		cfg.Min = 1
+	}
+	if cfg.ScaleInterval <= 0 {
+		cfg.ScaleInterval = 2 * time.Second
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = func(string, ...any) {}
+	}
+	return &Pool[T]{
+		cfg:  cfg,
+		work: work,
+		jobs: make(chan T, cfg.QueueSize),
+		done: make(chan struct{}),
+	}
+}
+
+// Start launches the initial workers and the autoscaler. Both stop when
+// ctx is done or once the pool is drained.
+func (p *Pool[T]) Start(ctx context.Context) {
+	p.mu.Lock()
+	p.ctx = ctx
+	p.epoch = p.cfg.Clock.Now()
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.spawn()
+	}
+	p.target = p.cfg.Workers
+	p.mu.Unlock()
+
+	go p.autoscale(ctx)
+}
+
+// Submit queues job, blocking while the queue is full. It fails with
+// ErrClosed after Drain and with ctx's error if ctx is done first.
+func (p *Pool[T]) Submit(ctx context.Context, job T) error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+
+	select {
+	case p.jobs <- job:
+		atomic.AddInt64(&p.submitted, 1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain stops accepting jobs and waits until every queued job has been
+// processed and all workers have exited.
+func (p *Pool[T]) Drain() {
+	p.closeMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+		close(p.done)
+	}
+	p.closeMu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Resize sets the number of workers to n, clamped to [1, Max]. Extra
+// workers start immediately; surplus workers, idle ones first, exit at
+// once or after finishing their current job. It does nothing once the
+// pool is drained.
+func (p *Pool[T]) Resize(n int) {
+	n = min(max(n, 1), p.cfg.Max)
+
+	// Holding closeMu keeps Drain from waiting for the workers before
+	// those started here are counted.
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx == nil {
+		p.cfg.Workers = n
+		return
+	}
+
+	for ; p.target < n; p.target++ {
+		p.spawn()
+	}
+	for ; p.target > n; p.target-- {
+		p.retire(false)
+	}
+}
+
+// retire tells a worker to stop and returns its ID: the newest idle
+// worker or, unless idleOnly, the newest worker. ok is false if there is
+// none. p.mu must be held.
+func (p *Pool[T]) retire(idleOnly bool) (id int, ok bool) {
+	p.live = slices.DeleteFunc(p.live, func(h *handle) bool { return h.u.exited.Load() >= 0 })
+	i := -1
+	for j := len(p.live) - 1; j >= 0; j-- {
+		if p.live[j].u.running.Load() < 0 {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		if idleOnly || len(p.live) == 0 {
+			return 0, false
+		}
+		i = len(p.live) - 1
+	}
+	h := p.live[i]
+	p.live = append(p.live[:i], p.live[i+1:]...)
+	close(h.stop)
+	return h.u.id, true
+}
+
+// Stats returns current pool statistics.
+func (p *Pool[T]) Stats() Stats {
+	return Stats{
+		Workers:   int(atomic.LoadInt64(&p.active)),
+		Busy:      int(atomic.LoadInt64(&p.busy)),
+		Queued:    len(p.jobs),
+		Submitted: atomic.LoadInt64(&p.submitted),
+		Completed: atomic.LoadInt64(&p.completed),
+	}
+}
+
+// Usage returns how every worker started so far, including those that
+// have exited, has spent its time, by ID.
+func (p *Pool[T]) Usage() []WorkerUsage {
+	p.mu.Lock()
+	workers := p.usage
+	p.mu.Unlock()
+	now := p.since()
+	out := make([]WorkerUsage, len(workers))
+	for i, u := range workers {
+		end, exited := u.exited.Load(), true
+		if end < 0 {
+			end, exited = now, false
+		}
+		busy := u.busy.Load()
+		if start := u.running.Load(); start >= 0 && !exited {
+			busy += max(now-start, 0)
+		}
+		busy = min(busy, end-u.start)
+		out[i] = WorkerUsage{
+			ID:     u.id,
+			Busy:   time.Duration(busy),
+			Idle:   time.Duration(end - u.start - busy),
+			Exited: exited,
+		}
+	}
+	return out
+}
+
+// since returns the nanoseconds since the pool started.
+func (p *Pool[T]) since() int64 {
+	return int64(p.cfg.Clock.Now().Sub(p.epoch))
+}
+
+// spawn starts a worker. p.mu must be held.
+func (p *Pool[T]) spawn() {
+	id := p.nextID
+	p.nextID++
+	u := &usage{id: id, start: p.since()}
+	u.running.Store(-1)
+	u.exited.Store(-1)
+	p.usage = append(p.usage, u)
+	h := &handle{u: u, stop: make(chan struct{})}
+	p.live = append(p.live, h)
+	p.wg.Add(1)
+	atomic.AddInt64(&p.active, 1)
+	go p.worker(p.ctx, id, u, h.stop)
+}
+
+func (p *Pool[T]) worker(ctx context.Context, id int, u *usage, stop <-chan struct{}) {
+	defer p.wg.Done()
+	defer atomic.AddInt64(&p.active, -1)
+	defer func() { u.exited.Store(p.since()) }()
+
+	for {
+		// A retired worker takes no further job, even with some queued:
+		// the select below would pick among ready cases at random.
+		select {
+		case <-stop:
+			p.cfg.Logf("Worker %d retired\n", id)
+			return
+		default:
+		}
+		select {
+		case <-ctx.Done():
+			p.cfg.Logf("Worker %d shutting down...\n", id)
+			return
+		case <-stop:
+			p.cfg.Logf("Worker %d retired\n", id)
+			return
+		case job, ok := <-p.jobs:
+			if !ok {
+				return
+			}
+			atomic.AddInt64(&p.busy, 1)
+			start := p.since()
+			u.running.Store(start)
+			p.work(ctx, id, job)
+			end := p.since()
+			u.running.Store(-1)
+			u.busy.Add(end - start)
+			atomic.AddInt64(&p.busy, -1)
+			atomic.AddInt64(&p.completed, 1)
+		}
+	}
+}
+
+// Worker Autoscaler
+func (p *Pool[T]) autoscale(ctx context.Context) {
+	ticker := p.cfg.Clock.NewTicker(p.cfg.ScaleInterval)
+	defer ticker.Stop()
+
+	changed := p.cfg.Debugf
+	if changed == nil {
+		changed = p.cfg.Logf
+	}
+	decided := func(action string, from, to, queued int) {
+		if p.cfg.Decided != nil {
+			p.cfg.Decided(Decision{Action: action, From: from, To: to, Queued: queued, QueueSize: cap(p.jobs)})
+		}
+	}
+	high := cap(p.jobs) / 2
+	low := cap(p.jobs) / 10
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			return
+		case <-ticker.C():
+			queueLength := len(p.jobs)
+
+			// Scale down by retiring one idle worker at a time, so that
+			// no job is held up.
+			p.mu.Lock()
+			current := p.target
+			retired, down := 0, false
+			if queueLength < low && current > p.cfg.Min {
+				if retired, down = p.retire(true); down {
+					p.target--
+				}
+			}
+			p.mu.Unlock()
+
+			switch {
+			// Scale up
+			case queueLength > high && current < p.cfg.Max:
+				n := min(current+2, p.cfg.Max)
+				p.Resize(n)
+				changed("Autoscaler: Spawned %d extra workers (total workers: %d)\n", n-current, n)
+				decided("up", current, n, queueLength)
+
+			case down:
+				changed("Autoscaler: Retired idle worker %d (total workers: %d)\n", retired, current-1)
+				decided("down", current, current-1, queueLength)
+
+			default:
+				if p.cfg.Debugf != nil {
+					p.cfg.Debugf("Autoscaler: Keeping %d workers (queue %d/%d)\n", current, queueLength, cap(p.jobs))
+				}
+				decided("keep", current, current, queueLength)
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package pool_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"fileprocessor/fileprocessortest"
+	"fileprocessor/pool"
+)
+
+const interval = time.Second
+
+// newPool returns a started pool whose autoscaler runs on a fake clock
+// and sends its decisions to the returned channel. Jobs block until
+// release is closed; started receives the worker ID of every job.
+func newPool(t *testing.T, cfg pool.Config) (p *pool.Pool[int], clk *fileprocessortest.Clock, decisions <-chan pool.Decision, started <-chan int, release chan struct{}) {
+	t.Helper()
+	clk = fileprocessortest.NewClock(time.Unix(0, 0))
+	d := make(chan pool.Decision, 16)
+	s := make(chan int, 64)
+	release = make(chan struct{})
+	cfg.Clock, cfg.ScaleInterval = clk, interval
+	cfg.Decided = func(dec pool.Decision) { d <- dec }
+	p = pool.New(cfg, func(ctx context.Context, worker, job int) {
+		s <- worker
+		<-release
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	p.Start(ctx)
+	clk.WaitForTickers(1)
+	return p, clk, d, s, release
+}
+
+// tick fires the autoscaler once and returns its decision.
+func tick(t *testing.T, clk *fileprocessortest.Clock, decisions <-chan pool.Decision) pool.Decision {
+	t.Helper()
+	clk.Advance(interval)
+	select {
+	case d := <-decisions:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no autoscaler decision")
+		return pool.Decision{}
+	}
+}
+
+// eventually waits for cond, failing the test after a while.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAutoscalerScalesUpWhileQueueIsFull(t *testing.T) {
+	p, clk, decisions, _, release := newPool(t, pool.Config{Workers: 1, Max: 4, QueueSize: 10})
+	for i := range 9 {
+		if err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if d := tick(t, clk, decisions); d.Action != "up" || d.From != 1 || d.To != 3 {
+		t.Errorf("first decision = %+v, want up from 1 to 3", d)
+	}
+	if d := tick(t, clk, decisions); d.Action != "up" || d.From != 3 || d.To != 4 {
+		t.Errorf("second decision = %+v, want up from 3 to Max", d)
+	}
+	if w := p.Stats().Workers; w != 4 {
+		t.Errorf("Workers = %d, want 4", w)
+	}
+	if d := tick(t, clk, decisions); d.Action != "keep" || d.To != 4 {
+		t.Errorf("decision at Max = %+v, want keep", d)
+	}
+
+	close(release)
+	p.Drain()
+	if s := p.Stats(); s.Completed != 9 || s.Workers != 0 {
+		t.Errorf("after Drain: %+v, want 9 completed and no workers", s)
+	}
+}
+
+func TestAutoscalerRetiresIdleWorkers(t *testing.T) {
+	p, clk, decisions, started, release := newPool(t, pool.Config{Workers: 3, Min: 1, Max: 3, QueueSize: 10})
+	if err := p.Submit(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	busy := <-started
+
+	for want := 3; want > 1; want-- {
+		if d := tick(t, clk, decisions); d.Action != "down" || d.From != want || d.To != want-1 {
+			t.Errorf("decision = %+v, want down from %d", d, want)
+		}
+		eventually(t, "a worker exits", func() bool { return p.Stats().Workers == want-1 })
+	}
+	if d := tick(t, clk, decisions); d.Action != "keep" || d.To != 1 {
+		t.Errorf("decision at Min = %+v, want keep", d)
+	}
+
+	// Only idle workers are retired: the one running the job is left.
+	for _, u := range p.Usage() {
+		if u.Exited == (u.ID == busy) {
+			t.Errorf("worker %d: Exited = %t with worker %d busy", u.ID, u.Exited, busy)
+		}
+	}
+
+	close(release)
+	p.Drain()
+	if s := p.Stats(); s.Completed != 1 {
+		t.Errorf("Completed = %d, want 1", s.Completed)
+	}
+}
+
+func TestAutoscalerKeepsBusyWorkers(t *testing.T) {
+	p, clk, decisions, started, release := newPool(t, pool.Config{Workers: 2, Min: 1, QueueSize: 10})
+	for i := range 2 {
+		if err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	<-started
+
+	if d := tick(t, clk, decisions); d.Action != "keep" || d.To != 2 {
+		t.Errorf("decision with every worker busy = %+v, want keep", d)
+	}
+	close(release)
+	p.Drain()
+}
+
+func TestResize(t *testing.T) {
+	p, _, _, started, release := newPool(t, pool.Config{Workers: 1, Max: 3, QueueSize: 10})
+	p.Resize(5)
+	if w := p.Stats().Workers; w != 3 {
+		t.Errorf("Workers after Resize(5) = %d, want Max", w)
+	}
+	if err := p.Submit(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	busy := <-started
+
+	p.Resize(1)
+	eventually(t, "the idle workers exit", func() bool { return p.Stats().Workers == 1 })
+	for _, u := range p.Usage() {
+		if u.Exited == (u.ID == busy) {
+			t.Errorf("worker %d: Exited = %t with worker %d busy", u.ID, u.Exited, busy)
+		}
+	}
+	close(release)
+	p.Drain()
+}
+
+func TestSubmitAfterDrain(t *testing.T) {
+	p, _, _, _, release := newPool(t, pool.Config{QueueSize: 1})
+	close(release)
+	p.Drain()
+	if err := p.Submit(context.Background(), 0); err != pool.ErrClosed {
+		t.Errorf("Submit after Drain = %v, want ErrClosed", err)
+	}
+}
+
+func TestDrainStopsTheAutoscaler(t *testing.T) {
+	before := runtime.NumGoroutine()
+	// A pool per batch of jobs, on a context that outlives them all.
+	for range 10 {
+		p := pool.New(pool.Config{Workers: 2, Max: 4, QueueSize: 4, ScaleInterval: time.Hour},
+			func(ctx context.Context, worker, job int) {})
+		p.Start(context.Background())
+		for i := range 8 {
+			if err := p.Submit(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		p.Drain()
+
+		p.Resize(4)
+		if w := p.Stats().Workers; w != 0 {
+			t.Fatalf("Resize after Drain started %d workers", w)
+		}
+	}
+	eventually(t, "the autoscalers exit", func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestRetiredBusyWorkerRunsNoFurtherJob(t *testing.T) {
+	p, _, _, started, release := newPool(t, pool.Config{Workers: 2, QueueSize: 10})
+	for i := range 10 {
+		if err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	<-started
+
+	// Both workers are busy with jobs queued behind them; the newest is
+	// told to stop and must exit once its job is done.
+	p.Resize(1)
+	close(release)
+	p.Drain()
+	jobs := map[int]int{}
+	for len(started) > 0 {
+		jobs[<-started]++
+	}
+	if jobs[1] != 0 {
+		t.Errorf("retired worker 1 ran %d more jobs", jobs[1])
+	}
+	if jobs[0] != 8 {
+		t.Errorf("worker 0 ran %d more jobs, want 8", jobs[0])
+	}
+}
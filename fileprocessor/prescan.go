@@ -0,0 +1,42 @@
+package fileprocessor
+
+import (
+	"context"
+	"io/fs"
+	"sync/atomic"
+)
+
+// count walks the default Walker's trees once without processing
+// anything and returns the number of files and their total size, the
+// totals Snapshot.Progress measures against. Files left out by
+// WithFilters are not counted, and links are counted with the size of
+// their target. A failed or cancelled count returns what it found
+// so far; the real walk reports the problem. Custom Walkers are not
+// counted, since they may not be able to run twice.
+func (p *Processor) count(ctx context.Context) (files, bytes int64) {
+	if p.walker != nil {
+		return 0, 0
+	}
+	var nfiles, nbytes atomic.Int64
+	var links *hardlinks
+	if p.hardlinks != nil {
+		links = newHardlinks()
+	}
+	p.treeWalker().Walk(ctx, func(path string, info fs.FileInfo) error {
+		if ok, _ := p.admit(path, info); !ok {
+			return nil
+		}
+		if links != nil {
+			if dup, _, _ := links.see(path, info); dup {
+				return nil
+			}
+		}
+		info = p.lookup(path, info)
+		nfiles.Add(1)
+		if info != nil {
+			nbytes.Add(info.Size())
+		}
+		return nil
+	})
+	return nfiles.Load(), nbytes.Load()
+}
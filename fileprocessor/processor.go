@@ -0,0 +1,558 @@
+package fileprocessor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fileprocessor/clock"
+	"fileprocessor/pool"
+)
+
+const (
+	defaultWorkers    = 4
+	defaultQueueSize  = 100
+	defaultMaxWorkers = 20
+	defaultMinWorkers = 2
+
+	defaultMetricsInterval = time.Second
+)
+
+// Processor walks a directory and processes its files on a worker pool.
+// A Processor is single-use: create a new one for every run.
+type Processor struct {
+	dir         string
+	dirs        []string
+	fsys        fs.FS
+	walker      Walker
+	workers     int
+	minWorkers  int
+	maxWorkers  int
+	queueSize   int
+	handler     FileHandler
+	middleware  []Middleware
+	reporter    Reporter
+	logger      *log.Logger
+	debugLogger *log.Logger
+	slog        *slog.Logger
+	clock       clock.Clock
+
+	// Settings of the default Walker.
+	walkWorkers    int
+	followSymlinks bool
+	skipDangling   bool
+	maxDepth       int
+	pruneDirs      []string
+	pruneMarkers   []string
+	oneFileSystem  bool
+	gitignore      bool
+	skipHidden     bool
+	exclude        []string
+
+	special SpecialFiles
+	filters []Filter
+
+	filterCmd   *filterCmd // nil unless WithFilterCommand
+	filterBatch int
+
+	archives *archives // nil unless WithArchives
+	urls     *urls     // nil unless WithURLs
+
+	metrics *MemoryMetrics
+	sizes   sizeHistogram
+	latency latencyHistogram
+	top     topFiles
+	sink    Metrics
+	pool    *pool.Pool[job]
+	pauser  pauser
+	busy    busyWorkers
+
+	throughput throughputMeter
+
+	errMu  sync.Mutex
+	errors []error
+
+	merkle      []merkleDirs // one per root
+	fingerprint fingerprint
+	dirStats    dirStats
+	similarity  similarity
+	duplicates  duplicates
+	drift       drift
+	baseline    map[string]string
+
+	hooks  hooks
+	tracer Tracer
+
+	sorted     bool
+	sequencer  sequencer
+	startAfter string
+	hardlinks  *hardlinks // nil unless WithHardlinks
+	subset     *subset    // nil unless sampling
+	limits     limits
+	prior      []Result
+	unchanged  map[string]Result // relative path -> earlier Result
+
+	prescan    bool
+	totalFiles int64
+	totalBytes int64
+	start      time.Time
+	interval   time.Duration // between snapshots; none if 0
+	walked     atomic.Int64
+	walkDone   atomic.Bool
+	running    atomic.Bool // set once the pool is up
+
+	spill      bool
+	spillDir   string
+	spillQueue *spillQueue
+
+	resultsMu sync.Mutex
+	results   chan Result
+	started   bool
+}
+
+// New returns a Processor configured by opts.
+func New(opts ...Option) *Processor {
+	p := &Processor{
+		dir:        ".",
+		workers:    defaultWorkers,
+		minWorkers: defaultMinWorkers,
+		maxWorkers: defaultMaxWorkers,
+		queueSize:  defaultQueueSize,
+		interval:   defaultMetricsInterval,
+		handler:    HashHandler{},
+		reporter:   nopReporter{},
+		clock:      clock.Real,
+		metrics:    NewMemoryMetrics(),
+		top:        topFiles{n: defaultTopFiles},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.handler = Chain(p.handler, p.middleware...)
+	p.merkle = make([]merkleDirs, len(p.roots()))
+	if p.baseline != nil {
+		p.drift.setBaseline(p.baseline, p.relPath)
+	}
+	if p.prior != nil {
+		p.setPrior()
+	}
+	if c := p.filterCmd; c != nil {
+		c.batch = defaultFilterBatch
+		if p.filterBatch > 0 {
+			c.batch = p.filterBatch
+		}
+	}
+	return p
+}
+
+// Run walks the configured directory and processes every file until the
+// walk is exhausted or ctx is cancelled. Per-file failures are recorded in
+// the returned Summary; Run only returns an error when the walk itself
+// fails, in which case the Summary still covers the files handled so far.
+func (p *Processor) Run(ctx context.Context) (summary Summary, err error) {
+	if p.prescan {
+		p.totalFiles, p.totalBytes = p.count(ctx)
+		if p.subset != nil {
+			p.totalFiles, p.totalBytes = p.subset.scale(p.totalFiles, p.totalBytes)
+		}
+	}
+	if p.subset != nil {
+		p.subset.start()
+	}
+	p.limits.reset()
+	start := p.clock.Now()
+	p.start = start
+	p.hooks.start(StartEvent{Dir: p.dir, Dirs: p.roots(), Workers: p.workers, Time: start,
+		TotalFiles: p.totalFiles, TotalBytes: p.totalBytes})
+	defer func() {
+		now := p.clock.Now()
+		summary = p.summary(now.Sub(start))
+		if r, ok := p.reporter.(SummaryReporter); ok {
+			r.ReportSummary(summary)
+		}
+		p.hooks.complete(CompleteEvent{Summary: summary, Err: err, Time: now})
+		p.trace(Span{Kind: SpanRun, Start: start, End: now, Err: err, Summary: &summary})
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if p.fsys != nil {
+		ctx = ContextWithFS(ctx, p.fsys)
+	}
+	if p.archives != nil {
+		ctx = context.WithValue(ctx, archivesKey{}, p.archives)
+	}
+	if p.urls != nil {
+		ctx = context.WithValue(ctx, urlsKey{}, p.urls)
+	}
+
+	if p.spill {
+		q, err := newSpillQueue(p.spillDir)
+		if err != nil {
+			return Summary{}, err
+		}
+		defer q.remove()
+		p.spillQueue = q
+	}
+
+	p.resultsMu.Lock()
+	p.started = true
+	p.resultsMu.Unlock()
+	defer p.closeResults()
+
+	cfg := pool.Config{
+		Workers:   p.workers,
+		Min:       p.minWorkers,
+		Max:       p.maxWorkers,
+		QueueSize: p.queueSize,
+		Clock:     p.clock,
+		Decided: func(d pool.Decision) {
+			p.hooks.scale(ScaleEvent{Action: d.Action, From: d.From, To: d.To,
+				Queue: d.Queued, QueueSize: d.QueueSize, Time: p.clock.Now()})
+		},
+	}
+	p.setLoggers(&cfg)
+	p.pool = pool.New(cfg, p.process)
+	p.pool.Start(ctx)
+	p.running.Store(true)
+
+	// Start metrics reporter
+	go p.metricsReporter(ctx)
+
+	// Walk directory
+	// The default walk skips what precedes startAfter itself, pruning
+	// whole directories, unless the order it has to follow is -sorted's.
+	w := p.walker
+	switch {
+	case w == nil:
+		tw := p.treeWalker()
+		tw.onSkip = func(string, error) { p.inc(MetricFilesSkipped, 1) }
+		if p.sorted {
+			tw.startAfter = ""
+		}
+		w = tw
+	case p.startAfter != "" && !p.sorted:
+		w = resumeWalker{w, p.startAfter}
+	}
+	if p.sorted {
+		w = sortedWalker{w, p.startAfter}
+	}
+	enqueue := func(j job) error { return p.pool.Submit(ctx, j) }
+	var fed chan error
+	if q := p.spillQueue; q != nil {
+		fed = make(chan error, 1)
+		go func() { fed <- q.feed(ctx, p.pool.Submit) }()
+		enqueue = q.push
+	}
+	submit := func(path string) error {
+		j := job{path: path, seq: p.sequencer.assign()}
+		if p.tracer != nil {
+			j.queued = p.clock.Now()
+		}
+		return enqueue(j)
+	}
+	queue := func(path string, info fs.FileInfo) error {
+		if res, ok := p.carryOver(path, info); ok {
+			p.inc(MetricFilesUnchanged, 1)
+			p.duplicates.add(path, res)
+			p.record(path, res)
+			p.finish(ctx, p.sequencer.assign(), res)
+			return nil
+		}
+		if p.hardlinks != nil {
+			if dup, seq, res := p.hardlinks.see(path, info); dup {
+				p.inc(MetricHardlinks, 1)
+				if res != nil {
+					p.finish(ctx, seq, *res)
+				}
+				return nil
+			}
+		}
+		if !p.admitWithin(path, info) {
+			return errLimit
+		}
+		if p.archives != nil {
+			if kind := archiveKind(path); kind != "" {
+				return p.archives.expand(ctx, path, kind, submit)
+			}
+		}
+		return submit(path)
+	}
+	skip := func() { p.inc(MetricFilesSkipped, 1) }
+	sample := func(path string, info fs.FileInfo) error {
+		if s := p.subset; s != nil {
+			if !s.rated() {
+				skip()
+				return nil
+			}
+			if s.count > 0 {
+				s.offer(path, info)
+				return nil
+			}
+		}
+		return queue(path, info)
+	}
+	walkStart := p.clock.Now()
+	p.walkDone.Store(false)
+	walkErr := w.Walk(ctx, func(path string, info fs.FileInfo) error {
+		p.walked.Add(1)
+		if ok, err := p.admit(path, info); !ok {
+			if err == nil {
+				skip()
+			}
+			return err
+		}
+		if c := p.filterCmd; c != nil {
+			return c.add(ctx, path, info, sample, skip)
+		}
+		return sample(path, info)
+	})
+	if c := p.filterCmd; c != nil && walkErr == nil {
+		walkErr = c.flush(ctx, sample, skip)
+	}
+	if s := p.subset; s != nil && s.count > 0 && walkErr == nil {
+		files, dropped := s.picked()
+		p.inc(MetricFilesSkipped, int64(dropped))
+		for _, f := range files {
+			if walkErr = queue(f.path, f.info); walkErr != nil {
+				break
+			}
+		}
+	}
+	if errors.Is(walkErr, errLimit) {
+		walkErr = nil
+	}
+	p.walkDone.Store(true)
+	p.trace(Span{Kind: SpanWalk, Start: walkStart, End: p.clock.Now(), Err: walkErr})
+	if fed != nil {
+		p.spillQueue.close()
+		if err := <-fed; err != nil && walkErr == nil {
+			walkErr = err
+		}
+	}
+	p.pool.Drain()
+	if p.hardlinks != nil {
+		p.hardlinks.flush(func(seq int, res Result) { p.finish(ctx, seq, res) })
+	}
+	if p.sorted {
+		p.sequencer.flush(func(res Result) { p.deliver(ctx, res) })
+	}
+	if walkErr != nil || ctx.Err() != nil || p.limits.truncated() {
+		p.drift.incomplete = true
+	}
+
+	if walkErr != nil && !errors.Is(walkErr, context.Canceled) {
+		return Summary{}, walkErr
+	}
+	return Summary{}, nil
+}
+
+// treeWalker returns the default Walker as configured by the options.
+func (p *Processor) treeWalker() *treeWalker {
+	return &treeWalker{
+		dirs:           p.roots(),
+		fsys:           p.fsys,
+		workers:        p.walkWorkers,
+		followSymlinks: p.followSymlinks,
+		skipDangling:   p.skipDangling,
+		maxDepth:       p.maxDepth,
+		pruneDirs:      p.pruneDirs,
+		pruneMarkers:   p.pruneMarkers,
+		oneFileSystem:  p.oneFileSystem,
+		startAfter:     p.startAfter,
+		gitignore:      p.gitignore,
+		skipHidden:     p.skipHidden,
+		exclude:        p.exclude,
+	}
+}
+
+// Processed returns the number of files processed successfully so far.
+func (p *Processor) Processed() int64 {
+	return p.metrics.Counter(MetricFilesProcessed)
+}
+
+// Failed returns the number of files that could not be processed so far.
+func (p *Processor) Failed() int64 {
+	return p.metrics.Counter(MetricFilesFailed)
+}
+
+// Errors returns the per-file errors collected during Run, sorted by
+// message with WithSorted.
+func (p *Processor) Errors() []error {
+	p.errMu.Lock()
+	errs := append([]error(nil), p.errors...)
+	p.errMu.Unlock()
+	if p.sorted {
+		slices.SortStableFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	}
+	return errs
+}
+
+// process runs the handler for one file on worker id.
+func (p *Processor) process(ctx context.Context, id int, j job) {
+	p.pauser.wait(ctx)
+	path := j.path
+	start := p.clock.Now()
+	p.busy.begin(id, path, start)
+	res, err := p.handler.Handle(ctx, path)
+	end := p.clock.Now()
+	p.busy.end(id)
+	if p.tracer != nil {
+		if !j.queued.IsZero() {
+			p.tracer.Span(Span{Kind: SpanQueue, Start: j.queued, End: start, Path: path, Worker: id})
+		}
+		p.tracer.Span(Span{Kind: SpanFile, Start: start, End: end, Path: path, Worker: id, Size: res.Size, Err: err})
+	}
+	if p.archives != nil {
+		p.archives.release(path)
+	}
+	if p.urls != nil && IsURL(path) {
+		res.ContentType = p.urls.contentType(path)
+	}
+	if errors.Is(err, ErrSkip) {
+		p.inc(MetricFilesSkipped, 1)
+		if p.sorted {
+			p.sequencer.skip(j.seq, func(res Result) { p.deliver(ctx, res) })
+		}
+		return
+	}
+	res.Path = path
+	res.Duration = end.Sub(start)
+	res.Err = err
+	p.observe(MetricFileSeconds, res.Duration.Seconds())
+	p.latency.add(res.Duration)
+
+	if err != nil {
+		p.inc(MetricFilesFailed, 1)
+
+		p.errMu.Lock()
+		p.errors = append(p.errors, err)
+		p.errMu.Unlock()
+
+		p.hooks.error(ErrorEvent{Path: path, Err: err, Worker: id, Time: end})
+		p.logFile(ctx, id, res)
+	} else {
+		p.inc(MetricFilesProcessed, 1)
+		p.inc(MetricBytesProcessed, res.Size)
+		p.sizes.add(res.Size)
+		p.similarity.add(path, res.Fuzzy)
+		p.duplicates.add(path, res)
+		p.hooks.file(FileEvent{Result: res, Worker: id, Time: end})
+		p.logFile(ctx, id, res)
+	}
+	p.top.add(FileStat{Path: path, Size: res.Size, Duration: res.Duration, Failed: err != nil})
+	p.record(path, res)
+
+	if p.hardlinks != nil {
+		var ok bool
+		if res, ok = p.hardlinks.finish(j.seq, res); !ok {
+			return
+		}
+	}
+	p.finish(ctx, j.seq, res)
+}
+
+// record adds the file at path to the views of the whole tree: the
+// fingerprint, the directory Merkle roots and statistics and the drift
+// report. res is the Result of path or, for a hard link, of the path it
+// was folded into.
+func (p *Processor) record(path string, res Result) {
+	if res.Err == nil {
+		res.Path = path
+		p.recordFingerprint(res)
+		if res.Merkle != nil {
+			if i, rel, ok := p.rootOf(path); ok {
+				p.merkle[i].add(filepath.ToSlash(rel), res.Merkle)
+			}
+		}
+	}
+	p.recordDirStats(path, res)
+	if p.drift.baseline != nil {
+		p.drift.add(p.relPath(path), res)
+	}
+}
+
+// finish hands the Result of job seq on, in job order with WithSorted.
+func (p *Processor) finish(ctx context.Context, seq int, res Result) {
+	for _, link := range res.Links {
+		p.record(link, res)
+	}
+	if p.sorted {
+		p.sequencer.put(seq, res, func(res Result) { p.deliver(ctx, res) })
+		return
+	}
+	p.deliver(ctx, res)
+}
+
+// deliver passes a finished file's Result to the reporter and the Results
+// channel.
+func (p *Processor) deliver(ctx context.Context, res Result) {
+	if r, ok := p.reporter.(FileReporter); ok {
+		r.ReportFile(res)
+	}
+	p.emit(ctx, res)
+}
+
+// Live metrics reporter
+func (p *Processor) metricsReporter(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+	ticker := p.clock.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			p.throughput.update(p.clock.Now().Sub(p.start), p.metrics.Counter(MetricBytesProcessed))
+			p.reporter.Report(p.snapshot())
+		}
+	}
+}
+
+// Snapshot returns the state of the run as the reporters get it, but as
+// of now. Before Run has started its workers it is empty.
+func (p *Processor) Snapshot() Snapshot {
+	if !p.running.Load() {
+		return Snapshot{}
+	}
+	return p.snapshot()
+}
+
+func (p *Processor) snapshot() Snapshot {
+	stats := p.pool.Stats()
+	if p.spillQueue != nil {
+		stats.Queued += p.spillQueue.len()
+	}
+	now := p.clock.Now()
+	return Snapshot{
+		Processed:  p.metrics.Counter(MetricFilesProcessed),
+		Failed:     p.metrics.Counter(MetricFilesFailed),
+		Queue:      stats.Queued,
+		Workers:    stats.Workers,
+		Goroutines: runtime.NumGoroutine(),
+		Bytes:      p.metrics.Counter(MetricBytesProcessed),
+		TotalFiles: p.totalFiles,
+		TotalBytes: p.totalBytes,
+		Elapsed:    now.Sub(p.start),
+		Active:     p.busy.active(now),
+		Usage:      p.usage(),
+		Throughput: p.throughput.get(),
+		Latency:    p.latency.latency(),
+		Paused:     p.Paused(),
+		Walked:     p.walked.Load(),
+		WalkDone:   p.walkDone.Load(),
+		QueueSize:  p.queueSize,
+	}
+}
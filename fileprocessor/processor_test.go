@@ -0,0 +1,61 @@
+package fileprocessor_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"fileprocessor"
+	"fileprocessor/fileprocessortest"
+)
+
+// The Processor ties the split-out packages together: the walker finds
+// the files, the pool runs them on workers, the hash handler digests them
+// and the reporter gets the summary.
+func TestProcessorHashesEveryFile(t *testing.T) {
+	fsys := fileprocessortest.NewFS().
+		File("a", "abc").
+		File("sub/b", "").
+		File("sub/deep/c", "abc").
+		Build()
+	p := fileprocessor.New(fileprocessor.WithFS(fsys), fileprocessor.WithWorkers(3))
+	results := fileprocessortest.Collect(t, context.Background(), p)
+	fileprocessortest.ExpectNoErrors(t, results)
+	fileprocessortest.ExpectPaths(t, results, "a", "sub/b", "sub/deep/c")
+	fileprocessortest.ExpectHashes(t, results, map[string]string{
+		"a":          "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sub/b":      "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"sub/deep/c": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	})
+}
+
+func TestProcessorSummary(t *testing.T) {
+	fsys := fileprocessortest.NewFS().File("ok", "data").File("bad", "data").Build()
+	failing := fileprocessor.HandlerFunc(func(ctx context.Context, path string) (fileprocessor.Result, error) {
+		if strings.HasSuffix(path, "bad") {
+			return fileprocessor.Result{}, errors.New("unreadable")
+		}
+		return fileprocessor.Result{Size: 4}, nil
+	})
+	p := fileprocessor.New(fileprocessor.WithFS(fsys), fileprocessor.WithHandler(failing))
+	results := fileprocessortest.Collect(t, context.Background(), p)
+	fileprocessortest.ExpectFailed(t, results, "bad")
+
+	s, err := fileprocessor.New(fileprocessor.WithFS(fsys), fileprocessor.WithHandler(failing)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Processed != 1 || s.Failed != 1 || s.Bytes != 4 || len(s.Errors) != 1 {
+		t.Errorf("summary = %d processed, %d failed, %d bytes, %d errors; want 1, 1, 4, 1",
+			s.Processed, s.Failed, s.Bytes, len(s.Errors))
+	}
+}
+
+func TestProcessorMissingRoot(t *testing.T) {
+	fsys := fileprocessortest.NewFS().File("a", "").Build()
+	_, err := fileprocessor.New(fileprocessor.WithFS(fsys), fileprocessor.WithDir("missing")).Run(context.Background())
+	if err == nil {
+		t.Error("Run of a missing root succeeded")
+	}
+}
@@ -0,0 +1,193 @@
+package remote
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	azureVersion  = "2021-08-06"
+	azureResource = "https://storage.azure.com/"
+	azureIMDS     = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// azure reads a container through the Blob service REST API.
+type azure struct {
+	endpoint  string
+	container string
+	sas       url.Values   // set when authenticating with a SAS token
+	token     *cachedToken // otherwise a Microsoft Entra ID token
+}
+
+func newAzure(account, container string) (*azure, error) {
+	endpoint := os.Getenv("AZURE_STORAGE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	a := &azure{endpoint: strings.TrimSuffix(endpoint, "/"), container: container}
+
+	if sas := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); sas != "" {
+		values, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return nil, fmt.Errorf("remote: AZURE_STORAGE_SAS_TOKEN: %w", err)
+		}
+		a.sas = values
+		return a, nil
+	}
+	a.token = &cachedToken{fetch: azureCredentials()}
+	return a, nil
+}
+
+func (a *azure) list(ctx context.Context, prefix string) ([]object, []string, error) {
+	var objs []object
+	var dirs []string
+	query := url.Values{
+		"restype":   {"container"},
+		"comp":      {"list"},
+		"prefix":    {prefix},
+		"delimiter": {"/"},
+	}
+	for {
+		resp, err := a.send(ctx, http.MethodGet, "/"+url.PathEscape(a.container), query)
+		if err != nil {
+			return nil, nil, err
+		}
+		var page struct {
+			Blobs []struct {
+				Name       string `xml:"Name"`
+				Properties struct {
+					LastModified  string `xml:"Last-Modified"`
+					ContentLength int64  `xml:"Content-Length"`
+				} `xml:"Properties"`
+			} `xml:"Blobs>Blob"`
+			Prefixes   []string `xml:"Blobs>BlobPrefix>Name"`
+			NextMarker string   `xml:"NextMarker"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, blob := range page.Blobs {
+			mtime, _ := http.ParseTime(blob.Properties.LastModified)
+			objs = append(objs, object{key: blob.Name, size: blob.Properties.ContentLength, modTime: mtime})
+		}
+		dirs = append(dirs, page.Prefixes...)
+		if page.NextMarker == "" {
+			return objs, dirs, nil
+		}
+		query.Set("marker", page.NextMarker)
+	}
+}
+
+func (a *azure) stat(ctx context.Context, key string) (object, error) {
+	resp, err := a.send(ctx, http.MethodHead, a.blobPath(key), nil)
+	if err != nil {
+		return object{}, err
+	}
+	resp.Body.Close()
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	mtime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return object{key: key, size: size, modTime: mtime}, nil
+}
+
+func (a *azure) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := a.send(ctx, http.MethodGet, a.blobPath(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (a *azure) blobPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return "/" + url.PathEscape(a.container) + "/" + strings.Join(segments, "/")
+}
+
+func (a *azure) send(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	for k, v := range a.sas {
+		q[k] = v
+	}
+	target := a.endpoint + path
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	if a.token != nil {
+		token, err := a.token.get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Azure credentials: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return do(req)
+}
+
+// azureCredentials picks a service principal from the environment when
+// one is configured and the managed identity of the host otherwise.
+func azureCredentials() tokenSource {
+	tenant, clientID, secret := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+	if tenant != "" && clientID != "" && secret != "" {
+		authority := os.Getenv("AZURE_AUTHORITY_HOST")
+		if authority == "" {
+			authority = "https://login.microsoftonline.com"
+		}
+		endpoint := strings.TrimSuffix(authority, "/") + "/" + url.PathEscape(tenant) + "/oauth2/v2.0/token"
+		return func(ctx context.Context) (string, time.Duration, error) {
+			req, err := postForm(ctx, endpoint, url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {clientID},
+				"client_secret": {secret},
+				"scope":         {azureResource + ".default"},
+			})
+			if err != nil {
+				return "", 0, err
+			}
+			return fetchToken(req)
+		}
+	}
+
+	return func(ctx context.Context) (string, time.Duration, error) {
+		query := url.Values{"resource": {azureResource}}
+		if clientID != "" {
+			query.Set("client_id", clientID)
+		}
+		// App Service and Functions publish their own identity endpoint;
+		// virtual machines use the instance metadata service.
+		endpoint, header, value := azureIMDS, "Metadata", "true"
+		query.Set("api-version", "2018-02-01")
+		if e := os.Getenv("IDENTITY_ENDPOINT"); e != "" {
+			endpoint, header, value = e, "X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER")
+			query.Set("api-version", "2019-08-01")
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set(header, value)
+		token, expiresIn, err := fetchToken(req)
+		if err != nil {
+			return "", 0, fmt.Errorf("no SAS token or service principal and no managed identity: %w", err)
+		}
+		return token, expiresIn, nil
+	}
+}
@@ -0,0 +1,275 @@
+package remote
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	gcsEndpoint    = "https://storage.googleapis.com"
+	gcsScope       = "https://www.googleapis.com/auth/devstorage.read_only"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// gcs reads a bucket through the Cloud Storage JSON API.
+type gcs struct {
+	endpoint string
+	bucket   string
+	token    *cachedToken // nil for unauthenticated emulators
+}
+
+func newGCS(bucket string) (*gcs, error) {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return &gcs{endpoint: strings.TrimSuffix(host, "/"), bucket: bucket}, nil
+	}
+	src, err := googleCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("remote: Google credentials: %w", err)
+	}
+	return &gcs{endpoint: gcsEndpoint, bucket: bucket, token: &cachedToken{fetch: src}}, nil
+}
+
+// gcsObject is the part of the JSON object resource that is used.
+type gcsObject struct {
+	Name    string    `json:"name"`
+	Size    string    `json:"size"`
+	Updated time.Time `json:"updated"`
+}
+
+func (o gcsObject) object() object {
+	size, _ := strconv.ParseInt(o.Size, 10, 64)
+	return object{key: o.Name, size: size, modTime: o.Updated}
+}
+
+func (g *gcs) list(ctx context.Context, prefix string) ([]object, []string, error) {
+	var objs []object
+	var dirs []string
+	query := url.Values{"prefix": {prefix}, "delimiter": {"/"}}
+	for {
+		var page struct {
+			Items         []gcsObject `json:"items"`
+			Prefixes      []string    `json:"prefixes"`
+			NextPageToken string      `json:"nextPageToken"`
+		}
+		if err := g.getJSON(ctx, "/storage/v1/b/"+url.PathEscape(g.bucket)+"/o?"+query.Encode(), &page); err != nil {
+			return nil, nil, err
+		}
+		for _, item := range page.Items {
+			objs = append(objs, item.object())
+		}
+		dirs = append(dirs, page.Prefixes...)
+		if page.NextPageToken == "" {
+			return objs, dirs, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (g *gcs) stat(ctx context.Context, key string) (object, error) {
+	var item gcsObject
+	if err := g.getJSON(ctx, g.objectPath(key), &item); err != nil {
+		return object{}, err
+	}
+	return item.object(), nil
+}
+
+func (g *gcs) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := g.send(ctx, g.objectPath(key)+"?alt=media")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (g *gcs) objectPath(key string) string {
+	return "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o/" + url.PathEscape(key)
+}
+
+func (g *gcs) getJSON(ctx context.Context, path string, v any) error {
+	resp, err := g.send(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (g *gcs) send(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if g.token != nil {
+		token, err := g.token.get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Google credentials: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return do(req)
+}
+
+// googleKey is a credentials file as written by the Cloud console
+// ("service_account") or by gcloud ("authorized_user").
+type googleKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleCredentials follows the Application Default Credentials search
+// order: GOOGLE_APPLICATION_CREDENTIALS, gcloud's well-known file, then
+// the instance metadata server.
+func googleCredentials() (tokenSource, error) {
+	file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if file == "" {
+		if wk := wellKnownGoogleFile(); wk != "" {
+			if _, err := os.Stat(wk); err == nil {
+				file = wk
+			}
+		}
+	}
+	if file == "" {
+		return googleMetadataToken, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var key googleKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	switch key.Type {
+	case "service_account":
+		return serviceAccountToken(key)
+	case "authorized_user":
+		return func(ctx context.Context) (string, time.Duration, error) {
+			req, err := postForm(ctx, googleTokenURL, url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {key.ClientID},
+				"client_secret": {key.ClientSecret},
+				"refresh_token": {key.RefreshToken},
+			})
+			if err != nil {
+				return "", 0, err
+			}
+			return fetchToken(req)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported credentials type %q", file, key.Type)
+	}
+}
+
+// wellKnownGoogleFile is where "gcloud auth application-default login"
+// stores credentials.
+func wellKnownGoogleFile() string {
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gcloud")
+		} else {
+			return ""
+		}
+	}
+	return filepath.Join(dir, "application_default_credentials.json")
+}
+
+// serviceAccountToken exchanges a self-signed JWT for an access token.
+func serviceAccountToken(key googleKey) (tokenSource, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, errors.New("service account key: no PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("service account key: %w", err)
+		}
+	}
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account key: not an RSA key")
+	}
+	tokenURL := key.TokenURI
+	if tokenURL == "" {
+		tokenURL = googleTokenURL
+	}
+
+	return func(ctx context.Context) (string, time.Duration, error) {
+		now := time.Now()
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+		claims, err := json.Marshal(map[string]any{
+			"iss":   key.ClientEmail,
+			"scope": gcsScope,
+			"aud":   tokenURL,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			return "", 0, err
+		}
+		signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+		sum := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+		if err != nil {
+			return "", 0, err
+		}
+		req, err := postForm(ctx, tokenURL, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {signed + "." + base64.RawURLEncoding.EncodeToString(sig)},
+		})
+		if err != nil {
+			return "", 0, err
+		}
+		return fetchToken(req)
+	}, nil
+}
+
+// googleMetadataToken asks the metadata server of the instance the
+// program runs on for its service account's token.
+func googleMetadataToken(ctx context.Context) (string, time.Duration, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	endpoint := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token?scopes=" + url.QueryEscape(gcsScope)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	token, expiresIn, err := fetchToken(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("no credentials file and no metadata server: %w", err)
+	}
+	return token, expiresIn, nil
+}
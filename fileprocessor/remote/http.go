@@ -0,0 +1,110 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client sends every request; object downloads can take arbitrarily long,
+// so it has no overall timeout.
+var client = &http.Client{}
+
+// tokenTimeout bounds a single credential request, so a missing metadata
+// server fails fast instead of hanging the run.
+const tokenTimeout = 10 * time.Second
+
+// do sends req and returns the response of a successful request. Status
+// 404 becomes an error wrapping fs.ErrNotExist.
+func do(req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		err = fmt.Errorf("%w: %s", err, msg)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		err = fmt.Errorf("%w (%w)", fs.ErrNotExist, err)
+	}
+	return nil, err
+}
+
+// tokenSource fetches a fresh OAuth2 access token.
+type tokenSource func(ctx context.Context) (token string, expiresIn time.Duration, err error)
+
+// cachedToken reuses a token until shortly before it expires.
+type cachedToken struct {
+	fetch tokenSource
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (c *cachedToken) get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Until(c.expires) > time.Minute {
+		return c.token, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, tokenTimeout)
+	defer cancel()
+	token, expiresIn, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expires = token, time.Now().Add(expiresIn)
+	return token, nil
+}
+
+// tokenResponse is the JSON answer of OAuth2 token endpoints and of the
+// cloud metadata services, which send expires_in as a number or a string.
+type tokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+}
+
+// fetchToken sends req and decodes a tokenResponse.
+func fetchToken(req *http.Request) (string, time.Duration, error) {
+	resp, err := do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	var tok tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", 0, fmt.Errorf("token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response without access_token")
+	}
+	secs, err := tok.ExpiresIn.Int64()
+	if err != nil {
+		secs = 0
+	}
+	return tok.AccessToken, time.Duration(secs) * time.Second, nil
+}
+
+// postForm builds a form-encoded POST request.
+func postForm(ctx context.Context, endpoint string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
@@ -0,0 +1,325 @@
+// Package remote exposes cloud object stores as read-only fs.FS trees, so
+// a Processor can read them through WithFS:
+//
+//	fsys, err := remote.Open(ctx, "gs://my-bucket/logs")
+//	if err != nil {
+//		return err
+//	}
+//	p := fileprocessor.New(fileprocessor.WithFS(fsys))
+//
+// Two kinds of URL are supported:
+//
+//	gs://BUCKET[/PREFIX]              Google Cloud Storage
+//	az://ACCOUNT/CONTAINER[/PREFIX]   Azure Blob Storage
+//
+// Object names below the prefix are split at "/" into directories, so the
+// tree looks the way the providers' consoles show it. Names that are not
+// valid fs.FS paths, such as "a//b" or "../x", are left out.
+//
+// Credentials are found the way the providers' own tools find them, with
+// nothing to configure on machines that already have them:
+//
+// Google Cloud Storage uses Application Default Credentials: the JSON key
+// named by GOOGLE_APPLICATION_CREDENTIALS, then the file written by
+// "gcloud auth application-default login", then the metadata server of
+// the Compute Engine, GKE or Cloud Run instance. STORAGE_EMULATOR_HOST
+// points it at an emulator without authentication.
+//
+// Azure Blob Storage uses AZURE_STORAGE_SAS_TOKEN when set, then a service
+// principal from AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET,
+// then the managed identity of the App Service or virtual machine.
+// AZURE_STORAGE_BLOB_ENDPOINT replaces https://ACCOUNT.blob.core.windows.net
+// for emulators and private endpoints.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IsURL reports whether s names a remote tree Open understands rather
+// than a local path.
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "gs://") || strings.HasPrefix(s, "az://")
+}
+
+// Open returns the objects below rawURL as an fs.FS. Credentials are
+// looked up once here; requests made by the returned FS use ctx, so
+// cancelling it aborts reads in progress.
+func Open(ctx context.Context, rawURL string) (fs.FS, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("remote: %s: missing bucket or account", rawURL)
+	}
+	rest := strings.Trim(u.Path, "/")
+
+	var s store
+	switch u.Scheme {
+	case "gs":
+		s, err = newGCS(u.Host)
+	case "az":
+		var container string
+		container, rest, _ = strings.Cut(rest, "/")
+		if container == "" {
+			return nil, fmt.Errorf("remote: %s: missing container", rawURL)
+		}
+		s, err = newAzure(u.Host, container)
+	default:
+		return nil, fmt.Errorf("remote: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(rest, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &bucketFS{ctx: ctx, store: s, prefix: prefix, known: make(map[string]object)}, nil
+}
+
+// store is one object store API.
+type store interface {
+	// list returns the objects and the sub-prefixes (ending in "/")
+	// directly below prefix, which is empty or ends in "/".
+	list(ctx context.Context, prefix string) ([]object, []string, error)
+	// stat returns the object named key, or an error wrapping
+	// fs.ErrNotExist.
+	stat(ctx context.Context, key string) (object, error)
+	// get streams the content of the object named key.
+	get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// object describes one stored object by its full key.
+type object struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+// bucketFS is the fs.FS of the objects below prefix.
+type bucketFS struct {
+	ctx    context.Context
+	store  store
+	prefix string
+
+	// known caches objects seen by ReadDir, so opening the files of a
+	// walk does not cost a metadata request each.
+	mu    sync.Mutex
+	known map[string]object
+}
+
+// key returns the object key of the FS path name.
+func (b *bucketFS) key(name string) string {
+	if name == "." {
+		return strings.TrimSuffix(b.prefix, "/")
+	}
+	return b.prefix + name
+}
+
+// dirPrefix returns the listing prefix of the FS directory name.
+func (b *bucketFS) dirPrefix(name string) string {
+	if name == "." {
+		return b.prefix
+	}
+	return b.prefix + name + "/"
+}
+
+// Open implements fs.FS.
+func (b *bucketFS) Open(name string) (fs.File, error) {
+	info, err := b.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirFile{fsys: b, name: name, info: info}, nil
+	}
+	return &objectFile{fsys: b, name: name, info: info}, nil
+}
+
+// Stat implements fs.StatFS.
+func (b *bucketFS) Stat(name string) (fs.FileInfo, error) {
+	return b.lookup("stat", name)
+}
+
+// lookup finds name as an object or, failing that, as a prefix with
+// objects below it.
+func (b *bucketFS) lookup(op, name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return fileInfo{name: ".", dir: true}, nil
+	}
+
+	b.mu.Lock()
+	obj, ok := b.known[name]
+	b.mu.Unlock()
+	if ok {
+		return objectInfo(obj), nil
+	}
+
+	obj, err := b.store.stat(b.ctx, b.key(name))
+	if err == nil {
+		return objectInfo(obj), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	objs, dirs, err := b.store.list(b.ctx, b.dirPrefix(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if len(objs) == 0 && len(dirs) == 0 {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return fileInfo{name: path.Base(name), dir: true}, nil
+}
+
+// ReadDir implements fs.ReadDirFS with one listing of the prefix.
+func (b *bucketFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := b.dirPrefix(name)
+	objs, dirs, err := b.store.list(b.ctx, prefix)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if len(objs) == 0 && len(dirs) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(objs)+len(dirs))
+	for _, d := range dirs {
+		base := strings.TrimSuffix(strings.TrimPrefix(d, prefix), "/")
+		if validName(base) {
+			entries = append(entries, fs.FileInfoToDirEntry(fileInfo{name: base, dir: true}))
+		}
+	}
+	b.mu.Lock()
+	for _, obj := range objs {
+		// A zero-length "dir/" object is a console placeholder for
+		// dir itself and yields an empty base name.
+		base := strings.TrimPrefix(obj.key, prefix)
+		if !validName(base) {
+			continue
+		}
+		b.known[path.Join(name, base)] = obj
+		entries = append(entries, fs.FileInfoToDirEntry(objectInfo(obj)))
+	}
+	b.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// validName reports whether base can be one element of an fs.FS path.
+func validName(base string) bool {
+	return base != "" && base != "." && base != ".." && !strings.Contains(base, "/")
+}
+
+// objectFile streams an object, starting the download on the first Read.
+type objectFile struct {
+	fsys *bucketFS
+	name string
+	info fs.FileInfo
+	body io.ReadCloser
+}
+
+func (f *objectFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *objectFile) Read(p []byte) (int, error) {
+	if f.body == nil {
+		body, err := f.fsys.store.get(f.fsys.ctx, f.fsys.key(f.name))
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = body
+	}
+	return f.body.Read(p)
+}
+
+func (f *objectFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	return f.body.Close()
+}
+
+// dirFile is an opened directory; its entries come from bucketFS.ReadDir.
+type dirFile struct {
+	fsys    *bucketFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// fileInfo describes an object or a directory prefix.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func objectInfo(obj object) fileInfo {
+	return fileInfo{name: path.Base(obj.key), size: obj.size, modTime: obj.modTime}
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.dir }
+func (fi fileInfo) Sys() any           { return nil }
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
@@ -0,0 +1,23 @@
+package report
+
+// ANSI escapes for the terminal reporters.
+const (
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiReset  = "\x1b[0m"
+)
+
+// palette colors text for a terminal, or leaves it as it is when off.
+type palette bool
+
+func (p palette) paint(color, s string) string {
+	if !p || s == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+func (p palette) red(s string) string    { return p.paint(ansiRed, s) }
+func (p palette) green(s string) string  { return p.paint(ansiGreen, s) }
+func (p palette) yellow(s string) string { return p.paint(ansiYellow, s) }
@@ -0,0 +1,283 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// Console prints metrics snapshots, per-file results and the final
+// summary as plain text.
+type Console struct {
+	mu    sync.Mutex
+	w     io.Writer
+	color palette
+	last  fileprocessor.Snapshot
+}
+
+// NewConsole returns a Console writing to w.
+func NewConsole(w io.Writer) *Console {
+	return &Console{w: w}
+}
+
+// SetColor turns ANSI colors on or off: the summary heading in green,
+// failures and errors in red, and truncation and drift from the baseline
+// in yellow. It is off by default, and should only be turned on when w is
+// a terminal.
+func (c *Console) SetColor(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.color = palette(on)
+}
+
+// Report prints a live metrics line. MB/s is the throughput since the
+// last line, followed by its one and five minute moving averages. Busy is
+// the share of the time the workers spent on files since the last line,
+// followed by that of the least and the most busy worker.
+func (c *Console) Report(s fileprocessor.Snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := s.Throughput
+	line := fmt.Sprintf("[METRICS] Processed: %d | Failed: %d | Bytes: %d | MB/s: %.2f (1m: %.2f, 5m: %.2f) | Queue: %d | Workers: %d | Goroutines: %d",
+		s.Processed, s.Failed, s.Bytes, t.Current/1e6, t.Avg1m/1e6, t.Avg5m/1e6, s.Queue, s.Workers, s.Goroutines)
+	if busy, ok := s.Utilization(c.last); ok {
+		lo, hi := workerRange(s, c.last)
+		line += fmt.Sprintf(" | Busy: %.0f%% (%.0f-%.0f%%)", 100*busy, 100*lo, 100*hi)
+	}
+	c.last = s
+	if fraction, remaining, ok := s.Progress(); ok {
+		line += fmt.Sprintf(" | Progress: %.1f%% | ETA: %s", 100*fraction, remaining.Round(time.Second))
+	}
+	fmt.Fprintf(c.w, "\n%s\n", line)
+}
+
+// workerRange returns the utilization of the least and the most busy
+// worker between the snapshots since and s, among those that were around
+// for at least half of that time, so that a worker the autoscaler has
+// just started doesn't count as idle.
+func workerRange(s, since fileprocessor.Snapshot) (lo, hi float64) {
+	usage := make([]fileprocessor.WorkerUsage, len(s.Usage))
+	var longest time.Duration
+	for i, u := range s.Usage {
+		if i < len(since.Usage) {
+			u.Busy -= since.Usage[i].Busy
+			u.Idle -= since.Usage[i].Idle
+		}
+		usage[i] = u
+		longest = max(longest, u.Busy+u.Idle)
+	}
+	lo = 1
+	for _, u := range usage {
+		if total := u.Busy + u.Idle; total > 0 && 2*total >= longest {
+			lo, hi = min(lo, u.Utilization()), max(hi, u.Utilization())
+		}
+	}
+	return min(lo, hi), hi
+}
+
+// ReportFile prints one line per successfully processed file. Failures
+// are left for ReportSummary.
+func (c *Console) ReportFile(res fileprocessor.Result) {
+	if res.Err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if res.Hash == "" {
+		fmt.Fprintf(c.w, "Processed: %s\n", res.Path)
+		return
+	}
+	line := fmt.Sprintf("Processed: %s | %s: %s", res.Path, algorithmLabel(res.Algorithm), res.Hash)
+	if res.Merkle != nil {
+		line += " | Merkle: " + res.Merkle.Root
+	}
+	if res.Fuzzy != "" {
+		line += " | Fuzzy: " + res.Fuzzy
+	}
+	if len(res.Chunks) > 0 {
+		line += fmt.Sprintf(" | Chunks: %d", len(res.Chunks))
+	}
+	if res.ContentType != "" {
+		line += " | Type: " + res.ContentType
+	}
+	if len(res.Links) > 0 {
+		line += " | Links: " + strings.Join(res.Links, ", ")
+	}
+	fmt.Fprintln(c.w, line)
+}
+
+// ReportSummary prints the totals of a run and every per-file error.
+func (c *Console) ReportSummary(s fileprocessor.Summary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintln(c.w, "\n"+c.color.green("Processing complete"))
+	fmt.Fprintln(c.w, "Files processed:", s.Processed)
+	if s.Failed > 0 {
+		fmt.Fprintln(c.w, c.color.red(fmt.Sprint("Files failed: ", s.Failed)))
+	} else {
+		fmt.Fprintln(c.w, "Files failed:", s.Failed)
+	}
+	fmt.Fprintln(c.w, "Files skipped:", s.Skipped)
+	fmt.Fprintln(c.w, "Bytes processed:", s.Bytes)
+	fmt.Fprintln(c.w, "Duration:", s.Duration.Round(time.Millisecond))
+	fmt.Fprintf(c.w, "Throughput: %.2f MB/s, %.1f files/s\n", s.BytesPerSecond()/1e6, s.FilesPerSecond())
+	if l := s.Latency; l.Count > 0 {
+		fmt.Fprintf(c.w, "Latency: p50 %s, p95 %s, p99 %s, max %s\n",
+			l.P50.Round(time.Microsecond), l.P95.Round(time.Microsecond), l.P99.Round(time.Microsecond), l.Max.Round(time.Microsecond))
+	}
+	if busy, ok := s.Utilization(); ok {
+		fmt.Fprintf(c.w, "Workers busy: %.0f%% of the time\n", 100*busy)
+	}
+	if s.Unchanged > 0 {
+		fmt.Fprintln(c.w, "Files unchanged:", s.Unchanged)
+	}
+	if s.Hardlinks > 0 {
+		fmt.Fprintln(c.w, "Hard links folded:", s.Hardlinks)
+	}
+	if s.Truncated {
+		fmt.Fprintln(c.w, c.color.yellow("Truncated: run limit reached, later files were not processed"))
+	}
+
+	if s.Processed > 0 {
+		fmt.Fprintln(c.w, "File sizes:")
+		for _, b := range s.Sizes {
+			if b.Files > 0 {
+				fmt.Fprintf(c.w, "  %-16s %8d files %12d bytes\n", bucketLabel(b), b.Files, b.Bytes)
+			}
+		}
+	}
+
+	if len(s.Slowest) > 0 {
+		fmt.Fprintln(c.w, "Slowest files:")
+		for _, f := range s.Slowest {
+			failed := ""
+			if f.Failed {
+				failed = " " + c.color.red("(failed)")
+			}
+			fmt.Fprintf(c.w, "  %12s  %s%s\n", f.Duration.Round(time.Microsecond), f.Path, failed)
+		}
+	}
+	if len(s.Largest) > 0 {
+		fmt.Fprintln(c.w, "Largest files:")
+		for _, f := range s.Largest {
+			fmt.Fprintf(c.w, "  %12d bytes  %s\n", f.Size, f.Path)
+		}
+	}
+
+	if len(s.FailureClasses) > 0 {
+		classes := slices.Collect(maps.Keys(s.FailureClasses))
+		slices.SortFunc(classes, func(a, b string) int {
+			return cmp.Or(cmp.Compare(s.FailureClasses[b], s.FailureClasses[a]), cmp.Compare(a, b))
+		})
+		fmt.Fprintln(c.w, c.color.red("Failures by class:"))
+		for _, class := range classes {
+			fmt.Fprintf(c.w, "  %-12s %d\n", class, s.FailureClasses[class])
+		}
+	}
+
+	if s.Fingerprint != "" {
+		fmt.Fprintln(c.w, "Fingerprint:", s.Fingerprint)
+	}
+
+	if len(s.Dirs) > 0 {
+		fmt.Fprintln(c.w, "Directory Merkle roots:")
+		for _, d := range s.Dirs {
+			fmt.Fprintf(c.w, "%s  %s\n", d.Root, d.Path)
+		}
+	}
+
+	if len(s.DirStats) > 0 {
+		fmt.Fprintln(c.w, "Directory statistics:")
+		fmt.Fprintf(c.w, "  %8s %14s %7s  %-64s  %s\n", "FILES", "BYTES", "FAILED", "HASH", "PATH")
+		for _, d := range s.DirStats {
+			fmt.Fprintf(c.w, "  %8d %14d %7d  %-64s  %s\n", d.Files, d.Bytes, d.Failed, d.Hash, d.Path)
+		}
+	}
+
+	if len(s.Clusters) > 0 {
+		fmt.Fprintln(c.w, "Similar files:")
+		for i, cluster := range s.Clusters {
+			fmt.Fprintf(c.w, "Cluster %d:\n", i+1)
+			for _, path := range cluster {
+				fmt.Fprintf(c.w, "  %s\n", path)
+			}
+		}
+	}
+
+	if len(s.Duplicates) > 0 {
+		var files int
+		for _, g := range s.Duplicates {
+			files += len(g.Paths)
+		}
+		fmt.Fprintf(c.w, "Duplicates: %d groups of %d files, %d bytes wasted\n", len(s.Duplicates), files, s.WastedBytes())
+		for _, g := range s.Duplicates {
+			fmt.Fprintf(c.w, "  %s  %d x %d bytes, %d wasted\n", g.Hash, len(g.Paths), g.Size, g.Wasted())
+			for _, path := range g.Paths {
+				fmt.Fprintf(c.w, "    %s\n", path)
+			}
+		}
+	}
+
+	if d := s.Drift; d != nil {
+		line := fmt.Sprintf("Drift: %d unchanged, %d modified, %d added, %d deleted",
+			d.Unchanged, len(d.Modified), len(d.Added), len(d.Deleted))
+		if len(d.Modified)+len(d.Added)+len(d.Deleted) > 0 {
+			line = c.color.yellow(line)
+		}
+		fmt.Fprintln(c.w, line)
+		for _, group := range []struct {
+			label string
+			paths []string
+		}{{"modified", d.Modified}, {"added", d.Added}, {"deleted", d.Deleted}} {
+			for _, path := range group.paths {
+				fmt.Fprintf(c.w, "  %s  %s\n", c.color.yellow(fmt.Sprintf("%-8s", group.label)), path)
+			}
+		}
+	}
+
+	if len(s.Errors) > 0 {
+		fmt.Fprintln(c.w, c.color.red("Some errors occurred:"))
+		for _, err := range s.Errors {
+			fmt.Fprintln(c.w, c.color.red("- "+err.Error()))
+		}
+	}
+}
+
+// bucketLabel renders the range of a size bucket, e.g. "16 KiB-256 KiB".
+func bucketLabel(b fileprocessor.SizeBucket) string {
+	switch {
+	case b.Max == 0:
+		return ">= " + binarySize(b.Min)
+	case b.Min == 0:
+		return "< " + binarySize(b.Max)
+	}
+	return binarySize(b.Min) + "-" + binarySize(b.Max)
+}
+
+// binarySize renders a power-of-two size with the largest binary unit
+// it is a whole multiple of.
+func binarySize(n int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for i < len(units)-1 && n >= 1024 && n%1024 == 0 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%d %s", n, units[i])
+}
+
+// algorithmLabel renders an algorithm name the way checksum tools do,
+// e.g. "SHA256" or "SHA3-256".
+func algorithmLabel(name string) string {
+	if name == "" {
+		return "Hash"
+	}
+	return strings.ToUpper(name)
+}
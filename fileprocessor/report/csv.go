@@ -0,0 +1,76 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// csvHeader names the columns of CSV output.
+var csvHeader = []string{"path", "size", "mtime", "hash", "algorithm", "duration_ms", "error"}
+
+// CSV writes a header row and then a row for every file, failed ones
+// included, quoted as RFC 4180 requires and with CRLF line endings, so
+// its output opens directly in a spreadsheet. A file's hard links get
+// rows of their own.
+type CSV struct {
+	mu     sync.Mutex
+	w      *csv.Writer
+	headed bool
+}
+
+// NewCSV returns a CSV reporter writing to w.
+func NewCSV(w io.Writer) *CSV {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return &CSV{w: cw}
+}
+
+// Report does nothing; CSV output has no progress rows.
+func (c *CSV) Report(fileprocessor.Snapshot) {}
+
+// ReportFile writes the rows for res.
+func (c *CSV) ReportFile(res fileprocessor.Result) {
+	var mtime, errText string
+	if !res.ModTime.IsZero() {
+		mtime = res.ModTime.Format(time.RFC3339Nano)
+	}
+	if res.Err != nil {
+		errText = res.Err.Error()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head()
+	for _, path := range append([]string{res.Path}, res.Links...) {
+		c.w.Write([]string{
+			path,
+			strconv.FormatInt(res.Size, 10),
+			mtime,
+			res.Hash,
+			res.Algorithm,
+			strconv.FormatInt(res.Duration.Milliseconds(), 10),
+			errText,
+		})
+	}
+	c.w.Flush()
+}
+
+// ReportSummary writes the header if no file was reported, so that the
+// output of an empty run is still a valid table.
+func (c *CSV) ReportSummary(fileprocessor.Summary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head()
+	c.w.Flush()
+}
+
+func (c *CSV) head() {
+	if !c.headed {
+		c.w.Write(csvHeader)
+		c.headed = true
+	}
+}
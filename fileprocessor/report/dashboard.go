@@ -0,0 +1,183 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+const (
+	// dashboardHistory is how many snapshots the sparkline spans.
+	dashboardHistory = 60
+	// dashboardErrors is how many recent errors the dashboard lists, and
+	// dashboardWorkers how many busy workers.
+	dashboardErrors  = 5
+	dashboardWorkers = 16
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Dashboard takes over a terminal while the run lasts, redrawing a full
+// screen with every snapshot: whether the run is paused, files done and
+// failed, queue depth, what each busy worker is on and for how long, a
+// sparkline of the throughput over the last minute and the most recent
+// errors. Once the summary arrives it gives the screen back and prints
+// the summary as Console does.
+//
+// It draws with ANSI escapes on the terminal's alternate screen, so w
+// should be a terminal. Lines are cut to width columns, unless width is
+// zero.
+type Dashboard struct {
+	mu      sync.Mutex
+	w       io.Writer
+	width   int
+	keys    string
+	last    fileprocessor.Snapshot
+	rates   []float64
+	errors  []string
+	drawn   bool
+	console *Console
+}
+
+// NewDashboard returns a Dashboard drawing on w. keys, if not empty, is a
+// line of key bindings to show at the bottom, such as "p pause  q quit".
+func NewDashboard(w io.Writer, width int, keys string) *Dashboard {
+	return &Dashboard{w: w, width: width, keys: keys, console: NewConsole(w)}
+}
+
+// SetColor turns ANSI colors on or off for the summary printed once the
+// screen is given back, as Console.SetColor describes.
+func (d *Dashboard) SetColor(on bool) {
+	d.console.SetColor(on)
+}
+
+// Report redraws the screen.
+func (d *Dashboard) Report(s fileprocessor.Snapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var rate float64
+	if dt := (s.Elapsed - d.last.Elapsed).Seconds(); dt > 0 {
+		rate = float64(s.Bytes-d.last.Bytes) / dt
+	}
+	d.last = s
+	d.rates = append(d.rates, rate)
+	if len(d.rates) > dashboardHistory {
+		d.rates = d.rates[len(d.rates)-dashboardHistory:]
+	}
+
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	state := "running"
+	if s.Paused {
+		state = "PAUSED"
+	}
+	add("fileprocessor  %s  %s elapsed", state, s.Elapsed.Round(time.Second))
+	add("")
+	add("Files    %d processed  %d failed", s.Processed, s.Failed)
+	if fraction, remaining, ok := s.Progress(); ok {
+		fraction = min(fraction, 1)
+		filled := int(fraction * progressWidth)
+		add("Progress [%s%s] %5.1f%%  ETA %s", strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled), 100*fraction, remaining.Round(time.Second))
+	}
+	add("Bytes    %d", s.Bytes)
+	add("Queue    %d", s.Queue)
+	add("Workers  %d (%d busy)", s.Workers, len(s.Active))
+	add("MB/s     %8.2f  %s", rate/1e6, sparkline(d.rates))
+	add("")
+	add("Busy workers:")
+	if len(s.Active) == 0 {
+		add("  (none)")
+	}
+	for i, w := range s.Active {
+		if i == dashboardWorkers {
+			add("  ... and %d more", len(s.Active)-i)
+			break
+		}
+		add("  #%-3d %8s  %s", w.ID, w.Elapsed.Round(100*time.Millisecond), w.Path)
+	}
+	add("")
+	add("Recent errors:")
+	if len(d.errors) == 0 {
+		add("  (none)")
+	}
+	for _, e := range d.errors {
+		add("  %s", e)
+	}
+	if d.keys != "" {
+		add("")
+		add("%s", d.keys)
+	}
+
+	var b strings.Builder
+	if !d.drawn {
+		b.WriteString("\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+		d.drawn = true
+	}
+	b.WriteString("\x1b[H")
+	for _, line := range lines {
+		if d.width > 0 {
+			if r := []rune(line); len(r) > d.width {
+				line = string(r[:d.width])
+			}
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K\r\n")
+	}
+	b.WriteString("\x1b[J")
+	fmt.Fprint(d.w, b.String())
+}
+
+// ReportFile keeps the errors of failed files for the screen.
+func (d *Dashboard) ReportFile(res fileprocessor.Result) {
+	if res.Err == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.errors = append(d.errors, res.Err.Error())
+	if len(d.errors) > dashboardErrors {
+		d.errors = d.errors[len(d.errors)-dashboardErrors:]
+	}
+}
+
+// ReportSummary gives the screen back and prints the summary.
+func (d *Dashboard) ReportSummary(s fileprocessor.Summary) {
+	d.Close()
+	d.console.ReportSummary(s)
+}
+
+// Close leaves the alternate screen, if the dashboard is on it. Callers
+// that may exit before the summary, such as on a fatal error, should call
+// it so that the terminal is usable again.
+func (d *Dashboard) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.drawn {
+		fmt.Fprint(d.w, "\x1b[?25h\x1b[?1049l")
+		d.drawn = false
+	}
+	return nil
+}
+
+// sparkline draws rates relative to their maximum.
+func sparkline(rates []float64) string {
+	var peak float64
+	for _, r := range rates {
+		peak = max(peak, r)
+	}
+	out := make([]rune, len(rates))
+	for i, r := range rates {
+		n := 0
+		if peak > 0 {
+			n = int(r / peak * float64(len(sparks)-1))
+		}
+		out[i] = sparks[n]
+	}
+	return string(out)
+}
@@ -0,0 +1,36 @@
+// Package report provides fileprocessor.Reporter implementations that
+// render a run as human-readable text, as JSON, JSON Lines or CSV, as a
+// checksum manifest, as SQLite or Parquet tables, as lines laid out by a
+// template, as an HTML page or Markdown tables, as Prometheus metrics
+// served or pushed to a Pushgateway, as health probes, or not at all.
+package report
+
+import "fileprocessor"
+
+// Compile-time checks that every reporter implements the optional
+// interfaces it advertises.
+var (
+	_ fileprocessor.FileReporter    = (*Console)(nil)
+	_ fileprocessor.SummaryReporter = (*Console)(nil)
+	_ fileprocessor.FileReporter    = (*JSON)(nil)
+	_ fileprocessor.SummaryReporter = (*JSON)(nil)
+	_ fileprocessor.FileReporter    = (*Manifest)(nil)
+	_ fileprocessor.FileReporter    = (*JSONLines)(nil)
+	_ fileprocessor.FileReporter    = (*CSV)(nil)
+	_ fileprocessor.FileReporter    = (*SQLite)(nil)
+	_ fileprocessor.FileReporter    = (*Parquet)(nil)
+	_ fileprocessor.FileReporter    = (*Template)(nil)
+	_ fileprocessor.SummaryReporter = (*CSV)(nil)
+	_ fileprocessor.SummaryReporter = (*Progress)(nil)
+	_ fileprocessor.FileReporter    = (*Dashboard)(nil)
+	_ fileprocessor.SummaryReporter = (*Dashboard)(nil)
+	_ fileprocessor.FileReporter    = (*HTML)(nil)
+	_ fileprocessor.SummaryReporter = (*HTML)(nil)
+	_ fileprocessor.SummaryReporter = (*Markdown)(nil)
+	_ fileprocessor.SummaryReporter = (*Prometheus)(nil)
+	_ fileprocessor.SummaryReporter = (*Pushgateway)(nil)
+	_ fileprocessor.SummaryReporter = (*Health)(nil)
+	_ fileprocessor.FileReporter    = Multi(nil)
+	_ fileprocessor.SummaryReporter = Multi(nil)
+	_ fileprocessor.Reporter        = Silent{}
+)
@@ -0,0 +1,159 @@
+package report
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// HealthConfig sets when Health reports a run as unhealthy or not ready.
+type HealthConfig struct {
+	// StallTimeout is how long the run may go without the walk coming
+	// across a file or a worker finishing one before it counts as hung,
+	// and also how long without a snapshot. Defaults to 5 minutes.
+	StallTimeout time.Duration
+	// MaxErrorRate is the share of the files finished over the last
+	// ErrorWindow that may fail while the run is ready, from 0 to 1.
+	// Defaults to 0.5; 1 never withholds readiness for failures.
+	// ErrorWindow defaults to a minute.
+	MaxErrorRate float64
+	ErrorWindow  time.Duration
+}
+
+// healthMinFiles is the number of files finished over the error window
+// below which the error rate is too noisy to go by.
+const healthMinFiles = 10
+
+// Health answers liveness and readiness probes, such as Kubernetes', from
+// the snapshots of a run. A run is live unless it is hung: neither walking
+// nor finishing files, while not paused, for longer than StallTimeout. It
+// is ready while it is live, running, not paused, with room in its queue
+// and failing no more than MaxErrorRate of its files. Once the summary
+// arrives the run is live but no longer ready.
+type Health struct {
+	mu       sync.Mutex
+	cfg      HealthConfig
+	last     fileprocessor.Snapshot
+	seen     bool
+	lastAt   time.Time // when last arrived
+	movedAt  time.Time // when the walk or a worker last got on
+	window   []healthSample
+	complete bool
+}
+
+// healthSample is the count of finished and failed files at a time.
+type healthSample struct {
+	at       time.Time
+	finished int64
+	failed   int64
+}
+
+// NewHealth returns a Health for a run that is starting.
+func NewHealth(cfg HealthConfig) *Health {
+	if cfg.StallTimeout <= 0 {
+		cfg.StallTimeout = 5 * time.Minute
+	}
+	if cfg.MaxErrorRate <= 0 {
+		cfg.MaxErrorRate = 0.5
+	}
+	if cfg.ErrorWindow <= 0 {
+		cfg.ErrorWindow = time.Minute
+	}
+	now := time.Now()
+	return &Health{cfg: cfg, lastAt: now, movedAt: now}
+}
+
+// Report notes whether the run got on since the previous snapshot.
+func (h *Health) Report(s fileprocessor.Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if !h.seen || s.Paused || s.Walked != h.last.Walked || s.Processed+s.Failed != h.last.Processed+h.last.Failed {
+		h.movedAt = now
+	}
+	h.last, h.seen, h.lastAt = s, true, now
+	h.window = append(h.window, healthSample{at: now, finished: s.Processed + s.Failed, failed: s.Failed})
+	for len(h.window) > 1 && now.Sub(h.window[1].at) >= h.cfg.ErrorWindow {
+		h.window = h.window[1:]
+	}
+}
+
+// ReportSummary marks the run as over.
+func (h *Health) ReportSummary(fileprocessor.Summary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.complete = true
+}
+
+// Live returns why the run is hung, or nil if it isn't.
+func (h *Health) Live() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.live(time.Now())
+}
+
+func (h *Health) live(now time.Time) error {
+	switch {
+	case h.complete:
+		return nil
+	case now.Sub(h.lastAt) > h.cfg.StallTimeout:
+		return fmt.Errorf("no snapshot of the run for %s", now.Sub(h.lastAt).Round(time.Second))
+	case now.Sub(h.movedAt) > h.cfg.StallTimeout:
+		return fmt.Errorf("stalled: no file walked or finished for %s", now.Sub(h.movedAt).Round(time.Second))
+	}
+	return nil
+}
+
+// Ready returns why the run should get no traffic, or nil if it should.
+func (h *Health) Ready() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.live(time.Now()); err != nil {
+		return err
+	}
+	s := h.last
+	switch {
+	case h.complete:
+		return fmt.Errorf("run complete")
+	case !h.seen:
+		return fmt.Errorf("starting")
+	case s.Paused:
+		return fmt.Errorf("paused")
+	case s.QueueSize > 0 && s.Queue >= s.QueueSize:
+		return fmt.Errorf("queue saturated: %d of %d files waiting", s.Queue, s.QueueSize)
+	}
+	if len(h.window) > 1 {
+		first, last := h.window[0], h.window[len(h.window)-1]
+		finished, failed := last.finished-first.finished, last.failed-first.failed
+		if finished >= healthMinFiles && float64(failed) > h.cfg.MaxErrorRate*float64(finished) {
+			return fmt.Errorf("error rate: %d of %d files failed in the last %s", failed, finished,
+				last.at.Sub(first.at).Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// Healthz serves the liveness probe, usually on /healthz: 200 while the
+// run is live, 503 with the reason otherwise.
+func (h *Health) Healthz(w http.ResponseWriter, r *http.Request) {
+	probe(w, h.Live())
+}
+
+// Readyz serves the readiness probe, usually on /readyz: 200 while the run
+// is ready, 503 with the reason otherwise.
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	probe(w, h.Ready())
+}
+
+func probe(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,312 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// HTML writes a standalone HTML page once the run is over, for sharing
+// with people who don't read terminal output: cards with the totals,
+// charts of the throughput over time drawn from the snapshots, the size
+// histogram, the slowest and largest files, the errors and the groups of
+// processed files with equal hashes. The page has no scripts and loads
+// nothing, so it can be mailed or attached to a ticket as it is.
+//
+// The duplicate groups need every processed file's hash and path until
+// the end of the run.
+type HTML struct {
+	mu      sync.Mutex
+	w       io.Writer
+	samples []fileprocessor.Snapshot
+	files   map[string][]string // paths by algorithm, hash and size
+	sizes   map[string]int64
+	err     error
+}
+
+// NewHTML returns an HTML reporter writing the page to w.
+func NewHTML(w io.Writer) *HTML {
+	return &HTML{w: w, files: make(map[string][]string), sizes: make(map[string]int64)}
+}
+
+// Report keeps the snapshot for the throughput charts.
+func (h *HTML) Report(s fileprocessor.Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples = append(h.samples, s)
+}
+
+// ReportFile keeps the hash of every processed, non-empty file for the
+// duplicate groups.
+func (h *HTML) ReportFile(res fileprocessor.Result) {
+	if res.Err != nil || res.Hash == "" || res.Size == 0 {
+		return
+	}
+	key := fmt.Sprintf("%s:%s:%d", res.Algorithm, res.Hash, res.Size)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.files[key] = append(h.files[key], res.Path)
+	h.sizes[key] = res.Size
+}
+
+// ReportSummary writes the page.
+func (h *HTML) ReportSummary(s fileprocessor.Summary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := htmlPage.Execute(h.w, h.page(s)); err != nil && h.err == nil {
+		h.err = err
+	}
+}
+
+// Close returns the first error writing the page.
+func (h *HTML) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+type htmlData struct {
+	Generated  string
+	Summary    fileprocessor.Summary
+	Cards      []htmlCard
+	Charts     []htmlChart
+	Sizes      []htmlBar
+	Classes    []htmlCount
+	Errors     []htmlError
+	Duplicates []htmlGroup
+}
+
+type htmlCard struct{ Label, Value string }
+
+type htmlBar struct {
+	Label   string
+	Files   int64
+	Bytes   int64
+	Percent float64
+}
+
+type htmlCount struct {
+	Class string
+	Count int64
+}
+
+type htmlError struct{ Class, Message string }
+
+type htmlGroup struct {
+	Hash   string
+	Size   int64
+	Wasted int64
+	Paths  []string
+}
+
+// htmlChart is a line chart of a rate over the run, as the points of an
+// SVG polyline in a chartWidth by chartHeight box.
+type htmlChart struct {
+	Title  string
+	Unit   string
+	Points string
+	Max    string
+	End    string
+}
+
+const chartWidth, chartHeight = 800, 200
+
+func (h *HTML) page(s fileprocessor.Summary) htmlData {
+	d := htmlData{
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		Summary:   s,
+		Cards: []htmlCard{
+			{"Files processed", fmt.Sprint(s.Processed)},
+			{"Files failed", fmt.Sprint(s.Failed)},
+			{"Files skipped", fmt.Sprint(s.Skipped)},
+			{"Bytes processed", fmt.Sprint(s.Bytes)},
+			{"Duration", s.Duration.Round(time.Millisecond).String()},
+			{"Throughput", fmt.Sprintf("%.2f MB/s", s.BytesPerSecond()/1e6)},
+			{"Files per second", fmt.Sprintf("%.1f", s.FilesPerSecond())},
+		},
+	}
+
+	// Rates between consecutive snapshots, ending with the summary. An
+	// interval much shorter than the reporting period, such as the one
+	// between the last snapshot and the summary, is merged into the next
+	// rather than drawn as a spike.
+	samples := append(slices.Clone(h.samples), fileprocessor.Snapshot{
+		Processed: s.Processed, Failed: s.Failed, Bytes: s.Bytes, Elapsed: s.Duration,
+	})
+	var mb, files []point
+	var last fileprocessor.Snapshot
+	for _, cur := range samples {
+		dt := (cur.Elapsed - last.Elapsed).Seconds()
+		if dt < 0.5 {
+			continue
+		}
+		t := cur.Elapsed.Seconds()
+		mb = append(mb, point{t, float64(cur.Bytes-last.Bytes) / 1e6 / dt})
+		files = append(files, point{t, float64(cur.Processed+cur.Failed-last.Processed-last.Failed) / dt})
+		last = cur
+	}
+	if len(mb) > 1 {
+		d.Charts = []htmlChart{chart("Throughput", "MB/s", mb), chart("Files per second", "files/s", files)}
+	}
+
+	var most int64
+	for _, b := range s.Sizes {
+		most = max(most, b.Files)
+	}
+	for _, b := range s.Sizes {
+		bar := htmlBar{Label: bucketLabel(b), Files: b.Files, Bytes: b.Bytes}
+		if most > 0 {
+			bar.Percent = 100 * float64(b.Files) / float64(most)
+		}
+		d.Sizes = append(d.Sizes, bar)
+	}
+
+	for class, n := range s.FailureClasses {
+		d.Classes = append(d.Classes, htmlCount{class, n})
+	}
+	slices.SortFunc(d.Classes, func(a, b htmlCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Class, b.Class))
+	})
+	for _, err := range s.Errors {
+		d.Errors = append(d.Errors, htmlError{fileprocessor.ErrorClass(err), err.Error()})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(h.files)) {
+		paths := h.files[key]
+		if len(paths) < 2 {
+			continue
+		}
+		size := h.sizes[key]
+		parts := strings.SplitN(key, ":", 3)
+		d.Duplicates = append(d.Duplicates, htmlGroup{
+			Hash:   parts[1],
+			Size:   size,
+			Wasted: size * int64(len(paths)-1),
+			Paths:  slices.Sorted(slices.Values(paths)),
+		})
+	}
+	slices.SortStableFunc(d.Duplicates, func(a, b htmlGroup) int { return cmp.Compare(b.Wasted, a.Wasted) })
+	return d
+}
+
+type point struct{ t, v float64 }
+
+func chart(title, unit string, pts []point) htmlChart {
+	var tmax, vmax float64
+	for _, p := range pts {
+		tmax, vmax = max(tmax, p.t), max(vmax, p.v)
+	}
+	if vmax == 0 {
+		vmax = 1
+	}
+	var b strings.Builder
+	for i, p := range pts {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", chartWidth*p.t/tmax, chartHeight-chartHeight*p.v/vmax)
+	}
+	return htmlChart{
+		Title:  title,
+		Unit:   unit,
+		Points: b.String(),
+		Max:    fmt.Sprintf("%.2f %s", vmax, unit),
+		End:    (time.Duration(tmax * float64(time.Second))).Round(time.Second).String(),
+	}
+}
+
+var htmlPage = template.Must(template.New("html").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string { return d.Round(time.Microsecond).String() },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>fileprocessor report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+.cards { display: flex; flex-wrap: wrap; gap: 1em; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: 0.8em 1.2em; min-width: 9em; }
+.card .value { font-size: 1.4em; font-weight: bold; }
+.card .label { color: #666; font-size: 0.9em; }
+.warn { color: #a40; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.25em 0.8em; border-bottom: 1px solid #eee; vertical-align: top; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+code { font-size: 0.9em; }
+.bar { background: #4a7fb5; height: 0.9em; }
+svg { border: 1px solid #ddd; background: #fafafa; }
+polyline { fill: none; stroke: #4a7fb5; stroke-width: 2; }
+</style>
+</head>
+<body>
+<h1>fileprocessor report</h1>
+<p>Generated {{.Generated}}{{with .Summary.Fingerprint}} &middot; fingerprint <code>{{.}}</code>{{end}}</p>
+{{if .Summary.Truncated}}<p class="warn">The run was truncated: the run limit was reached and later files were not processed.</p>{{end}}
+
+<div class="cards">
+{{range .Cards}}<div class="card"><div class="value">{{.Value}}</div><div class="label">{{.Label}}</div></div>
+{{end}}</div>
+
+{{range .Charts}}
+<h2>{{.Title}}</h2>
+<svg viewBox="0 0 800 200" width="800" height="200" role="img" aria-label="{{.Title}} over time">
+<polyline points="{{.Points}}"/>
+</svg>
+<p>Peak {{.Max}}, over {{.End}}.</p>
+{{end}}
+
+<h2>File sizes</h2>
+<table>
+<tr><th>Size</th><th>Files</th><th>Bytes</th><th></th></tr>
+{{range .Sizes}}<tr><td>{{.Label}}</td><td class="num">{{.Files}}</td><td class="num">{{.Bytes}}</td><td style="width: 20em"><div class="bar" style="width: {{printf "%.1f" .Percent}}%"></div></td></tr>
+{{end}}</table>
+
+{{with .Summary.Slowest}}
+<h2>Slowest files</h2>
+<table>
+<tr><th>Duration</th><th>Path</th></tr>
+{{range .}}<tr><td class="num">{{ms .Duration}}</td><td>{{.Path}}{{if .Failed}} <span class="warn">(failed)</span>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{with .Summary.Largest}}
+<h2>Largest files</h2>
+<table>
+<tr><th>Bytes</th><th>Path</th></tr>
+{{range .}}<tr><td class="num">{{.Size}}</td><td>{{.Path}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .Errors}}
+<h2>Errors</h2>
+<table>
+<tr><th>Class</th><th>Files</th></tr>
+{{range .Classes}}<tr><td>{{.Class}}</td><td class="num">{{.Count}}</td></tr>
+{{end}}</table>
+<p></p>
+<table>
+<tr><th>Class</th><th>Error</th></tr>
+{{range .Errors}}<tr><td>{{.Class}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .Duplicates}}
+<h2>Duplicate groups</h2>
+<table>
+<tr><th>Hash</th><th>Bytes each</th><th>Bytes wasted</th><th>Paths</th></tr>
+{{range .Duplicates}}<tr><td><code>{{.Hash}}</code></td><td class="num">{{.Size}}</td><td class="num">{{.Wasted}}</td><td>{{range $i, $p := .Paths}}{{if $i}}<br>{{end}}{{$p}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,292 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// JSON writes one JSON object per line for every snapshot, file and the
+// final summary. Each object has a "type" field of "snapshot", "file" or
+// "summary".
+type JSON struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSON returns a JSON reporter writing to w.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{enc: json.NewEncoder(w)}
+}
+
+type jsonSnapshot struct {
+	Type       string `json:"type"`
+	Processed  int64  `json:"processed"`
+	Failed     int64  `json:"failed"`
+	Queue      int    `json:"queue"`
+	Workers    int    `json:"workers"`
+	Goroutines int    `json:"goroutines"`
+	Bytes      int64  `json:"bytes"`
+	ElapsedMS  int64  `json:"elapsed_ms"`
+	// Bytes per second since the last snapshot and over one and five
+	// minutes.
+	BytesPerSecond   float64 `json:"bytes_per_second"`
+	BytesPerSecond1m float64 `json:"bytes_per_second_1m"`
+	BytesPerSecond5m float64 `json:"bytes_per_second_5m"`
+
+	// Set only with a pre-scan.
+	TotalFiles int64    `json:"total_files,omitempty"`
+	TotalBytes int64    `json:"total_bytes,omitempty"`
+	Percent    *float64 `json:"percent,omitempty"`
+	ETAMS      *int64   `json:"eta_ms,omitempty"`
+}
+
+type jsonFile struct {
+	Type        string      `json:"type,omitempty"`
+	Path        string      `json:"path"`
+	Size        int64       `json:"size"`
+	ModTime     string      `json:"mtime,omitempty"`
+	Hash        string      `json:"hash,omitempty"`
+	Algorithm   string      `json:"algorithm,omitempty"`
+	MerkleRoot  string      `json:"merkle_root,omitempty"`
+	Chunks      []string    `json:"chunks,omitempty"`
+	Fuzzy       string      `json:"fuzzy,omitempty"`
+	CDC         []jsonChunk `json:"cdc,omitempty"`
+	ContentType string      `json:"content_type,omitempty"`
+	Links       []string    `json:"links,omitempty"`
+	DurationMS  int64       `json:"duration_ms"`
+	Error       string      `json:"error,omitempty"`
+}
+
+type jsonChunk struct {
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Hash   string `json:"hash"`
+}
+
+type jsonSummary struct {
+	Type        string           `json:"type"`
+	Processed   int64            `json:"processed"`
+	Failed      int64            `json:"failed"`
+	Skipped     int64            `json:"skipped"`
+	Bytes       int64            `json:"bytes"`
+	DurationMS  int64            `json:"duration_ms"`
+	BytesPerSec float64          `json:"bytes_per_sec"`
+	FilesPerSec float64          `json:"files_per_sec"`
+	Sizes       []jsonSize       `json:"size_histogram"`
+	Latency     *jsonLatency     `json:"latency,omitempty"`
+	Failures    map[string]int64 `json:"failures_by_class,omitempty"`
+	Slowest     []jsonStat       `json:"slowest,omitempty"`
+	Largest     []jsonStat       `json:"largest,omitempty"`
+	Unchanged   int64            `json:"unchanged,omitempty"`
+	Hardlinks   int64            `json:"hardlinks,omitempty"`
+	Truncated   bool             `json:"truncated,omitempty"`
+	Errors      []string         `json:"errors,omitempty"`
+	Dirs        []jsonDir        `json:"dirs,omitempty"`
+	DirStats    []jsonDirStat    `json:"dir_stats,omitempty"`
+	Fingerprint string           `json:"fingerprint,omitempty"`
+	Clusters    [][]string       `json:"clusters,omitempty"`
+	Duplicates  []jsonDuplicate  `json:"duplicates,omitempty"`
+	Wasted      int64            `json:"wasted_bytes,omitempty"`
+	Drift       *jsonDrift       `json:"drift,omitempty"`
+}
+
+// jsonSize is a bucket of the size histogram; Max is omitted for the
+// last, unbounded one.
+type jsonSize struct {
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max,omitempty"`
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// jsonLatency gives the percentiles of the file durations in
+// milliseconds, fractions included.
+type jsonLatency struct {
+	Files int64   `json:"files"`
+	P50MS float64 `json:"p50_ms"`
+	P95MS float64 `json:"p95_ms"`
+	P99MS float64 `json:"p99_ms"`
+	MaxMS float64 `json:"max_ms"`
+}
+
+type jsonStat struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	DurationMS int64  `json:"duration_ms"`
+	Failed     bool   `json:"failed,omitempty"`
+}
+
+type jsonDuplicate struct {
+	Algorithm string   `json:"algorithm,omitempty"`
+	Hash      string   `json:"hash"`
+	Size      int64    `json:"size"`
+	Wasted    int64    `json:"wasted"`
+	Paths     []string `json:"paths"`
+}
+
+type jsonDrift struct {
+	Unchanged int      `json:"unchanged"`
+	Modified  []string `json:"modified"`
+	Added     []string `json:"added"`
+	Deleted   []string `json:"deleted"`
+}
+
+type jsonDirStat struct {
+	Path   string `json:"path"`
+	Files  int64  `json:"files"`
+	Bytes  int64  `json:"bytes"`
+	Failed int64  `json:"failed"`
+	Hash   string `json:"hash"`
+}
+
+type jsonDir struct {
+	Path       string `json:"path"`
+	MerkleRoot string `json:"merkle_root"`
+}
+
+// Report writes a snapshot object.
+func (j *JSON) Report(s fileprocessor.Snapshot) {
+	var percent *float64
+	var eta *int64
+	if fraction, remaining, ok := s.Progress(); ok {
+		pct, ms := 100*fraction, remaining.Milliseconds()
+		percent, eta = &pct, &ms
+	}
+	j.write(jsonSnapshot{
+		Type:       "snapshot",
+		Processed:  s.Processed,
+		Failed:     s.Failed,
+		Queue:      s.Queue,
+		Workers:    s.Workers,
+		Goroutines: s.Goroutines,
+		Bytes:      s.Bytes,
+		ElapsedMS:  s.Elapsed.Milliseconds(),
+
+		BytesPerSecond:   s.Throughput.Current,
+		BytesPerSecond1m: s.Throughput.Avg1m,
+		BytesPerSecond5m: s.Throughput.Avg5m,
+
+		TotalFiles: s.TotalFiles,
+		TotalBytes: s.TotalBytes,
+		Percent:    percent,
+		ETAMS:      eta,
+	})
+}
+
+// ReportFile writes a file object, including failed files.
+func (j *JSON) ReportFile(res fileprocessor.Result) {
+	f := fileObject(res)
+	f.Type = "file"
+	j.write(f)
+}
+
+// fileObject is the JSON form of res, without a type.
+func fileObject(res fileprocessor.Result) jsonFile {
+	f := jsonFile{
+		Path:        res.Path,
+		Size:        res.Size,
+		Hash:        res.Hash,
+		Algorithm:   res.Algorithm,
+		Fuzzy:       res.Fuzzy,
+		ContentType: res.ContentType,
+		Links:       res.Links,
+		DurationMS:  res.Duration.Milliseconds(),
+	}
+	if !res.ModTime.IsZero() {
+		f.ModTime = res.ModTime.Format(time.RFC3339Nano)
+	}
+	if res.Merkle != nil {
+		f.MerkleRoot, f.Chunks = res.Merkle.Root, res.Merkle.Leaves
+	}
+	for _, c := range res.Chunks {
+		f.CDC = append(f.CDC, jsonChunk{Offset: c.Offset, Length: c.Length, Hash: c.Hash})
+	}
+	if res.Err != nil {
+		f.Error = res.Err.Error()
+	}
+	return f
+}
+
+// ReportSummary writes the summary object.
+func (j *JSON) ReportSummary(s fileprocessor.Summary) {
+	sum := jsonSummary{
+		Type:        "summary",
+		Processed:   s.Processed,
+		Failed:      s.Failed,
+		Skipped:     s.Skipped,
+		Unchanged:   s.Unchanged,
+		Hardlinks:   s.Hardlinks,
+		Truncated:   s.Truncated,
+		Bytes:       s.Bytes,
+		DurationMS:  s.Duration.Milliseconds(),
+		BytesPerSec: s.BytesPerSecond(),
+		FilesPerSec: s.FilesPerSecond(),
+		Sizes:       []jsonSize{},
+		Failures:    s.FailureClasses,
+		Fingerprint: s.Fingerprint,
+		Clusters:    s.Clusters,
+		Wasted:      s.WastedBytes(),
+	}
+	for _, b := range s.Sizes {
+		sum.Sizes = append(sum.Sizes, jsonSize(b))
+	}
+	if l := s.Latency; l.Count > 0 {
+		ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+		sum.Latency = &jsonLatency{Files: l.Count, P50MS: ms(l.P50), P95MS: ms(l.P95), P99MS: ms(l.P99), MaxMS: ms(l.Max)}
+	}
+	for _, f := range s.Slowest {
+		sum.Slowest = append(sum.Slowest, statObject(f))
+	}
+	for _, f := range s.Largest {
+		sum.Largest = append(sum.Largest, statObject(f))
+	}
+	for _, err := range s.Errors {
+		sum.Errors = append(sum.Errors, err.Error())
+	}
+	for _, d := range s.Dirs {
+		sum.Dirs = append(sum.Dirs, jsonDir{Path: d.Path, MerkleRoot: d.Root})
+	}
+	for _, g := range s.Duplicates {
+		sum.Duplicates = append(sum.Duplicates, jsonDuplicate{
+			Algorithm: g.Algorithm,
+			Hash:      g.Hash,
+			Size:      g.Size,
+			Wasted:    g.Wasted(),
+			Paths:     g.Paths,
+		})
+	}
+	for _, d := range s.DirStats {
+		sum.DirStats = append(sum.DirStats, jsonDirStat(d))
+	}
+	if d := s.Drift; d != nil {
+		sum.Drift = &jsonDrift{
+			Unchanged: d.Unchanged,
+			Modified:  nonNil(d.Modified),
+			Added:     nonNil(d.Added),
+			Deleted:   nonNil(d.Deleted),
+		}
+	}
+	j.write(sum)
+}
+
+func statObject(f fileprocessor.FileStat) jsonStat {
+	return jsonStat{Path: f.Path, Size: f.Size, DurationMS: f.Duration.Milliseconds(), Failed: f.Failed}
+}
+
+// nonNil makes empty lists encode as [] rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func (j *JSON) write(v any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.enc.Encode(v)
+}
@@ -0,0 +1,33 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"fileprocessor"
+)
+
+// JSONLines writes one JSON object per line for every file, failed ones
+// included, and nothing else, so its output can be fed to jq or loaded as
+// newline-delimited JSON. The objects are those of the JSON reporter
+// without the "type" field.
+type JSONLines struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLines returns a JSONLines reporter writing to w.
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{enc: json.NewEncoder(w)}
+}
+
+// Report does nothing; JSON Lines output has no progress objects.
+func (j *JSONLines) Report(fileprocessor.Snapshot) {}
+
+// ReportFile writes the object for res.
+func (j *JSONLines) ReportFile(res fileprocessor.Result) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.enc.Encode(fileObject(res))
+}
@@ -0,0 +1,50 @@
+package report
+
+import (
+	"io"
+	"sync"
+
+	"fileprocessor"
+	"fileprocessor/manifest"
+)
+
+// Manifest writes a sha256sum-compatible line for every hashed file and
+// nothing else, so its output can be checked later with the -check mode
+// or with coreutils.
+type Manifest struct {
+	mu  sync.Mutex
+	w   io.Writer
+	tag bool
+}
+
+// NewManifest returns a Manifest reporter writing to w.
+func NewManifest(w io.Writer) *Manifest {
+	return &Manifest{w: w}
+}
+
+// NewTaggedManifest returns a Manifest reporter writing BSD-style
+// "SHA256 (path) = digest" lines, as printed by sha256sum --tag and macOS
+// shasum and md5.
+func NewTaggedManifest(w io.Writer) *Manifest {
+	return &Manifest{w: w, tag: true}
+}
+
+// Report does nothing; a manifest has no progress lines.
+func (m *Manifest) Report(fileprocessor.Snapshot) {}
+
+// ReportFile writes the manifest line for res, and one for each of its
+// hard links. Failed and unhashed files are left out.
+func (m *Manifest) ReportFile(res fileprocessor.Result) {
+	if res.Err != nil || res.Hash == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, path := range append([]string{res.Path}, res.Links...) {
+		if m.tag {
+			manifest.WriteTag(m.w, algorithmLabel(res.Algorithm), res.Hash, path)
+		} else {
+			manifest.Write(m.w, res.Hash, path)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// Markdown writes the summary of a run as GitHub-flavored Markdown once it
+// is over: a table of the totals, then tables of the file sizes, the
+// slowest and largest files, the failures, the duplicate groups and the
+// drift from a baseline, each if there is anything to show. It is meant
+// to be pasted into pull requests, wikis and tickets, so it lists no
+// files one by one.
+type Markdown struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewMarkdown returns a Markdown reporter writing to w.
+func NewMarkdown(w io.Writer) *Markdown {
+	return &Markdown{w: w}
+}
+
+// Report does nothing; the summary is written at the end.
+func (m *Markdown) Report(fileprocessor.Snapshot) {}
+
+// ReportSummary writes the summary.
+func (m *Markdown) ReportSummary(s fileprocessor.Summary) {
+	var b strings.Builder
+	row := func(cells ...any) {
+		b.WriteString("|")
+		for _, c := range cells {
+			fmt.Fprintf(&b, " %s |", markdownCell(fmt.Sprint(c)))
+		}
+		b.WriteString("\n")
+	}
+	// Header names ending in ":" are right-aligned, for numbers.
+	table := func(title string, header ...string) {
+		fmt.Fprintf(&b, "\n### %s\n\n|", title)
+		for _, h := range header {
+			fmt.Fprintf(&b, " %s |", strings.TrimSuffix(h, ":"))
+		}
+		b.WriteString("\n|")
+		for _, h := range header {
+			if strings.HasSuffix(h, ":") {
+				b.WriteString(" ---: |")
+			} else {
+				b.WriteString(" --- |")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## fileprocessor summary\n\n")
+	b.WriteString("| | |\n| --- | ---: |\n")
+	row("Files processed", s.Processed)
+	row("Files failed", s.Failed)
+	row("Files skipped", s.Skipped)
+	if s.Unchanged > 0 {
+		row("Files unchanged", s.Unchanged)
+	}
+	if s.Hardlinks > 0 {
+		row("Hard links folded", s.Hardlinks)
+	}
+	row("Bytes processed", s.Bytes)
+	row("Duration", s.Duration.Round(time.Millisecond))
+	row("Throughput", fmt.Sprintf("%.2f MB/s, %.1f files/s", s.BytesPerSecond()/1e6, s.FilesPerSecond()))
+	if len(s.Duplicates) > 0 {
+		row("Bytes wasted by duplicates", s.WastedBytes())
+	}
+	if s.Fingerprint != "" {
+		row("Fingerprint", "`"+s.Fingerprint+"`")
+	}
+	if s.Truncated {
+		b.WriteString("\n> **Truncated:** the run limit was reached and later files were not processed.\n")
+	}
+
+	if s.Processed > 0 {
+		table("File sizes", "Size", "Files:", "Bytes:")
+		for _, sb := range s.Sizes {
+			if sb.Files > 0 {
+				row(bucketLabel(sb), sb.Files, sb.Bytes)
+			}
+		}
+	}
+	if len(s.Slowest) > 0 {
+		table("Slowest files", "Duration:", "Path")
+		for _, f := range s.Slowest {
+			path := f.Path
+			if f.Failed {
+				path += " (failed)"
+			}
+			row(f.Duration.Round(time.Microsecond), path)
+		}
+	}
+	if len(s.Largest) > 0 {
+		table("Largest files", "Bytes:", "Path")
+		for _, f := range s.Largest {
+			row(f.Size, f.Path)
+		}
+	}
+
+	if len(s.Errors) > 0 {
+		classes := slices.Collect(maps.Keys(s.FailureClasses))
+		slices.SortFunc(classes, func(a, b string) int {
+			return cmp.Or(cmp.Compare(s.FailureClasses[b], s.FailureClasses[a]), cmp.Compare(a, b))
+		})
+		table("Failures by class", "Class", "Files:")
+		for _, class := range classes {
+			row(class, s.FailureClasses[class])
+		}
+		table("Errors", "Class", "Error")
+		for _, err := range s.Errors {
+			row(fileprocessor.ErrorClass(err), err)
+		}
+	}
+
+	if len(s.Duplicates) > 0 {
+		table("Duplicates", "Hash", "Copies:", "Bytes each:", "Bytes wasted:", "Paths")
+		for _, g := range s.Duplicates {
+			paths := make([]string, len(g.Paths))
+			for i, p := range g.Paths {
+				paths[i] = markdownCell(p)
+			}
+			// The paths are escaped here, so that the line breaks
+			// between them survive.
+			fmt.Fprintf(&b, "| `%s` | %d | %d | %d | %s |\n", g.Hash, len(g.Paths), g.Size, g.Wasted(), strings.Join(paths, "<br>"))
+		}
+	}
+
+	if d := s.Drift; d != nil && d.Changed() {
+		table("Drift", "Change", "Path")
+		for _, group := range []struct {
+			label string
+			paths []string
+		}{{"modified", d.Modified}, {"added", d.Added}, {"deleted", d.Deleted}} {
+			for _, path := range group.paths {
+				row(group.label, path)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := io.WriteString(m.w, b.String()); err != nil && m.err == nil {
+		m.err = err
+	}
+}
+
+// Close returns the first error writing the summary.
+func (m *Markdown) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// markdownEscaper escapes what would end a table cell or turn into
+// formatting in one.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "&", "&amp;", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", "&lt;", "\n", " ", "\r", " ",
+)
+
+// markdownCell escapes s for a table cell, leaving a whole code span such
+// as a fingerprint alone.
+func markdownCell(s string) string {
+	if len(s) > 1 && strings.HasPrefix(s, "`") && strings.HasSuffix(s, "`") && !strings.Contains(s[1:len(s)-1], "`") {
+		return s
+	}
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,34 @@
+package report
+
+import "fileprocessor"
+
+// Multi hands everything it is told to each of its reporters in turn, so
+// that, say, progress can go to the console while results go to a file.
+// Each reporter gets files and the summary only if it implements
+// FileReporter or SummaryReporter.
+type Multi []fileprocessor.Reporter
+
+// Report hands s to every reporter.
+func (m Multi) Report(s fileprocessor.Snapshot) {
+	for _, r := range m {
+		r.Report(s)
+	}
+}
+
+// ReportFile hands res to every FileReporter.
+func (m Multi) ReportFile(res fileprocessor.Result) {
+	for _, r := range m {
+		if fr, ok := r.(fileprocessor.FileReporter); ok {
+			fr.ReportFile(res)
+		}
+	}
+}
+
+// ReportSummary hands s to every SummaryReporter.
+func (m Multi) ReportSummary(s fileprocessor.Summary) {
+	for _, r := range m {
+		if sr, ok := r.(fileprocessor.SummaryReporter); ok {
+			sr.ReportSummary(s)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"io"
+	"sync"
+
+	"fileprocessor"
+	"fileprocessor/internal/parquet"
+)
+
+// parquetColumns are the columns of Parquet output.
+var parquetColumns = []parquet.Column{
+	{Name: "path", Kind: parquet.String},
+	{Name: "size", Kind: parquet.Int64},
+	{Name: "mtime", Kind: parquet.Timestamp, Optional: true},
+	{Name: "hash", Kind: parquet.String, Optional: true},
+	{Name: "algorithm", Kind: parquet.String, Optional: true},
+	{Name: "duration_ms", Kind: parquet.Int64},
+	{Name: "error", Kind: parquet.String, Optional: true},
+}
+
+// Parquet writes a row for every file, failed ones included, to an
+// Apache Parquet file with the columns path, size, mtime (a UTC
+// timestamp), hash, algorithm, duration_ms and error, the last five null
+// where there is nothing to record. Rows go out in GZIP-compressed row
+// groups of parquet.RowGroupRows, so memory stays bounded however many
+// files there are. A file's hard links get rows of their own.
+//
+// The file is only complete once Close has written its footer.
+type Parquet struct {
+	mu sync.Mutex
+	w  *parquet.Writer
+}
+
+// NewParquet returns a Parquet reporter writing to w.
+func NewParquet(w io.Writer) *Parquet {
+	return &Parquet{w: parquet.NewWriter(w, "fileprocessor version "+fileprocessor.Version, parquetColumns...)}
+}
+
+// Report does nothing; Parquet output has no progress rows.
+func (p *Parquet) Report(fileprocessor.Snapshot) {}
+
+// ReportFile writes the rows for res. A write error is returned by
+// Close.
+func (p *Parquet) ReportFile(res fileprocessor.Result) {
+	mtime, hash, algorithm, errText := any(nil), any(nil), any(nil), any(nil)
+	if !res.ModTime.IsZero() {
+		mtime = res.ModTime
+	}
+	if res.Hash != "" {
+		hash = res.Hash
+	}
+	if res.Algorithm != "" {
+		algorithm = res.Algorithm
+	}
+	if res.Err != nil {
+		errText = res.Err.Error()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, path := range append([]string{res.Path}, res.Links...) {
+		p.w.Write(path, res.Size, mtime, hash, algorithm, res.Duration.Milliseconds(), errText)
+	}
+}
+
+// Close writes the rows still buffered and the file footer, returning
+// the first error writing the file. It does not close the underlying
+// writer.
+func (p *Parquet) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.w.Close()
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// progressWidth is the number of cells of the progress bar.
+const progressWidth = 30
+
+// Progress redraws a single status line on a terminal with every snapshot:
+// files done, the throughput since the previous snapshot and, when the
+// pre-scan found totals, a bar, the percentage and the ETA. Files are not
+// listed one by one; the summary is printed as Console prints it.
+//
+// The line is redrawn with a carriage return and an ANSI erase, so w
+// should be a terminal.
+type Progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	last    fileprocessor.Snapshot
+	drawn   bool
+	color   palette
+	console *Console
+}
+
+// NewProgress returns a Progress reporter drawing on w.
+func NewProgress(w io.Writer) *Progress {
+	return &Progress{w: w, console: NewConsole(w)}
+}
+
+// SetColor turns ANSI colors on or off, for the failure count and for the
+// summary as Console.SetColor describes.
+func (p *Progress) SetColor(on bool) {
+	p.mu.Lock()
+	p.color = palette(on)
+	p.mu.Unlock()
+	p.console.SetColor(on)
+}
+
+// Report redraws the status line.
+func (p *Progress) Report(s fileprocessor.Snapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var rate float64
+	if dt := (s.Elapsed - p.last.Elapsed).Seconds(); dt > 0 {
+		rate = float64(s.Bytes-p.last.Bytes) / dt
+	}
+	p.last = s
+
+	var b strings.Builder
+	done := s.Processed + s.Failed
+	if fraction, remaining, ok := s.Progress(); ok {
+		fraction = min(fraction, 1)
+		filled := int(fraction * progressWidth)
+		fmt.Fprintf(&b, "[%s%s] %5.1f%%  ", strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled), 100*fraction)
+		if s.TotalFiles > 0 {
+			fmt.Fprintf(&b, "%d/%d files", done, s.TotalFiles)
+		} else {
+			fmt.Fprintf(&b, "%d files", done)
+		}
+		fmt.Fprintf(&b, "  %.1f MB/s  ETA %s", rate/1e6, remaining.Round(time.Second))
+	} else {
+		fmt.Fprintf(&b, "%d files  %.1f MB  %.1f MB/s  %s elapsed", done, float64(s.Bytes)/1e6, rate/1e6, s.Elapsed.Round(time.Second))
+	}
+	if s.Failed > 0 {
+		fmt.Fprintf(&b, "  %s", p.color.red(fmt.Sprintf("%d failed", s.Failed)))
+	}
+	fmt.Fprintf(p.w, "\r\x1b[K%s", b.String())
+	p.drawn = true
+}
+
+// ReportSummary clears the status line and prints the summary.
+func (p *Progress) ReportSummary(s fileprocessor.Summary) {
+	p.mu.Lock()
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\x1b[K")
+		p.drawn = false
+	}
+	p.mu.Unlock()
+	p.console.ReportSummary(s)
+}
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"fileprocessor"
+)
+
+// Prometheus keeps the latest snapshot of a run for a Prometheus server
+// to scrape: it is an http.Handler serving the metrics in the Prometheus
+// text format, usually on /metrics. Besides the file, byte and queue
+// figures it exports how long every worker has been busy and idle, so
+// that
+//
+//	rate(fileprocessor_worker_busy_seconds_total[1m])
+//
+// is each worker's utilization, and fileprocessor_file_duration_seconds
+// has the 50th, 95th and 99th percentiles of the time files took. Once the
+// summary arrives the figures are final and fileprocessor_run_complete is 1.
+type Prometheus struct {
+	mu       sync.Mutex
+	last     fileprocessor.Snapshot
+	complete bool
+}
+
+// NewPrometheus returns a Prometheus reporter with all figures at zero.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{}
+}
+
+// Report keeps s for the next scrape.
+func (p *Prometheus) Report(s fileprocessor.Snapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.last = s
+}
+
+// ReportSummary updates the counts to their final values.
+func (p *Prometheus) ReportSummary(s fileprocessor.Summary) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.last.Processed, p.last.Failed, p.last.Bytes = s.Processed, s.Failed, s.Bytes
+	p.last.Elapsed = s.Duration
+	p.last.Queue, p.last.Active, p.last.Usage = 0, nil, s.Usage
+	p.last.Latency = s.Latency
+	p.complete = true
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format to w.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	s, complete := p.last, p.complete
+	p.mu.Unlock()
+
+	var b bytes.Buffer
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP fileprocessor_%s %s\n# TYPE fileprocessor_%s %s\n", name, help, name, kind)
+	}
+	value := func(name, labels string, v float64) {
+		fmt.Fprintf(&b, "fileprocessor_%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	single := func(name, kind, help string, v float64) {
+		metric(name, kind, help)
+		value(name, "", v)
+	}
+	single("files_processed_total", "counter", "Files processed successfully.", float64(s.Processed))
+	single("files_failed_total", "counter", "Files that could not be processed.", float64(s.Failed))
+	single("bytes_processed_total", "counter", "Bytes of the files processed successfully.", float64(s.Bytes))
+	single("queue_depth", "gauge", "Files waiting for a worker.", float64(s.Queue))
+	single("workers", "gauge", "Running workers.", float64(s.Workers))
+	single("workers_busy", "gauge", "Workers processing a file.", float64(len(s.Active)))
+	single("goroutines", "gauge", "Goroutines of the process.", float64(s.Goroutines))
+	single("paused", "gauge", "1 while the run is paused.", boolValue(s.Paused))
+	single("run_elapsed_seconds", "gauge", "Time since the run started.", s.Elapsed.Seconds())
+	single("run_complete", "gauge", "1 once the run is over.", boolValue(complete))
+	if l := s.Latency; l.Count > 0 {
+		metric("file_duration_seconds", "summary", "Time files took to process, processed or failed.")
+		value("file_duration_seconds", `{quantile="0.5"}`, l.P50.Seconds())
+		value("file_duration_seconds", `{quantile="0.95"}`, l.P95.Seconds())
+		value("file_duration_seconds", `{quantile="0.99"}`, l.P99.Seconds())
+		value("file_duration_seconds_sum", "", l.Sum.Seconds())
+		value("file_duration_seconds_count", "", float64(l.Count))
+	}
+	if len(s.Usage) > 0 {
+		metric("worker_busy_seconds_total", "counter", "Time each worker spent processing files.")
+		for _, u := range s.Usage {
+			value("worker_busy_seconds_total", workerLabel(u.ID), u.Busy.Seconds())
+		}
+		metric("worker_idle_seconds_total", "counter", "Time each worker spent waiting for files.")
+		for _, u := range s.Usage {
+			value("worker_idle_seconds_total", workerLabel(u.ID), u.Idle.Seconds())
+		}
+	}
+	return b.WriteTo(w)
+}
+
+func workerLabel(id int) string {
+	return `{worker="` + strconv.Itoa(id) + `"}`
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
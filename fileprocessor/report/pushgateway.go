@@ -0,0 +1,102 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+// pushTimeout bounds the push to a Pushgateway.
+const pushTimeout = 10 * time.Second
+
+// Pushgateway pushes the final metrics of a run, those Prometheus serves,
+// to a Prometheus Pushgateway once the run is over, for runs too short to
+// be scraped such as cron jobs. They replace the metrics the group of the
+// job and instance labels held, so the Pushgateway always has the last
+// run's.
+type Pushgateway struct {
+	mu     sync.Mutex
+	prom   *Prometheus
+	url    string
+	client *http.Client
+	err    error
+}
+
+// NewPushgateway returns a reporter pushing to the Pushgateway at url,
+// e.g. http://pushgateway:9091, with the labels job and instance. client
+// sends the request; http.DefaultClient if nil.
+func NewPushgateway(url, job, instance string, client *http.Client) *Pushgateway {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	url = strings.TrimSuffix(url, "/") + "/metrics/job/" + pushLabel(job)
+	if instance != "" {
+		url += "/instance/" + pushLabel(instance)
+	}
+	return &Pushgateway{prom: NewPrometheus(), url: url, client: client}
+}
+
+// pushLabel encodes a label value for the path of a push URL, in base64
+// if it has a slash or is empty, as the Pushgateway expects.
+func pushLabel(v string) string {
+	if v != "" && !strings.Contains(v, "/") {
+		return v
+	}
+	if v == "" {
+		return "@base64/="
+	}
+	return "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(v))
+}
+
+// Report keeps s, for the figures the summary lacks.
+func (g *Pushgateway) Report(s fileprocessor.Snapshot) {
+	g.prom.Report(s)
+}
+
+// ReportSummary pushes the final metrics.
+func (g *Pushgateway) ReportSummary(s fileprocessor.Summary) {
+	g.prom.ReportSummary(s)
+	err := g.push()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err != nil && g.err == nil {
+		g.err = err
+	}
+}
+
+func (g *Pushgateway) push() error {
+	var body bytes.Buffer
+	g.prom.WriteTo(&body)
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, g.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", g.url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Close returns the first error pushing the metrics.
+func (g *Pushgateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
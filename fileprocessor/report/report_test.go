@@ -0,0 +1,333 @@
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fileprocessor"
+	"fileprocessor/fileprocessortest"
+	"fileprocessor/internal/sqlite"
+	"fileprocessor/report"
+)
+
+var (
+	hashed = fileprocessor.Result{Path: "dir/a.txt", Size: 3, Hash: "abc123", Algorithm: "sha256",
+		Duration: 1500 * time.Millisecond, Links: []string{"dir/b.txt"}}
+	failed = fileprocessor.Result{Path: "dir/c.txt", Err: errors.New("permission denied")}
+)
+
+func TestConsole(t *testing.T) {
+	var b bytes.Buffer
+	c := report.NewConsole(&b)
+	p := fileprocessor.New(
+		fileprocessor.WithFS(fileprocessortest.NewFS().File("a", "abc").File("sub/b", "").Build()),
+		fileprocessor.WithWorkers(2),
+		fileprocessor.WithReporter(c),
+	)
+	results := fileprocessortest.Collect(t, context.Background(), p)
+	fileprocessortest.ExpectNoErrors(t, results)
+	fileprocessortest.ExpectPaths(t, results, "a", "sub/b")
+
+	out := b.String()
+	for _, want := range []string{
+		"Processed: a | SHA256: ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad\n",
+		"Processed: sub/b | SHA256: e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n",
+		"\nProcessing complete\nFiles processed: 2\nFiles failed: 0\nFiles skipped: 0\nBytes processed: 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("console output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConsoleSummaryErrors(t *testing.T) {
+	var b bytes.Buffer
+	c := report.NewConsole(&b)
+	c.ReportFile(failed)
+	c.ReportSummary(fileprocessor.Summary{Failed: 1, Errors: []error{failed.Err}})
+	out := b.String()
+	if strings.Contains(out, "Processed: dir/c.txt") {
+		t.Errorf("failed file listed as processed:\n%s", out)
+	}
+	if !strings.Contains(out, "Files failed: 1\n") || !strings.Contains(out, "permission denied") {
+		t.Errorf("summary lacks the failure:\n%s", out)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		new  func(io.Writer) *report.Manifest
+		want string
+	}{
+		{"plain", report.NewManifest, "abc123  dir/a.txt\nabc123  dir/b.txt\n"},
+		{"tagged", report.NewTaggedManifest, "SHA256 (dir/a.txt) = abc123\nSHA256 (dir/b.txt) = abc123\n"},
+	} {
+		var b bytes.Buffer
+		m := tc.new(&b)
+		m.ReportFile(hashed)
+		m.ReportFile(failed)
+		if b.String() != tc.want {
+			t.Errorf("%s manifest = %q, want %q", tc.name, b.String(), tc.want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var b bytes.Buffer
+	c := report.NewCSV(&b)
+	c.ReportFile(hashed)
+	c.ReportFile(failed)
+	c.ReportSummary(fileprocessor.Summary{})
+	want := "path,size,mtime,hash,algorithm,duration_ms,error\r\n" +
+		"dir/a.txt,3,,abc123,sha256,1500,\r\n" +
+		"dir/b.txt,3,,abc123,sha256,1500,\r\n" +
+		"dir/c.txt,0,,,,0,permission denied\r\n"
+	if b.String() != want {
+		t.Errorf("CSV = %q, want %q", b.String(), want)
+	}
+
+	// An empty run still gets its header.
+	b.Reset()
+	report.NewCSV(&b).ReportSummary(fileprocessor.Summary{})
+	if want := "path,size,mtime,hash,algorithm,duration_ms,error\r\n"; b.String() != want {
+		t.Errorf("CSV of an empty run = %q, want %q", b.String(), want)
+	}
+}
+
+func TestSQLiteAppendsRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 10, time.FixedZone("", 3600))
+	for _, run := range []struct {
+		id      string
+		results []fileprocessor.Result
+	}{
+		{"first", []fileprocessor.Result{hashed, failed}},
+		{"second", []fileprocessor.Result{{Path: "dir/d.txt", Size: 1, Hash: "def456", ModTime: mtime}}},
+	} {
+		s, err := report.NewSQLite(path, run.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, res := range run.results {
+			s.ReportFile(res)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	db, err := sqlite.Open(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Rows("results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]any{
+		{"dir/a.txt", "abc123", int64(3), nil, int64(1500), nil, "first"},
+		{"dir/b.txt", "abc123", int64(3), nil, int64(1500), nil, "first"},
+		{"dir/c.txt", nil, int64(0), nil, int64(0), "permission denied", "first"},
+		{"dir/d.txt", "def456", int64(1), "2024-05-06T06:08:09.000000010Z", int64(0), nil, "second"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+	if len(db.Schema) != 4 {
+		t.Errorf("schema = %+v, want the table and its three indexes", db.Schema)
+	}
+}
+
+func TestSQLiteRefusesOtherDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.db")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sqlite.Write(f, sqlite.Table{Name: "accounts", SQL: "CREATE TABLE accounts (name)", Rows: [][]any{{"x"}}})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := report.NewSQLite(path, "run"); err == nil || !strings.Contains(err.Error(), `"accounts"`) {
+		t.Errorf("NewSQLite of another database = %v, want an error naming its table", err)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	var b bytes.Buffer
+	j := report.NewJSONLines(&b)
+	j.ReportFile(hashed)
+	j.ReportFile(failed)
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), b.String())
+	}
+	var objs [2]map[string]any
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &objs[i]); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+	}
+	if objs[0]["path"] != "dir/a.txt" || objs[0]["hash"] != "abc123" || objs[0]["algorithm"] != "sha256" {
+		t.Errorf("first object = %v", objs[0])
+	}
+	if _, ok := objs[0]["type"]; ok {
+		t.Errorf("first object has a type: %v", objs[0])
+	}
+	if objs[1]["path"] != "dir/c.txt" || objs[1]["error"] != "permission denied" {
+		t.Errorf("second object = %v", objs[1])
+	}
+}
+
+func TestPrometheus(t *testing.T) {
+	p := report.NewPrometheus()
+	p.Report(fileprocessor.Snapshot{Processed: 4, Failed: 1, Bytes: 4096, Queue: 7, Workers: 2})
+	metrics := scrape(t, p)
+	for _, want := range []string{
+		"# TYPE fileprocessor_files_processed_total counter\nfileprocessor_files_processed_total 4\n",
+		"fileprocessor_files_failed_total 1\n",
+		"fileprocessor_bytes_processed_total 4096\n",
+		"fileprocessor_queue_depth 7\n",
+		"fileprocessor_workers 2\n",
+		"fileprocessor_run_complete 0\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics lack %q:\n%s", want, metrics)
+		}
+	}
+
+	p.ReportSummary(fileprocessor.Summary{Processed: 5, Failed: 1, Bytes: 5120,
+		Usage: []fileprocessor.WorkerUsage{{ID: 0, Busy: 2 * time.Second, Idle: time.Second}}})
+	metrics = scrape(t, p)
+	for _, want := range []string{
+		"fileprocessor_files_processed_total 5\n",
+		"fileprocessor_queue_depth 0\n",
+		"fileprocessor_run_complete 1\n",
+		`fileprocessor_worker_busy_seconds_total{worker="0"} 2` + "\n",
+		`fileprocessor_worker_idle_seconds_total{worker="0"} 1` + "\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("final metrics lack %q:\n%s", want, metrics)
+		}
+	}
+}
+
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	return rec.Body.String()
+}
+
+func TestPushgateway(t *testing.T) {
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(b)
+	}))
+	defer srv.Close()
+
+	g := report.NewPushgateway(srv.URL+"/", "nightly", "host/1", srv.Client())
+	g.Report(fileprocessor.Snapshot{Processed: 1})
+	g.ReportSummary(fileprocessor.Summary{Processed: 2})
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	if want := "/metrics/job/nightly/instance/@base64/aG9zdC8x"; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if !strings.Contains(body, "fileprocessor_files_processed_total 2\n") || !strings.Contains(body, "fileprocessor_run_complete 1\n") {
+		t.Errorf("pushed metrics aren't final:\n%s", body)
+	}
+}
+
+func TestPushgatewayError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad metrics", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	g := report.NewPushgateway(srv.URL, "job", "", nil)
+	g.ReportSummary(fileprocessor.Summary{})
+	if err := g.Close(); err == nil || !strings.Contains(err.Error(), "bad metrics") {
+		t.Errorf("Close = %v, want the Pushgateway's error", err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	h := report.NewHealth(report.HealthConfig{})
+	if err := h.Live(); err != nil {
+		t.Errorf("Live before the first snapshot = %v", err)
+	}
+	if err := h.Ready(); err == nil {
+		t.Error("ready before the first snapshot")
+	}
+
+	h.Report(fileprocessor.Snapshot{Walked: 1, QueueSize: 10})
+	if err := h.Ready(); err != nil {
+		t.Errorf("Ready = %v", err)
+	}
+	h.Report(fileprocessor.Snapshot{Walked: 2, Paused: true, QueueSize: 10})
+	if err := h.Ready(); err == nil {
+		t.Error("ready while paused")
+	}
+	h.Report(fileprocessor.Snapshot{Walked: 3, Queue: 10, QueueSize: 10})
+	if err := h.Ready(); err == nil || !strings.Contains(err.Error(), "queue saturated") {
+		t.Errorf("Ready with a full queue = %v", err)
+	}
+	h.Report(fileprocessor.Snapshot{Walked: 20, Processed: 5, Failed: 15, QueueSize: 10})
+	if err := h.Ready(); err == nil || !strings.Contains(err.Error(), "error rate") {
+		t.Errorf("Ready with most files failing = %v", err)
+	}
+
+	h.ReportSummary(fileprocessor.Summary{})
+	if err := h.Live(); err != nil {
+		t.Errorf("Live after the summary = %v", err)
+	}
+	if err := h.Ready(); err == nil {
+		t.Error("ready after the summary")
+	}
+}
+
+func TestHealthStall(t *testing.T) {
+	h := report.NewHealth(report.HealthConfig{StallTimeout: 10 * time.Millisecond})
+	h.Report(fileprocessor.Snapshot{Walked: 1})
+	time.Sleep(20 * time.Millisecond)
+	h.Report(fileprocessor.Snapshot{Walked: 1})
+	if err := h.Live(); err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Errorf("Live with nothing walked or finished = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz status = %d, want 503", rec.Code)
+	}
+}
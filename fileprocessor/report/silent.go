@@ -0,0 +1,10 @@
+package report
+
+import "fileprocessor"
+
+// Silent discards everything. It is the Processor's default and is useful
+// to switch reporting off explicitly.
+type Silent struct{}
+
+// Report does nothing.
+func (Silent) Report(fileprocessor.Snapshot) {}
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"fileprocessor"
+	"fileprocessor/internal/sqlite"
+)
+
+// sqliteSchema is the table SQLite reporters write, with its indexes.
+var sqliteSchema = sqlite.Table{
+	Name: "results",
+	SQL:  "CREATE TABLE results (path TEXT NOT NULL, hash TEXT, size INTEGER NOT NULL, mtime TEXT, duration_ms INTEGER NOT NULL, error TEXT, run_id TEXT NOT NULL)",
+	Indexes: []sqlite.Index{
+		{Name: "results_path", SQL: "CREATE INDEX results_path ON results (path)", Columns: []int{0}},
+		{Name: "results_hash", SQL: "CREATE INDEX results_hash ON results (hash)", Columns: []int{1}},
+		{Name: "results_run", SQL: "CREATE INDEX results_run ON results (run_id)", Columns: []int{6}},
+	},
+}
+
+// sqliteTime is the layout of the mtime column: UTC and of fixed width,
+// so that text order is time order, and understood by SQLite's date and
+// time functions.
+const sqliteTime = "2006-01-02T15:04:05.000000000Z"
+
+// SQLite records the result of every file, failed ones included, as a row
+// of the "results" table of a SQLite database, with columns path, hash,
+// size, mtime, duration_ms, error and run_id and indexes on path, hash and
+// run_id. Rows of earlier runs already in the database are kept, so runs
+// can be compared with SQL. A file's hard links get rows of their own.
+//
+// Rows are held in memory and the database is rewritten by Close, through
+// a temporary file renamed over it, so readers never see half a run.
+type SQLite struct {
+	mu    sync.Mutex
+	path  string
+	runID string
+	rows  [][]any
+}
+
+// NewSQLite returns a SQLite reporter for the database at path, which
+// need not exist, tagging this run's rows with runID. The database must
+// hold nothing but the results table and its indexes.
+func NewSQLite(path, runID string) (*SQLite, error) {
+	s := &SQLite{path: path, runID: runID}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := sqlite.Open(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, o := range db.Schema {
+		if !ownObject(o) {
+			return nil, fmt.Errorf("%s: %s %q is not one of fileprocessor's results, and would be lost", path, o.Type, o.Name)
+		}
+	}
+	if s.rows, err = db.Rows(sqliteSchema.Name); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ownObject reports whether o is part of sqliteSchema as written.
+func ownObject(o sqlite.Object) bool {
+	if o.Type == "table" {
+		return o.Name == sqliteSchema.Name && o.SQL == sqliteSchema.SQL
+	}
+	for _, ix := range sqliteSchema.Indexes {
+		if o.Type == "index" && o.Name == ix.Name && o.SQL == ix.SQL {
+			return true
+		}
+	}
+	return false
+}
+
+// Report does nothing; the database has no progress rows.
+func (s *SQLite) Report(fileprocessor.Snapshot) {}
+
+// ReportFile adds the rows for res.
+func (s *SQLite) ReportFile(res fileprocessor.Result) {
+	var hash, mtime, errText any
+	if res.Hash != "" {
+		hash = res.Hash
+	}
+	if !res.ModTime.IsZero() {
+		mtime = res.ModTime.UTC().Format(sqliteTime)
+	}
+	if res.Err != nil {
+		errText = res.Err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, path := range append([]string{res.Path}, res.Links...) {
+		s.rows = append(s.rows, []any{path, hash, res.Size, mtime, res.Duration.Milliseconds(), errText, s.runID})
+	}
+}
+
+// Close writes the database.
+func (s *SQLite) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp makes the file private; give it the mode of the database
+	// it replaces, or that of a new file.
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(s.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	t := sqliteSchema
+	t.Rows = s.rows
+	err = tmp.Chmod(mode)
+	if err == nil {
+		err = sqlite.Write(tmp, t)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
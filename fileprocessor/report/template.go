@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"strconv"
+	"sync"
+	"text/template"
+
+	"fileprocessor"
+)
+
+// templateFuncs are the functions templates can use besides text/template's
+// built-ins.
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"base": path.Base,
+	"dir":  path.Dir,
+	"ms":   func(r fileprocessor.Result) int64 { return r.Duration.Milliseconds() },
+}
+
+// Template writes a line for every file, failed ones included, by
+// executing a text/template with the file's fileprocessor.Result, so that
+// {{.Hash}}, {{.Size}}, {{.Path}} and the other fields can be laid out as
+// a legacy format demands. A newline ends each line unless the template
+// wrote one itself. A file's hard links get lines of their own, with Path set to
+// the link. Besides the built-in functions, templates have quote (a Go
+// string literal), json, base and dir (of slash-separated paths) and ms
+// (a Result's Duration in milliseconds).
+type Template struct {
+	mu  sync.Mutex
+	w   io.Writer
+	t   *template.Template
+	buf bytes.Buffer
+	err error
+}
+
+// NewTemplate returns a Template reporter writing to w. It fails if text
+// does not parse, or cannot be executed with a zero Result, such as when
+// it names a field Result lacks.
+func NewTemplate(w io.Writer, text string) (*Template, error) {
+	t, err := template.New("template").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(io.Discard, fileprocessor.Result{}); err != nil {
+		return nil, err
+	}
+	return &Template{w: w, t: t}, nil
+}
+
+// Report does nothing; template output has no progress lines.
+func (t *Template) Report(fileprocessor.Snapshot) {}
+
+// ReportFile writes the lines for res. The first error executing the
+// template or writing stops further output and is returned by Close.
+func (t *Template) ReportFile(res fileprocessor.Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range append([]string{res.Path}, res.Links...) {
+		if t.err != nil {
+			return
+		}
+		r := res
+		r.Path = p
+		t.buf.Reset()
+		if t.err = t.t.Execute(&t.buf, r); t.err != nil {
+			return
+		}
+		if !bytes.HasSuffix(t.buf.Bytes(), []byte("\n")) {
+			t.buf.WriteByte('\n')
+		}
+		_, t.err = t.w.Write(t.buf.Bytes())
+	}
+}
+
+// Close returns the first error writing output, if any. It does not close
+// the underlying writer.
+func (t *Template) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
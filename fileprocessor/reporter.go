@@ -0,0 +1,94 @@
+package fileprocessor
+
+import "time"
+
+// Snapshot is a point-in-time view of a running Processor.
+type Snapshot struct {
+	Processed  int64
+	Failed     int64
+	Queue      int
+	Workers    int
+	Goroutines int
+	// Bytes is the size of the files processed so far and Elapsed the
+	// time since Run started.
+	Bytes   int64
+	Elapsed time.Duration
+	// TotalFiles and TotalBytes are what the WithPrescan pass found, and
+	// zero without it.
+	TotalFiles int64
+	TotalBytes int64
+	// Active lists the busy workers by ID, and Paused tells whether the
+	// run is paused; see Processor.Pause.
+	Active []WorkerState
+	Paused bool
+	// Usage accounts for the time of every worker started so far, by ID,
+	// including those that have exited.
+	Usage []WorkerUsage
+	// Throughput is the rate bytes are processed at, now and on average
+	// over the last minute and the last five.
+	Throughput Throughput
+	// Latency sums up how long the files so far took.
+	Latency Latency
+	// Walked is the number of files the walk has come across so far,
+	// whether queued, skipped or filtered out, and WalkDone tells whether
+	// it is over.
+	Walked   int64
+	WalkDone bool
+	// QueueSize is the capacity of the queue; with WithSpillQueue, Queue
+	// may exceed it.
+	QueueSize int
+}
+
+// Utilization returns the share of the time the workers of s and since
+// spent busy between the two, from 0 to 1, or, if since is the zero
+// Snapshot, since they started. ok is false if no worker time passed.
+func (s Snapshot) Utilization(since Snapshot) (utilization float64, ok bool) {
+	return busyShare(s.Usage, since.Usage)
+}
+
+// Progress estimates how far the run is, as a fraction between 0 and 1,
+// and how long the rest will take at the average rate so far. Bytes are
+// the measure when the pre-scan found any, files otherwise. ok is false
+// without a pre-scan total or before anything has been processed.
+func (s Snapshot) Progress() (fraction float64, remaining time.Duration, ok bool) {
+	switch {
+	case s.TotalBytes > 0:
+		fraction = float64(s.Bytes) / float64(s.TotalBytes)
+	case s.TotalFiles > 0:
+		fraction = float64(s.Processed+s.Failed) / float64(s.TotalFiles)
+	default:
+		return 0, 0, false
+	}
+	if fraction <= 0 {
+		return 0, 0, false
+	}
+	fraction = min(fraction, 1)
+	remaining = time.Duration(float64(s.Elapsed) * (1 - fraction) / fraction)
+	return fraction, remaining, true
+}
+
+// Reporter receives a Snapshot every second, or as WithMetricsInterval
+// says, while a Processor runs.
+//
+// A Reporter may also implement FileReporter and SummaryReporter to be told
+// about every file and about the end of the run. Ready-made console, JSON
+// and silent reporters live in the report package.
+type Reporter interface {
+	Report(s Snapshot)
+}
+
+// FileReporter is implemented by Reporters that want the Result of every
+// file. ReportFile is called concurrently from all workers.
+type FileReporter interface {
+	ReportFile(res Result)
+}
+
+// SummaryReporter is implemented by Reporters that want the final Summary
+// once Run completes.
+type SummaryReporter interface {
+	ReportSummary(s Summary)
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Report(Snapshot) {}
@@ -0,0 +1,75 @@
+package fileprocessor
+
+import (
+	"context"
+	"iter"
+)
+
+// Results returns a channel that receives the Result of every file,
+// successful or not, and is closed when Run returns. It must be called
+// before Run, and the channel must be drained: workers block until their
+// result is received. Processors that never call Results don't deliver
+// results anywhere.
+func (p *Processor) Results() <-chan Result {
+	p.resultsMu.Lock()
+	defer p.resultsMu.Unlock()
+	if p.results == nil {
+		p.results = make(chan Result, p.queueSize)
+		if p.started {
+			close(p.results)
+		}
+	}
+	return p.results
+}
+
+// All runs the Processor and yields every file's Result together with its
+// error. If the walk itself fails, a final zero Result is yielded with that
+// error. Stopping the iteration early cancels the run.
+func (p *Processor) All(ctx context.Context) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := p.Results()
+		runErr := make(chan error, 1)
+		go func() {
+			_, err := p.Run(ctx)
+			runErr <- err
+		}()
+
+		for res := range results {
+			if !yield(res, res.Err) {
+				cancel()
+				for range results {
+				}
+				<-runErr
+				return
+			}
+		}
+		if err := <-runErr; err != nil {
+			yield(Result{}, err)
+		}
+	}
+}
+
+func (p *Processor) emit(ctx context.Context, res Result) {
+	p.resultsMu.Lock()
+	results := p.results
+	p.resultsMu.Unlock()
+	if results == nil {
+		return
+	}
+
+	select {
+	case results <- res:
+	case <-ctx.Done():
+	}
+}
+
+func (p *Processor) closeResults() {
+	p.resultsMu.Lock()
+	defer p.resultsMu.Unlock()
+	if p.results != nil {
+		close(p.results)
+	}
+}
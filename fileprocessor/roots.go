@@ -0,0 +1,42 @@
+package fileprocessor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// roots returns the directories the default Walker visits.
+func (p *Processor) roots() []string {
+	if len(p.dirs) > 0 {
+		return p.dirs
+	}
+	return []string{p.dir}
+}
+
+// rootOf returns the index of the root containing path and path relative
+// to it. ok is false for paths outside every root.
+func (p *Processor) rootOf(path string) (i int, rel string, ok bool) {
+	path = normPath(path)
+	for i, root := range p.roots() {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return i, rel, true
+		}
+	}
+	return 0, "", false
+}
+
+// relPath names path in per-tree aggregates such as the fingerprint and
+// drift report: relative to the walked directory for a single root, and
+// the cleaned path itself with several, where names relative to different
+// roots could collide.
+func (p *Processor) relPath(path string) string {
+	path = normPath(path)
+	if len(p.roots()) > 1 {
+		return filepath.Clean(path)
+	}
+	if rel, err := filepath.Rel(p.dir, path); err == nil {
+		return rel
+	}
+	return filepath.Clean(path)
+}
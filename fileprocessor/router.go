@@ -0,0 +1,50 @@
+package fileprocessor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// Route sends files whose base name matches Pattern (filepath.Match
+// syntax, case-sensitive, e.g. "*.iso") to Handler.
+type Route struct {
+	Pattern string
+	Handler FileHandler
+}
+
+// Router dispatches every file to the first Route that matches it, and to
+// Default otherwise. It lets one run hash large media with a fast checksum
+// while critical artifacts get a cryptographic digest.
+type Router struct {
+	Routes  []Route
+	Default FileHandler
+}
+
+// NewRouter returns a Router after checking every pattern, so a typo is
+// reported up front instead of once per file.
+func NewRouter(def FileHandler, routes ...Route) (Router, error) {
+	for _, r := range routes {
+		if _, err := filepath.Match(r.Pattern, ""); err != nil {
+			return Router{}, fmt.Errorf("route %q: %w", r.Pattern, err)
+		}
+		if r.Handler == nil {
+			return Router{}, fmt.Errorf("route %q: nil handler", r.Pattern)
+		}
+	}
+	return Router{Routes: routes, Default: def}, nil
+}
+
+// Handle runs the handler the file at path is routed to.
+func (r Router) Handle(ctx context.Context, path string) (Result, error) {
+	name := filepath.Base(path)
+	for _, route := range r.Routes {
+		if ok, _ := filepath.Match(route.Pattern, name); ok {
+			return route.Handler.Handle(ctx, path)
+		}
+	}
+	if r.Default == nil {
+		return Result{}, fmt.Errorf("%s: no route matches", path)
+	}
+	return r.Default.Handle(ctx, path)
+}
@@ -0,0 +1,81 @@
+package fileprocessor
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/fs"
+	"slices"
+)
+
+// sampleDigest hashes the file's size followed by n bytes from its start,
+// middle and end, the imohash scheme. Files shorter than 4*n are hashed in
+// full after the size, so small files never collide by accident.
+func sampleDigest(ctx context.Context, file fs.File, n int64, newHash func() hash.Hash) (string, int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	size := info.Size()
+
+	h := newHash()
+	var buf [binary.MaxVarintLen64]byte
+	h.Write(buf[:binary.PutUvarint(buf[:], uint64(size))])
+
+	r := ctxReader{ctx, file}
+	if size < 4*n {
+		if _, err := io.Copy(h, r); err != nil {
+			return "", 0, err
+		}
+		return hex.EncodeToString(h.Sum(nil)), size, nil
+	}
+
+	var pos int64
+	for _, off := range []int64{0, size / 2, size - n} {
+		if err := skip(file, r, off-pos); err != nil {
+			return "", 0, err
+		}
+		if _, err := io.CopyN(h, r, n); err != nil {
+			return "", 0, err
+		}
+		pos = off + n
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// skip advances r by n bytes, seeking when the file allows it.
+func skip(file fs.File, r io.Reader, n int64) error {
+	if n == 0 {
+		return nil
+	}
+	if s, ok := file.(io.Seeker); ok {
+		_, err := s.Seek(n, io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
+
+// SampleCandidates returns, sorted, the paths of successful results whose
+// Algorithm and Hash are shared with at least one other result. Run over the output of a
+// HashHandler with Sample set, it lists the only files a full hashing
+// pass needs to look at to find every duplicate.
+func SampleCandidates(results []Result) []string {
+	groups := make(map[string][]string)
+	for _, res := range results {
+		if res.Err == nil && res.Hash != "" {
+			key := res.Algorithm + ":" + res.Hash
+			groups[key] = append(groups[key], res.Path)
+		}
+	}
+	var paths []string
+	for _, group := range groups {
+		if len(group) > 1 {
+			paths = append(paths, group...)
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
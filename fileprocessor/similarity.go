@@ -0,0 +1,122 @@
+package fileprocessor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fileprocessor/internal/fuzzy"
+)
+
+// FuzzyAlgorithms lists the similarity digests HashHandler.Fuzzy accepts.
+func FuzzyAlgorithms() []string {
+	return fuzzy.Names()
+}
+
+// Similarity scores two Result.Fuzzy digests of the same algorithm from 0
+// (unrelated) to 100 (identical). ssdeep digests use the ssdeep match
+// score; for TLSH a distance d scores 100-d, floored at 0.
+func Similarity(a, b string) (int, error) {
+	return fuzzy.Similarity(a, b)
+}
+
+// similarity collects fuzzy digests during a Run and groups near
+// duplicates for the Summary.
+type similarity struct {
+	threshold int
+
+	mu      sync.Mutex
+	paths   []string
+	digests []string
+}
+
+func (s *similarity) add(path, digest string) {
+	if s.threshold <= 0 || digest == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paths = append(s.paths, path)
+	s.digests = append(s.digests, digest)
+}
+
+// clusters returns every group of two or more files linked by pairs that
+// score at least threshold, each sorted by path, largest groups first.
+func (s *similarity) clusters() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.threshold <= 0 {
+		return nil
+	}
+
+	parent := make([]int, len(s.paths))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for _, group := range s.candidates() {
+		for x := 0; x < len(group); x++ {
+			for y := x + 1; y < len(group); y++ {
+				i, j := group[x], group[y]
+				if find(i) == find(j) {
+					continue
+				}
+				if score, err := fuzzy.Similarity(s.digests[i], s.digests[j]); err == nil && score >= s.threshold {
+					parent[find(i)] = find(j)
+				}
+			}
+		}
+	}
+
+	byRoot := make(map[int][]string)
+	for i, path := range s.paths {
+		r := find(i)
+		byRoot[r] = append(byRoot[r], path)
+	}
+	var out [][]string
+	for _, paths := range byRoot {
+		if len(paths) > 1 {
+			sort.Strings(paths)
+			out = append(out, paths)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i]) != len(out[j]) {
+			return len(out[i]) > len(out[j])
+		}
+		return out[i][0] < out[j][0]
+	})
+	return out
+}
+
+// candidates splits the digests into groups that can possibly match, so
+// the pairwise comparison stays local. ssdeep digests only match when
+// their block sizes are equal or a factor of two apart; each group holds
+// one block size and the next larger one.
+func (s *similarity) candidates() [][]int {
+	byBlock := make(map[uint64][]int)
+	var other []int
+	for i, d := range s.digests {
+		prefix, _, ok := strings.Cut(d, ":")
+		bs, err := strconv.ParseUint(prefix, 10, 64)
+		if !ok || err != nil {
+			other = append(other, i)
+			continue
+		}
+		byBlock[bs] = append(byBlock[bs], i)
+	}
+
+	groups := [][]int{other}
+	for bs, idx := range byBlock {
+		groups = append(groups, append(append([]int(nil), idx...), byBlock[bs*2]...))
+	}
+	return groups
+}
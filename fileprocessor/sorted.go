@@ -0,0 +1,123 @@
+package fileprocessor
+
+import (
+	"context"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// job is one file queued for the workers. seq numbers jobs in the order
+// they were submitted, which WithSorted restores on output. queued is when
+// it was submitted, kept only for a Tracer.
+type job struct {
+	path   string
+	seq    int
+	queued time.Time
+}
+
+// sortedWalker visits everything w finds in lexicographic path order,
+// starting after the path after when it is set. It has to see the whole
+// walk before it can visit the first file.
+type sortedWalker struct {
+	w     Walker
+	after string
+}
+
+func (s sortedWalker) Walk(ctx context.Context, visit func(path string, info fs.FileInfo) error) error {
+	type entry struct {
+		path string
+		info fs.FileInfo
+	}
+	var mu sync.Mutex
+	var found []entry
+	err := s.w.Walk(ctx, func(path string, info fs.FileInfo) error {
+		mu.Lock()
+		found = append(found, entry{path, info})
+		mu.Unlock()
+		return nil
+	})
+	slices.SortStableFunc(found, func(a, b entry) int { return strings.Compare(a.path, b.path) })
+	for _, e := range found {
+		if s.after != "" && e.path <= s.after {
+			continue
+		}
+		if cerr := ctx.Err(); cerr != nil {
+			return cerr
+		}
+		if verr := visit(e.path, e.info); verr != nil {
+			return verr
+		}
+	}
+	return err
+}
+
+// sequencer hands results on in job order although workers finish them
+// in any order, holding back each result until all earlier ones are out.
+type sequencer struct {
+	mu      sync.Mutex
+	last    int             // seq of the most recent job submitted
+	next    int             // seq of the next result to hand on
+	pending map[int]*Result // nil for skipped jobs
+}
+
+// assign returns the number of the next job.
+func (s *sequencer) assign() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last++
+	return s.last
+}
+
+// put records the result of job seq and passes every result that is now
+// in order to out.
+func (s *sequencer) put(seq int, res Result, out func(Result)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[int]*Result)
+	}
+	s.pending[seq] = &res
+	s.release(out)
+}
+
+// skip records that job seq produced no result.
+func (s *sequencer) skip(seq int, out func(Result)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[int]*Result)
+	}
+	s.pending[seq] = nil
+	s.release(out)
+}
+
+// flush passes on whatever is still held back, in order, skipping jobs
+// that never produced a result because the run was cancelled.
+func (s *sequencer) flush(out func(Result)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) > 0 {
+		if _, ok := s.pending[s.next+1]; !ok {
+			s.next++
+			continue
+		}
+		s.release(out)
+	}
+}
+
+func (s *sequencer) release(out func(Result)) {
+	for {
+		res, ok := s.pending[s.next+1]
+		if !ok {
+			return
+		}
+		delete(s.pending, s.next+1)
+		s.next++
+		if res != nil {
+			out(*res)
+		}
+	}
+}
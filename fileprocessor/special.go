@@ -0,0 +1,32 @@
+package fileprocessor
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// ErrSpecialFile is returned by Open for devices, named pipes, sockets and
+// other files that are not regular files or directories. Reading them
+// could block forever or never end.
+var ErrSpecialFile = errors.New("not a regular file")
+
+// SpecialFiles selects what a Processor does with the special files the
+// walk finds. The zero value is SpecialReport.
+type SpecialFiles string
+
+const (
+	// SpecialReport hands special files to the handler like any other
+	// file. Open refuses them without blocking, so handlers that read
+	// them fail with ErrSpecialFile.
+	SpecialReport SpecialFiles = "report"
+	// SpecialSkip counts special files as skipped and doesn't queue them.
+	SpecialSkip SpecialFiles = "skip"
+	// SpecialFail stops the run at the first special file.
+	SpecialFail SpecialFiles = "fail"
+)
+
+// isSpecial reports whether mode is neither a regular file, a directory
+// nor a symbolic link.
+func isSpecial(mode fs.FileMode) bool {
+	return mode&(fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe|fs.ModeSocket|fs.ModeIrregular) != 0
+}
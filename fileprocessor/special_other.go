@@ -0,0 +1,26 @@
+//go:build !unix
+
+package fileprocessor
+
+import (
+	"io/fs"
+	"os"
+)
+
+// openFile opens a file on disk for reading, refusing devices.
+func openFile(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if isSpecial(info.Mode()) {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: path, Err: ErrSpecialFile}
+	}
+	return f, nil
+}
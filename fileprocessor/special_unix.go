@@ -0,0 +1,29 @@
+//go:build unix
+
+package fileprocessor
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// openFile opens a file on disk for reading. O_NONBLOCK keeps the open
+// from waiting for the writer of a named pipe; it has no effect on
+// regular files.
+func openFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if isSpecial(info.Mode()) {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: path, Err: ErrSpecialFile}
+	}
+	return f, nil
+}
@@ -0,0 +1,216 @@
+package fileprocessor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// spillMemory is how many jobs the spill queue holds in memory before
+	// it starts writing them to disk.
+	spillMemory = 4096
+	// spillChunk is the size of the spill file's write and read buffers.
+	spillChunk = 64 << 10
+)
+
+// spillQueue is an unbounded FIFO of jobs between the walker and the
+// pool, so the walk never waits for the workers. The first spillMemory
+// jobs are kept in memory; while the workers are behind, later ones are
+// appended to a temporary file and read back in order. The file is
+// emptied whenever the backlog is drained.
+type spillQueue struct {
+	mu     sync.Mutex
+	cond   sync.Cond
+	mem    []job
+	file   *os.File
+	onDisk int    // jobs in the file and the two buffers
+	wbuf   []byte // encoded jobs not yet written
+	wOff   int64
+	rbuf   []byte // bytes read back but not yet decoded
+	rOff   int64
+	err    error
+	closed bool
+}
+
+func newSpillQueue(dir string) (*spillQueue, error) {
+	f, err := os.CreateTemp(dir, "fileprocessor-queue-*")
+	if err != nil {
+		return nil, fmt.Errorf("spill queue: %w", err)
+	}
+	q := &spillQueue{file: f}
+	q.cond.L = &q.mu
+	return q, nil
+}
+
+// push appends j. It fails only when the spill file can't be written.
+func (q *spillQueue) push(j job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return q.err
+	}
+	if q.onDisk == 0 && len(q.mem) < spillMemory {
+		q.mem = append(q.mem, j)
+	} else {
+		var queued int64
+		if !j.queued.IsZero() {
+			queued = j.queued.UnixNano()
+		}
+		q.wbuf = binary.AppendUvarint(q.wbuf, uint64(j.seq))
+		q.wbuf = binary.AppendVarint(q.wbuf, queued)
+		q.wbuf = binary.AppendUvarint(q.wbuf, uint64(len(j.path)))
+		q.wbuf = append(q.wbuf, j.path...)
+		q.onDisk++
+		if len(q.wbuf) >= spillChunk {
+			q.flush()
+		}
+	}
+	q.cond.Signal()
+	return q.err
+}
+
+// flush writes wbuf to the end of the file.
+func (q *spillQueue) flush() {
+	n, err := q.file.WriteAt(q.wbuf, q.wOff)
+	q.wOff += int64(n)
+	q.wbuf = q.wbuf[:0]
+	if err != nil && q.err == nil {
+		q.err = fmt.Errorf("spill queue: %w", err)
+	}
+}
+
+// pop waits for the oldest job. ok is false once the queue is closed and
+// empty, or broken.
+func (q *spillQueue) pop() (j job, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.mem) == 0 && q.onDisk == 0 && !q.closed && q.err == nil {
+		q.cond.Wait()
+	}
+	if q.err != nil {
+		return job{}, false
+	}
+	if len(q.mem) > 0 {
+		j = q.mem[0]
+		q.mem[0] = job{}
+		q.mem = q.mem[1:]
+		return j, true
+	}
+	if q.onDisk == 0 {
+		return job{}, false
+	}
+	j, ok = q.decode()
+	if !ok {
+		return job{}, false
+	}
+	q.onDisk--
+	if q.onDisk == 0 {
+		// Everything spilled has been read back: start the file over.
+		q.wOff, q.rOff, q.rbuf = 0, 0, q.rbuf[:0]
+		if err := q.file.Truncate(0); err != nil && q.err == nil {
+			q.err = fmt.Errorf("spill queue: %w", err)
+		}
+	}
+	return j, true
+}
+
+// decode reads the next spilled job, refilling rbuf from the file or,
+// once the file is exhausted, from wbuf.
+func (q *spillQueue) decode() (job, bool) {
+	for {
+		if j, n := decodeJob(q.rbuf); n > 0 {
+			q.rbuf = q.rbuf[n:]
+			return j, true
+		}
+		if q.rOff < q.wOff {
+			chunk := make([]byte, min(int64(spillChunk), q.wOff-q.rOff))
+			n, err := q.file.ReadAt(chunk, q.rOff)
+			q.rOff += int64(n)
+			q.rbuf = append(q.rbuf, chunk[:n]...)
+			if err != nil && n == 0 {
+				q.err = fmt.Errorf("spill queue: %w", err)
+				return job{}, false
+			}
+			continue
+		}
+		if len(q.wbuf) == 0 {
+			q.err = errors.New("spill queue: truncated record")
+			return job{}, false
+		}
+		q.rbuf = append(q.rbuf, q.wbuf...)
+		q.wbuf = q.wbuf[:0]
+	}
+}
+
+// decodeJob decodes the job at the start of b, as push encoded it: its
+// seq, the Unix nanoseconds it was queued at or zero, and its path. n is
+// the length of the record, or zero if b holds only part of it.
+func decodeJob(b []byte) (j job, n int) {
+	seq, n1 := binary.Uvarint(b)
+	if n1 <= 0 {
+		return job{}, 0
+	}
+	queued, n2 := binary.Varint(b[n1:])
+	if n2 <= 0 {
+		return job{}, 0
+	}
+	size, n3 := binary.Uvarint(b[n1+n2:])
+	start := n1 + n2 + n3
+	if n3 <= 0 || uint64(len(b)-start) < size {
+		return job{}, 0
+	}
+	j = job{seq: int(seq), path: string(b[start : start+int(size)])}
+	if queued != 0 {
+		j.queued = time.Unix(0, queued)
+	}
+	return j, start + int(size)
+}
+
+// len returns the number of jobs waiting.
+func (q *spillQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.mem) + q.onDisk
+}
+
+// close marks the end of input; pop drains what is left.
+func (q *spillQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// remove deletes the spill file.
+func (q *spillQueue) remove() {
+	q.file.Close()
+	os.Remove(q.file.Name())
+}
+
+// feed moves jobs from q to submit until q is drained or submit fails,
+// and returns the first error of either.
+func (q *spillQueue) feed(ctx context.Context, submit func(context.Context, job) error) error {
+	for {
+		j, ok := q.pop()
+		if !ok {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return q.err
+		}
+		if err := submit(ctx, j); err != nil {
+			// Unblock a walker still pushing and drop the backlog.
+			q.mu.Lock()
+			if q.err == nil {
+				q.err = err
+			}
+			q.mu.Unlock()
+			q.cond.Broadcast()
+			return err
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package fileprocessor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// defaultTopFiles is how many of the slowest and largest files the
+// Summary lists unless WithTopFiles says otherwise.
+const defaultTopFiles = 20
+
+// sizeBounds are the lower bounds of the size buckets of
+// Summary.Sizes: under 1 KiB, then a factor of 16 each up to 1 GiB and
+// over.
+var sizeBounds = [...]int64{0, 1 << 10, 1 << 14, 1 << 18, 1 << 22, 1 << 26, 1 << 30}
+
+// SizeBucket counts the processed files whose size is at least Min and,
+// unless Max is zero for the last bucket, less than Max.
+type SizeBucket struct {
+	Min, Max int64
+	Files    int64
+	Bytes    int64
+}
+
+// sizeHistogram counts processed files by size for Summary.Sizes.
+type sizeHistogram struct {
+	files, bytes [len(sizeBounds)]atomic.Int64
+}
+
+func (h *sizeHistogram) add(size int64) {
+	i := len(sizeBounds) - 1
+	for i > 0 && size < sizeBounds[i] {
+		i--
+	}
+	h.files[i].Add(1)
+	h.bytes[i].Add(size)
+}
+
+func (h *sizeHistogram) buckets() []SizeBucket {
+	out := make([]SizeBucket, len(sizeBounds))
+	for i, min := range sizeBounds {
+		out[i] = SizeBucket{Min: min, Files: h.files[i].Load(), Bytes: h.bytes[i].Load()}
+		if i+1 < len(sizeBounds) {
+			out[i].Max = sizeBounds[i+1]
+		}
+	}
+	return out
+}
+
+// ErrorClass sorts a file's error into a broad class, for the failure
+// breakdown of Summary.FailureClasses: "canceled", "timeout",
+// "not_found", "permission", "special_file", "network", "io" for other
+// operating system errors, or "other".
+func ErrorClass(err error) string {
+	var timeout interface{ Timeout() bool }
+	var pathErr *fs.PathError
+	var errno syscall.Errno
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &timeout) && timeout.Timeout():
+		return "timeout"
+	case errors.Is(err, fs.ErrNotExist):
+		return "not_found"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission"
+	case errors.Is(err, ErrSpecialFile):
+		return "special_file"
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.As(err, &pathErr), errors.As(err, &errno):
+		return "io"
+	}
+	return "other"
+}
+
+// failureClasses counts errs by ErrorClass.
+func failureClasses(errs []error) map[string]int64 {
+	if len(errs) == 0 {
+		return nil
+	}
+	classes := make(map[string]int64)
+	for _, err := range errs {
+		classes[ErrorClass(err)]++
+	}
+	return classes
+}
+
+// FileStat is an entry of Summary.Slowest or Summary.Largest.
+type FileStat struct {
+	Path     string
+	Size     int64
+	Duration time.Duration
+	// Failed is set for files whose handler returned an error. They only
+	// appear in Summary.Slowest.
+	Failed bool
+}
+
+// topFiles keeps the n slowest and the n largest files of a run, each
+// list sorted in descending order with ties broken by path.
+type topFiles struct {
+	n                int
+	mu               sync.Mutex
+	slowest, largest []FileStat
+}
+
+func (t *topFiles) add(s FileStat) {
+	if t.n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.slowest = insertTop(t.slowest, s, t.n, func(s FileStat) int64 { return int64(s.Duration) })
+	if !s.Failed {
+		t.largest = insertTop(t.largest, s, t.n, func(s FileStat) int64 { return s.Size })
+	}
+}
+
+// insertTop inserts s into list, sorted by descending key, if it is among
+// the n greatest.
+func insertTop(list []FileStat, s FileStat, n int, key func(FileStat) int64) []FileStat {
+	order := func(a, b FileStat) int {
+		if ka, kb := key(a), key(b); ka != kb {
+			if ka > kb {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Path, b.Path)
+	}
+	i, _ := slices.BinarySearchFunc(list, s, order)
+	if i >= n {
+		return list
+	}
+	list = slices.Insert(list, i, s)
+	return list[:min(len(list), n)]
+}
+
+func (t *topFiles) lists() (slowest, largest []FileStat) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.slowest), slices.Clone(t.largest)
+}
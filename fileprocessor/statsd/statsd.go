@@ -0,0 +1,187 @@
+// Package statsd sends the metrics of a Processor to a StatsD server or
+// the Datadog agent, as counters and timings:
+//
+//	m, err := statsd.New(statsd.Config{Addr: "localhost:8125", Tags: []string{"env:prod"}})
+//	if err != nil {
+//		return err
+//	}
+//	defer m.Close()
+//	p := fileprocessor.New(fileprocessor.WithMetrics(m))
+//
+// Every counter becomes a StatsD counter named after it with the prefix,
+// such as fileprocessor.files_processed. Observations of durations, whose
+// names end in _seconds, become timings in milliseconds without the
+// suffix, such as fileprocessor.file_duration; other observations become
+// histograms. Tags are sent in the DogStatsD form, which plain StatsD
+// servers that don't know it may reject, so leave them out for those.
+//
+// Metrics are packed into datagrams and sent every flushInterval, or when
+// a datagram is full, so the workers never wait for the network.
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fileprocessor"
+)
+
+const (
+	// flushInterval is how long a metric waits for its datagram to fill.
+	flushInterval = time.Second
+	// udpPacket is the largest datagram sent over UDP, small enough not to
+	// be fragmented on a 1500 byte MTU; Unix sockets take larger ones.
+	udpPacket  = 1432
+	unixPacket = 8192
+)
+
+// Config configures a Client.
+type Config struct {
+	// Addr is the server's host:port, usually localhost:8125, or
+	// unix:///path for the Datadog agent's Unix domain socket.
+	Addr string
+	// Prefix is put before every metric name; "fileprocessor." if empty.
+	Prefix string
+	// Tags are DogStatsD tags, such as "env:prod", sent with every metric.
+	Tags []string
+}
+
+var _ fileprocessor.Metrics = (*Client)(nil)
+
+// Client is a fileprocessor.Metrics sending everything it records to a
+// StatsD server. It also keeps the values in memory for Snapshot.
+type Client struct {
+	mem    *fileprocessor.MemoryMetrics
+	conn   net.Conn
+	prefix string
+	suffix string // the tags, or empty
+	size   int    // of a datagram
+
+	mu      sync.Mutex
+	buf     []byte
+	err     error // the first failed send
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+	closing sync.Once
+}
+
+// New returns a Client for cfg and starts its flush goroutine. The caller
+// must Close it to send the last metrics.
+func New(cfg Config) (*Client, error) {
+	network, addr, size := "udp", cfg.Addr, udpPacket
+	if path, ok := strings.CutPrefix(cfg.Addr, "unix://"); ok {
+		network, addr, size = "unixgram", path, unixPacket
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	c := &Client{
+		mem:    fileprocessor.NewMemoryMetrics(),
+		conn:   conn,
+		prefix: cfg.Prefix,
+		size:   size,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if c.prefix == "" {
+		c.prefix = "fileprocessor."
+	}
+	if len(cfg.Tags) > 0 {
+		c.suffix = "|#" + strings.Join(cfg.Tags, ",")
+	}
+	go c.loop()
+	return c, nil
+}
+
+// Inc adds delta to the named counter.
+func (c *Client) Inc(name string, delta int64) {
+	c.mem.Inc(name, delta)
+	c.send(name, strconv.FormatInt(delta, 10), "c")
+}
+
+// Observe records one sample of the named distribution, as a timing in
+// milliseconds if the name ends in _seconds.
+func (c *Client) Observe(name string, value float64) {
+	c.mem.Observe(name, value)
+	if base, ok := strings.CutSuffix(name, "_seconds"); ok {
+		c.send(base, strconv.FormatFloat(value*1000, 'f', -1, 64), "ms")
+		return
+	}
+	c.send(name, strconv.FormatFloat(value, 'f', -1, 64), "h")
+}
+
+// Snapshot returns the values recorded so far.
+func (c *Client) Snapshot() fileprocessor.MetricsSnapshot {
+	return c.mem.Snapshot()
+}
+
+// send adds one metric to the datagram being filled, sending the datagram
+// first if the metric doesn't fit.
+func (c *Client) send(name, value, kind string) {
+	line := c.prefix + name + ":" + value + "|" + kind + c.suffix
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	if len(c.buf) > 0 && len(c.buf)+1+len(line) > c.size {
+		c.flush()
+	}
+	if len(c.buf) > 0 {
+		c.buf = append(c.buf, '\n')
+	}
+	c.buf = append(c.buf, line...)
+}
+
+// flush sends the datagram being filled. c.mu must be held.
+func (c *Client) flush() {
+	if len(c.buf) == 0 {
+		return
+	}
+	if _, err := c.conn.Write(c.buf); err != nil && c.err == nil {
+		c.err = fmt.Errorf("statsd: %w", err)
+	}
+	c.buf = c.buf[:0]
+}
+
+// loop sends what has been recorded every flushInterval until Close.
+func (c *Client) loop() {
+	defer close(c.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			c.flush()
+			c.mu.Unlock()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close sends the metrics still waiting and closes the connection. Later
+// metrics are only kept in memory. It returns the first send that failed.
+func (c *Client) Close() error {
+	c.closing.Do(func() {
+		close(c.stop)
+		<-c.done
+		c.mu.Lock()
+		c.flush()
+		c.closed = true
+		if err := c.conn.Close(); err != nil && c.err == nil {
+			c.err = fmt.Errorf("statsd: %w", err)
+		}
+		c.mu.Unlock()
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
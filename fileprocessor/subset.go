@@ -0,0 +1,87 @@
+package fileprocessor
+
+import (
+	"io/fs"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// subset picks the random sample of files set by WithSampleRate and
+// WithSampleCount. A rate keeps each file with that probability as the
+// walk finds it; a count keeps a reservoir of that many files, every file
+// found equally likely to be among them, which is only complete once the
+// walk has ended.
+type subset struct {
+	rate  float64
+	count int
+	seed  uint64
+
+	mu        sync.Mutex
+	rng       *rand.Rand
+	offered   int
+	reservoir []sampled
+}
+
+// sampled is a file in the reservoir; n orders it by when it was found.
+type sampled struct {
+	n    int
+	path string
+	info fs.FileInfo
+}
+
+// start readies s for a walk.
+func (s *subset) start() {
+	seed := s.seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	s.rng = rand.New(rand.NewPCG(seed, seed))
+	s.offered, s.reservoir = 0, nil
+}
+
+// rated reports whether a file survives the sample rate.
+func (s *subset) rated() bool {
+	if s.rate <= 0 || s.rate >= 1 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64() < s.rate
+}
+
+// offer puts a file found by the walk to the reservoir, in which it
+// replaces a random earlier file once the reservoir is full (Algorithm R).
+func (s *subset) offer(path string, info fs.FileInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := sampled{n: s.offered, path: path, info: info}
+	s.offered++
+	if len(s.reservoir) < s.count {
+		s.reservoir = append(s.reservoir, f)
+	} else if i := s.rng.IntN(s.offered); i < s.count {
+		s.reservoir[i] = f
+	}
+}
+
+// picked returns the reservoir in walk order and how many files offered
+// to it were left out.
+func (s *subset) picked() (files []sampled, dropped int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files = slices.Clone(s.reservoir)
+	slices.SortFunc(files, func(a, b sampled) int { return a.n - b.n })
+	return files, s.offered - len(files)
+}
+
+// scale estimates the totals of a pre-scan that counted files and bytes
+// before sampling.
+func (s *subset) scale(files, bytes int64) (int64, int64) {
+	if s.rate > 0 && s.rate < 1 {
+		files, bytes = int64(float64(files)*s.rate), int64(float64(bytes)*s.rate)
+	}
+	if s.count > 0 && files > int64(s.count) {
+		files, bytes = int64(s.count), int64(float64(bytes)*float64(s.count)/float64(files))
+	}
+	return files, bytes
+}
@@ -0,0 +1,128 @@
+package fileprocessor
+
+import "time"
+
+// Summary is the outcome of a Run.
+type Summary struct {
+	// Processed and Failed count files the handler succeeded or failed on.
+	Processed int64
+	Failed    int64
+	// Skipped counts files that were never handed to a worker, such as
+	// entries the walker could not stat or files filtered out, and files a
+	// handler skipped with ErrSkip.
+	Skipped int64
+	// Bytes is the total size of all successfully processed files and
+	// Duration the wall time of the run.
+	Bytes    int64
+	Duration time.Duration
+	// Sizes counts the successfully processed files by size, in buckets
+	// from under 1 KiB to 1 GiB and over, each 16 times the last.
+	Sizes []SizeBucket
+	// Latency sums up how long the files took, processed or failed, with
+	// the percentiles that show a long tail of slow ones.
+	Latency Latency
+	// FailureClasses counts the failed files by the ErrorClass of their
+	// error. It is nil if none failed.
+	FailureClasses map[string]int64
+	// Slowest and Largest list the files that took longest and the
+	// largest processed files, up to the number set by WithTopFiles, in
+	// descending order.
+	Slowest []FileStat
+	Largest []FileStat
+	// DirStats rolls the results up by directory, sorted by path. It is
+	// nil unless WithDirStats is set.
+	DirStats []DirStat
+	// Hardlinks counts paths that WithHardlinks folded into the Result of
+	// another link to the same file instead of processing them again.
+	Hardlinks int64
+	// Usage accounts for the time of every worker of the run, by ID.
+	Usage []WorkerUsage
+	// Unchanged counts files whose Result WithSkipUnchanged carried over
+	// from an earlier run without processing them.
+	Unchanged int64
+	// Truncated reports that WithMaxFiles or WithMaxBytes stopped the run
+	// before every file was queued.
+	Truncated bool
+	// Errors holds the error of every failed file.
+	Errors []error
+	// Dirs holds the Merkle root of every directory when the handler
+	// produced per-file Merkle trees, sorted by path within each root.
+	Dirs []DirRoot
+	// Fingerprint is a single digest of every file name and content hash
+	// in the tree, set when WithFingerprint is enabled and no file failed.
+	Fingerprint string
+	// Clusters groups the paths of near-duplicate files when
+	// WithSimilarity is set, largest groups first.
+	Clusters [][]string
+	// Duplicates groups the files with identical content when
+	// WithDuplicates is set, the groups wasting the most bytes first.
+	Duplicates []DuplicateGroup
+	// Drift compares the run with the baseline given to WithBaseline. It
+	// is nil without one.
+	Drift *Drift
+}
+
+// FilesPerSecond returns the rate at which files were handled, failed
+// ones included, over the whole run.
+func (s Summary) FilesPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Processed+s.Failed) / s.Duration.Seconds()
+}
+
+// BytesPerSecond returns the rate at which bytes were processed over the
+// whole run.
+func (s Summary) BytesPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Duration.Seconds()
+}
+
+// WastedBytes returns the bytes taken up by duplicate copies, over every
+// group in Duplicates.
+func (s Summary) WastedBytes() int64 {
+	var n int64
+	for _, g := range s.Duplicates {
+		n += g.Wasted()
+	}
+	return n
+}
+
+// Utilization returns the share of the workers' time they spent busy,
+// from 0 to 1. ok is false without workers.
+func (s Summary) Utilization() (utilization float64, ok bool) {
+	return busyShare(s.Usage, nil)
+}
+
+func (p *Processor) summary(d time.Duration) Summary {
+	m := p.metrics.Snapshot()
+	s := Summary{
+		Processed:  m.Counters[MetricFilesProcessed],
+		Failed:     m.Counters[MetricFilesFailed],
+		Skipped:    m.Counters[MetricFilesSkipped],
+		Bytes:      m.Counters[MetricBytesProcessed],
+		Duration:   d,
+		Sizes:      p.sizes.buckets(),
+		Latency:    p.latency.latency(),
+		Hardlinks:  m.Counters[MetricHardlinks],
+		Usage:      p.usage(),
+		Unchanged:  m.Counters[MetricFilesUnchanged],
+		Truncated:  p.limits.truncated(),
+		Errors:     p.Errors(),
+		Dirs:       p.merkleRoots(),
+		DirStats:   p.allDirStats(),
+		Clusters:   p.similarity.clusters(),
+		Duplicates: p.duplicates.groups(),
+		Drift:      p.drift.report(),
+	}
+	s.FailureClasses = failureClasses(s.Errors)
+	s.Slowest, s.Largest = p.top.lists()
+	// A fingerprint that silently leaves out unreadable files, or those
+	// past a run limit, would look like a match for a tree that differs.
+	if p.fingerprint.enabled && s.Failed == 0 && !s.Truncated {
+		s.Fingerprint = p.fingerprint.sum()
+	}
+	return s
+}
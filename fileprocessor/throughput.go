@@ -0,0 +1,57 @@
+package fileprocessor
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Throughput is how fast a run processes bytes, in bytes per second.
+type Throughput struct {
+	// Current is the rate since the previous metrics tick.
+	Current float64
+	// Avg1m and Avg5m are moving averages over one and five minutes,
+	// weighted exponentially like load averages. Until the run is that
+	// old they are the average since it started.
+	Avg1m float64
+	Avg5m float64
+}
+
+// throughputMeter keeps the Throughput up to date, once per metrics tick.
+type throughputMeter struct {
+	mu      sync.Mutex
+	elapsed time.Duration
+	bytes   int64
+	t       Throughput
+}
+
+// update accounts for bytes processed after elapsed of the run.
+func (m *throughputMeter) update(elapsed time.Duration, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dt := (elapsed - m.elapsed).Seconds()
+	if dt <= 0 {
+		return
+	}
+	m.t.Current = float64(bytes-m.bytes) / dt
+	average := float64(bytes) / elapsed.Seconds()
+	m.t.Avg1m = movingAverage(m.t.Avg1m, m.t.Current, average, elapsed, dt, time.Minute)
+	m.t.Avg5m = movingAverage(m.t.Avg5m, m.t.Current, average, elapsed, dt, 5*time.Minute)
+	m.elapsed, m.bytes = elapsed, bytes
+}
+
+// movingAverage moves avg towards current by the weight dt seconds carry
+// in window, or returns the average since the start if the run is younger
+// than window.
+func movingAverage(avg, current, average float64, elapsed time.Duration, dt float64, window time.Duration) float64 {
+	if elapsed < window {
+		return average
+	}
+	return avg + (1-math.Exp(-dt/window.Seconds()))*(current-avg)
+}
+
+func (m *throughputMeter) get() Throughput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.t
+}
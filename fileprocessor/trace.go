@@ -0,0 +1,48 @@
+package fileprocessor
+
+import "time"
+
+// The kinds of Span a Run emits.
+const (
+	// SpanRun covers a whole Run and holds every other span of it.
+	SpanRun = "run"
+	// SpanWalk covers the walk, from the first directory read until the
+	// last file found is queued.
+	SpanWalk = "walk"
+	// SpanQueue covers the time a file waited in the queue for a worker.
+	SpanQueue = "queue"
+	// SpanFile covers a worker running the handler on a file.
+	SpanFile = "file"
+)
+
+// Span is a timed part of a Run, handed to a Tracer as it ends.
+type Span struct {
+	Kind  string
+	Start time.Time
+	End   time.Time
+	// Path and Worker are the file and the worker that took it, for queue
+	// and file spans.
+	Path   string
+	Worker int
+	// Size and Err are the outcome of a file span, and Err that of a run
+	// or walk span.
+	Size int64
+	Err  error
+	// Summary is set on the run span.
+	Summary *Summary
+}
+
+// Tracer receives the spans of a Run: the walk and, for every file, its
+// wait in the queue and its processing, followed last by the run span.
+// Spans of files arrive on the workers' goroutines, so Span must be safe
+// for concurrent use, and it should not block.
+type Tracer interface {
+	Span(Span)
+}
+
+// trace hands s to the Tracer, if there is one.
+func (p *Processor) trace(s Span) {
+	if p.tracer != nil {
+		p.tracer.Span(s)
+	}
+}
@@ -0,0 +1,47 @@
+package fileprocessor
+
+import "io/fs"
+
+// WithSkipUnchanged carries the Results of an earlier run over for files
+// whose size and modification time are unchanged since, instead of handing
+// them to a worker again. Records are matched by path like WithBaseline's,
+// and those without a ModTime or with an error are ignored. A carried-over
+// Result is delivered like any other, with its old Hash, so the output of
+// the run stays complete and can serve as the next run's prior; it counts
+// in Summary.Unchanged rather than Processed. The earlier run must have
+// used the same handler settings, or the carried hashes won't match the
+// new ones.
+func WithSkipUnchanged(prior []Result) Option {
+	return func(p *Processor) {
+		p.prior = prior
+	}
+}
+
+// setPrior indexes the records of WithSkipUnchanged by relative path.
+func (p *Processor) setPrior() {
+	p.unchanged = make(map[string]Result, len(p.prior))
+	for _, res := range p.prior {
+		if res.Err == nil && !res.ModTime.IsZero() {
+			p.unchanged[p.relPath(res.Path)] = res
+		}
+	}
+	p.prior = nil
+}
+
+// carryOver returns the earlier Result for the file at path if its size
+// and modification time still match.
+func (p *Processor) carryOver(path string, info fs.FileInfo) (Result, bool) {
+	if p.unchanged == nil {
+		return Result{}, false
+	}
+	rec, ok := p.unchanged[p.relPath(path)]
+	if !ok {
+		return Result{}, false
+	}
+	info = p.lookup(path, info)
+	if info == nil || info.Size() != rec.Size || !info.ModTime().Equal(rec.ModTime) {
+		return Result{}, false
+	}
+	rec.Path, rec.Duration, rec.Links = path, 0, nil
+	return rec, true
+}
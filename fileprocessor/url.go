@@ -0,0 +1,206 @@
+package fileprocessor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type urlsKey struct{}
+
+// IsURL reports whether path is an http:// or https:// URL, which Open and
+// Stat fetch when the Processor is configured with WithURLs.
+func IsURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// urls fetches URL inputs, limiting the request rate and the number of
+// downloads in flight per host.
+type urls struct {
+	client     *http.Client
+	perSecond  float64
+	concurrent int
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimit
+	types map[string]string // Content-Type by URL until its job finishes
+}
+
+func newURLs(client *http.Client) *urls {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &urls{client: client, hosts: make(map[string]*hostLimit), types: make(map[string]string)}
+}
+
+// hostLimit is the request budget of one host.
+type hostLimit struct {
+	interval time.Duration
+	sem      chan struct{} // nil when downloads are not limited
+
+	mu   sync.Mutex
+	slot time.Time
+}
+
+func (u *urls) host(name string) *hostLimit {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	l, ok := u.hosts[name]
+	if !ok {
+		l = &hostLimit{}
+		if u.perSecond > 0 {
+			l.interval = time.Duration(float64(time.Second) / u.perSecond)
+		}
+		if u.concurrent > 0 {
+			l.sem = make(chan struct{}, u.concurrent)
+		}
+		u.hosts[name] = l
+	}
+	return l
+}
+
+// acquire waits for the host's next request slot and a free download; the
+// returned func gives the download back.
+func (l *hostLimit) acquire(ctx context.Context) (release func(), err error) {
+	if l.interval > 0 {
+		l.mu.Lock()
+		now := time.Now()
+		if l.slot.Before(now) {
+			l.slot = now
+		}
+		wait := l.slot.Sub(now)
+		l.slot = l.slot.Add(l.interval)
+		l.mu.Unlock()
+		if wait > 0 && !sleep(ctx, wait) {
+			return nil, ctx.Err()
+		}
+	}
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-l.sem }) }, nil
+}
+
+// fetch sends a method request for rawURL within the host's limits. The
+// caller must call release once it is done with the response body.
+func (u *urls) fetch(ctx context.Context, method, rawURL string) (resp *http.Response, release func(), err error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	release, err = u.host(parsed.Host).acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	resp, err = u.client.Do(req)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		release()
+		err = fmt.Errorf("%s %s: %s", method, parsed.Redacted(), resp.Status)
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			err = fmt.Errorf("%w (%w)", fs.ErrNotExist, err)
+		}
+		return nil, nil, err
+	}
+	u.mu.Lock()
+	u.types[rawURL] = resp.Header.Get("Content-Type")
+	u.mu.Unlock()
+	return resp, release, nil
+}
+
+func (u *urls) open(ctx context.Context, rawURL string) (fs.File, error) {
+	resp, release, err := u.fetch(ctx, http.MethodGet, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &urlFile{info: responseInfo(resp), body: resp.Body, release: release}, nil
+}
+
+func (u *urls) stat(ctx context.Context, rawURL string) (fs.FileInfo, error) {
+	resp, release, err := u.fetch(ctx, http.MethodHead, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	release()
+	return responseInfo(resp), nil
+}
+
+// contentType returns and forgets the Content-Type last received for
+// rawURL.
+func (u *urls) contentType(rawURL string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t := u.types[rawURL]
+	delete(u.types, rawURL)
+	return t
+}
+
+// urlFile is the fs.File of a response body.
+type urlFile struct {
+	info    fs.FileInfo
+	body    io.ReadCloser
+	release func()
+}
+
+func (f *urlFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *urlFile) Read(b []byte) (int, error) { return f.body.Read(b) }
+
+func (f *urlFile) Close() error {
+	err := f.body.Close()
+	f.release()
+	return err
+}
+
+// responseInfo describes a response as a read-only regular file named
+// after the last element of the URL path. The size is -1 when the server
+// did not send a Content-Length.
+func responseInfo(resp *http.Response) fs.FileInfo {
+	name := path.Base(resp.Request.URL.Path)
+	if name == "/" || name == "." {
+		name = resp.Request.URL.Host
+	}
+	mtime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return urlInfo{name: name, size: resp.ContentLength, modTime: mtime}
+}
+
+type urlInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi urlInfo) Name() string       { return fi.name }
+func (fi urlInfo) Size() int64        { return fi.size }
+func (fi urlInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi urlInfo) ModTime() time.Time { return fi.modTime }
+func (fi urlInfo) IsDir() bool        { return false }
+func (fi urlInfo) Sys() any           { return nil }
+
+func urlsFromContext(ctx context.Context) *urls {
+	u, _ := ctx.Value(urlsKey{}).(*urls)
+	return u
+}
@@ -0,0 +1,176 @@
+package fileprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"fileprocessor/internal/walker"
+)
+
+// Walker enumerates the files a Processor handles. Walk calls visit for
+// every file, stopping early if visit returns an error, and must return
+// promptly once ctx is done. The default Walker recursively walks the
+// directory set by WithDir, on disk or in the fs.FS set by WithFS.
+type Walker interface {
+	Walk(ctx context.Context, visit func(path string, info fs.FileInfo) error) error
+}
+
+// WalkerFunc adapts an ordinary function to the Walker interface.
+type WalkerFunc func(ctx context.Context, visit func(path string, info fs.FileInfo) error) error
+
+// Walk calls f(ctx, visit).
+func (f WalkerFunc) Walk(ctx context.Context, visit func(path string, info fs.FileInfo) error) error {
+	return f(ctx, visit)
+}
+
+// ListWalker returns a Walker over the paths read from r, one per record
+// terminated by delim: '\n' for line-oriented lists, with any "\r"
+// before it dropped, or 0 for the output of find -print0 and xargs -0,
+// which can carry any file name. Empty records are skipped. Paths are
+// handed over exactly as read, so the list should contain only files
+// (find -type f).
+func ListWalker(r io.Reader, delim byte) Walker {
+	return WalkerFunc(func(ctx context.Context, visit func(string, fs.FileInfo) error) error {
+		br := bufio.NewReader(r)
+		for {
+			rec, err := br.ReadBytes(delim)
+			rec = bytes.TrimSuffix(rec, []byte{delim})
+			if delim == '\n' {
+				rec = bytes.TrimSuffix(rec, []byte{'\r'})
+			}
+			if len(rec) > 0 {
+				if cerr := ctx.Err(); cerr != nil {
+					return cerr
+				}
+				if verr := visit(string(rec), nil); verr != nil {
+					return verr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read file list: %w", err)
+			}
+		}
+	})
+}
+
+// treeWalker is the default Walker.
+type treeWalker struct {
+	dirs    []string
+	fsys    fs.FS
+	workers int
+
+	followSymlinks bool
+	skipDangling   bool
+	maxDepth       int
+	pruneDirs      []string
+	pruneMarkers   []string
+	oneFileSystem  bool
+	startAfter     string
+	gitignore      bool
+	skipHidden     bool
+	exclude        []string
+
+	onSkip func(path string, err error)
+}
+
+func (w *treeWalker) Walk(ctx context.Context, visit func(path string, info fs.FileInfo) error) error {
+	resumeAt := -1
+	if w.startAfter != "" {
+		if resumeAt = w.rootOf(w.startAfter); resumeAt < 0 {
+			return fmt.Errorf("start after %s: not below a walked directory", w.startAfter)
+		}
+	}
+	for i, dir := range w.dirs {
+		if i < resumeAt {
+			continue
+		}
+		after := ""
+		if i == resumeAt {
+			after = w.startAfter
+		}
+		if err := w.walk(ctx, dir, after, visit); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rootOf returns the index of the first root that path lies below, or -1.
+func (w *treeWalker) rootOf(p string) int {
+	for i, dir := range w.dirs {
+		if w.fsys != nil {
+			if dir == "." || p == dir || strings.HasPrefix(p, dir+"/") {
+				return i
+			}
+			continue
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *treeWalker) walk(ctx context.Context, dir, after string, visit func(path string, info fs.FileInfo) error) error {
+	err := walker.Walk(ctx, dir, walker.Options{
+		FS:      w.fsys,
+		OnSkip:  w.onSkip,
+		Workers: w.workers,
+
+		FollowSymlinks: w.followSymlinks,
+		SkipDangling:   w.skipDangling,
+		MaxDepth:       w.maxDepth,
+		Prune:          w.pruneDirs,
+		PruneMarkers:   w.pruneMarkers,
+		OneFileSystem:  w.oneFileSystem,
+		StartAfter:     after,
+		Gitignore:      w.gitignore,
+		SkipHidden:     w.skipHidden,
+		Exclude:        w.exclude,
+	}, func(e walker.Entry) error {
+		return visit(e.Path, e.Info)
+	})
+	if err != nil {
+		return fmt.Errorf("walk %s: %w", dir, err)
+	}
+	return nil
+}
+
+// resumeWalker skips what w finds up to and including the first visit of
+// after. It resumes walks whose order can't be predicted, such as a file
+// list: nothing is visited if after never comes up.
+type resumeWalker struct {
+	w     Walker
+	after string
+}
+
+func (r resumeWalker) Walk(ctx context.Context, visit func(path string, info fs.FileInfo) error) error {
+	var mu sync.Mutex
+	passed := false
+	return r.w.Walk(ctx, func(path string, info fs.FileInfo) error {
+		mu.Lock()
+		skip := !passed
+		if path == r.after {
+			passed = true
+		}
+		mu.Unlock()
+		if skip {
+			return nil
+		}
+		return visit(path, info)
+	})
+}